@@ -64,6 +64,7 @@ var (
 	chartCacheDir       = flag.String("cachedir", filepath.Join(os.TempDir(), "chart-cache"), "location for the local cache of downloaded charts")
 	resync              = flag.Duration("resync", defaultResync, "Informer's cache re-sync in Go's duration format.")
 	restTimeout         = flag.Duration("rest-timeout", defaultRESTTimeout, "Timeout value for management and target REST clients. Does not affect informer watches.")
+	sadPodLogs          = flag.Bool("sad-pod-logs", false, "Put the end of the logs of crashing containers in the status of CapacityTargets. Anyone who can read CapacityTargets can then read them, so only enable this if application logs never hold secrets or personal data.")
 )
 
 type metricsCfg struct {
@@ -342,32 +343,31 @@ func buildInitializers() map[string]initFunc {
 	return controllers
 }
 
+// dynamicClientBuilder returns a dynamic client for objects of kind gvk in an
+// application cluster. Configs from the cluster client store already have the
+// REST timeout set, unless they're meant for watches, so it's left as is.
+func (cfg *cfg) dynamicClientBuilder(gvk *schema.GroupVersionKind, config *rest.Config, cluster *shipper.Cluster) dynamic.Interface {
+	config.APIPath = dynamic.LegacyAPIPathResolverFunc(*gvk)
+	config.GroupVersion = &schema.GroupVersion{Group: gvk.Group, Version: gvk.Version}
+
+	dynamicClient, newClientErr := dynamic.NewForConfig(config)
+	if newClientErr != nil {
+		klog.Fatal(newClientErr)
+	}
+	return dynamicClient
+}
+
 func startInstallationController(cfg *cfg) (bool, error) {
 	enabled := cfg.enabledControllers["installation"]
 	if !enabled {
 		return false, nil
 	}
 
-	dynamicClientBuilderFunc := func(gvk *schema.GroupVersionKind, config *rest.Config, cluster *shipper.Cluster) dynamic.Interface {
-		config.APIPath = dynamic.LegacyAPIPathResolverFunc(*gvk)
-		config.GroupVersion = &schema.GroupVersion{Group: gvk.Group, Version: gvk.Version}
-
-		if cfg.restTimeout != nil {
-			config.Timeout = *cfg.restTimeout
-		}
-
-		dynamicClient, newClientErr := dynamic.NewForConfig(config)
-		if newClientErr != nil {
-			klog.Fatal(newClientErr)
-		}
-		return dynamicClient
-	}
-
 	c := installation.NewController(
 		client.NewShipperClientOrDie(installation.AgentName, cfg.restCfg),
 		cfg.store,
 		cfg.shipperInformerFactory,
-		dynamicClientBuilderFunc,
+		cfg.dynamicClientBuilder,
 		cfg.chartFetcher,
 		cfg.recorder(installation.AgentName),
 	)
@@ -391,7 +391,9 @@ func startCapacityController(cfg *cfg) (bool, error) {
 		client.NewShipperClientOrDie(capacity.AgentName, cfg.restCfg),
 		cfg.shipperInformerFactory,
 		cfg.store,
+		cfg.dynamicClientBuilder,
 		cfg.recorder(capacity.AgentName),
+		*sadPodLogs,
 	)
 	cfg.wg.Add(1)
 	go func() {
@@ -411,6 +413,7 @@ func startTrafficController(cfg *cfg) (bool, error) {
 		client.NewShipperClientOrDie(traffic.AgentName, cfg.restCfg),
 		cfg.shipperInformerFactory,
 		cfg.store,
+		cfg.dynamicClientBuilder,
 		cfg.recorder(traffic.AgentName),
 	)
 
@@ -27,6 +27,7 @@ import (
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 
+	"github.com/bookingcom/shipper/pkg/analysis"
 	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
 	"github.com/bookingcom/shipper/pkg/chart/repo"
 	"github.com/bookingcom/shipper/pkg/client"
@@ -68,6 +69,7 @@ var (
 	webhookKeyPath      = flag.String("webhook-key", "", "Path to the TLS private key for the webhook controller.")
 	webhookBindAddr     = flag.String("webhook-addr", "0.0.0.0", "Addr to bind the webhook controller.")
 	webhookBindPort     = flag.String("webhook-port", "9443", "Port to bind the webhook controller.")
+	prometheusURL       = flag.String("prometheus-url", "", "Address of the Prometheus server used to run release analysis queries.")
 	relDurationBuckets  = flag.String("release-duration-buckets", "15,30,45,60,120", "Comma-separated list of buckets for the shipper_objects_release_durations histogram, in seconds")
 )
 
@@ -97,6 +99,8 @@ type cfg struct {
 	chartVersionResolver repo.ChartVersionResolver
 	chartFetcher         repo.ChartFetcher
 
+	analysisProvider analysis.Provider
+
 	certPath, keyPath string
 	ns                string
 	workers           int
@@ -177,6 +181,12 @@ func main() {
 		stopCh,
 	)
 
+	var analysisProvider analysis.Provider
+	if *prometheusURL != "" {
+		klog.V(1).Infof("Release analysis will query Prometheus at %q", *prometheusURL)
+		analysisProvider = analysis.NewPrometheusProvider(*prometheusURL, &http.Client{Timeout: *restTimeout})
+	}
+
 	ssm := statemetrics.Metrics{
 		AppsLister:     shipperInformerFactory.Shipper().V1alpha1().Applications().Lister(),
 		RelsLister:     shipperInformerFactory.Shipper().V1alpha1().Releases().Lister(),
@@ -207,6 +217,8 @@ func main() {
 		chartVersionResolver: repo.ResolveChartVersionFunc(repoCatalog),
 		chartFetcher:         repo.FetchChartFunc(repoCatalog),
 
+		analysisProvider: analysisProvider,
+
 		ns:      *ns,
 		workers: *workers,
 
@@ -403,7 +415,9 @@ func startReleaseController(cfg *cfg) (bool, error) {
 		client.NewShipperClientOrDie(release.AgentName, cfg.restCfg),
 		cfg.store,
 		cfg.shipperInformerFactory,
+		cfg.kubeInformerFactory,
 		cfg.chartFetcher,
+		cfg.analysisProvider,
 		cfg.recorder(release.AgentName),
 	)
 
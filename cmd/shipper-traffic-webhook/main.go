@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/client"
+	"github.com/bookingcom/shipper/pkg/webhook"
+)
+
+const defaultRESTTimeout time.Duration = 10 * time.Second
+const defaultResync time.Duration = 0 * time.Second
+
+var (
+	masterURL       = flag.String("master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	kubeconfig      = flag.String("kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
+	resync          = flag.Duration("resync", defaultResync, "Informer's cache re-sync in Go's duration format.")
+	restTimeout     = flag.Duration("rest-timeout", defaultRESTTimeout, "Timeout value for REST clients. Does not affect informer watches.")
+	webhookCertPath = flag.String("webhook-cert", "", "Path to the TLS certificate for the webhook.")
+	webhookKeyPath  = flag.String("webhook-key", "", "Path to the TLS private key for the webhook.")
+	webhookBindAddr = flag.String("webhook-addr", "0.0.0.0", "Addr to bind the webhook.")
+	webhookBindPort = flag.String("webhook-port", "9443", "Port to bind the webhook.")
+)
+
+func main() {
+	klog.InitFlags(nil)
+	flag.Parse()
+
+	restCfg, err := prepareRestConfig()
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	// The informer client is only used for watches, which should not be
+	// subject to the REST timeout.
+	informerRestCfg := rest.CopyConfig(restCfg)
+	informerRestCfg.Timeout = 0
+	informerKubeClient := client.NewKubeClientOrDie("kube-shared-informer", informerRestCfg)
+	kubeClient := client.NewKubeClientOrDie(webhook.PodTrafficAgentName, restCfg)
+
+	stopCh := setupSignalHandler()
+
+	// Traffic decisions are only ever kept in ConfigMaps belonging to
+	// an application.
+	kubeInformerFactory := informers.NewSharedInformerFactoryWithOptions(
+		informerKubeClient, *resync,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = shipper.AppLabel
+		}))
+
+	w := webhook.NewPodTrafficWebhook(
+		*webhookBindAddr, *webhookBindPort, *webhookKeyPath, *webhookCertPath,
+		kubeClient, kubeInformerFactory)
+
+	kubeInformerFactory.Start(stopCh)
+
+	w.Run(stopCh)
+}
+
+func setupSignalHandler() <-chan struct{} {
+	stopCh := make(chan struct{})
+
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-c
+		close(stopCh)
+		<-c
+		os.Exit(1) // Second signal. Exit directly.
+	}()
+
+	return stopCh
+}
+
+func prepareRestConfig() (*rest.Config, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags(*masterURL, *kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	if restTimeout != nil {
+		cfg.Timeout = *restTimeout
+	}
+	return cfg, nil
+}
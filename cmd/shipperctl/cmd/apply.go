@@ -250,6 +250,14 @@ func createOrUpdateCrds(cmd *cobra.Command, configurator *configurator.Cluster)
 		return err
 	}
 
+	if err := configurator.CreateOrUpdateCRD(crds.RolloutStrategy); err != nil {
+		return err
+	}
+
+	if err := configurator.CreateOrUpdateCRD(crds.ReleaseApproval); err != nil {
+		return err
+	}
+
 	cmd.Println("done")
 
 	return nil
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"github.com/bookingcom/shipper/cmd/shipperctl/config"
+	"github.com/bookingcom/shipper/cmd/shipperctl/configurator"
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/chart/repo"
+	shipperclientset "github.com/bookingcom/shipper/pkg/client/clientset/versioned"
+	"github.com/bookingcom/shipper/pkg/controller/release"
+	releaseutil "github.com/bookingcom/shipper/pkg/util/release"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan [release name]",
+	Short: "Show what Shipper would do to roll out a Release, without changing anything",
+	Long: `Show, for every step of the strategy, how many replicas and how much traffic
+the contender and the incumbent would get in each cluster.
+
+Either pass the name of an existing release, or an application manifest with
+-f to see what would happen if it was applied.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPlanCommand,
+}
+
+// Parameters
+var (
+	planContext         string
+	planNamespace       string
+	planApplicationFile string
+)
+
+func init() {
+	fileFlagName := "file"
+	kubeConfigFlagName := "kube-config"
+	planCmd.Flags().StringVarP(&planApplicationFile, fileFlagName, "f", "", "an application manifest to plan a rollout for")
+	planCmd.Flags().StringVar(&kubeConfigFile, kubeConfigFlagName, "~/.kube/config", "the path to the Kubernetes configuration file")
+	planCmd.Flags().StringVar(&planContext, "context", "", "the context of the management cluster in the Kubernetes configuration file")
+	planCmd.Flags().StringVarP(&planNamespace, "namespace", "n", metav1.NamespaceDefault, "the namespace of the release or application")
+
+	err := planCmd.MarkFlagFilename(fileFlagName, "yaml")
+	if err != nil {
+		planCmd.Printf("warning: could not mark %q for filename autocompletion: %s\n", fileFlagName, err)
+	}
+	err = planCmd.MarkFlagFilename(kubeConfigFlagName, "yaml")
+	if err != nil {
+		planCmd.Printf("warning: could not mark %q for filename autocompletion: %s\n", kubeConfigFlagName, err)
+	}
+}
+
+func runPlanCommand(cmd *cobra.Command, args []string) error {
+	if (len(args) == 0) == (planApplicationFile == "") {
+		return fmt.Errorf("either a release name or an application manifest must be specified")
+	}
+
+	cluster, err := configurator.NewClusterConfigurator(
+		&config.ClusterConfiguration{Context: planContext}, kubeConfigFile)
+	if err != nil {
+		return err
+	}
+	client := cluster.ShipperClient
+
+	cacheDir, err := ioutil.TempDir("", "shipperctl-charts")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(cacheDir)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	catalog := repo.NewCatalog(repo.DefaultFileCacheFactory(cacheDir), repo.DefaultRemoteFetcher, stopCh)
+
+	var contender, incumbent *shipper.Release
+	if planApplicationFile != "" {
+		contender, incumbent, err = planApplicationReleases(client, catalog)
+	} else {
+		contender, incumbent, err = planExistingReleases(client, args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	clusterList, err := client.ShipperV1alpha1().Clusters().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	clusters := make([]*shipper.Cluster, 0, len(clusterList.Items))
+	for i := range clusterList.Items {
+		clusters = append(clusters, &clusterList.Items[i])
+	}
+
+	plan, err := release.Plan(contender, incumbent, clusters, repo.FetchChartFunc(catalog))
+	if err != nil {
+		return err
+	}
+
+	printPlan(cmd, plan)
+
+	return nil
+}
+
+// planExistingReleases returns the named release and the release it would
+// take over from.
+func planExistingReleases(client shipperclientset.Interface, name string) (*shipper.Release, *shipper.Release, error) {
+	rel, err := client.ShipperV1alpha1().Releases(planNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	appName, err := releaseutil.ApplicationNameForRelease(rel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rels, err := listApplicationReleases(client, appName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	incumbent, _, err := releaseutil.GetSiblingReleases(rel, rels)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rel, incumbent, nil
+}
+
+// planApplicationReleases builds the release Shipper would create for the
+// application manifest, and returns it along with the application's current
+// latest release, which would become its incumbent.
+func planApplicationReleases(client shipperclientset.Interface, catalog *repo.Catalog) (*shipper.Release, *shipper.Release, error) {
+	data, err := ioutil.ReadFile(planApplicationFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	app := &shipper.Application{}
+	if err := yaml.Unmarshal(data, app); err != nil {
+		return nil, nil, err
+	}
+	if app.Namespace == "" {
+		app.Namespace = planNamespace
+	}
+
+	rel := &shipper.Release{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-plan", app.Name),
+			Namespace: app.Namespace,
+			Labels: map[string]string{
+				shipper.AppLabel: app.Name,
+			},
+			Annotations: map[string]string{},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: shipper.SchemeGroupVersion.String(),
+					Kind:       "Application",
+					Name:       app.Name,
+				},
+			},
+		},
+		Spec: shipper.ReleaseSpec{
+			Environment: *app.Spec.Template.DeepCopy(),
+		},
+	}
+
+	cv, err := repo.ResolveChartVersionFunc(catalog)(&rel.Spec.Environment.Chart)
+	if err != nil {
+		return nil, nil, err
+	}
+	rel.Spec.Environment.Chart.Version = cv.Version
+
+	if ref := rel.Spec.Environment.StrategyRef; ref != nil {
+		rs, err := client.ShipperV1alpha1().RolloutStrategies().Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		rel.Spec.Environment.Strategy = rs.Spec.DeepCopy()
+	}
+
+	rels, err := listApplicationReleases(client, app.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var incumbent *shipper.Release
+	if len(rels) > 0 {
+		incumbent = releaseutil.SortByGenerationDescending(rels)[0]
+	}
+
+	return rel, incumbent, nil
+}
+
+func listApplicationReleases(client shipperclientset.Interface, appName string) ([]*shipper.Release, error) {
+	selector := labels.Set{shipper.AppLabel: appName}.AsSelector()
+	list, err := client.ShipperV1alpha1().Releases(planNamespace).List(metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rels := make([]*shipper.Release, 0, len(list.Items))
+	for i := range list.Items {
+		rels = append(rels, &list.Items[i])
+	}
+
+	return rels, nil
+}
+
+func printPlan(cmd *cobra.Command, plan *release.RolloutPlan) {
+	incumbent := plan.Incumbent
+	if incumbent == "" {
+		incumbent = "none"
+	}
+	cmd.Printf("Contender: %s\nIncumbent: %s\n\n", plan.Contender, incumbent)
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tCLUSTER\tCONTENDER PODS\tCONTENDER TRAFFIC\tINCUMBENT PODS\tINCUMBENT TRAFFIC")
+	for i, step := range plan.Steps {
+		for _, c := range step.Clusters {
+			contenderPods, contenderTraffic := describePlannedRelease(c.Contender)
+			incumbentPods, incumbentTraffic := describePlannedRelease(c.Incumbent)
+			fmt.Fprintf(w, "%d (%s)\t%s\t%s\t%s\t%s\t%s\n",
+				i, step.Name, c.Name,
+				contenderPods, contenderTraffic,
+				incumbentPods, incumbentTraffic)
+		}
+	}
+	w.Flush()
+}
+
+func describePlannedRelease(r *release.PlannedRelease) (string, string) {
+	if r == nil {
+		return "-", "-"
+	}
+	return fmt.Sprintf("%d/%d", r.Replicas, r.TotalReplicaCount), r.Traffic()
+}
@@ -0,0 +1,12 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var releaseCmd = &cobra.Command{
+	Use:   "release",
+	Short: "inspect Shipper Releases",
+}
+
+func init() {
+	releaseCmd.AddCommand(planCmd)
+}
@@ -92,6 +92,11 @@ func (c *Cluster) CreateClusterRole(domain, name string) error {
 				APIGroups: []string{""},
 				Resources: []string{"secrets"},
 			},
+			rbacv1.PolicyRule{
+				Verbs:     []string{"get", "list", "watch"},
+				APIGroups: []string{""},
+				Resources: []string{"namespaces"},
+			},
 			rbacv1.PolicyRule{
 				Verbs:     []string{rbacv1.VerbAll},
 				APIGroups: []string{""},
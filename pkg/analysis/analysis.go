@@ -0,0 +1,96 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"text/template"
+	"time"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+)
+
+// Provider runs metric queries against a monitoring backend.
+type Provider interface {
+	// Query evaluates query at the given time and returns the highest
+	// value found in its result.
+	Query(query string, ts time.Time) (float64, error)
+}
+
+// QueryVars are the values available to analysis query templates.
+type QueryVars struct {
+	Namespace   string
+	Application string
+	Release     string
+}
+
+// Result describes the outcome of a single analysis query.
+type Result struct {
+	Name      string
+	Value     float64
+	Threshold float64
+}
+
+func (r Result) Breached() bool {
+	return r.Value > r.Threshold
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%s=%g (threshold %g)", r.Name, r.Value, r.Threshold)
+}
+
+// Evaluate runs all the queries in spec at the given time. When window is
+// non-zero, every query is evaluated as the maximum it reached over that
+// window rather than its instant value. It returns the first query that
+// breached its threshold, if any.
+func Evaluate(
+	provider Provider,
+	spec *shipper.RolloutStrategyStepAnalysis,
+	vars QueryVars,
+	window time.Duration,
+	ts time.Time,
+) (*Result, error) {
+	for _, q := range spec.Queries {
+		threshold, err := strconv.ParseFloat(q.Threshold, 64)
+		if err != nil {
+			return nil, shippererrors.NewInvalidAnalysisQueryError(q.Name, err)
+		}
+
+		query, err := RenderQuery(q.Query, vars)
+		if err != nil {
+			return nil, shippererrors.NewInvalidAnalysisQueryError(q.Name, err)
+		}
+
+		if window > 0 {
+			query = fmt.Sprintf("max_over_time((%s)[%ds:])", query, int64(window.Seconds()))
+		}
+
+		value, err := provider.Query(query, ts)
+		if err != nil {
+			return nil, shippererrors.NewAnalysisQueryError(query, err)
+		}
+
+		result := Result{Name: q.Name, Value: value, Threshold: threshold}
+		if result.Breached() {
+			return &result, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// RenderQuery expands the template in query with vars.
+func RenderQuery(query string, vars QueryVars) (string, error) {
+	tpl, err := template.New("query").Option("missingkey=error").Parse(query)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
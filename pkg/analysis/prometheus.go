@@ -0,0 +1,125 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrometheusProvider queries the HTTP API of a Prometheus server.
+type PrometheusProvider struct {
+	url    string
+	client *http.Client
+}
+
+var _ Provider = (*PrometheusProvider)(nil)
+
+func NewPrometheusProvider(address string, client *http.Client) *PrometheusProvider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &PrometheusProvider{
+		url:    strings.TrimSuffix(address, "/"),
+		client: client,
+	}
+}
+
+type prometheusResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+type prometheusSample struct {
+	Value []interface{} `json:"value"`
+}
+
+func (p *PrometheusProvider) Query(query string, ts time.Time) (float64, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", strconv.FormatFloat(float64(ts.UnixNano())/1e9, 'f', 3, 64))
+
+	resp, err := p.client.Get(fmt.Sprintf("%s/api/v1/query?%s", p.url, params.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var body prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode response with status %q: %s", resp.Status, err)
+	}
+
+	if body.Status != "success" {
+		return 0, fmt.Errorf("%s: %s", body.ErrorType, body.Error)
+	}
+
+	var values [][]interface{}
+	switch body.Data.ResultType {
+	case "scalar":
+		var value []interface{}
+		if err := json.Unmarshal(body.Data.Result, &value); err != nil {
+			return 0, err
+		}
+		values = append(values, value)
+	case "vector":
+		var samples []prometheusSample
+		if err := json.Unmarshal(body.Data.Result, &samples); err != nil {
+			return 0, err
+		}
+		for _, sample := range samples {
+			values = append(values, sample.Value)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported result type %q", body.Data.ResultType)
+	}
+
+	max := math.NaN()
+	for _, value := range values {
+		v, err := parseSampleValue(value)
+		if err != nil {
+			return 0, err
+		}
+
+		// NaN usually comes out of a division by zero, such as an
+		// error ratio with no requests, so it tells us nothing.
+		if math.IsNaN(v) {
+			continue
+		}
+
+		if math.IsNaN(max) || v > max {
+			max = v
+		}
+	}
+
+	if math.IsNaN(max) {
+		return 0, fmt.Errorf("query returned no data")
+	}
+
+	return max, nil
+}
+
+// parseSampleValue extracts the value out of a [ <timestamp>, "<value>" ]
+// pair, which is how Prometheus encodes samples.
+func parseSampleValue(value []interface{}) (float64, error) {
+	if len(value) != 2 {
+		return 0, fmt.Errorf("malformed sample %v", value)
+	}
+
+	s, ok := value[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("malformed sample value %v", value[1])
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,121 @@
+package analysis
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+func newPrometheusStandIn(t *testing.T, responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			t.Errorf("unexpected request path %q", r.URL.Path)
+		}
+
+		resp, ok := responses[r.URL.Query().Get("query")]
+		if !ok {
+			t.Errorf("unexpected query %q", r.URL.Query().Get("query"))
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"unexpected query"}`)
+			return
+		}
+
+		fmt.Fprint(w, resp)
+	}))
+}
+
+func TestPrometheusProviderQuery(t *testing.T) {
+	srv := newPrometheusStandIn(t, map[string]string{
+		"vector": `{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"pod":"a"},"value":[1570000000,"0.2"]},
+			{"metric":{"pod":"b"},"value":[1570000000,"NaN"]},
+			{"metric":{"pod":"c"},"value":[1570000000,"0.5"]}]}}`,
+		"scalar": `{"status":"success","data":{"resultType":"scalar","result":[1570000000,"3"]}}`,
+		"empty":  `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+		"nan":    `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1570000000,"NaN"]}]}}`,
+		"broken": `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+	})
+	defer srv.Close()
+
+	provider := NewPrometheusProvider(srv.URL+"/", nil)
+
+	tests := []struct {
+		query   string
+		value   float64
+		wantErr bool
+	}{
+		{"vector", 0.5, false},
+		{"scalar", 3, false},
+		{"empty", 0, true},
+		{"nan", 0, true},
+		{"broken", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			value, err := provider.Query(tt.query, time.Now())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got value %g", value)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if value != tt.value {
+				t.Fatalf("expected value %g, got %g", tt.value, value)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	srv := newPrometheusStandIn(t, map[string]string{
+		`errors{release="foo-0"}`:                         `{"status":"success","data":{"resultType":"scalar","result":[1570000000,"0.01"]}}`,
+		`max_over_time((errors{release="foo-0"})[300s:])`: `{"status":"success","data":{"resultType":"scalar","result":[1570000000,"0.2"]}}`,
+	})
+	defer srv.Close()
+
+	provider := NewPrometheusProvider(srv.URL, nil)
+	spec := &shipper.RolloutStrategyStepAnalysis{
+		Window: metav1.Duration{Duration: 5 * time.Minute},
+		Queries: []shipper.AnalysisQuery{
+			{
+				Name:      "error-rate",
+				Query:     `errors{release="{{.Release}}"}`,
+				Threshold: "0.1",
+			},
+		},
+	}
+	vars := QueryVars{Namespace: "test", Application: "foo", Release: "foo-0"}
+
+	breach, err := Evaluate(provider, spec, vars, 0, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if breach != nil {
+		t.Fatalf("expected no breach for the instant query, got %s", breach)
+	}
+
+	breach, err = Evaluate(provider, spec, vars, spec.Window.Duration, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if breach == nil || breach.Name != "error-rate" || breach.Value != 0.2 {
+		t.Fatalf("expected error-rate to be breached over the window, got %v", breach)
+	}
+
+	spec.Queries[0].Threshold = "not a number"
+	if _, err := Evaluate(provider, spec, vars, 0, time.Now()); err == nil {
+		t.Fatalf("expected an error for an invalid threshold")
+	}
+}
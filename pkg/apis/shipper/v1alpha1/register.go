@@ -47,6 +47,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&TrafficTargetList{},
 		&RolloutBlock{},
 		&RolloutBlockList{},
+		&RolloutStrategy{},
+		&RolloutStrategyList{},
+		&ReleaseApproval{},
+		&ReleaseApprovalList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
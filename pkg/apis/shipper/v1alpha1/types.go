@@ -5,6 +5,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
@@ -20,6 +21,10 @@ const (
 	PodTrafficStatusLabel        = "shipper-traffic-status"
 	InstallationTargetOwnerLabel = "shipper-owned-by"
 
+	// ApprovalRequiredLabel is set to "true" on namespaces where Releases
+	// only move on to a strategy step once a ReleaseApproval exists for it.
+	ApprovalRequiredLabel = "shipper-approval-required"
+
 	AppHighestObservedGenerationAnnotation = "shipper.booking.com/app.highestObservedGeneration"
 
 	AppChartNameAnnotation            = "shipper.booking.com/app.chart.name"
@@ -35,6 +40,20 @@ const (
 
 	RolloutBlocksOverrideAnnotation = "shipper.booking.com/rollout-block.override"
 
+	// WorkloadLabel marks the object in a chart that runs the pods of a
+	// release when it's neither a Deployment nor a StatefulSet. Its
+	// replicas are read from WorkloadReplicasPathAnnotation, and the
+	// number of them that are ready from
+	// WorkloadReadyReplicasPathAnnotation.
+	WorkloadLabel                       = "shipper-workload"
+	WorkloadReplicasPathAnnotation      = "shipper.booking.com/workload.replicasPath"
+	WorkloadReadyReplicasPathAnnotation = "shipper.booking.com/workload.readyReplicasPath"
+
+	// HPAMinReplicasAnnotation keeps the minReplicas a chart gives its
+	// HorizontalPodAutoscaler, as the capacity controller scales it down
+	// along with maxReplicas over the course of a rollout.
+	HPAMinReplicasAnnotation = "shipper.booking.com/hpa.minReplicas"
+
 	LBLabel         = "shipper-lb"
 	LBForProduction = "production"
 
@@ -79,6 +98,65 @@ type ApplicationList struct {
 type ApplicationSpec struct {
 	RevisionHistoryLimit *int32             `json:"revisionHistoryLimit"`
 	Template             ReleaseEnvironment `json:"template"`
+	// AutoRollback opts the application into being rolled back to the
+	// incumbent release when the contender fails to achieve capacity.
+	AutoRollback *ApplicationAutoRollback `json:"autoRollback,omitempty"`
+	// TrafficBackend selects how traffic is shifted between the releases
+	// of the application. It overrides the backend of the cluster.
+	TrafficBackend TrafficBackendType `json:"trafficBackend,omitempty"`
+	// TrafficShifting limits how fast traffic is shifted between the
+	// releases of the application in each cluster.
+	TrafficShifting *ApplicationTrafficShifting `json:"trafficShifting,omitempty"`
+	// DrainDelay is how long the traffic of a release has to stay the
+	// same before its capacity is reduced, so that the connections it
+	// still has get a chance to finish.
+	DrainDelay *metav1.Duration `json:"drainDelay,omitempty"`
+}
+
+// TrafficBackendType is the mechanism used to shift traffic between the
+// releases of an application in an application cluster.
+type TrafficBackendType string
+
+const (
+	// TrafficBackendPodLabels shifts traffic by labelling pods so that
+	// they are selected by the application's Service. This is the
+	// default.
+	TrafficBackendPodLabels TrafficBackendType = "podLabels"
+	// TrafficBackendIstio shifts traffic by writing weighted routes to
+	// an Istio VirtualService and DestinationRule.
+	TrafficBackendIstio TrafficBackendType = "istio"
+	// TrafficBackendSMI shifts traffic by writing backend weights to an
+	// SMI TrafficSplit, for meshes like Linkerd.
+	TrafficBackendSMI TrafficBackendType = "smi"
+	// TrafficBackendNginxCanary shifts traffic by setting the weight of
+	// an ingress-nginx canary Ingress for the contender.
+	TrafficBackendNginxCanary TrafficBackendType = "nginxCanary"
+)
+
+type ApplicationTrafficShifting struct {
+	// MaxPodsPerSync is the maximum number of pods that get their
+	// traffic status changed every time traffic is shifted in a cluster.
+	// Zero means no limit.
+	MaxPodsPerSync int32 `json:"maxPodsPerSync,omitempty"`
+	// MaxPodsPerInterval is the maximum number of pods that get their
+	// traffic status changed in a cluster within Interval. Zero means no
+	// limit.
+	MaxPodsPerInterval int32           `json:"maxPodsPerInterval,omitempty"`
+	Interval           metav1.Duration `json:"interval,omitempty"`
+	// Parallelism is the number of pods that get their traffic status
+	// changed at the same time.
+	Parallelism int32 `json:"parallelism,omitempty"`
+	// MaxWeightDeviation is how many percentage points the traffic a
+	// release can get with the pods it has is allowed to be away from the
+	// traffic it asked for, before it gets reported. Zero means the
+	// default of 10.
+	MaxWeightDeviation int32 `json:"maxWeightDeviation,omitempty"`
+}
+
+type ApplicationAutoRollback struct {
+	// Deadline is how long the contender is allowed to remain stuck in
+	// any cluster before it gets rolled back.
+	Deadline metav1.Duration `json:"deadline"`
 }
 
 type ApplicationStatus struct {
@@ -150,6 +228,9 @@ type ClusterSpec struct {
 	Region       string                   `json:"region"`
 	APIMaster    string                   `json:"apiMaster"`
 	Scheduler    ClusterSchedulerSettings `json:"scheduler"`
+	// TrafficBackend is the default traffic backend for applications
+	// in this cluster.
+	TrafficBackend TrafficBackendType `json:"trafficBackend,omitempty"`
 }
 
 type ClusterSchedulerSettings struct {
@@ -197,11 +278,27 @@ type ReleaseStatus struct {
 	AchievedStep *AchievedStep          `json:"achievedStep,omitempty"`
 	Strategy     *ReleaseStrategyStatus `json:"strategy,omitempty"`
 	Conditions   []ReleaseCondition     `json:"conditions,omitempty"`
+	// Timeline records, in order, every strategy step this release has
+	// targeted and when each of its phases was reached.
+	Timeline []ReleaseStepTimeline `json:"timeline,omitempty"`
+}
+
+type ReleaseStepTimeline struct {
+	Step                   int32        `json:"step"`
+	Name                   string       `json:"name"`
+	TargetedAt             metav1.Time  `json:"targetedAt"`
+	InstallationAchievedAt *metav1.Time `json:"installationAchievedAt,omitempty"`
+	CapacityAchievedAt     *metav1.Time `json:"capacityAchievedAt,omitempty"`
+	TrafficAchievedAt      *metav1.Time `json:"trafficAchievedAt,omitempty"`
+	CompletedAt            *metav1.Time `json:"completedAt,omitempty"`
 }
 
 type AchievedStep struct {
 	Step int32  `json:"step"`
 	Name string `json:"name"`
+	// AchievedAt is the moment Shipper first observed this step as
+	// achieved. It is used to honour step pauses before advancing.
+	AchievedAt metav1.Time `json:"achievedAt,omitempty"`
 }
 
 type ReleaseConditionType string
@@ -211,6 +308,9 @@ const (
 	ReleaseConditionTypeStrategyExecuted ReleaseConditionType = "StrategyExecuted"
 	ReleaseConditionTypeComplete         ReleaseConditionType = "Complete"
 	ReleaseConditionTypeBlocked          ReleaseConditionType = "Blocked"
+	ReleaseConditionTypeAnalysisFailed   ReleaseConditionType = "AnalysisFailed"
+	ReleaseConditionTypeStepTimedOut     ReleaseConditionType = "StepTimedOut"
+	ReleaseConditionTypeAwaitingApproval ReleaseConditionType = "AwaitingApproval"
 )
 
 type ReleaseCondition struct {
@@ -231,7 +331,17 @@ type ReleaseEnvironment struct {
 	// requirements for target clusters for the deployment
 	ClusterRequirements ClusterRequirements `json:"clusterRequirements"`
 
-	Strategy *RolloutStrategy `json:"strategy,omitempty"`
+	Strategy *RolloutStrategySpec `json:"strategy,omitempty"`
+
+	// StrategyRef points to a RolloutStrategy object to use instead of
+	// an inline strategy. It is resolved into Strategy when a Release
+	// gets created, so changes to the RolloutStrategy don't affect
+	// existing Releases.
+	StrategyRef *RolloutStrategyReference `json:"strategyRef,omitempty"`
+}
+
+type RolloutStrategyReference struct {
+	Name string `json:"name"`
 }
 
 type ClusterRequirements struct {
@@ -245,7 +355,29 @@ type RegionRequirement struct {
 	Replicas *int32 `json:"replicas,omitempty"`
 }
 
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// A RolloutStrategy is a cluster-wide rollout strategy that Applications can
+// refer to by name instead of carrying a copy of it.
 type RolloutStrategy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RolloutStrategySpec `json:"spec"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type RolloutStrategyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []RolloutStrategy `json:"items"`
+}
+
+type RolloutStrategySpec struct {
 	Steps []RolloutStrategyStep `json:"steps"`
 }
 
@@ -253,11 +385,59 @@ type RolloutStrategyStep struct {
 	Name     string                   `json:"name"`
 	Capacity RolloutStrategyStepValue `json:"capacity"`
 	Traffic  RolloutStrategyStepValue `json:"traffic"`
+
+	// AutoAdvance tells the release controller to move the release on to
+	// the next step by itself once this one is achieved, instead of
+	// waiting for a command.
+	AutoAdvance bool `json:"autoAdvance,omitempty"`
+	// Pause is how long an achieved step is held before it is
+	// automatically advanced. It has no effect unless AutoAdvance is set.
+	Pause *metav1.Duration `json:"pause,omitempty"`
+	// Deadline is how long the contender and incumbent are given to
+	// achieve the capacity and traffic of this step once it is
+	// targeted. Exceeding it marks the release as StepTimedOut.
+	Deadline *metav1.Duration `json:"deadline,omitempty"`
+
+	// Analysis gates this step on metrics of the contender: the step is
+	// only considered achieved once all the queries have stayed within
+	// their thresholds for the whole window.
+	Analysis *RolloutStrategyStepAnalysis `json:"analysis,omitempty"`
+
+	// ClusterOverrides replaces the capacity and traffic values of this
+	// step in the listed clusters, so a rollout can progress through
+	// clusters in waves rather than in all of them at once.
+	ClusterOverrides []RolloutStrategyClusterOverride `json:"clusterOverrides,omitempty"`
+}
+
+type RolloutStrategyClusterOverride struct {
+	Clusters []string                 `json:"clusters"`
+	Capacity RolloutStrategyStepValue `json:"capacity"`
+	Traffic  RolloutStrategyStepValue `json:"traffic"`
 }
 
+type RolloutStrategyStepAnalysis struct {
+	// Window is how long the contender has to stay within the
+	// thresholds after the step has been rolled out.
+	Window  metav1.Duration `json:"window"`
+	Queries []AnalysisQuery `json:"queries"`
+}
+
+type AnalysisQuery struct {
+	Name string `json:"name"`
+	// Query is a PromQL expression. It is rendered as a Go template with
+	// the Namespace, Application and Release fields available.
+	Query string `json:"query"`
+	// Threshold is the highest acceptable value for the query result,
+	// as a decimal number.
+	Threshold string `json:"threshold"`
+}
+
+// RolloutStrategyStepValue holds the capacity or traffic of a strategy step.
+// Integers and strings ending in "%", like 5 or "5%", are percentages, while
+// other strings, like "1", are an absolute number of pods.
 type RolloutStrategyStepValue struct {
-	Incumbent int32 `json:"incumbent"`
-	Contender int32 `json:"contender"`
+	Incumbent intstr.IntOrString `json:"incumbent"`
+	Contender intstr.IntOrString `json:"contender"`
 }
 
 type TargetConditionType string
@@ -366,6 +546,11 @@ type ClusterConditionType string
 const (
 	ClusterConditionTypeOperational ClusterConditionType = "Operational"
 	ClusterConditionTypeReady       ClusterConditionType = "Ready"
+
+	// ClusterConditionTypeWeightDeviation is only present in traffic
+	// statuses, when there are not enough pods to get close enough to the
+	// requested weight.
+	ClusterConditionTypeWeightDeviation ClusterConditionType = "WeightDeviation"
 )
 
 type ClusterCapacityCondition struct {
@@ -381,6 +566,29 @@ type PodStatus struct {
 	Containers     []corev1.ContainerStatus `json:"containers"`
 	InitContainers []corev1.ContainerStatus `json:"initContainers"`
 	Condition      corev1.PodCondition      `json:"condition"`
+
+	// Terminations has the last termination of every container in the
+	// pod that terminated, with the end of its log.
+	Terminations []ContainerTermination `json:"terminations,omitempty"`
+	// Events has the most recent Warning events about the pod, such as
+	// FailedScheduling or FailedMount.
+	Events []PodEvent `json:"events,omitempty"`
+}
+
+type ContainerTermination struct {
+	Container string `json:"container"`
+	Reason    string `json:"reason,omitempty"`
+	Message   string `json:"message,omitempty"`
+	ExitCode  int32  `json:"exitCode"`
+	// LogTail is the end of the log the container wrote before it
+	// terminated, capped in size.
+	LogTail string `json:"logTail,omitempty"`
+}
+
+type PodEvent struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
+	Count   int32  `json:"count,omitempty"`
 }
 
 // the capacity and traffic controllers need context to pick the right
@@ -390,12 +598,25 @@ type PodStatus struct {
 
 type CapacityTargetSpec struct {
 	Clusters []ClusterCapacityTarget `json:"clusters"`
+	// Workload is the kind of object running the pods of the release when
+	// it's neither a Deployment nor a StatefulSet.
+	Workload *WorkloadReference `json:"workload,omitempty"`
+}
+
+// WorkloadReference identifies the kind of a workload the chart marks with
+// WorkloadLabel. Its objects must implement the scale subresource.
+type WorkloadReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
 }
 
 type ClusterCapacityTarget struct {
 	Name              string `json:"name"`
 	Percent           int32  `json:"percent"`
 	TotalReplicaCount int32  `json:"totalReplicaCount"`
+	// Replicas is an absolute number of replicas to run, used instead of
+	// Percent when set. It never exceeds TotalReplicaCount.
+	Replicas *int32 `json:"replicas,omitempty"`
 }
 
 // +genclient
@@ -429,9 +650,17 @@ type TrafficTargetStatus struct {
 }
 
 type ClusterTrafficStatus struct {
-	Name            string                    `json:"name"`
-	AchievedTraffic uint32                    `json:"achievedTraffic"`
-	Conditions      []ClusterTrafficCondition `json:"conditions"`
+	Name             string `json:"name"`
+	RequestedTraffic uint32 `json:"requestedTraffic"`
+	// AchievedTraffic is always a weight. For releases that asked for a
+	// number of pods instead, it's the share of the traffic of the
+	// application, out of 100, that those pods get.
+	AchievedTraffic uint32 `json:"achievedTraffic"`
+	// RequestedPods and AchievedPods are only set for releases that asked
+	// for a number of pods to receive traffic instead of a weight.
+	RequestedPods *uint32                   `json:"requestedPods,omitempty"`
+	AchievedPods  *uint32                   `json:"achievedPods,omitempty"`
+	Conditions    []ClusterTrafficCondition `json:"conditions"`
 }
 
 type ClusterTrafficCondition struct {
@@ -447,9 +676,12 @@ type TrafficTargetSpec struct {
 }
 
 type ClusterTrafficTarget struct {
-	Name string `json:"name"`
-	// apimachinery intstr for percentages?
+	Name   string `json:"name"`
 	Weight uint32 `json:"weight"`
+	// Pods is an absolute number of pods that should receive traffic,
+	// used instead of Weight when set. The pods of the release are taken
+	// out of the ones shared by weight between the other releases.
+	Pods *uint32 `json:"pods,omitempty"`
 }
 
 type ReleaseStrategyStatus struct {
@@ -481,6 +713,7 @@ const (
 	StrategyConditionContenderAchievedTraffic      StrategyConditionType = "ContenderAchievedTraffic"
 	StrategyConditionIncumbentAchievedCapacity     StrategyConditionType = "IncumbentAchievedCapacity"
 	StrategyConditionIncumbentAchievedTraffic      StrategyConditionType = "IncumbentAchievedTraffic"
+	StrategyConditionContenderAchievedAnalysis     StrategyConditionType = "ContenderAchievedAnalysis"
 )
 
 type StrategyState string
@@ -536,6 +769,40 @@ const (
 	RolloutBlockReason = "RolloutsBlocked"
 )
 
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// A ReleaseApproval records that someone approved a Release to move on to a
+// strategy step. In namespaces labelled with ApprovalRequiredLabel, the
+// release controller only honours target steps that have been approved.
+type ReleaseApproval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ReleaseApprovalSpec `json:"spec"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type ReleaseApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ReleaseApproval `json:"items"`
+}
+
+type ReleaseApprovalSpec struct {
+	// Release is the name of the approved Release, in the same namespace
+	// as the approval.
+	Release string `json:"release"`
+	// Step is the index of the approved strategy step.
+	Step int32 `json:"step"`
+	// Approver is the user who approved the step. The admission webhook
+	// only accepts approvals made by the user they name.
+	Approver string `json:"approver"`
+	Comment  string `json:"comment,omitempty"`
+}
+
 func (ss *StrategyState) UnmarshalJSON(b []byte) error {
 	var s string
 	if err := json.Unmarshal(b, &s); err != nil {
@@ -5,13 +5,15 @@
 package v1alpha1
 
 import (
-	v1 "k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AchievedStep) DeepCopyInto(out *AchievedStep) {
 	*out = *in
+	in.AchievedAt.DeepCopyInto(&out.AchievedAt)
 	return
 }
 
@@ -25,6 +27,22 @@ func (in *AchievedStep) DeepCopy() *AchievedStep {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AnalysisQuery) DeepCopyInto(out *AnalysisQuery) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnalysisQuery.
+func (in *AnalysisQuery) DeepCopy() *AnalysisQuery {
+	if in == nil {
+		return nil
+	}
+	out := new(AnalysisQuery)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Application) DeepCopyInto(out *Application) {
 	*out = *in
@@ -53,6 +71,23 @@ func (in *Application) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationAutoRollback) DeepCopyInto(out *ApplicationAutoRollback) {
+	*out = *in
+	out.Deadline = in.Deadline
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationAutoRollback.
+func (in *ApplicationAutoRollback) DeepCopy() *ApplicationAutoRollback {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationAutoRollback)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ApplicationCondition) DeepCopyInto(out *ApplicationCondition) {
 	*out = *in
@@ -112,6 +147,21 @@ func (in *ApplicationSpec) DeepCopyInto(out *ApplicationSpec) {
 		**out = **in
 	}
 	in.Template.DeepCopyInto(&out.Template)
+	if in.AutoRollback != nil {
+		in, out := &in.AutoRollback, &out.AutoRollback
+		*out = new(ApplicationAutoRollback)
+		**out = **in
+	}
+	if in.TrafficShifting != nil {
+		in, out := &in.TrafficShifting, &out.TrafficShifting
+		*out = new(ApplicationTrafficShifting)
+		**out = **in
+	}
+	if in.DrainDelay != nil {
+		in, out := &in.DrainDelay, &out.DrainDelay
+		*out = new(v1.Duration)
+		**out = **in
+	}
 	return
 }
 
@@ -153,6 +203,23 @@ func (in *ApplicationStatus) DeepCopy() *ApplicationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationTrafficShifting) DeepCopyInto(out *ApplicationTrafficShifting) {
+	*out = *in
+	out.Interval = in.Interval
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationTrafficShifting.
+func (in *ApplicationTrafficShifting) DeepCopy() *ApplicationTrafficShifting {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationTrafficShifting)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CapacityTarget) DeepCopyInto(out *CapacityTarget) {
 	*out = *in
@@ -220,7 +287,14 @@ func (in *CapacityTargetSpec) DeepCopyInto(out *CapacityTargetSpec) {
 	if in.Clusters != nil {
 		in, out := &in.Clusters, &out.Clusters
 		*out = make([]ClusterCapacityTarget, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Workload != nil {
+		in, out := &in.Workload, &out.Workload
+		*out = new(WorkloadReference)
+		**out = **in
 	}
 	return
 }
@@ -369,6 +443,11 @@ func (in *ClusterCapacityStatus) DeepCopy() *ClusterCapacityStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterCapacityTarget) DeepCopyInto(out *ClusterCapacityTarget) {
 	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
@@ -567,6 +646,16 @@ func (in *ClusterTrafficCondition) DeepCopy() *ClusterTrafficCondition {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterTrafficStatus) DeepCopyInto(out *ClusterTrafficStatus) {
 	*out = *in
+	if in.RequestedPods != nil {
+		in, out := &in.RequestedPods, &out.RequestedPods
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.AchievedPods != nil {
+		in, out := &in.AchievedPods, &out.AchievedPods
+		*out = new(uint32)
+		**out = **in
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]ClusterTrafficCondition, len(*in))
@@ -590,6 +679,11 @@ func (in *ClusterTrafficStatus) DeepCopy() *ClusterTrafficStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterTrafficTarget) DeepCopyInto(out *ClusterTrafficTarget) {
 	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = new(uint32)
+		**out = **in
+	}
 	return
 }
 
@@ -603,6 +697,22 @@ func (in *ClusterTrafficTarget) DeepCopy() *ClusterTrafficTarget {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerTermination) DeepCopyInto(out *ContainerTermination) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerTermination.
+func (in *ContainerTermination) DeepCopy() *ContainerTermination {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerTermination)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InstallationTarget) DeepCopyInto(out *InstallationTarget) {
 	*out = *in
@@ -729,24 +839,50 @@ func (in *InstallationTargetStatus) DeepCopy() *InstallationTargetStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodEvent) DeepCopyInto(out *PodEvent) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodEvent.
+func (in *PodEvent) DeepCopy() *PodEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(PodEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodStatus) DeepCopyInto(out *PodStatus) {
 	*out = *in
 	if in.Containers != nil {
 		in, out := &in.Containers, &out.Containers
-		*out = make([]v1.ContainerStatus, len(*in))
+		*out = make([]corev1.ContainerStatus, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	if in.InitContainers != nil {
 		in, out := &in.InitContainers, &out.InitContainers
-		*out = make([]v1.ContainerStatus, len(*in))
+		*out = make([]corev1.ContainerStatus, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	in.Condition.DeepCopyInto(&out.Condition)
+	if in.Terminations != nil {
+		in, out := &in.Terminations, &out.Terminations
+		*out = make([]ContainerTermination, len(*in))
+		copy(*out, *in)
+	}
+	if in.Events != nil {
+		in, out := &in.Events, &out.Events
+		*out = make([]PodEvent, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -809,6 +945,82 @@ func (in *Release) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReleaseApproval) DeepCopyInto(out *ReleaseApproval) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseApproval.
+func (in *ReleaseApproval) DeepCopy() *ReleaseApproval {
+	if in == nil {
+		return nil
+	}
+	out := new(ReleaseApproval)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ReleaseApproval) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReleaseApprovalList) DeepCopyInto(out *ReleaseApprovalList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ReleaseApproval, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseApprovalList.
+func (in *ReleaseApprovalList) DeepCopy() *ReleaseApprovalList {
+	if in == nil {
+		return nil
+	}
+	out := new(ReleaseApprovalList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ReleaseApprovalList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReleaseApprovalSpec) DeepCopyInto(out *ReleaseApprovalSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseApprovalSpec.
+func (in *ReleaseApprovalSpec) DeepCopy() *ReleaseApprovalSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ReleaseApprovalSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReleaseCondition) DeepCopyInto(out *ReleaseCondition) {
 	*out = *in
@@ -838,9 +1050,14 @@ func (in *ReleaseEnvironment) DeepCopyInto(out *ReleaseEnvironment) {
 	in.ClusterRequirements.DeepCopyInto(&out.ClusterRequirements)
 	if in.Strategy != nil {
 		in, out := &in.Strategy, &out.Strategy
-		*out = new(RolloutStrategy)
+		*out = new(RolloutStrategySpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.StrategyRef != nil {
+		in, out := &in.StrategyRef, &out.StrategyRef
+		*out = new(RolloutStrategyReference)
+		**out = **in
+	}
 	return
 }
 
@@ -910,7 +1127,7 @@ func (in *ReleaseStatus) DeepCopyInto(out *ReleaseStatus) {
 	if in.AchievedStep != nil {
 		in, out := &in.AchievedStep, &out.AchievedStep
 		*out = new(AchievedStep)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Strategy != nil {
 		in, out := &in.Strategy, &out.Strategy
@@ -924,6 +1141,13 @@ func (in *ReleaseStatus) DeepCopyInto(out *ReleaseStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Timeline != nil {
+		in, out := &in.Timeline, &out.Timeline
+		*out = make([]ReleaseStepTimeline, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -937,6 +1161,39 @@ func (in *ReleaseStatus) DeepCopy() *ReleaseStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReleaseStepTimeline) DeepCopyInto(out *ReleaseStepTimeline) {
+	*out = *in
+	in.TargetedAt.DeepCopyInto(&out.TargetedAt)
+	if in.InstallationAchievedAt != nil {
+		in, out := &in.InstallationAchievedAt, &out.InstallationAchievedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CapacityAchievedAt != nil {
+		in, out := &in.CapacityAchievedAt, &out.CapacityAchievedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.TrafficAchievedAt != nil {
+		in, out := &in.TrafficAchievedAt, &out.TrafficAchievedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseStepTimeline.
+func (in *ReleaseStepTimeline) DeepCopy() *ReleaseStepTimeline {
+	if in == nil {
+		return nil
+	}
+	out := new(ReleaseStepTimeline)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReleaseStrategyCondition) DeepCopyInto(out *ReleaseStrategyCondition) {
 	*out = *in
@@ -1123,21 +1380,122 @@ func (in *RolloutBlockStatus) DeepCopy() *RolloutBlockStatus {
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RolloutStrategy) DeepCopyInto(out *RolloutStrategy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategy.
+func (in *RolloutStrategy) DeepCopy() *RolloutStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RolloutStrategy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStrategyClusterOverride) DeepCopyInto(out *RolloutStrategyClusterOverride) {
+	*out = *in
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.Capacity = in.Capacity
+	out.Traffic = in.Traffic
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategyClusterOverride.
+func (in *RolloutStrategyClusterOverride) DeepCopy() *RolloutStrategyClusterOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStrategyClusterOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStrategyList) DeepCopyInto(out *RolloutStrategyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RolloutStrategy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategyList.
+func (in *RolloutStrategyList) DeepCopy() *RolloutStrategyList {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStrategyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RolloutStrategyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStrategyReference) DeepCopyInto(out *RolloutStrategyReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategyReference.
+func (in *RolloutStrategyReference) DeepCopy() *RolloutStrategyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStrategyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStrategySpec) DeepCopyInto(out *RolloutStrategySpec) {
 	*out = *in
 	if in.Steps != nil {
 		in, out := &in.Steps, &out.Steps
 		*out = make([]RolloutStrategyStep, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategy.
-func (in *RolloutStrategy) DeepCopy() *RolloutStrategy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategySpec.
+func (in *RolloutStrategySpec) DeepCopy() *RolloutStrategySpec {
 	if in == nil {
 		return nil
 	}
-	out := new(RolloutStrategy)
+	out := new(RolloutStrategySpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -1147,6 +1505,28 @@ func (in *RolloutStrategyStep) DeepCopyInto(out *RolloutStrategyStep) {
 	*out = *in
 	out.Capacity = in.Capacity
 	out.Traffic = in.Traffic
+	if in.Pause != nil {
+		in, out := &in.Pause, &out.Pause
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Deadline != nil {
+		in, out := &in.Deadline, &out.Deadline
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Analysis != nil {
+		in, out := &in.Analysis, &out.Analysis
+		*out = new(RolloutStrategyStepAnalysis)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ClusterOverrides != nil {
+		in, out := &in.ClusterOverrides, &out.ClusterOverrides
+		*out = make([]RolloutStrategyClusterOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -1160,9 +1540,33 @@ func (in *RolloutStrategyStep) DeepCopy() *RolloutStrategyStep {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStrategyStepAnalysis) DeepCopyInto(out *RolloutStrategyStepAnalysis) {
+	*out = *in
+	out.Window = in.Window
+	if in.Queries != nil {
+		in, out := &in.Queries, &out.Queries
+		*out = make([]AnalysisQuery, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategyStepAnalysis.
+func (in *RolloutStrategyStepAnalysis) DeepCopy() *RolloutStrategyStepAnalysis {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStrategyStepAnalysis)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RolloutStrategyStepValue) DeepCopyInto(out *RolloutStrategyStepValue) {
 	*out = *in
+	out.Incumbent = in.Incumbent
+	out.Contender = in.Contender
 	return
 }
 
@@ -1260,7 +1664,9 @@ func (in *TrafficTargetSpec) DeepCopyInto(out *TrafficTargetSpec) {
 	if in.Clusters != nil {
 		in, out := &in.Clusters, &out.Clusters
 		*out = make([]ClusterTrafficTarget, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	return
 }
@@ -1308,3 +1714,19 @@ func (in *TrafficTargetStatus) DeepCopy() *TrafficTargetStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadReference) DeepCopyInto(out *WorkloadReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadReference.
+func (in *WorkloadReference) DeepCopy() *WorkloadReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadReference)
+	in.DeepCopyInto(out)
+	return out
+}
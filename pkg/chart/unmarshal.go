@@ -2,8 +2,16 @@ package chart
 
 import (
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	workloadutil "github.com/bookingcom/shipper/pkg/util/workload"
 )
 
 func GetDeployments(rawRendered []string) []appsv1.Deployment {
@@ -33,3 +41,167 @@ func GetDeployments(rawRendered []string) []appsv1.Deployment {
 
 	return deployments
 }
+
+func GetStatefulSets(rawRendered []string) []appsv1.StatefulSet {
+	var statefulSets []appsv1.StatefulSet
+
+	decoder := scheme.Codecs.UniversalDeserializer()
+
+	for _, raw := range rawRendered {
+		obj, _, err := decoder.Decode([]byte(raw), nil, nil)
+		if err != nil {
+			klog.V(10).Infof("failed to unmarshal an object, skipping: %s", err)
+			continue
+		}
+
+		if s, ok := obj.(*appsv1.StatefulSet); ok {
+			statefulSets = append(statefulSets, *s)
+		}
+	}
+
+	return statefulSets
+}
+
+// GetWorkloads returns the custom resources marked as the workload of a
+// release. Objects of kinds built into Kubernetes are never taken as one.
+func GetWorkloads(rawRendered []string) []*unstructured.Unstructured {
+	var workloads []*unstructured.Unstructured
+
+	for _, raw := range rawRendered {
+		obj, err := DecodeUnstructured(raw)
+		if err != nil {
+			klog.V(10).Infof("failed to unmarshal an object, skipping: %s", err)
+			continue
+		}
+
+		if scheme.Scheme.Recognizes(obj.GroupVersionKind()) {
+			continue
+		}
+
+		if workloadutil.IsMarked(obj) {
+			workloads = append(workloads, obj)
+		}
+	}
+
+	return workloads
+}
+
+// WorkloadReplicas returns the number of replicas a workload asks for, or nil
+// if it doesn't say.
+func WorkloadReplicas(obj *unstructured.Unstructured) (*int32, error) {
+	path := workloadutil.ReplicasPath(obj)
+	replicas, ok, err := unstructured.NestedInt64(obj.Object, path...)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	r := int32(replicas)
+	return &r, nil
+}
+
+// DecodeUnstructured decodes a manifest of any kind, including the ones
+// Kubernetes doesn't know about.
+func DecodeUnstructured(raw string) (*unstructured.Unstructured, error) {
+	data, err := yaml.YAMLToJSON([]byte(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// HorizontalPodAutoscaler holds what Shipper needs to know about a
+// HorizontalPodAutoscaler, whichever autoscaling API version the chart uses
+// for it.
+type HorizontalPodAutoscaler struct {
+	Name           string
+	ScaleTargetRef autoscalingv1.CrossVersionObjectReference
+	MinReplicas    int32
+	MaxReplicas    int32
+}
+
+// Targets returns whether the HorizontalPodAutoscaler scales the object of
+// the given kind and name.
+func (hpa *HorizontalPodAutoscaler) Targets(kind, name string) bool {
+	return hpa.ScaleTargetRef.Kind == kind && hpa.ScaleTargetRef.Name == name
+}
+
+// AsHorizontalPodAutoscaler returns the HorizontalPodAutoscaler in obj, or
+// false if obj is not one.
+func AsHorizontalPodAutoscaler(obj runtime.Object) (*HorizontalPodAutoscaler, bool) {
+	var (
+		hpa         *HorizontalPodAutoscaler
+		minReplicas *int32
+	)
+
+	switch obj := obj.(type) {
+	case *autoscalingv1.HorizontalPodAutoscaler:
+		hpa = &HorizontalPodAutoscaler{
+			Name:           obj.Name,
+			ScaleTargetRef: obj.Spec.ScaleTargetRef,
+			MaxReplicas:    obj.Spec.MaxReplicas,
+		}
+		minReplicas = obj.Spec.MinReplicas
+	case *autoscalingv2beta1.HorizontalPodAutoscaler:
+		ref := obj.Spec.ScaleTargetRef
+		hpa = &HorizontalPodAutoscaler{
+			Name: obj.Name,
+			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{
+				Kind:       ref.Kind,
+				Name:       ref.Name,
+				APIVersion: ref.APIVersion,
+			},
+			MaxReplicas: obj.Spec.MaxReplicas,
+		}
+		minReplicas = obj.Spec.MinReplicas
+	case *autoscalingv2beta2.HorizontalPodAutoscaler:
+		ref := obj.Spec.ScaleTargetRef
+		hpa = &HorizontalPodAutoscaler{
+			Name: obj.Name,
+			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{
+				Kind:       ref.Kind,
+				Name:       ref.Name,
+				APIVersion: ref.APIVersion,
+			},
+			MaxReplicas: obj.Spec.MaxReplicas,
+		}
+		minReplicas = obj.Spec.MinReplicas
+	default:
+		return nil, false
+	}
+
+	// minReplicas defaults to 1 when unspecified. See
+	// k8s.io/api/autoscaling/v1/types.go's
+	// HorizontalPodAutoscalerSpec.
+	hpa.MinReplicas = 1
+	if minReplicas != nil {
+		hpa.MinReplicas = *minReplicas
+	}
+
+	return hpa, true
+}
+
+func GetHorizontalPodAutoscalers(rawRendered []string) []*HorizontalPodAutoscaler {
+	var hpas []*HorizontalPodAutoscaler
+
+	decoder := scheme.Codecs.UniversalDeserializer()
+
+	for _, raw := range rawRendered {
+		obj, _, err := decoder.Decode([]byte(raw), nil, nil)
+		if err != nil {
+			klog.V(10).Infof("failed to unmarshal an object, skipping: %s", err)
+			continue
+		}
+
+		if hpa, ok := AsHorizontalPodAutoscaler(obj); ok {
+			hpas = append(hpas, hpa)
+		}
+	}
+
+	return hpas
+}
@@ -19,6 +19,22 @@ spec:
           image: "nginx:stable"
 `
 
+const statefulSetText = `
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: my-stateful-app
+  namespace: default
+spec:
+  replicas: 3
+  serviceName: my-stateful-app
+  template:
+    spec:
+      containers:
+        - name: my-stateful-app
+          image: "redis:5"
+`
+
 const somethingElseText = `
 apiVersion: v1
 kind: Service
@@ -65,3 +81,125 @@ func TestGetDeploymentsValid(t *testing.T) {
 		t.Errorf("expected %d replicas but got %d", expectedReplicas, *d.Spec.Replicas)
 	}
 }
+
+const hpaV1Text = `
+apiVersion: autoscaling/v1
+kind: HorizontalPodAutoscaler
+metadata:
+  name: my-complex-app
+  namespace: default
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: my-complex-app
+  maxReplicas: 20
+  targetCPUUtilizationPercentage: 80
+`
+
+const hpaV2beta2Text = `
+apiVersion: autoscaling/v2beta2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: my-other-app
+  namespace: default
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: my-other-app
+  minReplicas: 4
+  maxReplicas: 8
+`
+
+func TestGetHorizontalPodAutoscalersValid(t *testing.T) {
+	hpas := GetHorizontalPodAutoscalers([]string{deploymentText, hpaV1Text, hpaV2beta2Text, garbage})
+	if len(hpas) != 2 {
+		t.Fatalf("expected exactly two HorizontalPodAutoscalers but got %d", len(hpas))
+	}
+
+	expected := []HorizontalPodAutoscaler{
+		{Name: "my-complex-app", MinReplicas: 1, MaxReplicas: 20},
+		{Name: "my-other-app", MinReplicas: 4, MaxReplicas: 8},
+	}
+
+	for i, hpa := range hpas {
+		if !hpa.Targets("Deployment", expected[i].Name) {
+			t.Errorf("expected %q to target Deployment %q, got %+v", hpa.Name, expected[i].Name, hpa.ScaleTargetRef)
+		}
+		if hpa.MinReplicas != expected[i].MinReplicas || hpa.MaxReplicas != expected[i].MaxReplicas {
+			t.Errorf("expected %q to scale between %d and %d replicas, got %d and %d",
+				hpa.Name, expected[i].MinReplicas, expected[i].MaxReplicas, hpa.MinReplicas, hpa.MaxReplicas)
+		}
+	}
+}
+
+func TestGetStatefulSetsValid(t *testing.T) {
+	statefulSets := GetStatefulSets([]string{deploymentText, statefulSetText, somethingElseText, garbage})
+	if len(statefulSets) != 1 {
+		t.Fatalf("expected exactly one StatefulSet but got %d", len(statefulSets))
+	}
+
+	s := statefulSets[0]
+
+	const (
+		expectedName     = "my-stateful-app"
+		expectedReplicas = 3
+	)
+
+	if s.GetName() != expectedName {
+		t.Errorf("expected name %q but got %q", expectedName, s.GetName())
+	}
+	if *s.Spec.Replicas != expectedReplicas {
+		t.Errorf("expected %d replicas but got %d", expectedReplicas, *s.Spec.Replicas)
+	}
+}
+
+const widgetText = `
+apiVersion: widgets.example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+  labels:
+    shipper-workload: "true"
+  annotations:
+    shipper.booking.com/workload.replicasPath: spec.widgets.count
+spec:
+  widgets:
+    count: 4
+`
+
+const unmarkedWidgetText = `
+apiVersion: widgets.example.com/v1
+kind: Widget
+metadata:
+  name: my-other-widget
+spec:
+  replicas: 2
+`
+
+func TestGetWorkloadsValid(t *testing.T) {
+	workloads := GetWorkloads([]string{deploymentText, widgetText, unmarkedWidgetText, garbage})
+	if len(workloads) != 1 {
+		t.Fatalf("expected exactly one workload but got %d", len(workloads))
+	}
+
+	w := workloads[0]
+
+	const (
+		expectedName     = "my-widget"
+		expectedReplicas = 4
+	)
+
+	if w.GetName() != expectedName {
+		t.Errorf("expected name %q but got %q", expectedName, w.GetName())
+	}
+
+	replicas, err := WorkloadReplicas(w)
+	if err != nil {
+		t.Fatalf("expected no error reading replicas but got: %s", err)
+	}
+	if replicas == nil || *replicas != expectedReplicas {
+		t.Errorf("expected %d replicas but got %v", expectedReplicas, replicas)
+	}
+}
@@ -0,0 +1,104 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeRolloutStrategies implements RolloutStrategyInterface
+type FakeRolloutStrategies struct {
+	Fake *FakeShipperV1alpha1
+}
+
+var rolloutstrategiesResource = schema.GroupVersionResource{Group: "shipper.booking.com", Version: "v1alpha1", Resource: "rolloutstrategies"}
+
+var rolloutstrategiesKind = schema.GroupVersionKind{Group: "shipper.booking.com", Version: "v1alpha1", Kind: "RolloutStrategy"}
+
+// Get takes name of the rolloutStrategy, and returns the corresponding rolloutStrategy object, and an error if there is any.
+func (c *FakeRolloutStrategies) Get(name string, options v1.GetOptions) (result *v1alpha1.RolloutStrategy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(rolloutstrategiesResource, name), &v1alpha1.RolloutStrategy{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.RolloutStrategy), err
+}
+
+// List takes label and field selectors, and returns the list of RolloutStrategies that match those selectors.
+func (c *FakeRolloutStrategies) List(opts v1.ListOptions) (result *v1alpha1.RolloutStrategyList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(rolloutstrategiesResource, rolloutstrategiesKind, opts), &v1alpha1.RolloutStrategyList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.RolloutStrategyList{ListMeta: obj.(*v1alpha1.RolloutStrategyList).ListMeta}
+	for _, item := range obj.(*v1alpha1.RolloutStrategyList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested rolloutStrategies.
+func (c *FakeRolloutStrategies) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(rolloutstrategiesResource, opts))
+}
+
+// Create takes the representation of a rolloutStrategy and creates it.  Returns the server's representation of the rolloutStrategy, and an error, if there is any.
+func (c *FakeRolloutStrategies) Create(rolloutStrategy *v1alpha1.RolloutStrategy) (result *v1alpha1.RolloutStrategy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(rolloutstrategiesResource, rolloutStrategy), &v1alpha1.RolloutStrategy{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.RolloutStrategy), err
+}
+
+// Update takes the representation of a rolloutStrategy and updates it. Returns the server's representation of the rolloutStrategy, and an error, if there is any.
+func (c *FakeRolloutStrategies) Update(rolloutStrategy *v1alpha1.RolloutStrategy) (result *v1alpha1.RolloutStrategy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(rolloutstrategiesResource, rolloutStrategy), &v1alpha1.RolloutStrategy{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.RolloutStrategy), err
+}
+
+// Delete takes name of the rolloutStrategy and deletes it. Returns an error if one occurs.
+func (c *FakeRolloutStrategies) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(rolloutstrategiesResource, name), &v1alpha1.RolloutStrategy{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeRolloutStrategies) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(rolloutstrategiesResource, listOptions)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.RolloutStrategyList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched rolloutStrategy.
+func (c *FakeRolloutStrategies) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.RolloutStrategy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(rolloutstrategiesResource, name, pt, data, subresources...), &v1alpha1.RolloutStrategy{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.RolloutStrategy), err
+}
@@ -32,10 +32,18 @@ func (c *FakeShipperV1alpha1) Releases(namespace string) v1alpha1.ReleaseInterfa
 	return &FakeReleases{c, namespace}
 }
 
+func (c *FakeShipperV1alpha1) ReleaseApprovals(namespace string) v1alpha1.ReleaseApprovalInterface {
+	return &FakeReleaseApprovals{c, namespace}
+}
+
 func (c *FakeShipperV1alpha1) RolloutBlocks(namespace string) v1alpha1.RolloutBlockInterface {
 	return &FakeRolloutBlocks{c, namespace}
 }
 
+func (c *FakeShipperV1alpha1) RolloutStrategies() v1alpha1.RolloutStrategyInterface {
+	return &FakeRolloutStrategies{c}
+}
+
 func (c *FakeShipperV1alpha1) TrafficTargets(namespace string) v1alpha1.TrafficTargetInterface {
 	return &FakeTrafficTargets{c, namespace}
 }
@@ -12,6 +12,10 @@ type InstallationTargetExpansion interface{}
 
 type ReleaseExpansion interface{}
 
+type ReleaseApprovalExpansion interface{}
+
 type RolloutBlockExpansion interface{}
 
+type RolloutStrategyExpansion interface{}
+
 type TrafficTargetExpansion interface{}
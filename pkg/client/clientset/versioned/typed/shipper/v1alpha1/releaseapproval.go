@@ -0,0 +1,158 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"time"
+
+	v1alpha1 "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	scheme "github.com/bookingcom/shipper/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// ReleaseApprovalsGetter has a method to return a ReleaseApprovalInterface.
+// A group's client should implement this interface.
+type ReleaseApprovalsGetter interface {
+	ReleaseApprovals(namespace string) ReleaseApprovalInterface
+}
+
+// ReleaseApprovalInterface has methods to work with ReleaseApproval resources.
+type ReleaseApprovalInterface interface {
+	Create(*v1alpha1.ReleaseApproval) (*v1alpha1.ReleaseApproval, error)
+	Update(*v1alpha1.ReleaseApproval) (*v1alpha1.ReleaseApproval, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha1.ReleaseApproval, error)
+	List(opts v1.ListOptions) (*v1alpha1.ReleaseApprovalList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.ReleaseApproval, err error)
+	ReleaseApprovalExpansion
+}
+
+// releaseApprovals implements ReleaseApprovalInterface
+type releaseApprovals struct {
+	client rest.Interface
+	ns     string
+}
+
+// newReleaseApprovals returns a ReleaseApprovals
+func newReleaseApprovals(c *ShipperV1alpha1Client, namespace string) *releaseApprovals {
+	return &releaseApprovals{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the releaseApproval, and returns the corresponding releaseApproval object, and an error if there is any.
+func (c *releaseApprovals) Get(name string, options v1.GetOptions) (result *v1alpha1.ReleaseApproval, err error) {
+	result = &v1alpha1.ReleaseApproval{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("releaseapprovals").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of ReleaseApprovals that match those selectors.
+func (c *releaseApprovals) List(opts v1.ListOptions) (result *v1alpha1.ReleaseApprovalList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.ReleaseApprovalList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("releaseapprovals").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested releaseApprovals.
+func (c *releaseApprovals) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("releaseapprovals").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+// Create takes the representation of a releaseApproval and creates it.  Returns the server's representation of the releaseApproval, and an error, if there is any.
+func (c *releaseApprovals) Create(releaseApproval *v1alpha1.ReleaseApproval) (result *v1alpha1.ReleaseApproval, err error) {
+	result = &v1alpha1.ReleaseApproval{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("releaseapprovals").
+		Body(releaseApproval).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a releaseApproval and updates it. Returns the server's representation of the releaseApproval, and an error, if there is any.
+func (c *releaseApprovals) Update(releaseApproval *v1alpha1.ReleaseApproval) (result *v1alpha1.ReleaseApproval, err error) {
+	result = &v1alpha1.ReleaseApproval{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("releaseapprovals").
+		Name(releaseApproval.Name).
+		Body(releaseApproval).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the releaseApproval and deletes it. Returns an error if one occurs.
+func (c *releaseApprovals) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("releaseapprovals").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *releaseApprovals) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("releaseapprovals").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched releaseApproval.
+func (c *releaseApprovals) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.ReleaseApproval, err error) {
+	result = &v1alpha1.ReleaseApproval{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("releaseapprovals").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
@@ -0,0 +1,148 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"time"
+
+	v1alpha1 "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	scheme "github.com/bookingcom/shipper/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// RolloutStrategiesGetter has a method to return a RolloutStrategyInterface.
+// A group's client should implement this interface.
+type RolloutStrategiesGetter interface {
+	RolloutStrategies() RolloutStrategyInterface
+}
+
+// RolloutStrategyInterface has methods to work with RolloutStrategy resources.
+type RolloutStrategyInterface interface {
+	Create(*v1alpha1.RolloutStrategy) (*v1alpha1.RolloutStrategy, error)
+	Update(*v1alpha1.RolloutStrategy) (*v1alpha1.RolloutStrategy, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha1.RolloutStrategy, error)
+	List(opts v1.ListOptions) (*v1alpha1.RolloutStrategyList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.RolloutStrategy, err error)
+	RolloutStrategyExpansion
+}
+
+// rolloutStrategies implements RolloutStrategyInterface
+type rolloutStrategies struct {
+	client rest.Interface
+}
+
+// newRolloutStrategies returns a RolloutStrategies
+func newRolloutStrategies(c *ShipperV1alpha1Client) *rolloutStrategies {
+	return &rolloutStrategies{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the rolloutStrategy, and returns the corresponding rolloutStrategy object, and an error if there is any.
+func (c *rolloutStrategies) Get(name string, options v1.GetOptions) (result *v1alpha1.RolloutStrategy, err error) {
+	result = &v1alpha1.RolloutStrategy{}
+	err = c.client.Get().
+		Resource("rolloutstrategies").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of RolloutStrategies that match those selectors.
+func (c *rolloutStrategies) List(opts v1.ListOptions) (result *v1alpha1.RolloutStrategyList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.RolloutStrategyList{}
+	err = c.client.Get().
+		Resource("rolloutstrategies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested rolloutStrategies.
+func (c *rolloutStrategies) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("rolloutstrategies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+// Create takes the representation of a rolloutStrategy and creates it.  Returns the server's representation of the rolloutStrategy, and an error, if there is any.
+func (c *rolloutStrategies) Create(rolloutStrategy *v1alpha1.RolloutStrategy) (result *v1alpha1.RolloutStrategy, err error) {
+	result = &v1alpha1.RolloutStrategy{}
+	err = c.client.Post().
+		Resource("rolloutstrategies").
+		Body(rolloutStrategy).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a rolloutStrategy and updates it. Returns the server's representation of the rolloutStrategy, and an error, if there is any.
+func (c *rolloutStrategies) Update(rolloutStrategy *v1alpha1.RolloutStrategy) (result *v1alpha1.RolloutStrategy, err error) {
+	result = &v1alpha1.RolloutStrategy{}
+	err = c.client.Put().
+		Resource("rolloutstrategies").
+		Name(rolloutStrategy.Name).
+		Body(rolloutStrategy).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the rolloutStrategy and deletes it. Returns an error if one occurs.
+func (c *rolloutStrategies) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("rolloutstrategies").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *rolloutStrategies) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("rolloutstrategies").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched rolloutStrategy.
+func (c *rolloutStrategies) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.RolloutStrategy, err error) {
+	result = &v1alpha1.RolloutStrategy{}
+	err = c.client.Patch(pt).
+		Resource("rolloutstrategies").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
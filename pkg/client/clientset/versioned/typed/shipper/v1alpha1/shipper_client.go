@@ -15,7 +15,9 @@ type ShipperV1alpha1Interface interface {
 	ClustersGetter
 	InstallationTargetsGetter
 	ReleasesGetter
+	ReleaseApprovalsGetter
 	RolloutBlocksGetter
+	RolloutStrategiesGetter
 	TrafficTargetsGetter
 }
 
@@ -44,10 +46,18 @@ func (c *ShipperV1alpha1Client) Releases(namespace string) ReleaseInterface {
 	return newReleases(c, namespace)
 }
 
+func (c *ShipperV1alpha1Client) ReleaseApprovals(namespace string) ReleaseApprovalInterface {
+	return newReleaseApprovals(c, namespace)
+}
+
 func (c *ShipperV1alpha1Client) RolloutBlocks(namespace string) RolloutBlockInterface {
 	return newRolloutBlocks(c, namespace)
 }
 
+func (c *ShipperV1alpha1Client) RolloutStrategies() RolloutStrategyInterface {
+	return newRolloutStrategies(c)
+}
+
 func (c *ShipperV1alpha1Client) TrafficTargets(namespace string) TrafficTargetInterface {
 	return newTrafficTargets(c, namespace)
 }
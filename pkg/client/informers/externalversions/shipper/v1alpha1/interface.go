@@ -18,8 +18,12 @@ type Interface interface {
 	InstallationTargets() InstallationTargetInformer
 	// Releases returns a ReleaseInformer.
 	Releases() ReleaseInformer
+	// ReleaseApprovals returns a ReleaseApprovalInformer.
+	ReleaseApprovals() ReleaseApprovalInformer
 	// RolloutBlocks returns a RolloutBlockInformer.
 	RolloutBlocks() RolloutBlockInformer
+	// RolloutStrategies returns a RolloutStrategyInformer.
+	RolloutStrategies() RolloutStrategyInformer
 	// TrafficTargets returns a TrafficTargetInformer.
 	TrafficTargets() TrafficTargetInformer
 }
@@ -60,11 +64,21 @@ func (v *version) Releases() ReleaseInformer {
 	return &releaseInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// ReleaseApprovals returns a ReleaseApprovalInformer.
+func (v *version) ReleaseApprovals() ReleaseApprovalInformer {
+	return &releaseApprovalInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // RolloutBlocks returns a RolloutBlockInformer.
 func (v *version) RolloutBlocks() RolloutBlockInformer {
 	return &rolloutBlockInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// RolloutStrategies returns a RolloutStrategyInformer.
+func (v *version) RolloutStrategies() RolloutStrategyInformer {
+	return &rolloutStrategyInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
 // TrafficTargets returns a TrafficTargetInformer.
 func (v *version) TrafficTargets() TrafficTargetInformer {
 	return &trafficTargetInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
@@ -0,0 +1,78 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ReleaseApprovalLister helps list ReleaseApprovals.
+type ReleaseApprovalLister interface {
+	// List lists all ReleaseApprovals in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.ReleaseApproval, err error)
+	// ReleaseApprovals returns an object that can list and get ReleaseApprovals.
+	ReleaseApprovals(namespace string) ReleaseApprovalNamespaceLister
+	ReleaseApprovalListerExpansion
+}
+
+// releaseApprovalLister implements the ReleaseApprovalLister interface.
+type releaseApprovalLister struct {
+	indexer cache.Indexer
+}
+
+// NewReleaseApprovalLister returns a new ReleaseApprovalLister.
+func NewReleaseApprovalLister(indexer cache.Indexer) ReleaseApprovalLister {
+	return &releaseApprovalLister{indexer: indexer}
+}
+
+// List lists all ReleaseApprovals in the indexer.
+func (s *releaseApprovalLister) List(selector labels.Selector) (ret []*v1alpha1.ReleaseApproval, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.ReleaseApproval))
+	})
+	return ret, err
+}
+
+// ReleaseApprovals returns an object that can list and get ReleaseApprovals.
+func (s *releaseApprovalLister) ReleaseApprovals(namespace string) ReleaseApprovalNamespaceLister {
+	return releaseApprovalNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// ReleaseApprovalNamespaceLister helps list and get ReleaseApprovals.
+type ReleaseApprovalNamespaceLister interface {
+	// List lists all ReleaseApprovals in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1alpha1.ReleaseApproval, err error)
+	// Get retrieves the ReleaseApproval from the indexer for a given namespace and name.
+	Get(name string) (*v1alpha1.ReleaseApproval, error)
+	ReleaseApprovalNamespaceListerExpansion
+}
+
+// releaseApprovalNamespaceLister implements the ReleaseApprovalNamespaceLister
+// interface.
+type releaseApprovalNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all ReleaseApprovals in the indexer for a given namespace.
+func (s releaseApprovalNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.ReleaseApproval, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.ReleaseApproval))
+	})
+	return ret, err
+}
+
+// Get retrieves the ReleaseApproval from the indexer for a given namespace and name.
+func (s releaseApprovalNamespaceLister) Get(name string) (*v1alpha1.ReleaseApproval, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("releaseapproval"), name)
+	}
+	return obj.(*v1alpha1.ReleaseApproval), nil
+}
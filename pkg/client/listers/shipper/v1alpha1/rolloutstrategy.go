@@ -0,0 +1,49 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// RolloutStrategyLister helps list RolloutStrategies.
+type RolloutStrategyLister interface {
+	// List lists all RolloutStrategies in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.RolloutStrategy, err error)
+	// Get retrieves the RolloutStrategy from the index for a given name.
+	Get(name string) (*v1alpha1.RolloutStrategy, error)
+	RolloutStrategyListerExpansion
+}
+
+// rolloutStrategyLister implements the RolloutStrategyLister interface.
+type rolloutStrategyLister struct {
+	indexer cache.Indexer
+}
+
+// NewRolloutStrategyLister returns a new RolloutStrategyLister.
+func NewRolloutStrategyLister(indexer cache.Indexer) RolloutStrategyLister {
+	return &rolloutStrategyLister{indexer: indexer}
+}
+
+// List lists all RolloutStrategies in the indexer.
+func (s *rolloutStrategyLister) List(selector labels.Selector) (ret []*v1alpha1.RolloutStrategy, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.RolloutStrategy))
+	})
+	return ret, err
+}
+
+// Get retrieves the RolloutStrategy from the index for a given name.
+func (s *rolloutStrategyLister) Get(name string) (*v1alpha1.RolloutStrategy, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("rolloutstrategy"), name)
+	}
+	return obj.(*v1alpha1.RolloutStrategy), nil
+}
@@ -0,0 +1,47 @@
+package clusterclientstore
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+// DynamicClientBuilderFunc is the signature of the functions controllers get
+// to build dynamic clients for objects of kind gvk in an application cluster.
+type DynamicClientBuilderFunc = func(gvk *schema.GroupVersionKind, restConfig *rest.Config, cluster *shipper.Cluster) dynamic.Interface
+
+// NewDynamicClient returns a dynamic client for objects of kind gvk in cluster,
+// built by buildClient out of the config of clientset.
+func NewDynamicClient(
+	clientset ClientsetInterface,
+	cluster *shipper.Cluster,
+	gvk schema.GroupVersionKind,
+	buildClient DynamicClientBuilderFunc,
+) dynamic.Interface {
+	return buildClient(&gvk, copyConfig(clientset), cluster)
+}
+
+// NewDynamicInformerClient is just like NewDynamicClient, but for clients
+// that are only used in informers. Setting an HTTP timeout would cut watches
+// short, so we leave it to client-go (see k8s.io/client-go/tools/cache) to
+// govern watch durations, as the client store does for its own informers.
+func NewDynamicInformerClient(
+	clientset ClientsetInterface,
+	cluster *shipper.Cluster,
+	gvk schema.GroupVersionKind,
+	buildClient DynamicClientBuilderFunc,
+) dynamic.Interface {
+	restConfig := copyConfig(clientset)
+	restConfig.Timeout = 0
+
+	return buildClient(&gvk, restConfig, cluster)
+}
+
+// copyConfig returns a copy of the config of clientset. The client store is
+// just like an informer cache: it's a shared pointer to a read-only struct,
+// so it has to be copied before mutating.
+func copyConfig(clientset ClientsetInterface) *rest.Config {
+	return rest.CopyConfig(clientset.GetConfig())
+}
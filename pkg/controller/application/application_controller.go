@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"k8s.io/apimachinery/pkg/labels"
 	"math"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -21,8 +22,10 @@ import (
 	clientset "github.com/bookingcom/shipper/pkg/client/clientset/versioned"
 	informers "github.com/bookingcom/shipper/pkg/client/informers/externalversions"
 	listers "github.com/bookingcom/shipper/pkg/client/listers/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/controller"
 	shippererrors "github.com/bookingcom/shipper/pkg/errors"
 	apputil "github.com/bookingcom/shipper/pkg/util/application"
+	capacityutil "github.com/bookingcom/shipper/pkg/util/capacity"
 	"github.com/bookingcom/shipper/pkg/util/conditions"
 	diffutil "github.com/bookingcom/shipper/pkg/util/diff"
 	releaseutil "github.com/bookingcom/shipper/pkg/util/release"
@@ -52,8 +55,16 @@ type Controller struct {
 	rbLister listers.RolloutBlockLister
 	rbSynced cache.InformerSynced
 
+	rsLister listers.RolloutStrategyLister
+	rsSynced cache.InformerSynced
+
+	ctLister listers.CapacityTargetLister
+	ctSynced cache.InformerSynced
+
 	versionResolver shipperrepo.ChartVersionResolver
 
+	capacityProgress *capacityProgressTracker
+
 	recorder record.EventRecorder
 }
 
@@ -67,6 +78,8 @@ func NewController(
 	appInformer := shipperInformerFactory.Shipper().V1alpha1().Applications()
 	relInformer := shipperInformerFactory.Shipper().V1alpha1().Releases()
 	rbInformer := shipperInformerFactory.Shipper().V1alpha1().RolloutBlocks()
+	rsInformer := shipperInformerFactory.Shipper().V1alpha1().RolloutStrategies()
+	ctInformer := shipperInformerFactory.Shipper().V1alpha1().CapacityTargets()
 
 	c := &Controller{
 		shipperClientset: shipperClientset,
@@ -81,8 +94,17 @@ func NewController(
 		rbLister: rbInformer.Lister(),
 		rbSynced: rbInformer.Informer().HasSynced,
 
+		rsLister: rsInformer.Lister(),
+		rsSynced: rsInformer.Informer().HasSynced,
+
+		ctLister: ctInformer.Lister(),
+		ctSynced: ctInformer.Informer().HasSynced,
+
 		versionResolver: versionResolver,
-		recorder:        recorder,
+
+		capacityProgress: newCapacityProgressTracker(),
+
+		recorder: recorder,
 	}
 
 	appInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -105,6 +127,10 @@ func NewController(
 		DeleteFunc: c.enqueueAppFromRolloutBlock,
 	})
 
+	ctInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: c.enqueueAppFromCapacityTarget,
+	})
+
 	return c
 }
 
@@ -117,7 +143,7 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) {
 	klog.V(2).Info("Starting Application controller")
 	defer klog.V(2).Info("Shutting down Application controller")
 
-	if !cache.WaitForCacheSync(stopCh, c.appSynced, c.relSynced, c.rbSynced) {
+	if !cache.WaitForCacheSync(stopCh, c.appSynced, c.relSynced, c.rbSynced, c.rsSynced, c.ctSynced) {
 		runtime.HandleError(fmt.Errorf("failed to sync caches for the Application controller"))
 		return
 	}
@@ -227,6 +253,60 @@ func (c *Controller) enqueueAppFromRolloutBlock(obj interface{}) {
 	}
 }
 
+// enqueueAppFromCapacityTarget enqueues the application a capacity target
+// belongs to whenever it becomes ready or stops being ready in any of its
+// clusters, so that stuck contenders can be rolled back.
+func (c *Controller) enqueueAppFromCapacityTarget(old, new interface{}) {
+	oldCT, oldOk := old.(*shipper.CapacityTarget)
+	newCT, newOk := new.(*shipper.CapacityTarget)
+	if !oldOk || !newOk {
+		runtime.HandleError(fmt.Errorf("not a shipper.CapacityTarget: %#v", new))
+		return
+	}
+
+	if !clusterReadinessChanged(oldCT, newCT) {
+		return
+	}
+
+	appName, ok := newCT.Labels[shipper.AppLabel]
+	if !ok {
+		runtime.HandleError(fmt.Errorf("CapacityTarget %s/%s is missing label %s",
+			newCT.Namespace, newCT.Name, shipper.AppLabel))
+		return
+	}
+
+	c.workqueue.Add(fmt.Sprintf("%s/%s", newCT.Namespace, appName))
+}
+
+// clusterReadinessChanged returns whether the Ready condition of any cluster
+// is different in old and new.
+func clusterReadinessChanged(old, new *shipper.CapacityTarget) bool {
+	readyConds := func(ct *shipper.CapacityTarget) map[string]shipper.ClusterCapacityCondition {
+		conds := make(map[string]shipper.ClusterCapacityCondition)
+		for _, status := range ct.Status.Clusters {
+			cond := capacityutil.GetClusterCapacityCondition(status, shipper.ClusterConditionTypeReady)
+			if cond != nil {
+				conds[status.Name] = *cond
+			}
+		}
+		return conds
+	}
+
+	oldConds, newConds := readyConds(old), readyConds(new)
+	if len(oldConds) != len(newConds) {
+		return true
+	}
+
+	for name, newCond := range newConds {
+		oldCond, ok := oldConds[name]
+		if !ok || oldCond.Status != newCond.Status || oldCond.Reason != newCond.Reason {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *Controller) syncApplication(key string) error {
 	ns, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
@@ -531,6 +611,16 @@ func (c *Controller) processApplication(app *shipper.Application) error {
 		highestObserved = generation
 	}
 
+	if aborted, err := c.abortStuckContender(app, contender, appReleases, diff); err != nil {
+		return err
+	} else if aborted {
+		apputil.SetHighestObservedGeneration(app, highestObserved)
+
+		appReleases = appReleases[1:]
+		app.Status.History = apputil.ReleasesToApplicationHistory(appReleases)
+		return c.cleanUpReleasesForApplication(app, appReleases)
+	}
+
 	if !identicalEnvironments(app.Spec.Template, contender.Spec.Environment) {
 		// The application's template has been modified and is different than
 		// the contender's environment. This means that a new release should
@@ -568,6 +658,183 @@ func (c *Controller) processApplication(app *shipper.Application) error {
 	return c.wrapUpApplicationConditions(app, appReleases)
 }
 
+// abortStuckContender rolls the application back to its incumbent release if
+// the contender has failed the analysis of one of its strategy steps, or if
+// the application opted into automatic rollbacks and the contender has been
+// failing to achieve capacity in at least one cluster for longer than the
+// configured deadline. The contender release is deleted and its environment
+// replaced by the incumbent's in the application template, which is exactly
+// what a user would do to abort a rollout by hand. It returns true if the
+// contender was rolled back. rels is expected to be sorted by generation in
+// descending order.
+func (c *Controller) abortStuckContender(
+	app *shipper.Application,
+	contender *shipper.Release,
+	rels []*shipper.Release,
+	diff *diffutil.MultiDiff,
+) (bool, error) {
+	if releaseutil.ReleaseComplete(contender) {
+		c.capacityProgress.forget(controller.MetaKey(contender))
+		return false, nil
+	}
+
+	analysisFailed := releaseutil.ReleaseAnalysisFailed(contender)
+	if app.Spec.AutoRollback == nil && !analysisFailed {
+		return false, nil
+	}
+
+	// If the application template has changed, the contender is about to
+	// be replaced anyway, so there's no point in rolling it back.
+	if !identicalEnvironments(app.Spec.Template, contender.Spec.Environment) {
+		return false, nil
+	}
+
+	incumbent, err := apputil.GetIncumbent(app.Name, rels)
+	if err != nil {
+		if shippererrors.IsIncumbentNotFoundError(err) {
+			// There is nothing to roll back to.
+			return false, nil
+		}
+		return false, err
+	}
+
+	var reason, msg string
+	if analysisFailed {
+		cond := releaseutil.GetReleaseCondition(contender.Status, shipper.ReleaseConditionTypeAnalysisFailed)
+		reason = conditions.ContenderAnalysisFailed
+		msg = fmt.Sprintf(
+			"release %q failed analysis, rolling back to release %q: %s",
+			contender.Name, incumbent.Name, cond.Message)
+	} else {
+		ct, err := c.ctLister.CapacityTargets(contender.Namespace).Get(contender.Name)
+		if err != nil {
+			if kerrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, shippererrors.NewKubeclientGetError(contender.Namespace, contender.Name, err).
+				WithShipperKind("CapacityTarget")
+		}
+
+		deadline := app.Spec.AutoRollback.Deadline.Duration
+		stuck, requeueAfter := c.stuckClusters(ct, deadline)
+		if len(stuck) == 0 {
+			if requeueAfter > 0 {
+				// The contender is failing somewhere, but hasn't
+				// run out of time yet. Nothing else might trigger a
+				// sync once the deadline passes, so schedule one
+				// ourselves.
+				if key, err := cache.MetaNamespaceKeyFunc(app); err == nil {
+					c.workqueue.AddAfter(key, requeueAfter)
+				}
+			}
+			return false, nil
+		}
+
+		reason = conditions.ContenderStuck
+		msg = fmt.Sprintf(
+			"release %q failed to achieve capacity for more than %s, rolling back to release %q: %s",
+			contender.Name, deadline, incumbent.Name, strings.Join(stuck, "; "))
+	}
+
+	err = c.shipperClientset.ShipperV1alpha1().Releases(contender.Namespace).Delete(contender.Name, &metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return false, shippererrors.NewKubeclientDeleteError(contender.Namespace, contender.Name, err).
+			WithShipperKind("Release")
+	}
+
+	c.capacityProgress.forget(controller.MetaKey(contender))
+
+	apputil.CopyEnvironment(app, incumbent)
+	apputil.UpdateChartVersionResolvedAnnotation(app, incumbent.Spec.Environment.Chart.Version)
+
+	abortingCond := apputil.NewApplicationCondition(
+		shipper.ApplicationConditionTypeAborting,
+		corev1.ConditionTrue,
+		reason,
+		msg)
+	diff.Append(apputil.SetApplicationCondition(&app.Status, *abortingCond))
+
+	rollingOutCond := apputil.NewApplicationCondition(
+		shipper.ApplicationConditionTypeRollingOut,
+		corev1.ConditionTrue,
+		"", "")
+	diff.Append(apputil.SetApplicationCondition(&app.Status, *rollingOutCond))
+
+	c.recorder.Event(app, corev1.EventTypeWarning, "ReleaseRolledBack", msg)
+
+	return true, nil
+}
+
+// stuckClusters returns a summary for every cluster in ct that has been
+// failing to achieve capacity for longer than deadline. If no cluster is past
+// the deadline but some are failing, it also returns the time left until the
+// first of them is.
+//
+// Clusters that cannot make progress on their own are failing since their
+// Ready condition turned False. Clusters whose pods are just slow to become
+// ready are only failing since the last time more of their replicas became
+// available, so that big scale-ups don't get rolled back while they're still
+// going.
+func (c *Controller) stuckClusters(ct *shipper.CapacityTarget, deadline time.Duration) ([]string, time.Duration) {
+	var (
+		stuck        []string
+		requeueAfter time.Duration
+	)
+
+	key := controller.MetaKey(ct)
+	for _, status := range ct.Status.Clusters {
+		cond := capacityutil.GetClusterCapacityCondition(status, shipper.ClusterConditionTypeReady)
+		if cond == nil || cond.Status != corev1.ConditionFalse {
+			c.capacityProgress.forgetCluster(key, status.Name)
+			continue
+		}
+
+		failingSince := cond.LastTransitionTime.Time
+		if cannotProgress(cond.Reason, status) {
+			c.capacityProgress.forgetCluster(key, status.Name)
+		} else if cond.Reason == capacityutil.PodsNotReady {
+			progressSince := c.capacityProgress.observe(key, status.Name, status.AvailableReplicas)
+			if progressSince.After(failingSince) {
+				failingSince = progressSince
+			}
+		} else {
+			c.capacityProgress.forgetCluster(key, status.Name)
+			continue
+		}
+
+		remaining := deadline - time.Since(failingSince)
+		if remaining > 0 {
+			if requeueAfter == 0 || remaining < requeueAfter {
+				requeueAfter = remaining
+			}
+			continue
+		}
+
+		summary := capacityutil.SummarizeSadPods(status.SadPods)
+		if summary == "" {
+			summary = cond.Message
+		}
+
+		stuck = append(stuck, fmt.Sprintf("%s: %s %s", status.Name, cond.Reason, summary))
+	}
+
+	return stuck, requeueAfter
+}
+
+// cannotProgress returns whether a cluster that is not ready for reason will
+// stay that way no matter how long we wait: its deployment is stuck, there's
+// no room left for it in the namespace quota, or its pods keep restarting.
+func cannotProgress(reason string, status shipper.ClusterCapacityStatus) bool {
+	switch reason {
+	case capacityutil.DeploymentStuck, capacityutil.QuotaInsufficient:
+		return true
+	case capacityutil.PodsNotReady:
+		return capacityutil.SadPodsRestarted(status.SadPods)
+	default:
+		return false
+	}
+}
+
 func (c *Controller) cleanUpReleasesForApplication(app *shipper.Application, releases []*shipper.Release) error {
 	var completedReleases []*shipper.Release
 
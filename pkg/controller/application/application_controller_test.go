@@ -9,6 +9,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubetesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/record"
@@ -71,7 +72,7 @@ func TestHashReleaseEnv(t *testing.T) {
 	}
 
 	distinctApp := newApplication(testAppName)
-	distinctApp.Spec.Template.Strategy = &shipper.RolloutStrategy{}
+	distinctApp.Spec.Template.Strategy = &shipper.RolloutStrategySpec{}
 	distinctHash := hashReleaseEnvironment(distinctApp.Spec.Template)
 	if distinctHash == appHash {
 		t.Errorf("two different environments hashed to the same thing: %q", distinctHash)
@@ -140,6 +141,75 @@ func TestCreateFirstRelease(t *testing.T) {
 	f.run()
 }
 
+func TestCreateFirstReleaseWithStrategyRef(t *testing.T) {
+	f := newFixture(t)
+	app := newApplication(testAppName)
+	app.Spec.Template.Strategy = nil
+	app.Spec.Template.StrategyRef = &shipper.RolloutStrategyReference{Name: "vanguard"}
+
+	rs := &shipper.RolloutStrategy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "vanguard",
+		},
+		Spec: *vanguard.DeepCopy(),
+	}
+
+	f.objects = append(f.objects, app, rs)
+	expectedApp := app.DeepCopy()
+	expectedApp.Annotations[shipper.AppHighestObservedGenerationAnnotation] = "0"
+	apputil.UpdateChartNameAnnotation(expectedApp, "simple")
+	apputil.UpdateChartVersionRawAnnotation(expectedApp, "0.0.1")
+	apputil.UpdateChartVersionResolvedAnnotation(expectedApp, "0.0.1")
+
+	envHash := hashReleaseEnvironment(expectedApp.Spec.Template)
+	expectedRelName := fmt.Sprintf("%s-%s-0", testAppName, envHash)
+
+	expectedApp.Status.Conditions = []shipper.ApplicationCondition{
+		{
+			Type:   shipper.ApplicationConditionTypeAborting,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeBlocked,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeReleaseSynced,
+			Status: corev1.ConditionTrue,
+		},
+		{
+			Type:    shipper.ApplicationConditionTypeRollingOut,
+			Status:  corev1.ConditionTrue,
+			Message: fmt.Sprintf(InitialReleaseMessageFormat, expectedRelName),
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeValidHistory,
+			Status: corev1.ConditionTrue,
+		},
+	}
+	expectedApp.Status.History = []string{expectedRelName}
+
+	// The release is expected to carry a copy of the referenced strategy,
+	// so later changes to the RolloutStrategy object do not affect
+	// releases that are already rolling out.
+	expectedRelease := newRelease(expectedRelName, expectedApp)
+	expectedRelease.Spec.Environment.Strategy = vanguard.DeepCopy()
+	expectedRelease.Labels[shipper.ReleaseEnvironmentHashLabel] = envHash
+	expectedRelease.Annotations[shipper.ReleaseTemplateIterationAnnotation] = "0"
+	expectedRelease.Annotations[shipper.ReleaseGenerationAnnotation] = "0"
+	expectedRelease.Annotations[shipper.RolloutBlocksOverrideAnnotation] = ""
+
+	f.expectReleaseCreate(expectedRelease)
+	f.expectApplicationUpdate(expectedApp)
+
+	f.expectedEvents = []string{
+		fmt.Sprintf(`Normal ApplicationConditionChanged [] -> [Aborting False], [] -> [ValidHistory True], [] -> [ReleaseSynced True], [] -> [RollingOut True Rolling out initial release "%s"]`, expectedRelease.Name),
+		"Normal ApplicationConditionChanged [] -> [Blocked False]",
+	}
+
+	f.run()
+}
+
 func TestCreateFirstReleaseWithChartVersionResolve(t *testing.T) {
 	f := newFixture(t)
 	app := newApplication(testAppName)
@@ -1019,6 +1089,451 @@ func TestStateRollingOut(t *testing.T) {
 	f.run()
 }
 
+func buildStuckRollout(stuckFor time.Duration) (*shipper.Application, *shipper.Release, *shipper.Release, *shipper.CapacityTarget) {
+	app := newApplication(testAppName)
+	app.Annotations[shipper.AppHighestObservedGenerationAnnotation] = "1"
+	app.Spec.AutoRollback = &shipper.ApplicationAutoRollback{
+		Deadline: metav1.Duration{Duration: 10 * time.Minute},
+	}
+
+	envHash := hashReleaseEnvironment(app.Spec.Template)
+	incumbentName := fmt.Sprintf("%s-%s-0", testAppName, envHash)
+	contenderName := fmt.Sprintf("%s-%s-1", testAppName, envHash)
+	app.Status.History = []string{incumbentName, contenderName}
+
+	incumbent := newRelease(incumbentName, app)
+	incumbent.Annotations[shipper.ReleaseGenerationAnnotation] = "0"
+	incumbent.Spec.Environment.ClusterRequirements = shipper.ClusterRequirements{
+		Regions: []shipper.RegionRequirement{{Name: "bar"}},
+	}
+	incumbent.Status.Conditions = []shipper.ReleaseCondition{
+		{Type: shipper.ReleaseConditionTypeComplete, Status: corev1.ConditionTrue},
+	}
+
+	contender := newRelease(contenderName, app)
+	contender.Annotations[shipper.ReleaseGenerationAnnotation] = "1"
+	contender.Spec.TargetStep = 1
+	contender.Status.AchievedStep = &shipper.AchievedStep{
+		Step: 0,
+		Name: contender.Spec.Environment.Strategy.Steps[0].Name,
+	}
+
+	ct := &shipper.CapacityTarget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      contenderName,
+			Namespace: app.Namespace,
+			Labels: map[string]string{
+				shipper.AppLabel:     testAppName,
+				shipper.ReleaseLabel: contenderName,
+			},
+		},
+		Status: shipper.CapacityTargetStatus{
+			Clusters: []shipper.ClusterCapacityStatus{
+				{
+					Name: shippertesting.TestCluster,
+					SadPods: []shipper.PodStatus{
+						{
+							Name: "test-pod",
+							Containers: []corev1.ContainerStatus{
+								{
+									Name: "app",
+									State: corev1.ContainerState{
+										Waiting: &corev1.ContainerStateWaiting{
+											Reason: "CrashLoopBackOff",
+										},
+									},
+									RestartCount: 3,
+								},
+							},
+						},
+					},
+					Conditions: []shipper.ClusterCapacityCondition{
+						{
+							Type:               shipper.ClusterConditionTypeReady,
+							Status:             corev1.ConditionFalse,
+							LastTransitionTime: metav1.NewTime(time.Now().Add(-stuckFor)),
+							Reason:             "PodsNotReady",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return app, incumbent, contender, ct
+}
+
+func TestAutoRollbackStuckContender(t *testing.T) {
+	f := newFixture(t)
+
+	app, incumbent, contender, ct := buildStuckRollout(time.Hour)
+	f.objects = append(f.objects, app, incumbent, contender, ct)
+
+	expectedApp := app.DeepCopy()
+	apputil.UpdateChartNameAnnotation(expectedApp, "simple")
+	apputil.UpdateChartVersionRawAnnotation(expectedApp, "0.0.1")
+	apputil.UpdateChartVersionResolvedAnnotation(expectedApp, "0.0.1")
+	// Should have overwritten the template with the incumbent's one.
+	expectedApp.Spec.Template = incumbent.Spec.Environment
+	expectedApp.Status.History = []string{incumbent.Name}
+
+	msg := fmt.Sprintf(
+		`release %q failed to achieve capacity for more than 10m0s, rolling back to release %q: %s: PodsNotReady 1x"app" containers with [CrashLoopBackOff]`,
+		contender.Name, incumbent.Name, shippertesting.TestCluster)
+
+	expectedApp.Status.Conditions = []shipper.ApplicationCondition{
+		{
+			Type:    shipper.ApplicationConditionTypeAborting,
+			Status:  corev1.ConditionTrue,
+			Reason:  conditions.ContenderStuck,
+			Message: msg,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeBlocked,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeRollingOut,
+			Status: corev1.ConditionTrue,
+		},
+	}
+
+	f.expectReleaseDelete(contender)
+	f.expectApplicationUpdate(expectedApp)
+
+	f.expectedEvents = []string{
+		fmt.Sprintf("Warning ReleaseRolledBack %s", msg),
+		fmt.Sprintf("Normal ApplicationConditionChanged [] -> [Blocked False], [] -> [Aborting True %s %s], [] -> [RollingOut True]", conditions.ContenderStuck, msg),
+	}
+
+	f.run()
+}
+
+// TestAutoRollbackStrategyRef verifies that rolling back an application that
+// refers to a RolloutStrategy leaves it with just the reference in its
+// template, as it would otherwise fail validation.
+func TestAutoRollbackStrategyRef(t *testing.T) {
+	f := newFixture(t)
+
+	app, incumbent, contender, ct := buildStuckRollout(time.Hour)
+	strategyRef := &shipper.RolloutStrategyReference{Name: "vanguard"}
+	app.Spec.Template.Strategy = nil
+	app.Spec.Template.StrategyRef = strategyRef
+	for _, rel := range []*shipper.Release{incumbent, contender} {
+		rel.Spec.Environment.StrategyRef = strategyRef
+	}
+	f.objects = append(f.objects, app, incumbent, contender, ct)
+
+	expectedApp := app.DeepCopy()
+	apputil.UpdateChartNameAnnotation(expectedApp, "simple")
+	apputil.UpdateChartVersionRawAnnotation(expectedApp, "0.0.1")
+	apputil.UpdateChartVersionResolvedAnnotation(expectedApp, "0.0.1")
+	expectedApp.Spec.Template = *incumbent.Spec.Environment.DeepCopy()
+	expectedApp.Spec.Template.Strategy = nil
+	expectedApp.Status.History = []string{incumbent.Name}
+
+	msg := fmt.Sprintf(
+		`release %q failed to achieve capacity for more than 10m0s, rolling back to release %q: %s: PodsNotReady 1x"app" containers with [CrashLoopBackOff]`,
+		contender.Name, incumbent.Name, shippertesting.TestCluster)
+
+	expectedApp.Status.Conditions = []shipper.ApplicationCondition{
+		{
+			Type:    shipper.ApplicationConditionTypeAborting,
+			Status:  corev1.ConditionTrue,
+			Reason:  conditions.ContenderStuck,
+			Message: msg,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeBlocked,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeRollingOut,
+			Status: corev1.ConditionTrue,
+		},
+	}
+
+	f.expectReleaseDelete(contender)
+	f.expectApplicationUpdate(expectedApp)
+
+	f.expectedEvents = []string{
+		fmt.Sprintf("Warning ReleaseRolledBack %s", msg),
+		fmt.Sprintf("Normal ApplicationConditionChanged [] -> [Blocked False], [] -> [Aborting True %s %s], [] -> [RollingOut True]", conditions.ContenderStuck, msg),
+	}
+
+	f.run()
+
+	for _, action := range f.client.Actions() {
+		update, ok := action.(kubetesting.UpdateAction)
+		if !ok {
+			continue
+		}
+
+		updated, ok := update.GetObject().(*shipper.Application)
+		if !ok {
+			continue
+		}
+
+		// This is what the validating webhook and the CRD schema
+		// require of an Application template.
+		template := updated.Spec.Template
+		if (template.Strategy == nil) == (template.StrategyRef == nil) {
+			t.Errorf("expected updated Application to have exactly one of strategy and strategyRef, got %v and %v",
+				template.Strategy, template.StrategyRef)
+		}
+	}
+}
+
+func TestAbortContenderFailedAnalysis(t *testing.T) {
+	f := newFixture(t)
+
+	// Failed analysis aborts the contender whether or not the
+	// application opted into automatic rollbacks.
+	app, incumbent, contender, _ := buildStuckRollout(0)
+	app.Spec.AutoRollback = nil
+	analysisMsg := fmt.Sprintf("release %q has failed analysis: error-rate=0.5 (threshold 0.1)", contender.Name)
+	contender.Status.Conditions = []shipper.ReleaseCondition{
+		{
+			Type:    shipper.ReleaseConditionTypeAnalysisFailed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "AnalysisFailed",
+			Message: analysisMsg,
+		},
+	}
+	f.objects = append(f.objects, app, incumbent, contender)
+
+	expectedApp := app.DeepCopy()
+	apputil.UpdateChartNameAnnotation(expectedApp, "simple")
+	apputil.UpdateChartVersionRawAnnotation(expectedApp, "0.0.1")
+	apputil.UpdateChartVersionResolvedAnnotation(expectedApp, "0.0.1")
+	// Should have overwritten the template with the incumbent's one.
+	expectedApp.Spec.Template = incumbent.Spec.Environment
+	expectedApp.Status.History = []string{incumbent.Name}
+
+	msg := fmt.Sprintf(
+		"release %q failed analysis, rolling back to release %q: %s",
+		contender.Name, incumbent.Name, analysisMsg)
+
+	expectedApp.Status.Conditions = []shipper.ApplicationCondition{
+		{
+			Type:    shipper.ApplicationConditionTypeAborting,
+			Status:  corev1.ConditionTrue,
+			Reason:  conditions.ContenderAnalysisFailed,
+			Message: msg,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeBlocked,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeRollingOut,
+			Status: corev1.ConditionTrue,
+		},
+	}
+
+	// The template now matches the incumbent, so there must be no
+	// new release, only the contender going away.
+	f.expectReleaseDelete(contender)
+	f.expectApplicationUpdate(expectedApp)
+
+	f.expectedEvents = []string{
+		fmt.Sprintf("Warning ReleaseRolledBack %s", msg),
+		fmt.Sprintf("Normal ApplicationConditionChanged [] -> [Blocked False], [] -> [Aborting True %s %s], [] -> [RollingOut True]", conditions.ContenderAnalysisFailed, msg),
+	}
+
+	f.run()
+
+	for _, action := range f.client.Actions() {
+		if action.Matches("create", "releases") {
+			t.Fatalf("expected no release to be created, got %v", action)
+		}
+	}
+}
+
+func TestAutoRollbackWaitsForDeadline(t *testing.T) {
+	f := newFixture(t)
+
+	app, incumbent, contender, ct := buildStuckRollout(time.Minute)
+	f.objects = append(f.objects, app, incumbent, contender, ct)
+
+	expectedApp := app.DeepCopy()
+	apputil.UpdateChartNameAnnotation(expectedApp, "simple")
+	apputil.UpdateChartVersionRawAnnotation(expectedApp, "0.0.1")
+	apputil.UpdateChartVersionResolvedAnnotation(expectedApp, "0.0.1")
+
+	expectedApp.Status.Conditions = []shipper.ApplicationCondition{
+		{
+			Type:   shipper.ApplicationConditionTypeAborting,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeBlocked,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeReleaseSynced,
+			Status: corev1.ConditionTrue,
+		},
+		{
+			Type:    shipper.ApplicationConditionTypeRollingOut,
+			Status:  corev1.ConditionTrue,
+			Message: fmt.Sprintf(TransitioningMessageFormat, incumbent.Name, contender.Name),
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeValidHistory,
+			Status: corev1.ConditionTrue,
+		},
+	}
+
+	f.expectApplicationUpdate(expectedApp)
+
+	f.expectedEvents = []string{
+		fmt.Sprintf(`Normal ApplicationConditionChanged [] -> [Aborting False], [] -> [ValidHistory True], [] -> [ReleaseSynced True], [] -> [RollingOut True Transitioning from "%s" to "%s"]`, incumbent.Name, contender.Name),
+		"Normal ApplicationConditionChanged [] -> [Blocked False]",
+	}
+
+	f.run()
+}
+
+// TestAutoRollbackSparesSlowScaleUp verifies that a contender whose pods are
+// just slow to become ready, without any of them restarting, doesn't get
+// rolled back for having been not ready for longer than the deadline.
+func TestAutoRollbackSparesSlowScaleUp(t *testing.T) {
+	f := newFixture(t)
+
+	app, incumbent, contender, ct := buildStuckRollout(time.Hour)
+	ct.Status.Clusters[0].AvailableReplicas = 5
+	ct.Status.Clusters[0].SadPods[0].Containers[0] = corev1.ContainerStatus{
+		Name: "app",
+		State: corev1.ContainerState{
+			Waiting: &corev1.ContainerStateWaiting{
+				Reason: "ContainerCreating",
+			},
+		},
+	}
+	f.objects = append(f.objects, app, incumbent, contender, ct)
+
+	expectedApp := app.DeepCopy()
+	apputil.UpdateChartNameAnnotation(expectedApp, "simple")
+	apputil.UpdateChartVersionRawAnnotation(expectedApp, "0.0.1")
+	apputil.UpdateChartVersionResolvedAnnotation(expectedApp, "0.0.1")
+
+	expectedApp.Status.Conditions = []shipper.ApplicationCondition{
+		{
+			Type:   shipper.ApplicationConditionTypeAborting,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeBlocked,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeReleaseSynced,
+			Status: corev1.ConditionTrue,
+		},
+		{
+			Type:    shipper.ApplicationConditionTypeRollingOut,
+			Status:  corev1.ConditionTrue,
+			Message: fmt.Sprintf(TransitioningMessageFormat, incumbent.Name, contender.Name),
+		},
+		{
+			Type:   shipper.ApplicationConditionTypeValidHistory,
+			Status: corev1.ConditionTrue,
+		},
+	}
+
+	f.expectApplicationUpdate(expectedApp)
+
+	f.expectedEvents = []string{
+		fmt.Sprintf(`Normal ApplicationConditionChanged [] -> [Aborting False], [] -> [ValidHistory True], [] -> [ReleaseSynced True], [] -> [RollingOut True Transitioning from "%s" to "%s"]`, incumbent.Name, contender.Name),
+		"Normal ApplicationConditionChanged [] -> [Blocked False]",
+	}
+
+	f.run()
+}
+
+// TestStuckClustersSlowScaleUp verifies that the deadline of a contender that
+// is slowly scaling up starts over whenever more of its replicas become
+// available, and that it runs out once they stop doing so.
+func TestStuckClustersSlowScaleUp(t *testing.T) {
+	_, _, _, ct := buildStuckRollout(time.Hour)
+	status := &ct.Status.Clusters[0]
+	status.SadPods[0].Containers[0].RestartCount = 0
+	status.AvailableReplicas = 1
+
+	const deadline = 10 * time.Minute
+
+	progress := newCapacityProgressTracker()
+	c := &Controller{capacityProgress: progress}
+
+	// The first time we see the cluster counts as progress.
+	progress.now = func() time.Time { return time.Now().Add(-20 * time.Minute) }
+	stuck, _ := c.stuckClusters(ct, deadline)
+	if len(stuck) != 1 {
+		t.Fatalf("expected cluster without progress for 20m to be stuck, got %v", stuck)
+	}
+
+	progress.now = time.Now
+	status.AvailableReplicas = 2
+	stuck, requeueAfter := c.stuckClusters(ct, deadline)
+	if len(stuck) != 0 {
+		t.Fatalf("expected cluster that just made progress not to be stuck, got %v", stuck)
+	}
+	if requeueAfter <= 0 || requeueAfter > deadline {
+		t.Fatalf("expected to be requeued within %s, got %s", deadline, requeueAfter)
+	}
+
+	// Pods restarting means the cluster cannot make progress anymore, so
+	// it's been failing ever since it stopped being ready.
+	status.SadPods[0].Containers[0].RestartCount = 1
+	stuck, _ = c.stuckClusters(ct, deadline)
+	if len(stuck) != 1 {
+		t.Fatalf("expected cluster with restarting pods to be stuck, got %v", stuck)
+	}
+}
+
+// TestCapacityTargetReadinessEnqueuesApp verifies that the application of a
+// contender gets looked at again when its capacity target becomes ready or
+// stops being ready in a cluster, and only then.
+func TestCapacityTargetReadinessEnqueuesApp(t *testing.T) {
+	_, _, _, ct := buildStuckRollout(0)
+
+	notReady := ct.DeepCopy()
+	notReady.Status.Clusters[0].SadPods = nil
+
+	ready := ct.DeepCopy()
+	ready.Status.Clusters[0].Conditions[0].Status = corev1.ConditionTrue
+	ready.Status.Clusters[0].Conditions[0].Reason = ""
+
+	tests := []struct {
+		name     string
+		old, new *shipper.CapacityTarget
+		expected int
+	}{
+		{"unchanged readiness", ct, notReady, 0},
+		{"becomes ready", ct, ready, 1},
+		{"stops being ready", ready, ct, 1},
+	}
+
+	for _, tt := range tests {
+		c, _ := newFixture(t).newController()
+		c.enqueueAppFromCapacityTarget(tt.old, tt.new)
+
+		if n := c.workqueue.Len(); n != tt.expected {
+			t.Errorf("%s: expected %d applications in the queue, got %d", tt.name, tt.expected, n)
+			continue
+		}
+
+		if tt.expected > 0 {
+			key, _ := c.workqueue.Get()
+			expectedKey := fmt.Sprintf("%s/%s", ct.Namespace, ct.Labels[shipper.AppLabel])
+			if key != expectedKey {
+				t.Errorf("%s: expected application %q in the queue, got %q", tt.name, expectedKey, key)
+			}
+		}
+	}
+}
+
 // If a release which is not installed is in the app history and it's not the
 // latest release, it should be nuked.
 func TestDeletingAbortedReleases(t *testing.T) {
@@ -1145,22 +1660,22 @@ func newRelease(releaseName string, app *shipper.Application) *shipper.Release {
 	}
 }
 
-var vanguard = shipper.RolloutStrategy{
+var vanguard = shipper.RolloutStrategySpec{
 	Steps: []shipper.RolloutStrategyStep{
 		{
 			Name:     "staging",
-			Capacity: shipper.RolloutStrategyStepValue{Incumbent: 100, Contender: 1},
-			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 100, Contender: 0},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(1)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(0)},
 		},
 		{
 			Name:     "50/50",
-			Capacity: shipper.RolloutStrategyStepValue{Incumbent: 50, Contender: 50},
-			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 50, Contender: 50},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(50), Contender: intstr.FromInt(50)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(50), Contender: intstr.FromInt(50)},
 		},
 		{
 			Name:     "full on",
-			Capacity: shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
-			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
 		},
 	},
 }
@@ -58,6 +58,17 @@ func (c *Controller) createReleaseForApplication(app *shipper.Application, relea
 	}
 	newRelease.Spec.Environment.Chart.Version = cv.Version
 
+	// Releases get a copy of the referenced strategy so that they keep
+	// rolling out the same way regardless of what happens to it later.
+	if ref := newRelease.Spec.Environment.StrategyRef; ref != nil {
+		rs, err := c.rsLister.Get(ref.Name)
+		if err != nil {
+			return nil, shippererrors.NewKubeclientGetError("", ref.Name, err).
+				WithShipperKind("RolloutStrategy")
+		}
+		newRelease.Spec.Environment.Strategy = rs.Spec.DeepCopy()
+	}
+
 	klog.V(4).Infof("Release %q labels: %v", controller.MetaKey(newRelease), newRelease.Labels)
 	klog.V(4).Infof("Release %q annotations: %v", controller.MetaKey(newRelease), newRelease.Annotations)
 
@@ -116,9 +127,9 @@ func identicalEnvironments(envs ...shipper.ReleaseEnvironment) bool {
 		return true
 	}
 
-	referenceHash := hashReleaseEnvironment(envs[0])
+	referenceHash := hashReleaseEnvironment(unresolvedEnvironment(envs[0]))
 	for _, env := range envs[1:] {
-		currentHash := hashReleaseEnvironment(env)
+		currentHash := hashReleaseEnvironment(unresolvedEnvironment(env))
 		klog.V(4).Infof("Comparing ReleaseEnvironments: %q vs %q", referenceHash, currentHash)
 
 		if referenceHash != currentHash {
@@ -128,6 +139,15 @@ func identicalEnvironments(envs ...shipper.ReleaseEnvironment) bool {
 	return true
 }
 
+// unresolvedEnvironment returns env as it would look in an Application
+// template, that is, without the strategy resolved from a reference.
+func unresolvedEnvironment(env shipper.ReleaseEnvironment) shipper.ReleaseEnvironment {
+	if env.StrategyRef != nil {
+		env.Strategy = nil
+	}
+	return env
+}
+
 func hashReleaseEnvironment(env shipper.ReleaseEnvironment) string {
 	copy := env.DeepCopy()
 	b, err := json.Marshal(copy)
@@ -0,0 +1,81 @@
+package application
+
+import (
+	"sync"
+	"time"
+)
+
+// capacityProgress is how many replicas of a release were available in a
+// cluster the last time that number went up, and when that was.
+type capacityProgress struct {
+	availableReplicas int32
+	since             time.Time
+}
+
+// capacityProgressTracker keeps track of when contenders that are slowly
+// scaling up last had more of their replicas become available in each
+// cluster, so they only get rolled back once they stop making progress. It is
+// shared by all the workers of the controller.
+type capacityProgressTracker struct {
+	mu       sync.Mutex
+	progress map[string]map[string]capacityProgress
+	now      func() time.Time
+}
+
+func newCapacityProgressTracker() *capacityProgressTracker {
+	return &capacityProgressTracker{
+		progress: make(map[string]map[string]capacityProgress),
+		now:      time.Now,
+	}
+}
+
+// observe records that availableReplicas replicas of the capacity target key
+// are available in cluster, and returns when that number last went up. The
+// first time a cluster is observed counts as progress, as we can't know how
+// long it has been scaling up for.
+func (t *capacityProgressTracker) observe(key, cluster string, availableReplicas int32) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clusters, ok := t.progress[key]
+	if !ok {
+		clusters = make(map[string]capacityProgress)
+		t.progress[key] = clusters
+	}
+
+	p, ok := clusters[cluster]
+	if !ok || availableReplicas > p.availableReplicas {
+		p = capacityProgress{
+			availableReplicas: availableReplicas,
+			since:             t.now(),
+		}
+		clusters[cluster] = p
+	}
+
+	return p.since
+}
+
+// forgetCluster stops keeping track of cluster for the capacity target key,
+// so that the next time it scales up slowly it gets a fresh start.
+func (t *capacityProgressTracker) forgetCluster(key, cluster string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clusters, ok := t.progress[key]
+	if !ok {
+		return
+	}
+
+	delete(clusters, cluster)
+	if len(clusters) == 0 {
+		delete(t.progress, key)
+	}
+}
+
+// forget stops keeping track of all the clusters of the capacity target key.
+func (t *capacityProgressTracker) forget(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.progress, key)
+}
@@ -0,0 +1,106 @@
+package capacity
+
+import (
+	"fmt"
+	"strconv"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	"github.com/bookingcom/shipper/pkg/util/replicas"
+)
+
+func (c *Controller) enqueueCapacityTargetFromHorizontalPodAutoscaler(obj interface{}) {
+	hpa, ok := obj.(*autoscalingv1.HorizontalPodAutoscaler)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("not a HorizontalPodAutoscaler: %#v", obj))
+		return
+	}
+
+	c.enqueueCapacityTargetFromReleaseObject(hpa)
+}
+
+// getHorizontalPodAutoscaler returns the HorizontalPodAutoscaler scaling a
+// release's workload, or nil if it isn't autoscaled.
+func getHorizontalPodAutoscaler(
+	informerFactory kubeinformers.SharedInformerFactory,
+	workload *workload,
+	selector labels.Selector,
+) (*autoscalingv1.HorizontalPodAutoscaler, error) {
+	namespace := workload.object.GetNamespace()
+	hpas, err := informerFactory.Autoscaling().V1().HorizontalPodAutoscalers().
+		Lister().HorizontalPodAutoscalers(namespace).List(selector)
+	if err != nil {
+		return nil, shippererrors.NewKubeclientListError(
+			autoscalingv1.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"),
+			namespace, selector, err)
+	}
+
+	for _, hpa := range hpas {
+		ref := hpa.Spec.ScaleTargetRef
+		if ref.Kind == workload.gvk.Kind && ref.Name == workload.object.GetName() {
+			return hpa, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// autoscaledReplicaRange returns the minReplicas and maxReplicas an
+// autoscaled workload gets when it's meant to have desiredReplicas out of
+// spec.TotalReplicaCount, which comes from the maxReplicas in the chart.
+// minReplicas is scaled down in the same proportion, but never below 1, as
+// HorizontalPodAutoscalers don't allow for it.
+func autoscaledReplicaRange(
+	hpa *autoscalingv1.HorizontalPodAutoscaler,
+	spec *shipper.ClusterCapacityTarget,
+	desiredReplicas int32,
+) (int32, int32) {
+	chartMinReplicas := 1
+	if v, ok := hpa.Annotations[shipper.HPAMinReplicasAnnotation]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			chartMinReplicas = n
+		}
+	}
+
+	percent := float64(desiredReplicas) / float64(spec.TotalReplicaCount) * 100
+	minReplicas := int32(replicas.CalculateDesiredReplicaCount(uint(chartMinReplicas), percent))
+	if minReplicas < 1 {
+		minReplicas = 1
+	} else if minReplicas > desiredReplicas {
+		minReplicas = desiredReplicas
+	}
+
+	return minReplicas, desiredReplicas
+}
+
+func (c *Controller) patchHorizontalPodAutoscalerWithReplicaRange(
+	hpa *autoscalingv1.HorizontalPodAutoscaler,
+	clusterName string,
+	minReplicas, maxReplicas int32,
+) (*autoscalingv1.HorizontalPodAutoscaler, error) {
+	appClientset, err := c.store.GetApplicationClusterClientset(clusterName, AgentName)
+	if err != nil {
+		return nil, err
+	}
+	targetClusterClient := appClientset.GetKubeClient()
+
+	patch := []byte(fmt.Sprintf(
+		`{"spec": {"minReplicas": %d, "maxReplicas": %d}}`,
+		minReplicas, maxReplicas))
+
+	updatedHPA, err := targetClusterClient.AutoscalingV1().
+		HorizontalPodAutoscalers(hpa.Namespace).
+		Patch(hpa.Name, types.StrategicMergePatchType, patch)
+	if err != nil {
+		return nil, shippererrors.NewKubeclientUpdateError(hpa, err).
+			WithKind(autoscalingv1.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"))
+	}
+
+	return updatedHPA, nil
+}
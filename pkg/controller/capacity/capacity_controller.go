@@ -8,11 +8,10 @@ import (
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubeinformers "k8s.io/client-go/informers"
@@ -31,7 +30,6 @@ import (
 	clusterstatusutil "github.com/bookingcom/shipper/pkg/util/clusterstatus"
 	diffutil "github.com/bookingcom/shipper/pkg/util/diff"
 	"github.com/bookingcom/shipper/pkg/util/filters"
-	"github.com/bookingcom/shipper/pkg/util/replicas"
 	targetutil "github.com/bookingcom/shipper/pkg/util/target"
 	shipperworkqueue "github.com/bookingcom/shipper/pkg/workqueue"
 )
@@ -40,14 +38,19 @@ const (
 	AgentName   = "capacity-controller"
 	SadPodLimit = 5
 
-	ClustersNotReady = "ClustersNotReady"
-	InProgress       = "InProgress"
-	InternalError    = "InternalError"
-	PodsNotReady     = "PodsNotReady"
-	DeploymentStuck  = "DeploymentStuck"
+	ClustersNotReady  = "ClustersNotReady"
+	InProgress        = "InProgress"
+	InternalError     = "InternalError"
+	PodsNotReady      = capacityutil.PodsNotReady
+	DeploymentStuck   = capacityutil.DeploymentStuck
+	QuotaInsufficient = capacityutil.QuotaInsufficient
 
 	CapacityTargetConditionChanged  = "CapacityTargetConditionChanged"
 	ClusterCapacityConditionChanged = "ClusterCapacityConditionChanged"
+
+	// genericWorkloadResyncInterval is how often we look at workloads we
+	// can't watch through an informer until they're ready.
+	genericWorkloadResyncInterval = 10 * time.Second
 )
 
 // Controller is the controller implementation for CapacityTarget resources
@@ -61,6 +64,13 @@ type Controller struct {
 	releasesLister       listers.ReleaseLister
 	releasesListerSynced cache.InformerSynced
 
+	clustersLister listers.ClusterLister
+	clustersSynced cache.InformerSynced
+
+	dynamicClientBuilder clusterclientstore.DynamicClientBuilderFunc
+
+	podLogs podLogsFunc
+
 	workqueue workqueue.RateLimitingInterface
 	recorder  record.EventRecorder
 }
@@ -70,13 +80,17 @@ func NewController(
 	clientset shipperclient.Interface,
 	shipperInformerFactory informers.SharedInformerFactory,
 	store clusterclientstore.Interface,
+	dynamicClientBuilder clusterclientstore.DynamicClientBuilderFunc,
 	recorder record.EventRecorder,
+	sadPodLogs bool,
 ) *Controller {
 
 	capacityTargetInformer := shipperInformerFactory.Shipper().V1alpha1().CapacityTargets()
 
 	releaseInformer := shipperInformerFactory.Shipper().V1alpha1().Releases()
 
+	clusterInformer := shipperInformerFactory.Shipper().V1alpha1().Clusters()
+
 	controller := &Controller{
 		clientset:             clientset,
 		store:                 store,
@@ -84,10 +98,17 @@ func NewController(
 		capacityTargetsSynced: capacityTargetInformer.Informer().HasSynced,
 		releasesLister:        releaseInformer.Lister(),
 		releasesListerSynced:  releaseInformer.Informer().HasSynced,
+		clustersLister:        clusterInformer.Lister(),
+		clustersSynced:        clusterInformer.Informer().HasSynced,
+		dynamicClientBuilder:  dynamicClientBuilder,
 		workqueue:             workqueue.NewNamedRateLimitingQueue(shipperworkqueue.NewDefaultControllerRateLimiter(), "capacity_controller_capacitytargets"),
 		recorder:              recorder,
 	}
 
+	if sadPodLogs {
+		controller.podLogs = getPodLogs
+	}
+
 	klog.Info("Setting up event handlers")
 	capacityTargetInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: controller.enqueueCapacityTarget,
@@ -96,8 +117,8 @@ func NewController(
 		},
 	})
 
-	store.AddSubscriptionCallback(controller.subscribeToDeployments)
-	store.AddEventHandlerCallback(controller.registerDeploymentEventHandlers)
+	store.AddSubscriptionCallback(controller.subscribeToWorkloads)
+	store.AddEventHandlerCallback(controller.registerWorkloadEventHandlers)
 
 	return controller
 }
@@ -113,7 +134,7 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) {
 	klog.V(2).Info("Starting Capacity controller")
 	defer klog.V(2).Info("Shutting down Capacity controller")
 
-	if !cache.WaitForCacheSync(stopCh, c.capacityTargetsSynced, c.releasesListerSynced) {
+	if !cache.WaitForCacheSync(stopCh, c.capacityTargetsSynced, c.releasesListerSynced, c.clustersSynced) {
 		runtime.HandleError(fmt.Errorf("failed to wait for caches to sync"))
 		return
 	}
@@ -204,9 +225,7 @@ func (c *Controller) processCapacityTargetOnCluster(
 		c.reportConditionChange(ct, ClusterCapacityConditionChanged, diff)
 	}()
 
-	appName := ct.Labels[shipper.AppLabel]
-	release := ct.Labels[shipper.ReleaseLabel]
-	deployment, pods, err := c.getClusterObjects(spec.Name, ct.Namespace, appName, release)
+	workload, hpa, pods, err := c.getClusterObjects(ct, spec.Name)
 	if err != nil {
 		operationalCond = capacityutil.NewClusterCapacityCondition(
 			shipper.ClusterConditionTypeOperational,
@@ -224,11 +243,77 @@ func (c *Controller) processCapacityTargetOnCluster(
 		"")
 
 	// availableReplicas will be used by the defer at the top of this func
-	availableReplicas = deployment.Status.AvailableReplicas
+	availableReplicas = workload.availableReplicas
+
+	// Workloads scaled by a HorizontalPodAutoscaler get a range of
+	// replicas instead of a fixed number of them, and we leave it to the
+	// autoscaler to pick the right one within it. HPAs don't act on
+	// workloads with no replicas, though, so we scale those ourselves.
+	desiredReplicas := capacityutil.DesiredReplicaCount(*spec)
+	minReplicas, maxReplicas := desiredReplicas, desiredReplicas
+	if hpa != nil && desiredReplicas > 0 {
+		minReplicas, maxReplicas = autoscaledReplicaRange(hpa, spec, desiredReplicas)
+
+		if hpa.Spec.MinReplicas == nil || *hpa.Spec.MinReplicas != minReplicas || hpa.Spec.MaxReplicas != maxReplicas {
+			quotaCond, err := c.quotaInsufficientCondition(spec.Name, workload, minReplicas)
+			if err != nil {
+				readyCond = capacityutil.NewClusterCapacityCondition(
+					shipper.ClusterConditionTypeReady,
+					corev1.ConditionFalse,
+					InternalError,
+					err.Error(),
+				)
+				return err
+			} else if quotaCond != nil {
+				readyCond = quotaCond
+				return nil
+			}
+
+			_, err = c.patchHorizontalPodAutoscalerWithReplicaRange(hpa, spec.Name, minReplicas, maxReplicas)
+			if err != nil {
+				readyCond = capacityutil.NewClusterCapacityCondition(
+					shipper.ClusterConditionTypeReady,
+					corev1.ConditionFalse,
+					InternalError,
+					err.Error(),
+				)
+				return err
+			} else {
+				readyCond = capacityutil.NewClusterCapacityCondition(
+					shipper.ClusterConditionTypeReady,
+					corev1.ConditionFalse,
+					InProgress,
+					"",
+				)
+				return nil
+			}
+		}
+	}
+
+	if workload.replicas == nil || *workload.replicas < minReplicas || *workload.replicas > maxReplicas {
+		replicaCount := minReplicas
+		if workload.replicas != nil && *workload.replicas > maxReplicas {
+			replicaCount = maxReplicas
+		}
 
-	desiredReplicas := int32(replicas.CalculateDesiredReplicaCount(uint(spec.TotalReplicaCount), float64(spec.Percent)))
-	if deployment.Spec.Replicas == nil || desiredReplicas != *deployment.Spec.Replicas {
-		_, err = c.patchDeploymentWithReplicaCount(deployment, spec.Name, desiredReplicas)
+		// Pods that don't fit in the namespace's quota would never be
+		// created, so we hold off scaling up until there's room for
+		// them, and say why.
+		quotaCond, err := c.quotaInsufficientCondition(spec.Name, workload, replicaCount)
+		if err != nil {
+			readyCond = capacityutil.NewClusterCapacityCondition(
+				shipper.ClusterConditionTypeReady,
+				corev1.ConditionFalse,
+				InternalError,
+				err.Error(),
+			)
+			return err
+		} else if quotaCond != nil {
+			readyCond = quotaCond
+			return nil
+		}
+
+		err = c.patchWorkloadWithReplicaCount(workload, spec.Name, replicaCount)
 		if err != nil {
 			readyCond = capacityutil.NewClusterCapacityCondition(
 				shipper.ClusterConditionTypeReady,
@@ -248,10 +333,14 @@ func (c *Controller) processCapacityTargetOnCluster(
 		}
 	}
 
-	// Deployment was successfully updated, but the update hasn't been
-	// observed by the deployment controller yet, so our change is still in
-	// flight, and we can't trust the status yet.
-	if deployment.Generation > deployment.Status.ObservedGeneration {
+	// From here on out, the number of replicas we want is whatever the
+	// workload asks for, as it's within the range we want.
+	desiredReplicas = *workload.replicas
+
+	// The workload was successfully updated, but the update hasn't been
+	// observed by its controller yet, so our change is still in flight,
+	// and we can't trust the status yet.
+	if workload.generation > workload.observedGeneration {
 		readyCond = capacityutil.NewClusterCapacityCondition(
 			shipper.ClusterConditionTypeReady,
 			corev1.ConditionFalse,
@@ -264,7 +353,7 @@ func (c *Controller) processCapacityTargetOnCluster(
 
 	// If the number of available replicas matches what we want, the
 	// CapacityTarget is Ready and there's nothing left to check.
-	if replicas.AchievedDesiredReplicaPercentage(spec.TotalReplicaCount, availableReplicas, spec.Percent) {
+	if availableReplicas == desiredReplicas {
 		readyCond = capacityutil.NewClusterCapacityCondition(
 			shipper.ClusterConditionTypeReady,
 			corev1.ConditionTrue,
@@ -285,28 +374,17 @@ func (c *Controller) processCapacityTargetOnCluster(
 		sadPods = sadPods[:SadPodLimit]
 	}
 
-	replicaFailureCond := getDeploymentCondition(deployment.Status, appsv1.DeploymentReplicaFailure)
-	progressingCond := getDeploymentCondition(deployment.Status, appsv1.DeploymentProgressing)
+	c.diagnoseSadPods(spec.Name, ct.Namespace, sadPods, status.SadPods)
 
 	var msg, reason string
 
-	if replicaFailureCond != nil && replicaFailureCond.Status == corev1.ConditionTrue {
-		// It is common for a Deployment to get stuck because of exceeded
-		// quotas. Looking at the ReplicaFailure condition exposes that
-		// condition, and potentially others too.
-		reason = DeploymentStuck
-		msg = replicaFailureCond.Message
-	} else if progressingCond != nil && progressingCond.Status == corev1.ConditionFalse {
-		// If the Deployment has a timeout defined, and exceeds it,
-		// Progressing becomes False. Note that True doesn't *actually*
-		// mean the rollout is still progressing, for our definition of
-		// progressing.
+	if workload.stuckMessage != "" {
 		reason = DeploymentStuck
-		msg = progressingCond.Message
+		msg = workload.stuckMessage
 	} else if l := len(sadPods); l > 0 {
 		// We ran out of conditions to look at, but we have pods that
 		// aren't Ready, so that's one reason to be concerned.
-		summary := summarizeSadPods(sadPods)
+		summary := capacityutil.SummarizeSadPods(sadPods)
 		reason = PodsNotReady
 		msg = fmt.Sprintf(
 			"%d/%d: %s",
@@ -357,6 +435,13 @@ func (c *Controller) capacityTargetSyncHandler(key string) error {
 		}
 	}
 
+	// We don't get to hear from workloads of arbitrary kinds when they
+	// change, so we keep checking on them for as long as they aren't
+	// ready.
+	if ready, _ := targetutil.IsReady(ct.Status.Conditions); err == nil && ct.Spec.Workload != nil && !ready {
+		c.enqueueCapacityTargetAfter(ct, genericWorkloadResyncInterval)
+	}
+
 	return err
 }
 
@@ -428,7 +513,19 @@ func (c *Controller) enqueueCapacityTarget(obj interface{}) {
 	c.workqueue.Add(key)
 }
 
-func (c *Controller) registerDeploymentEventHandlers(informerFactory kubeinformers.SharedInformerFactory, clusterName string) {
+// enqueueCapacityTargetAfter puts a CapacityTarget back in the work queue
+// after the given duration.
+func (c *Controller) enqueueCapacityTargetAfter(obj interface{}, duration time.Duration) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+
+	c.workqueue.AddAfter(key, duration)
+}
+
+func (c *Controller) registerWorkloadEventHandlers(informerFactory kubeinformers.SharedInformerFactory, clusterName string) {
 	handler := cache.FilteringResourceEventHandler{
 		FilterFunc: filters.BelongsToRelease,
 		Handler: cache.ResourceEventHandlerFuncs{
@@ -440,72 +537,123 @@ func (c *Controller) registerDeploymentEventHandlers(informerFactory kubeinforme
 		},
 	}
 	informerFactory.Apps().V1().Deployments().Informer().AddEventHandler(handler)
+
+	informerFactory.Apps().V1().StatefulSets().Informer().AddEventHandler(
+		cache.FilteringResourceEventHandler{
+			FilterFunc: filters.BelongsToRelease,
+			Handler: cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.enqueueCapacityTargetFromStatefulSet,
+				DeleteFunc: c.enqueueCapacityTargetFromStatefulSet,
+				UpdateFunc: func(oldObj, newObj interface{}) {
+					c.enqueueCapacityTargetFromStatefulSet(newObj)
+				},
+			},
+		})
+
+	quotaHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueueCapacityTargetsHeldBackByQuota,
+		DeleteFunc: c.enqueueCapacityTargetsHeldBackByQuota,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueCapacityTargetsHeldBackByQuota(newObj)
+		},
+	}
+	informerFactory.Core().V1().ResourceQuotas().Informer().AddEventHandler(quotaHandler)
+	informerFactory.Core().V1().LimitRanges().Informer().AddEventHandler(quotaHandler)
+
+	informerFactory.Autoscaling().V1().HorizontalPodAutoscalers().Informer().AddEventHandler(
+		cache.FilteringResourceEventHandler{
+			FilterFunc: filters.BelongsToRelease,
+			Handler: cache.ResourceEventHandlerFuncs{
+				AddFunc: c.enqueueCapacityTargetFromHorizontalPodAutoscaler,
+				UpdateFunc: func(oldObj, newObj interface{}) {
+					c.enqueueCapacityTargetFromHorizontalPodAutoscaler(newObj)
+				},
+			},
+		})
 }
 
-func (c *Controller) subscribeToDeployments(informerFactory kubeinformers.SharedInformerFactory) {
+func (c *Controller) subscribeToWorkloads(informerFactory kubeinformers.SharedInformerFactory) {
 	informerFactory.Apps().V1().Deployments().Informer()
+	informerFactory.Apps().V1().StatefulSets().Informer()
 	informerFactory.Core().V1().Pods().Informer()
+	informerFactory.Autoscaling().V1().HorizontalPodAutoscalers().Informer()
+	informerFactory.Core().V1().ResourceQuotas().Informer()
+	informerFactory.Core().V1().LimitRanges().Informer()
+	podWarningEventInformer(informerFactory)
 }
 
-func (c Controller) getClusterObjects(clusterName, ns, appName, release string) (*appsv1.Deployment, []*corev1.Pod, error) {
+func (c Controller) getClusterObjects(ct *shipper.CapacityTarget, clusterName string) (*workload, *autoscalingv1.HorizontalPodAutoscaler, []*corev1.Pod, error) {
 	appClientset, err := c.store.GetApplicationClusterClientset(clusterName, AgentName)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	informerFactory := appClientset.GetKubeInformerFactory()
 
-	deploymentSelector := labels.Set{
-		shipper.AppLabel:     appName,
-		shipper.ReleaseLabel: release,
+	ns := ct.Namespace
+	workloadSelector := labels.Set{
+		shipper.AppLabel:     ct.Labels[shipper.AppLabel],
+		shipper.ReleaseLabel: ct.Labels[shipper.ReleaseLabel],
 	}.AsSelector()
-	deploymentGVK := corev1.SchemeGroupVersion.WithKind("Deployment")
-	deployments, err := informerFactory.Apps().V1().Deployments().
-		Lister().Deployments(ns).List(deploymentSelector)
-	if err != nil {
-		return nil, nil, shippererrors.NewKubeclientListError(
-			deploymentGVK, ns, deploymentSelector, err)
-	}
 
-	if l := len(deployments); l != 1 {
-		return nil, nil, shippererrors.NewUnexpectedObjectCountFromSelectorError(
-			deploymentSelector, deploymentGVK, 1, l)
+	var workload *workload
+	if ref := ct.Spec.Workload; ref != nil {
+		workload, err = c.getGenericWorkload(clusterName, appClientset, ref, ns, workloadSelector)
+	} else {
+		workload, err = getAppsWorkload(informerFactory, ns, workloadSelector)
+	}
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	deployment := deployments[0]
-
-	podSelector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	hpa, err := getHorizontalPodAutoscaler(informerFactory, workload, workloadSelector)
 	if err != nil {
-		return nil, nil, shippererrors.NewUnrecoverableError(fmt.Errorf("failed to transform label selector %v into a selector: %s", deployment.Spec.Selector, err))
+		return nil, nil, nil, err
 	}
 
 	pods, err := informerFactory.Core().V1().Pods().Lister().
-		Pods(deployment.Namespace).List(podSelector)
+		Pods(ns).List(workload.selector)
 	if err != nil {
-		return nil, nil, shippererrors.NewKubeclientListError(
+		return nil, nil, nil, shippererrors.NewKubeclientListError(
 			corev1.SchemeGroupVersion.WithKind("Pod"),
-			deployment.Namespace, podSelector, err)
+			ns, workload.selector, err)
 	}
 
-	return deployment, pods, nil
+	return workload, hpa, pods, nil
 }
 
-func (c *Controller) patchDeploymentWithReplicaCount(deployment *appsv1.Deployment, clusterName string, replicaCount int32) (*appsv1.Deployment, error) {
-	appClientset, err := c.store.GetApplicationClusterClientset(clusterName, AgentName)
+// getAppsWorkload returns the workload of a release that runs its pods
+// either in a Deployment or in a StatefulSet, but never in both.
+func getAppsWorkload(
+	informerFactory kubeinformers.SharedInformerFactory,
+	ns string,
+	selector labels.Selector,
+) (*workload, error) {
+	deployments, err := informerFactory.Apps().V1().Deployments().
+		Lister().Deployments(ns).List(selector)
 	if err != nil {
-		return nil, err
+		return nil, shippererrors.NewKubeclientListError(
+			deploymentGVK, ns, selector, err)
 	}
-	targetClusterClient := appClientset.GetKubeClient()
-
-	patch := []byte(fmt.Sprintf(`{"spec": {"replicas": %d}}`, replicaCount))
 
-	updatedDeployment, err := targetClusterClient.AppsV1().
-		Deployments(deployment.Namespace).
-		Patch(deployment.Name, types.StrategicMergePatchType, patch)
+	statefulSets, err := informerFactory.Apps().V1().StatefulSets().
+		Lister().StatefulSets(ns).List(selector)
 	if err != nil {
-		return nil, shippererrors.NewKubeclientUpdateError(deployment, err)
+		return nil, shippererrors.NewKubeclientListError(
+			statefulSetGVK, ns, selector, err)
+	}
+
+	switch {
+	case len(deployments) == 1 && len(statefulSets) == 0:
+		return newDeploymentWorkload(deployments[0])
+	case len(deployments) == 0 && len(statefulSets) == 1:
+		return newStatefulSetWorkload(statefulSets[0])
+	case len(statefulSets) == 0:
+		return nil, shippererrors.NewUnexpectedObjectCountFromSelectorError(
+			selector, deploymentGVK, 1, len(deployments))
+	default:
+		return nil, shippererrors.NewUnexpectedObjectCountFromSelectorError(
+			selector, statefulSetGVK, 1, len(deployments)+len(statefulSets))
 	}
-
-	return updatedDeployment, nil
 }
 
 func (c *Controller) reportConditionChange(ct *shipper.CapacityTarget, reason string, diff diffutil.Diff) {
@@ -3,12 +3,20 @@ package capacity
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 
 	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
 	shippertesting "github.com/bookingcom/shipper/pkg/testing"
@@ -22,6 +30,11 @@ const (
 	ctName   = "foobar"
 )
 
+var (
+	widgetGVK = schema.GroupVersionKind{Group: "widgets.example.com", Version: "v1", Kind: "Widget"}
+	widgetGVR = widgetGVK.GroupVersion().WithResource("widgets")
+)
+
 type capacityTargetTestExpectation struct {
 	capacityTarget    *shipper.CapacityTarget
 	status            shipper.CapacityTargetStatus
@@ -230,6 +243,622 @@ func TestCapacityShiftingSadPods(t *testing.T) {
 	)
 }
 
+// TestAutoscaledDeployment verifies that the capacity controller scales the
+// HorizontalPodAutoscaler of a Deployment instead of the Deployment itself,
+// only stepping in to get it out of zero replicas.
+func TestAutoscaledDeployment(t *testing.T) {
+	totalReplicaCount := int32(10)
+	ct := buildCapacityTarget(shippertesting.TestApp, ctName, []shipper.ClusterCapacityTarget{
+		{
+			Name:              clusterA,
+			Percent:           50,
+			TotalReplicaCount: totalReplicaCount,
+		},
+	})
+
+	// The chart asks for 4 to 10 replicas, so half the capacity is 2 to 5
+	// of them.
+	expectedMinReplicas, expectedMaxReplicas := int32(2), int32(5)
+	deployment := buildDeployment(shippertesting.TestApp, ctName, 0, expectedMinReplicas)
+	hpa := buildHorizontalPodAutoscaler(deployment, 4, 4, totalReplicaCount)
+
+	f := shippertesting.NewControllerTestFixture()
+	cluster := f.AddNamedCluster(clusterA)
+	cluster.AddMany([]runtime.Object{deployment, hpa})
+	f.ShipperClient.Tracker().Add(ct)
+
+	runController(f)
+
+	ctGVR := shipper.SchemeGroupVersion.WithResource("capacitytargets")
+	object, err := f.ShipperClient.Tracker().Get(ctGVR, ct.Namespace, ct.Name)
+	if err != nil {
+		t.Fatalf("could not Get CapacityTarget %q: %s", ct.Name, err)
+	}
+
+	expectedStatus := shipper.CapacityTargetStatus{
+		Clusters: []shipper.ClusterCapacityStatus{
+			{
+				Name:              clusterA,
+				AchievedPercent:   20,
+				AvailableReplicas: expectedMinReplicas,
+				Conditions: []shipper.ClusterCapacityCondition{
+					ClusterCapacityOperational,
+					ClusterCapacityReady,
+				},
+			},
+		},
+		Conditions: []shipper.TargetCondition{
+			TargetConditionOperational,
+			TargetConditionReady,
+		},
+	}
+
+	eq, diff := shippertesting.DeepEqualDiff(expectedStatus, object.(*shipper.CapacityTarget).Status)
+	if !eq {
+		t.Errorf("CapacityTarget %q has Status different from expected:\n%s", ct.Name, diff)
+	}
+
+	assertDeploymentReplicas(t, ct, cluster, expectedMinReplicas)
+
+	hpaGVR := autoscalingv1.SchemeGroupVersion.WithResource("horizontalpodautoscalers")
+	object, err = cluster.Client.Tracker().Get(hpaGVR, hpa.Namespace, hpa.Name)
+	if err != nil {
+		t.Fatalf("could not Get HorizontalPodAutoscaler %q: %s", hpa.Name, err)
+	}
+	hpa = object.(*autoscalingv1.HorizontalPodAutoscaler)
+
+	if *hpa.Spec.MinReplicas != expectedMinReplicas || hpa.Spec.MaxReplicas != expectedMaxReplicas {
+		t.Errorf("expected HorizontalPodAutoscaler %q to scale between %d and %d replicas, got %d and %d",
+			hpa.Name, expectedMinReplicas, expectedMaxReplicas, *hpa.Spec.MinReplicas, hpa.Spec.MaxReplicas)
+	}
+}
+
+// TestStatefulSet verifies that the capacity controller scales StatefulSets
+// just like it does Deployments, and reports their sad pods.
+func TestStatefulSet(t *testing.T) {
+	totalReplicaCount := int32(3)
+	readyReplicaCount := int32(2)
+	ct := buildCapacityTarget(shippertesting.TestApp, ctName, []shipper.ClusterCapacityTarget{
+		{
+			Name:              clusterA,
+			Percent:           100,
+			TotalReplicaCount: totalReplicaCount,
+		},
+	})
+
+	statefulSet := buildStatefulSet(shippertesting.TestApp, ctName, 0, readyReplicaCount)
+	sadPod := buildSadPod(statefulSet.Namespace, statefulSet.Name, statefulSet.Spec.Selector)
+
+	f := shippertesting.NewControllerTestFixture()
+	cluster := f.AddNamedCluster(clusterA)
+	cluster.AddMany([]runtime.Object{statefulSet, sadPod})
+	f.ShipperClient.Tracker().Add(ct)
+
+	runController(f)
+
+	ctGVR := shipper.SchemeGroupVersion.WithResource("capacitytargets")
+	object, err := f.ShipperClient.Tracker().Get(ctGVR, ct.Namespace, ct.Name)
+	if err != nil {
+		t.Fatalf("could not Get CapacityTarget %q: %s", ct.Name, err)
+	}
+
+	msg := `1/3: 1x"app" containers with [ExpectedFail]`
+	expectedStatus := shipper.CapacityTargetStatus{
+		Clusters: []shipper.ClusterCapacityStatus{
+			{
+				Name:              clusterA,
+				AchievedPercent:   67,
+				AvailableReplicas: readyReplicaCount,
+				Conditions: []shipper.ClusterCapacityCondition{
+					ClusterCapacityOperational,
+					{
+						Type:    shipper.ClusterConditionTypeReady,
+						Status:  corev1.ConditionFalse,
+						Reason:  PodsNotReady,
+						Message: msg,
+					},
+				},
+				SadPods: []shipper.PodStatus{
+					{
+						Name:       sadPod.Name,
+						Condition:  sadPod.Status.Conditions[0],
+						Containers: sadPod.Status.ContainerStatuses,
+					},
+				},
+			},
+		},
+		Conditions: []shipper.TargetCondition{
+			TargetConditionOperational,
+			{
+				Type:    shipper.TargetConditionTypeReady,
+				Status:  corev1.ConditionFalse,
+				Reason:  ClustersNotReady,
+				Message: fmt.Sprintf(`%s: PodsNotReady %s`, clusterA, msg),
+			},
+		},
+	}
+
+	eq, diff := shippertesting.DeepEqualDiff(expectedStatus, object.(*shipper.CapacityTarget).Status)
+	if !eq {
+		t.Errorf("CapacityTarget %q has Status different from expected:\n%s", ct.Name, diff)
+	}
+
+	statefulSetGVR := appsv1.SchemeGroupVersion.WithResource("statefulsets")
+	object, err = cluster.Client.Tracker().Get(statefulSetGVR, statefulSet.Namespace, statefulSet.Name)
+	if err != nil {
+		t.Fatalf("could not Get StatefulSet %q: %s", statefulSet.Name, err)
+	}
+
+	if replicas := *object.(*appsv1.StatefulSet).Spec.Replicas; replicas != totalReplicaCount {
+		t.Errorf("expected StatefulSet %q to have %d replicas, got %d", statefulSet.Name, totalReplicaCount, replicas)
+	}
+}
+
+// TestGenericWorkload verifies that the capacity controller scales workloads
+// of kinds it knows nothing about through their scale subresource, and
+// reports their readiness from wherever they say it is.
+func TestGenericWorkload(t *testing.T) {
+	totalReplicaCount := int32(3)
+	readyReplicaCount := int32(2)
+	ct := buildCapacityTarget(shippertesting.TestApp, ctName, []shipper.ClusterCapacityTarget{
+		{
+			Name:              clusterA,
+			Percent:           100,
+			TotalReplicaCount: totalReplicaCount,
+		},
+	})
+	ct.Spec.Workload = &shipper.WorkloadReference{
+		APIVersion: widgetGVK.GroupVersion().String(),
+		Kind:       widgetGVK.Kind,
+	}
+
+	tests := []struct {
+		name     string
+		replicas int32
+		status   shipper.ClusterCapacityStatus
+	}{
+		{
+			name:     "scales up the workload",
+			replicas: 0,
+			status: shipper.ClusterCapacityStatus{
+				Name:              clusterA,
+				AchievedPercent:   67,
+				AvailableReplicas: readyReplicaCount,
+				Conditions: []shipper.ClusterCapacityCondition{
+					ClusterCapacityOperational,
+					{
+						Type:   shipper.ClusterConditionTypeReady,
+						Status: corev1.ConditionFalse,
+						Reason: InProgress,
+					},
+				},
+			},
+		},
+		{
+			name:     "reads ready replicas from annotated path",
+			replicas: totalReplicaCount,
+			status: shipper.ClusterCapacityStatus{
+				Name:              clusterA,
+				AchievedPercent:   67,
+				AvailableReplicas: readyReplicaCount,
+				Conditions: []shipper.ClusterCapacityCondition{
+					ClusterCapacityOperational,
+					{
+						Type:   shipper.ClusterConditionTypeReady,
+						Status: corev1.ConditionFalse,
+						Reason: InProgress,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			widget := buildWidget(shippertesting.TestApp, ctName, tt.replicas, readyReplicaCount)
+
+			f := shippertesting.NewControllerTestFixture()
+			cluster := f.AddNamedCluster(clusterA)
+			cluster.InitializeDynamicClient([]runtime.Object{widget})
+			cluster.InitializeDiscovery([]*metav1.APIResourceList{
+				{
+					GroupVersion: widgetGVK.GroupVersion().String(),
+					APIResources: []metav1.APIResource{
+						{Name: widgetGVR.Resource, Namespaced: true, Kind: widgetGVK.Kind},
+						{Name: widgetGVR.Resource + "/scale", Namespaced: true, Kind: "Scale"},
+					},
+				},
+			})
+			f.ShipperClient.Tracker().Add(&shipper.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: clusterA},
+			})
+			f.ShipperClient.Tracker().Add(ct.DeepCopy())
+
+			runController(f)
+
+			ctGVR := shipper.SchemeGroupVersion.WithResource("capacitytargets")
+			object, err := f.ShipperClient.Tracker().Get(ctGVR, ct.Namespace, ct.Name)
+			if err != nil {
+				t.Fatalf("could not Get CapacityTarget %q: %s", ct.Name, err)
+			}
+
+			clusters := object.(*shipper.CapacityTarget).Status.Clusters
+			eq, diff := shippertesting.DeepEqualDiff([]shipper.ClusterCapacityStatus{tt.status}, clusters)
+			if !eq {
+				t.Errorf("CapacityTarget %q has cluster statuses different from expected:\n%s", ct.Name, diff)
+			}
+
+			w, err := cluster.DynamicClient.Resource(widgetGVR).Namespace(widget.GetNamespace()).
+				Get(widget.GetName(), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("could not Get Widget %q: %s", widget.GetName(), err)
+			}
+
+			replicas, _, _ := unstructured.NestedInt64(w.Object, "spec", "replicas")
+			if replicas != int64(totalReplicaCount) {
+				t.Errorf("expected Widget %q to have %d replicas, got %d", widget.GetName(), totalReplicaCount, replicas)
+			}
+		})
+	}
+}
+
+// TestSadPodDiagnostics verifies that the capacity controller reports why sad
+// pods are sad: how their containers last terminated, with the end of their
+// logs, and recent Warning events about them.
+func TestSadPodDiagnostics(t *testing.T) {
+	ct := buildCapacityTarget(shippertesting.TestApp, ctName, []shipper.ClusterCapacityTarget{
+		{
+			Name:              clusterA,
+			Percent:           100,
+			TotalReplicaCount: 1,
+		},
+	})
+
+	deployment := buildDeployment(shippertesting.TestApp, ctName, 1, 0)
+	sadPod := buildSadPodForDeployment(deployment)
+	sadPod.Status.ContainerStatuses[0].LastTerminationState = corev1.ContainerState{
+		Terminated: &corev1.ContainerStateTerminated{
+			Reason:   "OOMKilled",
+			ExitCode: 137,
+		},
+	}
+
+	buildEvent := func(name, involvedObject, eventType, reason string, minutesAgo int) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: sadPod.Namespace,
+				Name:      name,
+			},
+			InvolvedObject: corev1.ObjectReference{
+				Kind:      "Pod",
+				Namespace: sadPod.Namespace,
+				Name:      involvedObject,
+			},
+			Type:          eventType,
+			Reason:        reason,
+			Message:       fmt.Sprintf("%s happened", reason),
+			Count:         1,
+			LastTimestamp: metav1.NewTime(time.Now().Add(-time.Duration(minutesAgo) * time.Minute)),
+		}
+	}
+
+	f := shippertesting.NewControllerTestFixture()
+	cluster := f.AddNamedCluster(clusterA)
+	cluster.AddMany([]runtime.Object{
+		deployment,
+		sadPod,
+		buildEvent("scheduling", sadPod.Name, corev1.EventTypeWarning, "FailedScheduling", 10),
+		buildEvent("backoff", sadPod.Name, corev1.EventTypeWarning, "BackOff", 1),
+		buildEvent("pulled", sadPod.Name, corev1.EventTypeNormal, "Pulled", 2),
+		buildEvent("someone-else", "another-pod", corev1.EventTypeWarning, "FailedMount", 1),
+	})
+	f.ShipperClient.Tracker().Add(ct)
+
+	log := strings.Repeat("x", SadPodLogLimitBytes) + "out of memory"
+	var logOpts *corev1.PodLogOptions
+
+	controller := newTestController(f)
+	controller.podLogs = func(client kubernetes.Interface, namespace, name string, opts *corev1.PodLogOptions) ([]byte, error) {
+		logOpts = opts
+		return []byte(log), nil
+	}
+
+	runTestController(f, controller)
+
+	ctGVR := shipper.SchemeGroupVersion.WithResource("capacitytargets")
+	object, err := f.ShipperClient.Tracker().Get(ctGVR, ct.Namespace, ct.Name)
+	if err != nil {
+		t.Fatalf("could not Get CapacityTarget %q: %s", ct.Name, err)
+	}
+
+	clusters := object.(*shipper.CapacityTarget).Status.Clusters
+	if len(clusters) != 1 || len(clusters[0].SadPods) != 1 {
+		t.Fatalf("expected exactly one sad pod in cluster %q, got status %v", clusterA, clusters)
+	}
+
+	expectedTerminations := []shipper.ContainerTermination{
+		{
+			Container: "app",
+			Reason:    "OOMKilled",
+			ExitCode:  137,
+			LogTail:   log[len(log)-SadPodLogLimitBytes:],
+		},
+	}
+	expectedEvents := []shipper.PodEvent{
+		{Reason: "BackOff", Message: "BackOff happened", Count: 1},
+		{Reason: "FailedScheduling", Message: "FailedScheduling happened", Count: 1},
+	}
+
+	pod := clusters[0].SadPods[0]
+	if eq, diff := shippertesting.DeepEqualDiff(expectedTerminations, pod.Terminations); !eq {
+		t.Errorf("sad pod has terminations different from expected:\n%s", diff)
+	}
+	if eq, diff := shippertesting.DeepEqualDiff(expectedEvents, pod.Events); !eq {
+		t.Errorf("sad pod has events different from expected:\n%s", diff)
+	}
+
+	if logOpts == nil || !logOpts.Previous || logOpts.Container != "app" {
+		t.Errorf("expected to fetch the previous log of container %q, got options %v", "app", logOpts)
+	}
+}
+
+// TestSadPodDiagnosticsWithoutRestarts verifies that the capacity controller
+// keeps the terminations it already reported for a sad pod instead of
+// fetching them again, as long as the pod's containers haven't restarted.
+func TestSadPodDiagnosticsWithoutRestarts(t *testing.T) {
+	ct := buildCapacityTarget(shippertesting.TestApp, ctName, []shipper.ClusterCapacityTarget{
+		{
+			Name:              clusterA,
+			Percent:           100,
+			TotalReplicaCount: 1,
+		},
+	})
+
+	deployment := buildDeployment(shippertesting.TestApp, ctName, 1, 0)
+	sadPod := buildSadPodForDeployment(deployment)
+	sadPod.Status.ContainerStatuses[0].RestartCount = 3
+	sadPod.Status.ContainerStatuses[0].LastTerminationState = corev1.ContainerState{
+		Terminated: &corev1.ContainerStateTerminated{
+			Reason:   "Error",
+			ExitCode: 1,
+		},
+	}
+
+	previousTerminations := []shipper.ContainerTermination{
+		{
+			Container: "app",
+			Reason:    "Error",
+			ExitCode:  1,
+			LogTail:   "connection refused",
+		},
+	}
+	ct.Status.Clusters = []shipper.ClusterCapacityStatus{
+		{
+			Name: clusterA,
+			SadPods: []shipper.PodStatus{
+				{
+					Name:         sadPod.Name,
+					Containers:   sadPod.Status.ContainerStatuses,
+					Terminations: previousTerminations,
+				},
+			},
+		},
+	}
+
+	f := shippertesting.NewControllerTestFixture()
+	cluster := f.AddNamedCluster(clusterA)
+	cluster.AddMany([]runtime.Object{deployment, sadPod})
+	f.ShipperClient.Tracker().Add(ct)
+
+	controller := newTestController(f)
+	controller.podLogs = func(client kubernetes.Interface, namespace, name string, opts *corev1.PodLogOptions) ([]byte, error) {
+		t.Errorf("expected not to fetch logs of pod %q again, but did", name)
+		return nil, nil
+	}
+
+	runTestController(f, controller)
+
+	ctGVR := shipper.SchemeGroupVersion.WithResource("capacitytargets")
+	object, err := f.ShipperClient.Tracker().Get(ctGVR, ct.Namespace, ct.Name)
+	if err != nil {
+		t.Fatalf("could not Get CapacityTarget %q: %s", ct.Name, err)
+	}
+
+	clusters := object.(*shipper.CapacityTarget).Status.Clusters
+	if len(clusters) != 1 || len(clusters[0].SadPods) != 1 {
+		t.Fatalf("expected exactly one sad pod in cluster %q, got status %v", clusterA, clusters)
+	}
+
+	pod := clusters[0].SadPods[0]
+	if eq, diff := shippertesting.DeepEqualDiff(previousTerminations, pod.Terminations); !eq {
+		t.Errorf("sad pod has terminations different from expected:\n%s", diff)
+	}
+}
+
+// TestQuotaInsufficient verifies that the capacity controller doesn't scale
+// up workloads whose pods would not fit in the ResourceQuotas or LimitRanges
+// of their namespace, and says why.
+func TestQuotaInsufficient(t *testing.T) {
+	buildQuota := func(hard, used string) *corev1.ResourceQuota {
+		return &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: shippertesting.TestNamespace,
+				Name:      "compute",
+			},
+			Status: corev1.ResourceQuotaStatus{
+				Hard: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse(hard)},
+				Used: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse(used)},
+			},
+		}
+	}
+
+	buildLimitRange := func(item corev1.LimitRangeItem) *corev1.LimitRange {
+		return &corev1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: shippertesting.TestNamespace,
+				Name:      "limits",
+			},
+			Spec: corev1.LimitRangeSpec{
+				Limits: []corev1.LimitRangeItem{item},
+			},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		requests         corev1.ResourceList
+		objects          []runtime.Object
+		expectedReplicas int32
+		expectedReason   string
+		expectedMessage  string
+	}{
+		{
+			name:             "enough room in quota",
+			requests:         corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")},
+			objects:          []runtime.Object{buildQuota("1", "500m")},
+			expectedReplicas: 2,
+			expectedReason:   InProgress,
+		},
+		{
+			name:             "not enough room in quota",
+			requests:         corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+			objects:          []runtime.Object{buildQuota("1", "500m")},
+			expectedReplicas: 0,
+			expectedReason:   QuotaInsufficient,
+			expectedMessage:  `2 more pods need 1 of requests.cpu, but ResourceQuota "compute" only has 500m left`,
+		},
+		{
+			name:     "default requests from limit range",
+			requests: nil,
+			objects: []runtime.Object{
+				buildQuota("1", "500m"),
+				buildLimitRange(corev1.LimitRangeItem{
+					Type:           corev1.LimitTypeContainer,
+					DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("300m")},
+				}),
+			},
+			expectedReplicas: 0,
+			expectedReason:   QuotaInsufficient,
+			expectedMessage:  `2 more pods need 600m of requests.cpu, but ResourceQuota "compute" only has 500m left`,
+		},
+		{
+			name:             "no requests in quota that requires them",
+			requests:         nil,
+			objects:          []runtime.Object{buildQuota("1", "0")},
+			expectedReplicas: 0,
+			expectedReason:   QuotaInsufficient,
+			expectedMessage:  `pods don't set requests.cpu, which ResourceQuota "compute" requires`,
+		},
+		{
+			name:     "over limit range max",
+			requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			objects: []runtime.Object{
+				buildLimitRange(corev1.LimitRangeItem{
+					Type: corev1.LimitTypeContainer,
+					Max:  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+				}),
+			},
+			expectedReplicas: 0,
+			expectedReason:   QuotaInsufficient,
+			expectedMessage:  `container "app" asks for more cpu than LimitRange "limits" allows`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ct := buildCapacityTarget(shippertesting.TestApp, ctName, []shipper.ClusterCapacityTarget{
+				{
+					Name:              clusterA,
+					Percent:           100,
+					TotalReplicaCount: 2,
+				},
+			})
+
+			deployment := buildDeployment(shippertesting.TestApp, ctName, 0, 0)
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{
+				{
+					Name:      "app",
+					Resources: corev1.ResourceRequirements{Requests: tt.requests},
+				},
+			}
+
+			f := shippertesting.NewControllerTestFixture()
+			cluster := f.AddNamedCluster(clusterA)
+			cluster.AddMany(append([]runtime.Object{deployment}, tt.objects...))
+			f.ShipperClient.Tracker().Add(ct)
+
+			runController(f)
+
+			ctGVR := shipper.SchemeGroupVersion.WithResource("capacitytargets")
+			object, err := f.ShipperClient.Tracker().Get(ctGVR, ct.Namespace, ct.Name)
+			if err != nil {
+				t.Fatalf("could not Get CapacityTarget %q: %s", ct.Name, err)
+			}
+
+			clusters := object.(*shipper.CapacityTarget).Status.Clusters
+			if len(clusters) != 1 {
+				t.Fatalf("expected exactly one cluster status, got %v", clusters)
+			}
+
+			cond := capacityutil.GetClusterCapacityCondition(clusters[0], shipper.ClusterConditionTypeReady)
+			if cond == nil || cond.Reason != tt.expectedReason || cond.Message != tt.expectedMessage {
+				t.Errorf("expected Ready condition with reason %q and message %q, got %v",
+					tt.expectedReason, tt.expectedMessage, cond)
+			}
+
+			assertDeploymentReplicas(t, ct, cluster, tt.expectedReplicas)
+		})
+	}
+}
+
+// TestQuotaDeletionTombstone verifies that capacity targets held back by quota
+// get another chance when a ResourceQuota is deleted, even if we only learn
+// about it through a tombstone.
+func TestQuotaDeletionTombstone(t *testing.T) {
+	ct := buildCapacityTarget(shippertesting.TestApp, ctName, []shipper.ClusterCapacityTarget{
+		{
+			Name:              clusterA,
+			Percent:           100,
+			TotalReplicaCount: 2,
+		},
+	})
+	ct.Status.Clusters = []shipper.ClusterCapacityStatus{
+		{
+			Name: clusterA,
+			Conditions: []shipper.ClusterCapacityCondition{
+				{
+					Type:   shipper.ClusterConditionTypeReady,
+					Status: corev1.ConditionFalse,
+					Reason: QuotaInsufficient,
+				},
+			},
+		},
+	}
+
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: shippertesting.TestNamespace,
+			Name:      "compute",
+		},
+	}
+
+	f := shippertesting.NewControllerTestFixture()
+	controller := newTestController(f)
+
+	informer := f.ShipperInformerFactory.Shipper().V1alpha1().CapacityTargets().Informer()
+	informer.GetIndexer().Add(ct)
+
+	controller.enqueueCapacityTargetsHeldBackByQuota(cache.DeletedFinalStateUnknown{
+		Key: fmt.Sprintf("%s/%s", quota.Namespace, quota.Name),
+		Obj: quota,
+	})
+
+	if controller.workqueue.Len() != 1 {
+		t.Fatalf("expected capacity target %q to be enqueued, got %d items in the work queue",
+			ct.Name, controller.workqueue.Len())
+	}
+}
+
 func runCapacityControllerTest(
 	t *testing.T,
 	objectsByCluster map[string][]runtime.Object,
@@ -304,14 +933,22 @@ func assertDeploymentReplicas(
 	}
 }
 
-func runController(f *shippertesting.ControllerTestFixture) {
-	controller := NewController(
+func newTestController(f *shippertesting.ControllerTestFixture) *Controller {
+	return NewController(
 		f.ShipperClient,
 		f.ShipperInformerFactory,
 		f.ClusterClientStore,
+		f.DynamicClientBuilder,
 		f.Recorder,
+		false,
 	)
+}
+
+func runController(f *shippertesting.ControllerTestFixture) {
+	runTestController(f, newTestController(f))
+}
 
+func runTestController(f *shippertesting.ControllerTestFixture, controller *Controller) {
 	stopCh := make(chan struct{})
 	defer close(stopCh)
 
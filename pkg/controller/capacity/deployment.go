@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"math"
 	"sort"
-	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/runtime"
 
@@ -22,16 +22,20 @@ func (c *Controller) enqueueCapacityTargetFromDeployment(obj interface{}) {
 		return
 	}
 
+	c.enqueueCapacityTargetFromReleaseObject(deployment)
+}
+
+func (c *Controller) enqueueCapacityTargetFromReleaseObject(obj metav1.Object) {
 	// Using ReleaseLabel here instead of the full set of Deployment labels because
 	// we can't guarantee that there isn't extra stuff there that was put directly
 	// in the chart.
 	// Also not using ObjectReference here because it would go over cluster
 	// boundaries. While technically it's probably ok, I feel like it'd be abusing
 	// the feature.
-	rel := deployment.GetLabels()[shipper.ReleaseLabel]
-	ct, err := c.getCapacityTargetForReleaseAndNamespace(rel, deployment.GetNamespace())
+	rel := obj.GetLabels()[shipper.ReleaseLabel]
+	ct, err := c.getCapacityTargetForReleaseAndNamespace(rel, obj.GetNamespace())
 	if err != nil {
-		runtime.HandleError(fmt.Errorf("cannot get capacity target for release '%s/%s': %#v", rel, deployment.GetNamespace(), err))
+		runtime.HandleError(fmt.Errorf("cannot get capacity target for release '%s/%s': %#v", rel, obj.GetNamespace(), err))
 		return
 	}
 
@@ -95,64 +99,3 @@ func (c Controller) calculatePercentageFromAmount(total, amount int32) int32 {
 
 	return int32(math.Ceil(result))
 }
-
-type sadContainerSummary struct {
-	pods    int
-	reasons map[string]struct{}
-}
-
-func summarizeSadPods(sadPods []shipper.PodStatus) string {
-	summary := make(map[string]*sadContainerSummary)
-
-	for _, sadPod := range sadPods {
-		summarizeContainers(sadPod.InitContainers, summary)
-		summarizeContainers(sadPod.Containers, summary)
-	}
-
-	containers := make([]string, 0, len(summary))
-	for c, _ := range summary {
-		containers = append(containers, c)
-	}
-
-	sort.Strings(containers)
-
-	summaryStrs := make([]string, 0, len(summary))
-	for _, container := range containers {
-		summary := summary[container]
-		reasons := make([]string, 0, len(summary.reasons))
-		for r, _ := range summary.reasons {
-			reasons = append(reasons, r)
-		}
-
-		sort.Strings(reasons)
-
-		summaryStrs = append(summaryStrs, fmt.Sprintf("%dx%q containers with %v", summary.pods, container, reasons))
-	}
-
-	return strings.Join(summaryStrs, "; ")
-}
-
-func summarizeContainers(containers []corev1.ContainerStatus, summary map[string]*sadContainerSummary) {
-	for _, container := range containers {
-		if container.Ready {
-			continue
-		}
-
-		sadContainer, ok := summary[container.Name]
-		if !ok {
-			sadContainer = &sadContainerSummary{
-				reasons: make(map[string]struct{}),
-			}
-
-			summary[container.Name] = sadContainer
-		}
-
-		sadContainer.pods++
-
-		if state := container.State.Waiting; state != nil {
-			sadContainer.reasons[state.Reason] = struct{}{}
-		} else if state := container.State.Terminated; state != nil {
-			sadContainer.reasons[state.Reason] = struct{}{}
-		}
-	}
-}
@@ -0,0 +1,238 @@
+package capacity
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	kubeinformers "k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+const (
+	// SadPodEventLimit is how many of the most recent Warning events we
+	// keep for each sad pod.
+	SadPodEventLimit = 5
+
+	// SadPodLogTailLines and SadPodLogLimitBytes cap how much of the log
+	// of a terminated container we keep for each sad pod, so that a
+	// chatty container can't make a CapacityTarget too big to store.
+	SadPodLogTailLines  = 20
+	SadPodLogLimitBytes = 2048
+
+	// podWarningEventIndex indexes Warning events by the namespace/name
+	// key of the pod they are about.
+	podWarningEventIndex = "pod"
+)
+
+// podLogsFunc returns the log of a container in a pod, as asked for in opts.
+type podLogsFunc func(client kubernetes.Interface, namespace, name string, opts *corev1.PodLogOptions) ([]byte, error)
+
+func getPodLogs(client kubernetes.Interface, namespace, name string, opts *corev1.PodLogOptions) ([]byte, error) {
+	return client.CoreV1().Pods(namespace).GetLogs(name, opts).DoRaw()
+}
+
+// podWarningEventInformer returns the shared informer for Warning events
+// about pods in an application cluster. As events are by far the busiest
+// objects in a cluster, it only watches the ones we care about, which means
+// it's registered in the informer factory in place of the informer for all
+// events.
+func podWarningEventInformer(informerFactory kubeinformers.SharedInformerFactory) cache.SharedIndexInformer {
+	return informerFactory.InformerFor(&corev1.Event{}, newPodWarningEventInformer)
+}
+
+func newPodWarningEventInformer(client kubernetes.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	selector := fields.Set{
+		"involvedObject.kind": "Pod",
+		"type":                corev1.EventTypeWarning,
+	}.AsSelector()
+
+	return coreinformers.NewFilteredEventInformer(
+		client,
+		metav1.NamespaceAll,
+		resyncPeriod,
+		cache.Indexers{podWarningEventIndex: podWarningEventIndexFunc},
+		func(opts *metav1.ListOptions) {
+			opts.FieldSelector = selector.String()
+		},
+	)
+}
+
+// podWarningEventIndexFunc indexes a Warning event by the key of the pod it
+// is about. Not every API server implementation filters events by all of the
+// fields we ask for, so anything else is left out of the index.
+func podWarningEventIndexFunc(obj interface{}) ([]string, error) {
+	event, ok := obj.(*corev1.Event)
+	if !ok {
+		return nil, fmt.Errorf("not a corev1.Event: %#v", obj)
+	}
+
+	if event.InvolvedObject.Kind != "Pod" || event.Type != corev1.EventTypeWarning {
+		return nil, nil
+	}
+
+	return []string{fmt.Sprintf("%s/%s", event.Namespace, event.InvolvedObject.Name)}, nil
+}
+
+// diagnoseSadPods fills in sadPods with what the application cluster can
+// tell about why they are sad: the last termination of their containers,
+// along with the end of their logs if the controller was asked to fetch
+// them, and the most recent Warning events about them. Terminations can only
+// change when containers restart, so they are carried over from previous for
+// pods that were already sad and haven't restarted since, instead of asking
+// the API server for their logs on every sync. This is only done on a best
+// effort basis, so anything we fail to fetch is left out instead of failing
+// the whole sync.
+func (c *Controller) diagnoseSadPods(clusterName, namespace string, sadPods, previous []shipper.PodStatus) {
+	if len(sadPods) == 0 {
+		return
+	}
+
+	appClientset, err := c.store.GetApplicationClusterClientset(clusterName, AgentName)
+	if err != nil {
+		klog.V(4).Infof("Cannot diagnose sad pods in cluster %q: %s", clusterName, err)
+		return
+	}
+	client := appClientset.GetKubeClient()
+	informerFactory := appClientset.GetKubeInformerFactory()
+
+	previousByName := make(map[string]shipper.PodStatus, len(previous))
+	for _, prev := range previous {
+		previousByName[prev.Name] = prev
+	}
+
+	for i := range sadPods {
+		sadPod := &sadPods[i]
+		if prev, ok := previousByName[sadPod.Name]; ok && restartCount(prev) == restartCount(*sadPod) {
+			sadPod.Terminations = prev.Terminations
+		} else {
+			sadPod.Terminations = c.getContainerTerminations(client, namespace, sadPod)
+		}
+		sadPod.Events = getPodWarningEvents(informerFactory, namespace, sadPod.Name)
+	}
+}
+
+// restartCount returns how many times the containers of a pod have been
+// restarted in total.
+func restartCount(pod shipper.PodStatus) int32 {
+	var count int32
+	for _, container := range pod.InitContainers {
+		count += container.RestartCount
+	}
+	for _, container := range pod.Containers {
+		count += container.RestartCount
+	}
+
+	return count
+}
+
+func (c *Controller) getContainerTerminations(
+	client kubernetes.Interface,
+	namespace string,
+	sadPod *shipper.PodStatus,
+) []shipper.ContainerTermination {
+	var terminations []shipper.ContainerTermination
+
+	var containers []corev1.ContainerStatus
+	containers = append(containers, sadPod.InitContainers...)
+	containers = append(containers, sadPod.Containers...)
+
+	for _, container := range containers {
+		terminated, previous := container.State.Terminated, false
+		if terminated == nil {
+			terminated, previous = container.LastTerminationState.Terminated, true
+		} else if terminated.ExitCode == 0 {
+			// Containers that are done and exited cleanly, such as
+			// init containers, aren't what's wrong with the pod.
+			continue
+		}
+
+		if terminated == nil {
+			continue
+		}
+
+		termination := shipper.ContainerTermination{
+			Container: container.Name,
+			Reason:    terminated.Reason,
+			Message:   terminated.Message,
+			ExitCode:  terminated.ExitCode,
+		}
+
+		// Logs end up in the status of the CapacityTarget, where
+		// anyone who can read it can see them, so we only fetch them
+		// if we were told to.
+		if c.podLogs == nil {
+			terminations = append(terminations, termination)
+			continue
+		}
+
+		tailLines, limitBytes := int64(SadPodLogTailLines), int64(SadPodLogLimitBytes)
+		log, err := c.podLogs(client, namespace, sadPod.Name, &corev1.PodLogOptions{
+			Container:  container.Name,
+			Previous:   previous,
+			TailLines:  &tailLines,
+			LimitBytes: &limitBytes,
+		})
+		if err != nil {
+			klog.V(4).Infof("Cannot get log of container %q in pod %s/%s: %s",
+				container.Name, namespace, sadPod.Name, err)
+		} else {
+			termination.LogTail = capLogTail(log)
+		}
+
+		terminations = append(terminations, termination)
+	}
+
+	return terminations
+}
+
+// capLogTail returns the end of log, no longer than SadPodLogLimitBytes, in
+// case the API server didn't honour the limit we asked for.
+func capLogTail(log []byte) string {
+	if l := len(log); l > SadPodLogLimitBytes {
+		log = log[l-SadPodLogLimitBytes:]
+	}
+
+	return string(log)
+}
+
+func getPodWarningEvents(informerFactory kubeinformers.SharedInformerFactory, namespace, name string) []shipper.PodEvent {
+	key := fmt.Sprintf("%s/%s", namespace, name)
+	objs, err := podWarningEventInformer(informerFactory).GetIndexer().ByIndex(podWarningEventIndex, key)
+	if err != nil {
+		klog.V(4).Infof("Cannot list events for pod %s: %s", key, err)
+		return nil
+	}
+
+	events := make([]*corev1.Event, 0, len(objs))
+	for _, obj := range objs {
+		events = append(events, obj.(*corev1.Event))
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[j].LastTimestamp.Before(&events[i].LastTimestamp)
+	})
+
+	if len(events) > SadPodEventLimit {
+		events = events[:SadPodEventLimit]
+	}
+
+	var podEvents []shipper.PodEvent
+	for _, event := range events {
+		podEvents = append(podEvents, shipper.PodEvent{
+			Reason:  event.Reason,
+			Message: event.Message,
+			Count:   event.Count,
+		})
+	}
+
+	return podEvents
+}
@@ -0,0 +1,372 @@
+package capacity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	capacityutil "github.com/bookingcom/shipper/pkg/util/capacity"
+)
+
+// limitsPrefix is what quotas on resource limits are prefixed with.
+const limitsPrefix = "limits."
+
+var (
+	resourceQuotaGVK = corev1.SchemeGroupVersion.WithKind("ResourceQuota")
+	limitRangeGVK    = corev1.SchemeGroupVersion.WithKind("LimitRange")
+)
+
+// checkQuota returns the reasons why the namespace of workload in a cluster
+// doesn't have room for the pods it would add if it were scaled to
+// replicaCount, according to the ResourceQuotas and LimitRanges in there. It
+// returns no reasons if there is room, or if there's no telling what the
+// pods of workload look like.
+func checkQuota(
+	informerFactory kubeinformers.SharedInformerFactory,
+	workload *workload,
+	replicaCount int32,
+) ([]string, error) {
+	var current int32
+	if workload.replicas != nil {
+		current = *workload.replicas
+	}
+
+	added := replicaCount - current
+	if added <= 0 || workload.podTemplate == nil {
+		return nil, nil
+	}
+
+	namespace := workload.object.GetNamespace()
+
+	limitRanges, err := informerFactory.Core().V1().LimitRanges().Lister().
+		LimitRanges(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, shippererrors.NewKubeclientListError(
+			limitRangeGVK, namespace, labels.Everything(), err)
+	}
+
+	quotas, err := informerFactory.Core().V1().ResourceQuotas().Lister().
+		ResourceQuotas(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, shippererrors.NewKubeclientListError(
+			resourceQuotaGVK, namespace, labels.Everything(), err)
+	}
+
+	podSpec := &workload.podTemplate.Spec
+	containerResources := make(map[string]corev1.ResourceRequirements)
+	for _, c := range allContainers(podSpec) {
+		containerResources[c.Name] = containerResourcesWithDefaults(c, limitRanges)
+	}
+
+	var reasons []string
+
+	for _, limitRange := range limitRanges {
+		reasons = append(reasons, checkLimitRange(limitRange, podSpec, containerResources)...)
+	}
+
+	requests, limits := podResources(podSpec, containerResources)
+	for _, quota := range quotas {
+		reasons = append(reasons, checkResourceQuota(quota, added, requests, limits)...)
+	}
+
+	return reasons, nil
+}
+
+// allContainers returns all of the containers in spec, init containers
+// first.
+func allContainers(spec *corev1.PodSpec) []corev1.Container {
+	containers := make([]corev1.Container, 0, len(spec.InitContainers)+len(spec.Containers))
+	containers = append(containers, spec.InitContainers...)
+	return append(containers, spec.Containers...)
+}
+
+// containerResourcesWithDefaults returns the resources of container as they
+// will be once it is admitted: limits default to the ones in limitRanges,
+// and requests default to the limits, or to the requests in limitRanges.
+func containerResourcesWithDefaults(container corev1.Container, limitRanges []*corev1.LimitRange) corev1.ResourceRequirements {
+	resources := corev1.ResourceRequirements{
+		Requests: container.Resources.Requests.DeepCopy(),
+		Limits:   container.Resources.Limits.DeepCopy(),
+	}
+	if resources.Requests == nil {
+		resources.Requests = corev1.ResourceList{}
+	}
+	if resources.Limits == nil {
+		resources.Limits = corev1.ResourceList{}
+	}
+
+	for _, limitRange := range limitRanges {
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+
+			for name, q := range item.Default {
+				if _, ok := resources.Limits[name]; !ok {
+					resources.Limits[name] = q.DeepCopy()
+				}
+			}
+
+			for name, q := range item.DefaultRequest {
+				if _, ok := resources.Requests[name]; !ok {
+					resources.Requests[name] = q.DeepCopy()
+				}
+			}
+		}
+	}
+
+	for name, q := range resources.Limits {
+		if _, ok := resources.Requests[name]; !ok {
+			resources.Requests[name] = q.DeepCopy()
+		}
+	}
+
+	return resources
+}
+
+// podResources returns what a pod with spec requests and is limited to,
+// which is the most out of all of its containers running together, and each
+// of its init containers running on its own.
+func podResources(spec *corev1.PodSpec, containerResources map[string]corev1.ResourceRequirements) (corev1.ResourceList, corev1.ResourceList) {
+	requests, limits := corev1.ResourceList{}, corev1.ResourceList{}
+
+	for _, c := range spec.Containers {
+		addResources(requests, containerResources[c.Name].Requests)
+		addResources(limits, containerResources[c.Name].Limits)
+	}
+
+	for _, c := range spec.InitContainers {
+		maxResources(requests, containerResources[c.Name].Requests)
+		maxResources(limits, containerResources[c.Name].Limits)
+	}
+
+	return requests, limits
+}
+
+func addResources(total, list corev1.ResourceList) {
+	for name, q := range list {
+		sum := total[name]
+		sum.Add(q)
+		total[name] = sum
+	}
+}
+
+func maxResources(total, list corev1.ResourceList) {
+	for name, q := range list {
+		if current, ok := total[name]; !ok || q.Cmp(current) > 0 {
+			total[name] = q.DeepCopy()
+		}
+	}
+}
+
+// checkLimitRange returns the reasons why the pods in spec would be refused
+// by limitRange.
+func checkLimitRange(
+	limitRange *corev1.LimitRange,
+	spec *corev1.PodSpec,
+	containerResources map[string]corev1.ResourceRequirements,
+) []string {
+	var reasons []string
+
+	for _, item := range limitRange.Spec.Limits {
+		switch item.Type {
+		case corev1.LimitTypeContainer:
+			for _, c := range allContainers(spec) {
+				resources := containerResources[c.Name]
+				for _, name := range exceededResources(item.Max, resources.Requests, resources.Limits) {
+					reasons = append(reasons, fmt.Sprintf(
+						"container %q asks for more %s than LimitRange %q allows",
+						c.Name, name, limitRange.Name))
+				}
+			}
+		case corev1.LimitTypePod:
+			requests, limits := podResources(spec, containerResources)
+			for _, name := range exceededResources(item.Max, requests, limits) {
+				reasons = append(reasons, fmt.Sprintf(
+					"pods ask for more %s than LimitRange %q allows",
+					name, limitRange.Name))
+			}
+		}
+	}
+
+	return reasons
+}
+
+// exceededResources returns the names of the resources in max that either
+// requests or limits go over.
+func exceededResources(max, requests, limits corev1.ResourceList) []string {
+	var exceeded []string
+
+	for name, q := range max {
+		request, hasRequest := requests[name]
+		limit, hasLimit := limits[name]
+		if (hasRequest && request.Cmp(q) > 0) || (hasLimit && limit.Cmp(q) > 0) {
+			exceeded = append(exceeded, string(name))
+		}
+	}
+
+	sort.Strings(exceeded)
+
+	return exceeded
+}
+
+// checkResourceQuota returns the reasons why there's no room in quota for
+// added pods with requests and limits.
+func checkResourceQuota(
+	quota *corev1.ResourceQuota,
+	added int32,
+	requests, limits corev1.ResourceList,
+) []string {
+	// Scoped quotas only count some of the pods in their namespace, and
+	// figuring out whether ours are among them is best left to the API
+	// server.
+	if len(quota.Spec.Scopes) > 0 || quota.Spec.ScopeSelector != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(quota.Status.Hard))
+	for name := range quota.Status.Hard {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	var reasons []string
+
+	for _, n := range names {
+		name := corev1.ResourceName(n)
+		perPod, ok, tracked := podQuotaUsage(name, requests, limits)
+		if !tracked {
+			continue
+		}
+
+		if !ok {
+			reasons = append(reasons, fmt.Sprintf(
+				"pods don't set %s, which ResourceQuota %q requires", name, quota.Name))
+			continue
+		}
+
+		needed := resource.Quantity{}
+		for i := int32(0); i < added; i++ {
+			needed.Add(perPod)
+		}
+
+		hard, used := quota.Status.Hard[name], quota.Status.Used[name]
+		headroom := hard.DeepCopy()
+		headroom.Sub(used)
+
+		if needed.Cmp(headroom) > 0 {
+			reasons = append(reasons, fmt.Sprintf(
+				"%d more pods need %s of %s, but ResourceQuota %q only has %s left",
+				added, needed.String(), name, quota.Name, headroom.String()))
+		}
+	}
+
+	return reasons
+}
+
+// podQuotaUsage returns how much of the quota resource name a single pod
+// with requests and limits uses. ok is false when the pod doesn't say how
+// much of it it needs but must, and tracked is false when quotas on name
+// don't apply to pods at all.
+func podQuotaUsage(name corev1.ResourceName, requests, limits corev1.ResourceList) (q resource.Quantity, ok bool, tracked bool) {
+	if name == corev1.ResourcePods || name == "count/pods" {
+		return *resource.NewQuantity(1, resource.DecimalSI), true, true
+	}
+
+	list, resourceName := requests, name
+	if n := string(name); strings.HasPrefix(n, corev1.DefaultResourceRequestsPrefix) {
+		resourceName = corev1.ResourceName(strings.TrimPrefix(n, corev1.DefaultResourceRequestsPrefix))
+	} else if strings.HasPrefix(n, limitsPrefix) {
+		list, resourceName = limits, corev1.ResourceName(strings.TrimPrefix(n, limitsPrefix))
+	} else if name != corev1.ResourceCPU && name != corev1.ResourceMemory && name != corev1.ResourceEphemeralStorage {
+		// Anything else, like services or count/deployments.apps, is
+		// not about pods.
+		return q, false, false
+	}
+
+	if resourceName == corev1.ResourceStorage {
+		// requests.storage is about PersistentVolumeClaims.
+		return q, false, false
+	}
+
+	if q, ok = list[resourceName]; ok {
+		return q, true, true
+	}
+
+	// Pods that don't set how much CPU or memory they need are refused in
+	// namespaces that have quotas on them. Anything else they don't set
+	// they don't use.
+	required := resourceName == corev1.ResourceCPU || resourceName == corev1.ResourceMemory
+	return q, !required, true
+}
+
+// quotaInsufficientCondition returns the Ready condition for a cluster where
+// quota stops workload from being scaled to replicaCount, or nil if there is
+// room for it.
+func (c *Controller) quotaInsufficientCondition(
+	clusterName string,
+	workload *workload,
+	replicaCount int32,
+) (*shipper.ClusterCapacityCondition, error) {
+	appClientset, err := c.store.GetApplicationClusterClientset(clusterName, AgentName)
+	if err != nil {
+		return nil, err
+	}
+
+	reasons, err := checkQuota(appClientset.GetKubeInformerFactory(), workload, replicaCount)
+	if err != nil || len(reasons) == 0 {
+		return nil, err
+	}
+
+	return capacityutil.NewClusterCapacityCondition(
+		shipper.ClusterConditionTypeReady,
+		corev1.ConditionFalse,
+		QuotaInsufficient,
+		strings.Join(reasons, "; "),
+	), nil
+}
+
+// enqueueCapacityTargetsHeldBackByQuota puts back in the work queue every
+// CapacityTarget in the namespace of a ResourceQuota or a LimitRange that
+// couldn't scale because of quota, as it might fit now.
+func (c *Controller) enqueueCapacityTargetsHeldBackByQuota(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	var namespace string
+	switch obj := obj.(type) {
+	case *corev1.ResourceQuota:
+		namespace = obj.Namespace
+	case *corev1.LimitRange:
+		namespace = obj.Namespace
+	default:
+		runtime.HandleError(fmt.Errorf("not a ResourceQuota nor a LimitRange: %#v", obj))
+		return
+	}
+
+	cts, err := c.capacityTargetsLister.CapacityTargets(namespace).List(labels.Everything())
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("cannot list capacity targets in namespace %q: %s", namespace, err))
+		return
+	}
+
+	for _, ct := range cts {
+		for _, status := range ct.Status.Clusters {
+			cond := capacityutil.GetClusterCapacityCondition(status, shipper.ClusterConditionTypeReady)
+			if cond != nil && cond.Reason == QuotaInsufficient {
+				c.enqueueCapacityTarget(ct)
+				break
+			}
+		}
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"sort"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
 	shippertesting "github.com/bookingcom/shipper/pkg/testing"
@@ -99,12 +101,94 @@ func buildDeployment(app, release string, replicas int32, availableReplicas int3
 	}
 }
 
+func buildHorizontalPodAutoscaler(deployment *appsv1.Deployment, chartMinReplicas, minReplicas, maxReplicas int32) *autoscalingv1.HorizontalPodAutoscaler {
+	return &autoscalingv1.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deployment.Name,
+			Namespace: deployment.Namespace,
+			Labels:    deployment.Labels,
+			Annotations: map[string]string{
+				shipper.HPAMinReplicasAnnotation: fmt.Sprintf("%d", chartMinReplicas),
+			},
+		},
+		Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{
+				APIVersion: appsv1.SchemeGroupVersion.String(),
+				Kind:       "Deployment",
+				Name:       deployment.Name,
+			},
+			MinReplicas: &minReplicas,
+			MaxReplicas: maxReplicas,
+		},
+	}
+}
+
+func buildStatefulSet(app, release string, replicas int32, readyReplicas int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      release,
+			Namespace: shippertesting.TestNamespace,
+			Labels: map[string]string{
+				shipper.AppLabel:     app,
+				shipper.ReleaseLabel: release,
+			},
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					shipper.AppLabel:     app,
+					shipper.ReleaseLabel: release,
+				},
+			},
+		},
+		Status: appsv1.StatefulSetStatus{
+			ReadyReplicas: readyReplicas,
+		},
+	}
+}
+
+// buildWidget returns a workload of a kind unknown to Kubernetes, that tells
+// how many of its replicas are ready at status.widgets.ready.
+func buildWidget(app, release string, replicas int32, readyReplicas int32) *unstructured.Unstructured {
+	widget := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"replicas": int64(replicas),
+			},
+			"status": map[string]interface{}{
+				"selector": fmt.Sprintf("%s=%s", shipper.ReleaseLabel, release),
+				"widgets": map[string]interface{}{
+					"ready": int64(readyReplicas),
+				},
+			},
+		},
+	}
+	widget.SetGroupVersionKind(widgetGVK)
+	widget.SetName(release)
+	widget.SetNamespace(shippertesting.TestNamespace)
+	widget.SetLabels(map[string]string{
+		shipper.AppLabel:      app,
+		shipper.ReleaseLabel:  release,
+		shipper.WorkloadLabel: shipper.True,
+	})
+	widget.SetAnnotations(map[string]string{
+		shipper.WorkloadReadyReplicasPathAnnotation: "status.widgets.ready",
+	})
+
+	return widget
+}
+
 func buildSadPodForDeployment(deployment *appsv1.Deployment) *corev1.Pod {
+	return buildSadPod(deployment.Namespace, deployment.Name, deployment.Spec.Selector)
+}
+
+func buildSadPod(namespace, owner string, selector *metav1.LabelSelector) *corev1.Pod {
 	return &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Namespace: deployment.Namespace,
-			Name:      fmt.Sprintf("%s-deadbeef", deployment.Name),
-			Labels:    deployment.Spec.Selector.MatchLabels,
+			Namespace: namespace,
+			Name:      fmt.Sprintf("%s-deadbeef", owner),
+			Labels:    selector.MatchLabels,
 		},
 		Status: corev1.PodStatus{
 			Phase: corev1.PodFailed,
@@ -0,0 +1,303 @@
+package capacity
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	kuberuntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/clusterclientstore"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	workloadutil "github.com/bookingcom/shipper/pkg/util/workload"
+)
+
+var (
+	deploymentGVK  = appsv1.SchemeGroupVersion.WithKind("Deployment")
+	statefulSetGVK = appsv1.SchemeGroupVersion.WithKind("StatefulSet")
+)
+
+type workloadObject interface {
+	metav1.Object
+	GroupVersionKind() schema.GroupVersionKind
+}
+
+// workload is what the capacity controller needs to know about the object
+// running the pods of a release, be it a Deployment, a StatefulSet, or any
+// other kind of object the chart marks as its workload.
+type workload struct {
+	object workloadObject
+	gvk    schema.GroupVersionKind
+
+	replicas          *int32
+	availableReplicas int32
+
+	generation         int64
+	observedGeneration int64
+
+	selector labels.Selector
+
+	// podTemplate is what the pods of the workload look like, if we can
+	// tell.
+	podTemplate *corev1.PodTemplateSpec
+
+	// scaleClient is used to scale workloads that are neither
+	// Deployments nor StatefulSets through their scale subresource.
+	scaleClient dynamic.ResourceInterface
+
+	// stuckMessage explains why the workload can't get to the number of
+	// replicas it asks for, when it can tell.
+	stuckMessage string
+}
+
+func newDeploymentWorkload(deployment *appsv1.Deployment) (*workload, error) {
+	selector, err := podSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &workload{
+		object:             deployment,
+		gvk:                deploymentGVK,
+		replicas:           deployment.Spec.Replicas,
+		availableReplicas:  deployment.Status.AvailableReplicas,
+		generation:         deployment.Generation,
+		observedGeneration: deployment.Status.ObservedGeneration,
+		selector:           selector,
+		podTemplate:        &deployment.Spec.Template,
+	}
+
+	replicaFailureCond := getDeploymentCondition(deployment.Status, appsv1.DeploymentReplicaFailure)
+	progressingCond := getDeploymentCondition(deployment.Status, appsv1.DeploymentProgressing)
+
+	if replicaFailureCond != nil && replicaFailureCond.Status == corev1.ConditionTrue {
+		// It is common for a Deployment to get stuck because of exceeded
+		// quotas. Looking at the ReplicaFailure condition exposes that
+		// condition, and potentially others too.
+		w.stuckMessage = replicaFailureCond.Message
+	} else if progressingCond != nil && progressingCond.Status == corev1.ConditionFalse {
+		// If the Deployment has a timeout defined, and exceeds it,
+		// Progressing becomes False. Note that True doesn't *actually*
+		// mean the rollout is still progressing, for our definition of
+		// progressing.
+		w.stuckMessage = progressingCond.Message
+	}
+
+	return w, nil
+}
+
+func newStatefulSetWorkload(statefulSet *appsv1.StatefulSet) (*workload, error) {
+	selector, err := podSelector(statefulSet.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	// StatefulSets don't tell available and ready pods apart, nor do they
+	// have any standard conditions to tell us they're stuck, so sad pods
+	// are all we can go by.
+	return &workload{
+		object:             statefulSet,
+		gvk:                statefulSetGVK,
+		replicas:           statefulSet.Spec.Replicas,
+		availableReplicas:  statefulSet.Status.ReadyReplicas,
+		generation:         statefulSet.Generation,
+		observedGeneration: statefulSet.Status.ObservedGeneration,
+		selector:           selector,
+		podTemplate:        &statefulSet.Spec.Template,
+	}, nil
+}
+
+// newGenericWorkload builds a workload out of an object of any kind that
+// implements the scale subresource, reachable through client. The number of
+// replicas it asks for, and the selector for its pods, come from its scale
+// subresource, while the number of them that are ready comes from wherever
+// the object's annotations say.
+func newGenericWorkload(
+	obj *unstructured.Unstructured,
+	client dynamic.ResourceInterface,
+	releaseSelector labels.Selector,
+) (*workload, error) {
+	gvk := obj.GroupVersionKind()
+	scale, err := client.Get(obj.GetName(), metav1.GetOptions{}, "scale")
+	if err != nil {
+		return nil, shippererrors.NewKubeclientGetError(obj.GetNamespace(), obj.GetName(), err).
+			WithKind(gvk)
+	}
+
+	// The scale subresource omits replicas when there are none.
+	specReplicas, _, _ := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+	replicas := int32(specReplicas)
+
+	// Workloads that don't tell what pods they select get the ones
+	// labeled for their release, which they get from their pod template
+	// if they have one.
+	selector := releaseSelector
+	if s, ok, _ := unstructured.NestedString(scale.Object, "status", "selector"); ok && s != "" {
+		selector, err = labels.Parse(s)
+		if err != nil {
+			return nil, shippererrors.NewUnrecoverableError(fmt.Errorf(
+				"failed to parse selector %q of %s %q: %s", s, gvk.Kind, obj.GetName(), err))
+		}
+	}
+
+	readyReplicasPath := workloadutil.ReadyReplicasPath(obj)
+	readyReplicas, _, err := unstructured.NestedInt64(obj.Object, readyReplicasPath...)
+	if err != nil {
+		return nil, shippererrors.NewUnrecoverableError(fmt.Errorf(
+			"failed to read ready replicas of %s %q from %q: %s",
+			gvk.Kind, obj.GetName(), strings.Join(readyReplicasPath, "."), err))
+	}
+
+	// Not every workload reports the generation it observed, so we need
+	// to take their status at face value.
+	observedGeneration, ok, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if !ok {
+		observedGeneration = obj.GetGeneration()
+	}
+
+	// Workloads that keep a pod template where Deployments do get their
+	// pods checked against quotas before they're scaled.
+	var podTemplate *corev1.PodTemplateSpec
+	if template, ok, _ := unstructured.NestedMap(obj.Object, "spec", "template"); ok {
+		podTemplate = &corev1.PodTemplateSpec{}
+		err := kuberuntime.DefaultUnstructuredConverter.FromUnstructured(template, podTemplate)
+		if err != nil {
+			klog.V(4).Infof("Cannot read pod template of %s %q: %s", gvk.Kind, obj.GetName(), err)
+			podTemplate = nil
+		}
+	}
+
+	return &workload{
+		object:             obj,
+		gvk:                gvk,
+		replicas:           &replicas,
+		availableReplicas:  int32(readyReplicas),
+		generation:         obj.GetGeneration(),
+		observedGeneration: observedGeneration,
+		selector:           selector,
+		podTemplate:        podTemplate,
+		scaleClient:        client,
+	}, nil
+}
+
+// getGenericWorkload returns the workload of a release marked in its chart
+// as being of the kind in ref.
+func (c *Controller) getGenericWorkload(
+	clusterName string,
+	clientset clusterclientstore.ClientsetInterface,
+	ref *shipper.WorkloadReference,
+	namespace string,
+	selector labels.Selector,
+) (*workload, error) {
+	cluster, err := c.clustersLister.Get(clusterName)
+	if err != nil {
+		return nil, shippererrors.NewKubeclientGetError("", clusterName, err).
+			WithShipperKind("Cluster")
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, shippererrors.NewUnrecoverableError(err)
+	}
+	gvk := gv.WithKind(ref.Kind)
+
+	resources, err := clientset.GetKubeClient().Discovery().ServerResourcesForGroupVersion(gv.String())
+	if err != nil {
+		return nil, shippererrors.NewKubeclientDiscoverError(gv, err)
+	}
+
+	var resource, scale *metav1.APIResource
+	for i, r := range resources.APIResources {
+		if r.Kind == gvk.Kind && !strings.Contains(r.Name, "/") {
+			resource = &resources.APIResources[i]
+		}
+	}
+	if resource != nil {
+		for i, r := range resources.APIResources {
+			if r.Name == resource.Name+"/scale" {
+				scale = &resources.APIResources[i]
+			}
+		}
+	}
+
+	if resource == nil {
+		err := fmt.Errorf("kind %s not found on the Kubernetes cluster", gvk.Kind)
+		return nil, shippererrors.NewUnrecoverableError(err)
+	} else if scale == nil {
+		err := fmt.Errorf("kind %s does not implement the scale subresource", gvk.Kind)
+		return nil, shippererrors.NewUnrecoverableError(err)
+	}
+
+	client := clusterclientstore.NewDynamicClient(clientset, cluster, gvk, c.dynamicClientBuilder).
+		Resource(gv.WithResource(resource.Name)).
+		Namespace(namespace)
+
+	list, err := client.List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, shippererrors.NewKubeclientListError(gvk, namespace, selector, err)
+	}
+
+	if l := len(list.Items); l != 1 {
+		return nil, shippererrors.NewUnexpectedObjectCountFromSelectorError(
+			selector, gvk, 1, l)
+	}
+
+	return newGenericWorkload(&list.Items[0], client, selector)
+}
+
+func podSelector(selector *metav1.LabelSelector) (labels.Selector, error) {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, shippererrors.NewUnrecoverableError(fmt.Errorf("failed to transform label selector %v into a selector: %s", selector, err))
+	}
+
+	return s, nil
+}
+
+func (c *Controller) enqueueCapacityTargetFromStatefulSet(obj interface{}) {
+	statefulSet, ok := obj.(*appsv1.StatefulSet)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("not a StatefulSet: %#v", obj))
+		return
+	}
+
+	c.enqueueCapacityTargetFromReleaseObject(statefulSet)
+}
+
+func (c *Controller) patchWorkloadWithReplicaCount(w *workload, clusterName string, replicaCount int32) error {
+	appClientset, err := c.store.GetApplicationClusterClientset(clusterName, AgentName)
+	if err != nil {
+		return err
+	}
+	targetClusterClient := appClientset.GetKubeClient()
+
+	patch := []byte(fmt.Sprintf(`{"spec": {"replicas": %d}}`, replicaCount))
+
+	name, namespace := w.object.GetName(), w.object.GetNamespace()
+	switch w.gvk {
+	case deploymentGVK:
+		_, err = targetClusterClient.AppsV1().Deployments(namespace).
+			Patch(name, types.StrategicMergePatchType, patch)
+	case statefulSetGVK:
+		_, err = targetClusterClient.AppsV1().StatefulSets(namespace).
+			Patch(name, types.StrategicMergePatchType, patch)
+	default:
+		_, err = w.scaleClient.Patch(name, types.MergePatchType, patch, metav1.PatchOptions{}, "scale")
+	}
+
+	if err != nil {
+		return shippererrors.NewKubeclientUpdateError(w.object, err).WithKind(w.gvk)
+	}
+
+	return nil
+}
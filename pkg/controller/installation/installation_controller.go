@@ -11,6 +11,7 @@ import (
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	kuberuntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubeinformers "k8s.io/client-go/informers"
@@ -35,6 +36,7 @@ import (
 	"github.com/bookingcom/shipper/pkg/util/filters"
 	installationutil "github.com/bookingcom/shipper/pkg/util/installation"
 	targetutil "github.com/bookingcom/shipper/pkg/util/target"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
 	shipperworkqueue "github.com/bookingcom/shipper/pkg/workqueue"
 )
 
@@ -137,11 +139,13 @@ func (c *Controller) registerAppClusterEventHandlers(informerFactory kubeinforme
 		},
 	}
 	informerFactory.Apps().V1().Deployments().Informer().AddEventHandler(handler)
+	informerFactory.Apps().V1().StatefulSets().Informer().AddEventHandler(handler)
 	informerFactory.Core().V1().Services().Informer().AddEventHandler(handler)
 }
 
 func (c *Controller) subscribeToAppClusterEvents(informerFactory kubeinformers.SharedInformerFactory) {
 	informerFactory.Apps().V1().Deployments().Informer()
+	informerFactory.Apps().V1().StatefulSets().Informer()
 	informerFactory.Core().V1().Services().Informer()
 }
 
@@ -316,7 +320,6 @@ func (c *Controller) processInstallationTarget(it *shipper.InstallationTarget) (
 
 	it.Status.Conditions = targetutil.TransitionToOperational(diff, it.Status.Conditions)
 
-	installer := NewInstaller(it, objects)
 	newClusterStatuses := make([]*shipper.ClusterInstallationStatus, 0, len(it.Spec.Clusters))
 	clusterErrors := shippererrors.NewMultiError()
 
@@ -333,7 +336,7 @@ func (c *Controller) processInstallationTarget(it *shipper.InstallationTarget) (
 			}
 		}
 
-		err := c.processInstallationTargetOnCluster(it, clusterName, clusterStatus, installer)
+		err := c.processInstallationTargetOnCluster(it, clusterName, clusterStatus, objects)
 		if err != nil {
 			clusterErrors.Append(err)
 		}
@@ -372,7 +375,7 @@ func (c *Controller) processInstallationTargetOnCluster(
 	it *shipper.InstallationTarget,
 	clusterName string,
 	status *shipper.ClusterInstallationStatus,
-	installer *Installer,
+	objects []kuberuntime.Object,
 ) error {
 	diff := diffutil.NewMultiDiff()
 	operationalCond := installationutil.NewClusterInstallationCondition(
@@ -426,6 +429,18 @@ func (c *Controller) processInstallationTargetOnCluster(
 		"",
 	)
 
+	installer, err := c.buildInstaller(it, cluster, objects)
+	if err != nil {
+		readyCond = installationutil.NewClusterInstallationCondition(
+			shipper.ClusterConditionTypeReady,
+			corev1.ConditionFalse,
+			reasonForReadyCondition(err),
+			err.Error(),
+		)
+
+		return err
+	}
+
 	err = installer.install(cluster, client, restConfig, c.dynamicClientBuilderFunc)
 	if err != nil {
 		readyCond = installationutil.NewClusterInstallationCondition(
@@ -448,6 +463,39 @@ func (c *Controller) processInstallationTargetOnCluster(
 	return nil
 }
 
+// buildInstaller returns an Installer for the objects of an
+// InstallationTarget in a cluster, adding the objects the traffic backend the
+// application uses there needs.
+func (c *Controller) buildInstaller(
+	it *shipper.InstallationTarget,
+	cluster *shipper.Cluster,
+	objects []kuberuntime.Object,
+) (*Installer, error) {
+	appName := it.Labels[shipper.AppLabel]
+	app, err := c.appLister.Applications(it.Namespace).Get(appName)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, shippererrors.NewKubeclientGetError(it.Namespace, appName, err).
+			WithShipperKind("Application")
+	} else if err != nil {
+		app = nil
+	}
+
+	if trafficutil.BackendType(app, cluster) != shipper.TrafficBackendNginxCanary {
+		return NewInstaller(it, objects), nil
+	}
+
+	canaryObjects, err := buildNginxCanaryObjects(it, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	allObjects := make([]kuberuntime.Object, 0, len(objects)+len(canaryObjects))
+	allObjects = append(allObjects, objects...)
+	allObjects = append(allObjects, canaryObjects...)
+
+	return NewInstaller(it, allObjects), nil
+}
+
 func (c *Controller) GetClusterAndConfig(clusterName string) (kubernetes.Interface, *rest.Config, error) {
 	clusterset, err := c.store.GetApplicationClusterClientset(clusterName, AgentName)
 	if err != nil {
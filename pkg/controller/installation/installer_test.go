@@ -1,6 +1,7 @@
 package installation
 
 import (
+	"fmt"
 	"regexp"
 	"testing"
 
@@ -368,7 +369,7 @@ func TestInstallerMultiServiceNoLB(t *testing.T) {
 	if err == nil {
 		t.Fatal("Expected an error, none raised")
 	}
-	if matched, err := regexp.MatchString("one and only one .* object .* is required", err.Error()); err != nil {
+	if matched, err := regexp.MatchString("at least one .* object .* is required", err.Error()); err != nil {
 		t.Fatalf("Failed to test error against the regex: %s", err)
 	} else if !matched {
 		t.Fatalf("Unexpected error raised: %s", err)
@@ -553,3 +554,148 @@ func TestInstallerNoOverride(t *testing.T) {
 	shippertesting.ShallowCheckActions(expectedActions, fakeCluster.Client.Actions(), t)
 	shippertesting.ShallowCheckActions(expectedDynamicActions, fakeCluster.DynamicClient.Actions(), t)
 }
+
+const statefulSetManifest = `
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: %s
+spec:
+  replicas: 3
+  serviceName: reviews-api
+  selector:
+    matchLabels:
+      app: reviews-api
+  template:
+    metadata:
+      labels:
+        app: reviews-api
+    spec:
+      containers:
+        - name: reviews-api
+          image: "redis:5"
+`
+
+const serviceManifest = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: reviews-api
+  labels:
+    app: reviews-api
+spec:
+  selector:
+    app: reviews-api
+  ports:
+    - port: 6379
+`
+
+// TestPrepareObjectsStatefulSet tests that StatefulSets in a chart get their
+// name validated, and get patched the same way Deployments do.
+func TestPrepareObjectsStatefulSet(t *testing.T) {
+	appName := "reviews-api"
+	chart := buildChart(appName, "0.0.1", repoUrl)
+	it := buildInstallationTarget("reviews-api", appName, []string{"minikube-a"}, &chart)
+
+	_, err := prepareObjects(it, []string{
+		fmt.Sprintf(statefulSetManifest, "redis"),
+		serviceManifest,
+	})
+	if _, ok := err.(shippererrors.InvalidChartError); !ok {
+		t.Fatalf("expected an InvalidChartError for a StatefulSet with an invalid name, got %v instead", err)
+	}
+
+	objects, err := prepareObjects(it, []string{
+		fmt.Sprintf(statefulSetManifest, it.Name+"-redis"),
+		serviceManifest,
+	})
+	if err != nil {
+		t.Fatalf("could not prepare objects: %s", err)
+	}
+
+	var statefulSet *appsv1.StatefulSet
+	for _, obj := range objects {
+		if s, ok := obj.(*appsv1.StatefulSet); ok {
+			statefulSet = s
+		}
+	}
+
+	if statefulSet == nil {
+		t.Fatalf("expected a StatefulSet among the prepared objects, got none")
+	}
+
+	if *statefulSet.Spec.Replicas != 0 {
+		t.Errorf("expected StatefulSet to start with 0 replicas, got %d", *statefulSet.Spec.Replicas)
+	}
+
+	selector := statefulSet.Spec.Selector.MatchLabels
+	if selector[shipper.InstallationTargetOwnerLabel] != it.Name {
+		t.Errorf("expected StatefulSet selector to include label %q, got %v",
+			shipper.InstallationTargetOwnerLabel, selector)
+	}
+
+	podLabels := statefulSet.Spec.Template.Labels
+	if podLabels[shipper.InstallationTargetOwnerLabel] != it.Name {
+		t.Errorf("expected StatefulSet pod template to include label %q, got %v",
+			shipper.InstallationTargetOwnerLabel, podLabels)
+	}
+}
+
+const widgetManifest = `
+apiVersion: widgets.example.com/v1
+kind: Widget
+metadata:
+  name: %s
+  labels:
+    shipper-workload: "true"
+spec:
+  replicas: 3
+  template:
+    metadata:
+      labels:
+        app: reviews-api
+`
+
+func TestPrepareObjectsWorkload(t *testing.T) {
+	appName := "reviews-api"
+	chart := buildChart(appName, "0.0.1", repoUrl)
+	it := buildInstallationTarget("reviews-api", appName, []string{"minikube-a"}, &chart)
+
+	_, err := prepareObjects(it, []string{
+		fmt.Sprintf(widgetManifest, "widget"),
+		serviceManifest,
+	})
+	if _, ok := err.(shippererrors.InvalidChartError); !ok {
+		t.Fatalf("expected an InvalidChartError for a workload with an invalid name, got %v instead", err)
+	}
+
+	objects, err := prepareObjects(it, []string{
+		fmt.Sprintf(widgetManifest, it.Name+"-widget"),
+		serviceManifest,
+	})
+	if err != nil {
+		t.Fatalf("could not prepare objects: %s", err)
+	}
+
+	var widget *unstructured.Unstructured
+	for _, obj := range objects {
+		if u, ok := obj.(*unstructured.Unstructured); ok && u.GetKind() == "Widget" {
+			widget = u
+		}
+	}
+
+	if widget == nil {
+		t.Fatalf("expected a Widget among the prepared objects, got none")
+	}
+
+	replicas, _, _ := unstructured.NestedInt64(widget.Object, "spec", "replicas")
+	if replicas != 0 {
+		t.Errorf("expected Widget to start with 0 replicas, got %d", replicas)
+	}
+
+	podLabels, _, _ := unstructured.NestedStringMap(widget.Object, "spec", "template", "metadata", "labels")
+	if podLabels[shipper.InstallationTargetOwnerLabel] != it.Name {
+		t.Errorf("expected Widget pod template to include label %q, got %v",
+			shipper.InstallationTargetOwnerLabel, podLabels)
+	}
+}
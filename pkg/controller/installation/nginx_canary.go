@@ -0,0 +1,171 @@
+package installation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+// buildNginxCanaryObjects returns the objects the nginxCanary traffic backend
+// needs on top of the ones in the chart: for every production Service, a
+// Service selecting only the pods of the release, and a canary Ingress for
+// every Ingress in the chart that routes to a production Service, sending
+// traffic to the release's Service instead.
+//
+// Canary Ingresses are named after the Ingress they shadow, so they're shared
+// by all the releases of an application and taken over by each contender as
+// it gets installed. They start with a weight of 0, and it's up to the
+// traffic controller to change it.
+func buildNginxCanaryObjects(it *shipper.InstallationTarget, objects []runtime.Object) ([]runtime.Object, error) {
+	var prodSvcs []*corev1.Service
+	for _, obj := range objects {
+		svc, ok := obj.(*corev1.Service)
+		if ok && svc.Labels[shipper.LBLabel] == shipper.LBForProduction {
+			prodSvcs = append(prodSvcs, svc)
+		}
+	}
+
+	if len(prodSvcs) == 0 {
+		return nil, shippererrors.NewInvalidChartError(
+			fmt.Sprintf("no v1.Service object with label %q found", shipper.LBLabel))
+	}
+
+	// Charts with a single production Service keep their canary
+	// Service named after the release alone.
+	canaryServiceNames := make(map[string]string, len(prodSvcs))
+	canaryObjects := make([]runtime.Object, 0, len(prodSvcs))
+	for _, prodSvc := range prodSvcs {
+		canaryServiceName := trafficutil.NginxCanaryServiceName(it.Name)
+		if len(prodSvcs) > 1 {
+			canaryServiceName = trafficutil.NginxCanaryServiceName(
+				fmt.Sprintf("%s-%s", it.Name, prodSvc.Name))
+		}
+
+		canaryServiceNames[prodSvc.Name] = canaryServiceName
+		canaryObjects = append(canaryObjects,
+			buildNginxCanaryService(it, prodSvc, canaryServiceName))
+	}
+
+	for _, obj := range objects {
+		var ing *networkingv1beta1.Ingress
+		switch o := obj.(type) {
+		case *networkingv1beta1.Ingress:
+			ing = o.DeepCopy()
+		case *extensionsv1beta1.Ingress:
+			// Both versions of Ingress have the same schema, so
+			// we only deal with the most recent one.
+			ing = &networkingv1beta1.Ingress{}
+			data, err := json.Marshal(o)
+			if err == nil {
+				err = json.Unmarshal(data, ing)
+			}
+			if err != nil {
+				return nil, shippererrors.NewConvertUnstructuredError(
+					"error converting Ingress %q: %s", o.Name, err)
+			}
+		default:
+			continue
+		}
+
+		if !rewriteIngressBackends(ing, canaryServiceNames) {
+			continue
+		}
+
+		annotations := make(map[string]string, len(ing.Annotations)+2)
+		for k, v := range ing.Annotations {
+			annotations[k] = v
+		}
+		annotations[trafficutil.NginxCanaryAnnotation] = "true"
+		annotations[trafficutil.NginxCanaryWeightAnnotation] = "0"
+
+		ing.TypeMeta = metav1.TypeMeta{
+			APIVersion: networkingv1beta1.SchemeGroupVersion.String(),
+			Kind:       "Ingress",
+		}
+		ing.ObjectMeta = metav1.ObjectMeta{
+			Name:        trafficutil.NginxCanaryIngressName(ing.Name),
+			Namespace:   ing.Namespace,
+			Labels:      ing.Labels,
+			Annotations: annotations,
+		}
+
+		canaryObjects = append(canaryObjects, ing)
+	}
+
+	return canaryObjects, nil
+}
+
+func buildNginxCanaryService(it *shipper.InstallationTarget, prodSvc *corev1.Service, name string) *corev1.Service {
+	selector := make(map[string]string, len(prodSvc.Spec.Selector)+1)
+	for k, v := range prodSvc.Spec.Selector {
+		selector[k] = v
+	}
+	delete(selector, shipper.PodTrafficStatusLabel)
+	selector[shipper.ReleaseLabel] = it.Name
+
+	ports := make([]corev1.ServicePort, 0, len(prodSvc.Spec.Ports))
+	for _, port := range prodSvc.Spec.Ports {
+		port.NodePort = 0
+		ports = append(ports, port)
+	}
+
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: prodSvc.Namespace,
+			Labels: labels.Merge(labels.Set(it.Labels), labels.Set{
+				shipper.InstallationTargetOwnerLabel: it.Name,
+			}),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: selector,
+			Ports:    ports,
+		},
+	}
+}
+
+// rewriteIngressBackends points every backend of ing that routes to one of
+// the Services in renames to the Service it maps to instead, and returns
+// whether it found any.
+func rewriteIngressBackends(ing *networkingv1beta1.Ingress, renames map[string]string) bool {
+	found := false
+	rewrite := func(backend *networkingv1beta1.IngressBackend) {
+		if backend == nil {
+			return
+		}
+
+		if to, ok := renames[backend.ServiceName]; ok {
+			backend.ServiceName = to
+			found = true
+		}
+	}
+
+	rewrite(ing.Spec.Backend)
+	for i := range ing.Spec.Rules {
+		http := ing.Spec.Rules[i].HTTP
+		if http == nil {
+			continue
+		}
+
+		for j := range http.Paths {
+			rewrite(&http.Paths[j].Backend)
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,239 @@
+package installation
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippertesting "github.com/bookingcom/shipper/pkg/testing"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+func TestBuildNginxCanaryObjects(t *testing.T) {
+	appName := "reviews-api"
+	it := buildInstallationTarget("test-namespace", appName, []string{"minikube-a"}, nil)
+	it.Name = "reviews-api-deadbeef-0"
+
+	prodSvc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: appName,
+			Labels: map[string]string{
+				shipper.AppLabel: appName,
+				shipper.LBLabel:  shipper.LBForProduction,
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeNodePort,
+			Selector: map[string]string{
+				shipper.AppLabel:              appName,
+				shipper.PodTrafficStatusLabel: shipper.Enabled,
+			},
+			Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80, NodePort: 30080},
+			},
+		},
+	}
+
+	ingress := &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: appName,
+			Labels: map[string]string{
+				shipper.AppLabel: appName,
+			},
+			Annotations: map[string]string{
+				"kubernetes.io/ingress.class": "nginx",
+			},
+		},
+		Spec: extensionsv1beta1.IngressSpec{
+			Rules: []extensionsv1beta1.IngressRule{
+				{
+					Host: "reviews.example.com",
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: appName,
+										ServicePort: intstr.FromString("http"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// An Ingress that does not route to the production Service doesn't
+	// get a canary.
+	unrelated := &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+		Spec: networkingv1beta1.IngressSpec{
+			Backend: &networkingv1beta1.IngressBackend{
+				ServiceName: "admin",
+				ServicePort: intstr.FromInt(8080),
+			},
+		},
+	}
+
+	objects, err := buildNginxCanaryObjects(it, []runtime.Object{prodSvc, ingress, unrelated})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(objects) != 2 {
+		t.Fatalf("expected a canary Service and a canary Ingress, got %d objects", len(objects))
+	}
+
+	canaryServiceName := trafficutil.NginxCanaryServiceName(it.Name)
+
+	svc, ok := objects[0].(*corev1.Service)
+	if !ok {
+		t.Fatalf("expected a Service, got %T", objects[0])
+	}
+
+	expectedSvc := corev1.ServiceSpec{
+		Type: corev1.ServiceTypeClusterIP,
+		Selector: map[string]string{
+			shipper.AppLabel:     appName,
+			shipper.ReleaseLabel: it.Name,
+		},
+		Ports: []corev1.ServicePort{
+			{Name: "http", Port: 80},
+		},
+	}
+	if svc.Name != canaryServiceName {
+		t.Errorf("expected Service to be named %q, got %q", canaryServiceName, svc.Name)
+	}
+	eq, diff := shippertesting.DeepEqualDiff(expectedSvc, svc.Spec)
+	if !eq {
+		t.Errorf("canary Service differs from expected:\n%s", diff)
+	}
+
+	ing, ok := objects[1].(*networkingv1beta1.Ingress)
+	if !ok {
+		t.Fatalf("expected a networking/v1beta1 Ingress, got %T", objects[1])
+	}
+
+	expectedName := trafficutil.NginxCanaryIngressName(appName)
+	if ing.Name != expectedName {
+		t.Errorf("expected canary Ingress to be named %q, got %q", expectedName, ing.Name)
+	}
+
+	expectedAnnotations := map[string]string{
+		"kubernetes.io/ingress.class":           "nginx",
+		trafficutil.NginxCanaryAnnotation:       "true",
+		trafficutil.NginxCanaryWeightAnnotation: "0",
+	}
+	eq, diff = shippertesting.DeepEqualDiff(expectedAnnotations, ing.Annotations)
+	if !eq {
+		t.Errorf("canary Ingress annotations differ from expected:\n%s", diff)
+	}
+
+	backend := ing.Spec.Rules[0].HTTP.Paths[0].Backend
+	if backend.ServiceName != canaryServiceName {
+		t.Errorf("expected canary Ingress to route to %q, got %q", canaryServiceName, backend.ServiceName)
+	}
+
+	if ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName != appName {
+		t.Errorf("the chart's Ingress should not be modified")
+	}
+}
+
+func TestBuildNginxCanaryObjectsMultipleServices(t *testing.T) {
+	appName := "reviews-api"
+	it := buildInstallationTarget("test-namespace", appName, []string{"minikube-a"}, nil)
+	it.Name = "reviews-api-deadbeef-0"
+
+	buildProdService := func(name string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					shipper.AppLabel: appName,
+					shipper.LBLabel:  shipper.LBForProduction,
+				},
+			},
+			Spec: corev1.ServiceSpec{
+				Selector: map[string]string{
+					shipper.AppLabel:              appName,
+					shipper.PodTrafficStatusLabel: shipper.Enabled,
+				},
+			},
+		}
+	}
+
+	ingress := &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: appName},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host: "reviews.example.com",
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+							Paths: []networkingv1beta1.HTTPIngressPath{
+								{
+									Path:    "/",
+									Backend: networkingv1beta1.IngressBackend{ServiceName: "http"},
+								},
+								{
+									Path:    "/grpc",
+									Backend: networkingv1beta1.IngressBackend{ServiceName: "grpc"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	objects, err := buildNginxCanaryObjects(it, []runtime.Object{
+		buildProdService("http"),
+		buildProdService("grpc"),
+		ingress,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(objects) != 3 {
+		t.Fatalf("expected two canary Services and a canary Ingress, got %d objects", len(objects))
+	}
+
+	expectedNames := map[string]string{
+		"http": trafficutil.NginxCanaryServiceName(it.Name + "-http"),
+		"grpc": trafficutil.NginxCanaryServiceName(it.Name + "-grpc"),
+	}
+	for i, prodSvc := range []string{"http", "grpc"} {
+		svc, ok := objects[i].(*corev1.Service)
+		if !ok {
+			t.Fatalf("expected a Service, got %T", objects[i])
+		}
+
+		if svc.Name != expectedNames[prodSvc] {
+			t.Errorf("expected Service to be named %q, got %q", expectedNames[prodSvc], svc.Name)
+		}
+	}
+
+	ing, ok := objects[2].(*networkingv1beta1.Ingress)
+	if !ok {
+		t.Fatalf("expected a networking/v1beta1 Ingress, got %T", objects[2])
+	}
+
+	for i, prodSvc := range []string{"http", "grpc"} {
+		backend := ing.Spec.Rules[0].HTTP.Paths[i].Backend
+		if backend.ServiceName != expectedNames[prodSvc] {
+			t.Errorf("expected canary Ingress to route %q to %q, got %q",
+				prodSvc, expectedNames[prodSvc], backend.ServiceName)
+		}
+	}
+}
@@ -2,15 +2,18 @@ package installation
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
 	shipperchart "github.com/bookingcom/shipper/pkg/chart"
 	shipperrepo "github.com/bookingcom/shipper/pkg/chart/repo"
 	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	workloadutil "github.com/bookingcom/shipper/pkg/util/workload"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	kubescheme "k8s.io/client-go/kubernetes/scheme"
@@ -62,30 +65,43 @@ func prepareObjects(it *shipper.InstallationTarget, manifests []string) ([]runti
 				UniversalDeserializer().
 				Decode([]byte(manifest), nil, nil)
 
+		if runtime.IsNotRegisteredError(err) {
+			// Custom resources are installed as they come,
+			// since we don't know their types.
+			decodedObj, err = shipperchart.DecodeUnstructured(manifest)
+		}
+
 		if err != nil {
 			return nil, shippererrors.NewDecodeManifestError("error decoding manifest: %s", err)
 		}
 
 		switch obj := decodedObj.(type) {
 		case *appsv1.Deployment:
-			// We need the Deployment in the chart to have a unique
-			// name, meaning that different installations need to
-			// generate Deployments with different names,
-			// otherwise, we try to overwrite a previous
-			// Deployment, and that fails with a "field is
-			// immutable" error.
-			deploymentName := obj.Name
-			expectedName := it.Name
-			if !strings.Contains(deploymentName, expectedName) {
-				return nil, shippererrors.NewInvalidChartError(
-					fmt.Sprintf("Deployment %q has invalid name."+
-						" The name of the Deployment should be"+
-						" templated with {{.Release.Name}}.",
-						deploymentName),
-				)
+			err := validateWorkloadName(it, "Deployment", obj.Name)
+			if err != nil {
+				return nil, err
 			}
 
 			decodedObj = patchDeployment(obj, shipperLabels)
+		case *appsv1.StatefulSet:
+			err := validateWorkloadName(it, "StatefulSet", obj.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			decodedObj = patchStatefulSet(obj, shipperLabels)
+		case *unstructured.Unstructured:
+			if workloadutil.IsMarked(obj) {
+				err := validateWorkloadName(it, obj.GetKind(), obj.GetName())
+				if err != nil {
+					return nil, err
+				}
+
+				err = patchWorkload(obj, shipperLabels)
+				if err != nil {
+					return nil, err
+				}
+			}
 		case *corev1.Service:
 			allServices = append(allServices, obj)
 
@@ -93,6 +109,10 @@ func prepareObjects(it *shipper.InstallationTarget, manifests []string) ([]runti
 			if ok && lbValue == shipper.LBForProduction {
 				productionLBServices = append(productionLBServices, obj)
 			}
+		default:
+			if hpa, ok := shipperchart.AsHorizontalPodAutoscaler(obj); ok {
+				patchHorizontalPodAutoscaler(obj.(metav1.Object), hpa)
+			}
 		}
 
 		obj := decodedObj.(kubeobj)
@@ -110,31 +130,96 @@ func prepareObjects(it *shipper.InstallationTarget, manifests []string) ([]runti
 		productionLBServices = allServices
 	}
 
-	// If, after all, we still can not identify a single Service which will
-	// be the production LB, there is nothing else to do rather than bail
-	// out
-	if len(productionLBServices) != 1 {
+	// If, after all, we still can not identify any Service which will be
+	// a production LB, there is nothing else to do rather than bail out.
+	// Charts may have several of them, and traffic is shifted through all
+	// of them alike.
+	if len(productionLBServices) == 0 {
 		return nil, shippererrors.NewInvalidChartError(
 			fmt.Sprintf(
-				"one and only one v1.Service object with label %q is required, but %d found instead",
-				shipper.LBLabel, len(productionLBServices)))
+				"at least one v1.Service object with label %q is required, but none found among %d",
+				shipper.LBLabel, len(allServices)))
 	}
 
-	err := patchService(it, productionLBServices[0])
-	if err != nil {
-		return nil, err
+	for _, svc := range productionLBServices {
+		err := patchService(it, svc)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return preparedObjects, nil
 }
 
+// validateWorkloadName checks that the Deployment or StatefulSet in the
+// chart has a unique name. Different installations need to generate them
+// with different names, otherwise we try to overwrite a previous one, and
+// that fails with a "field is immutable" error.
+func validateWorkloadName(it *shipper.InstallationTarget, kind, name string) error {
+	if strings.Contains(name, it.Name) {
+		return nil
+	}
+
+	return shippererrors.NewInvalidChartError(
+		fmt.Sprintf("%s %q has invalid name."+
+			" The name of the %s should be"+
+			" templated with {{.Release.Name}}.",
+			kind, name, kind),
+	)
+}
+
 func patchDeployment(d *appsv1.Deployment, labelsToInject map[string]string) runtime.Object {
 	replicas := int32(0)
 	d.Spec.Replicas = &replicas
+	d.Spec.Selector = patchPodSelection(d.Spec.Selector, &d.Spec.Template, labelsToInject)
 
+	return d
+}
+
+func patchStatefulSet(s *appsv1.StatefulSet, labelsToInject map[string]string) runtime.Object {
+	replicas := int32(0)
+	s.Spec.Replicas = &replicas
+	s.Spec.Selector = patchPodSelection(s.Spec.Selector, &s.Spec.Template, labelsToInject)
+
+	return s
+}
+
+// patchWorkload does for workloads marked with shipper.WorkloadLabel what
+// patchDeployment does for Deployments, as far as we can tell where things
+// are. Their selectors are left alone, as we don't know what they look like.
+func patchWorkload(obj *unstructured.Unstructured, labelsToInject map[string]string) error {
+	err := unstructured.SetNestedField(obj.Object, int64(0), workloadutil.ReplicasPath(obj)...)
+	if err != nil {
+		return shippererrors.NewInvalidChartError(
+			fmt.Sprintf("cannot set replicas of %s %q: %s", obj.GetKind(), obj.GetName(), err))
+	}
+
+	templateLabelsPath := []string{"spec", "template", "metadata", "labels"}
+	if _, ok, _ := unstructured.NestedMap(obj.Object, "spec", "template"); !ok {
+		return nil
+	}
+
+	podTemplateLabels, _, _ := unstructured.NestedStringMap(obj.Object, templateLabelsPath...)
+	err = unstructured.SetNestedStringMap(obj.Object,
+		labels.Merge(podTemplateLabels, labelsToInject), templateLabelsPath...)
+	if err != nil {
+		return shippererrors.NewInvalidChartError(
+			fmt.Sprintf("cannot set pod template labels of %s %q: %s", obj.GetKind(), obj.GetName(), err))
+	}
+
+	return nil
+}
+
+// patchPodSelection injects labels into a pod template and returns a copy
+// of selector that selects on them as well.
+func patchPodSelection(
+	selector *metav1.LabelSelector,
+	template *corev1.PodTemplateSpec,
+	labelsToInject map[string]string,
+) *metav1.LabelSelector {
 	var newSelector *metav1.LabelSelector
-	if d.Spec.Selector != nil {
-		newSelector = d.Spec.Selector.DeepCopy()
+	if selector != nil {
+		newSelector = selector.DeepCopy()
 	} else {
 		newSelector = &metav1.LabelSelector{
 			MatchLabels: map[string]string{},
@@ -144,15 +229,27 @@ func patchDeployment(d *appsv1.Deployment, labelsToInject map[string]string) run
 	for k, v := range labelsToInject {
 		newSelector.MatchLabels[k] = v
 	}
-	d.Spec.Selector = newSelector
 
-	podTemplateLabels := d.Spec.Template.Labels
+	podTemplateLabels := template.Labels
 	for k, v := range labelsToInject {
 		podTemplateLabels[k] = v
 	}
-	d.Spec.Template.SetLabels(podTemplateLabels)
+	template.SetLabels(podTemplateLabels)
 
-	return d
+	return newSelector
+}
+
+// patchHorizontalPodAutoscaler annotates an HPA with the minReplicas in the
+// chart, so the capacity controller can scale it over the course of a
+// rollout without losing track of it.
+func patchHorizontalPodAutoscaler(obj metav1.Object, hpa *shipperchart.HorizontalPodAutoscaler) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[shipper.HPAMinReplicasAnnotation] = strconv.Itoa(int(hpa.MinReplicas))
+	obj.SetAnnotations(annotations)
 }
 
 func patchService(it *shipper.InstallationTarget, s *corev1.Service) error {
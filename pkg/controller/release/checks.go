@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"sort"
 
+	"k8s.io/apimachinery/pkg/util/intstr"
+
 	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	capacityutil "github.com/bookingcom/shipper/pkg/util/capacity"
+	releaseutil "github.com/bookingcom/shipper/pkg/util/release"
 	targetutil "github.com/bookingcom/shipper/pkg/util/target"
 )
 
@@ -12,27 +16,40 @@ func checkInstallation(it *shipper.InstallationTarget) (bool, string) {
 	return targetutil.IsReady(it.Status.Conditions)
 }
 
+// clusterStepValue returns the value a strategy step defines for a given
+// cluster.
+type clusterStepValue func(cluster string) intstr.IntOrString
+
 func checkCapacity(
 	ct *shipper.CapacityTarget,
-	stepCapacity int32,
+	stepCapacity clusterStepValue,
 ) (
 	bool,
 	*shipper.CapacityTargetSpec,
 	string,
 ) {
 	canProceed := true
-	newSpec := &shipper.CapacityTargetSpec{}
+	newSpec := &shipper.CapacityTargetSpec{Workload: ct.Spec.Workload}
 	reason := ""
 
 	clustersNotReadyMap := make(map[string]struct{})
 	for _, spec := range ct.Spec.Clusters {
+		value, isPercent, err := releaseutil.ParseStepValue(stepCapacity(spec.Name))
+		if err != nil {
+			return false, nil, fmt.Sprintf("%s: %s", spec.Name, err)
+		}
+
 		t := spec
-		if spec.Percent != stepCapacity {
+		if !capacityMatches(spec, value, isPercent) {
 			t = shipper.ClusterCapacityTarget{
 				Name:              spec.Name,
-				Percent:           stepCapacity,
 				TotalReplicaCount: spec.TotalReplicaCount,
 			}
+			if isPercent {
+				t.Percent = value
+			} else {
+				t.Replicas = &value
+			}
 
 			clustersNotReadyMap[spec.Name] = struct{}{}
 			canProceed = false
@@ -79,7 +96,7 @@ func checkCapacity(
 
 func checkTraffic(
 	tt *shipper.TrafficTarget,
-	stepTrafficWeight uint32,
+	stepTrafficWeight clusterStepValue,
 ) (
 	bool,
 	*shipper.TrafficTargetSpec,
@@ -91,11 +108,21 @@ func checkTraffic(
 
 	clustersNotReadyMap := make(map[string]struct{})
 	for _, spec := range tt.Spec.Clusters {
+		value, isPercent, err := releaseutil.ParseStepValue(stepTrafficWeight(spec.Name))
+		if err != nil {
+			return false, nil, fmt.Sprintf("%s: %s", spec.Name, err)
+		}
+
 		t := spec
-		if spec.Weight != stepTrafficWeight {
+		if !trafficMatches(spec, uint32(value), isPercent) {
 			t = shipper.ClusterTrafficTarget{
-				Name:   spec.Name,
-				Weight: stepTrafficWeight,
+				Name: spec.Name,
+			}
+			if isPercent {
+				t.Weight = uint32(value)
+			} else {
+				pods := uint32(value)
+				t.Pods = &pods
 			}
 
 			clustersNotReadyMap[spec.Name] = struct{}{}
@@ -140,3 +167,42 @@ func checkTraffic(
 
 	return canProceed, newSpec, reason
 }
+
+// capacityMatches tells whether a cluster capacity target already asks for
+// the capacity of a strategy step.
+func capacityMatches(spec shipper.ClusterCapacityTarget, value int32, isPercent bool) bool {
+	if isPercent {
+		return spec.Replicas == nil && spec.Percent == value
+	}
+	return spec.Replicas != nil && *spec.Replicas == value
+}
+
+// trafficMatches tells whether a cluster traffic target already asks for
+// the traffic of a strategy step.
+func trafficMatches(spec shipper.ClusterTrafficTarget, value uint32, isPercent bool) bool {
+	if isPercent {
+		return spec.Pods == nil && spec.Weight == value
+	}
+	return spec.Pods != nil && *spec.Pods == value
+}
+
+// capacityDecreases returns whether newSpec asks for fewer replicas than ct
+// currently does in any of its clusters.
+func capacityDecreases(ct *shipper.CapacityTarget, newSpec *shipper.CapacityTargetSpec) bool {
+	if newSpec == nil {
+		return false
+	}
+
+	current := make(map[string]int32, len(ct.Spec.Clusters))
+	for _, spec := range ct.Spec.Clusters {
+		current[spec.Name] = capacityutil.DesiredReplicaCount(spec)
+	}
+
+	for _, spec := range newSpec.Clusters {
+		if replicas, ok := current[spec.Name]; ok && capacityutil.DesiredReplicaCount(spec) < replicas {
+			return true
+		}
+	}
+
+	return false
+}
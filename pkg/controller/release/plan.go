@@ -0,0 +1,267 @@
+package release
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shipperrepo "github.com/bookingcom/shipper/pkg/chart/repo"
+	"github.com/bookingcom/shipper/pkg/controller"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	capacityutil "github.com/bookingcom/shipper/pkg/util/capacity"
+)
+
+// maxPlanIterations bounds how many times the strategy executor is run for a
+// single step while planning. Every run either patches one target object or
+// moves on, so a step converges long before this.
+const maxPlanIterations = 10
+
+// RolloutPlan describes, step by step, how Shipper would roll a contender
+// release out on top of an incumbent.
+type RolloutPlan struct {
+	Contender string
+	Incumbent string
+	Steps     []PlannedStep
+}
+
+// PlannedStep is the state of every cluster once a strategy step has been
+// achieved.
+type PlannedStep struct {
+	Name     string
+	Clusters []PlannedCluster
+}
+
+// PlannedCluster holds what the contender and the incumbent get in a single
+// cluster. Either is nil if that release doesn't run in the cluster.
+type PlannedCluster struct {
+	Name      string
+	Contender *PlannedRelease
+	Incumbent *PlannedRelease
+}
+
+// PlannedRelease is the capacity and traffic a release gets in a cluster.
+// Traffic is either a weight or, for absolute step values, a number of pods.
+type PlannedRelease struct {
+	Replicas          int32
+	TotalReplicaCount int32
+	Weight            uint32
+	Pods              *uint32
+}
+
+// Traffic returns a human readable description of the traffic a release gets.
+func (r *PlannedRelease) Traffic() string {
+	if r.Pods != nil {
+		return fmt.Sprintf("%d pods", *r.Pods)
+	}
+	return fmt.Sprintf("weight %d", r.Weight)
+}
+
+// Plan computes what Shipper would do to roll out contender, without writing
+// anything. It schedules the releases on clusterList the same way the release
+// controller does, fetches their charts to find out the replica count, and
+// walks the strategy executor through every step as if the target objects
+// achieved every spec immediately. incumbent may be nil.
+func Plan(
+	contender, incumbent *shipper.Release,
+	clusterList []*shipper.Cluster,
+	chartFetcher shipperrepo.ChartFetcher,
+) (*RolloutPlan, error) {
+	strategy := contender.Spec.Environment.Strategy
+	if strategy == nil || len(strategy.Steps) == 0 {
+		return nil, shippererrors.NewInvalidStrategyError(
+			"release %q has no strategy steps", controller.MetaKey(contender))
+	}
+
+	plan := &RolloutPlan{Contender: contender.Name}
+
+	curr, err := planReleaseInfo(contender, clusterList, chartFetcher)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev *releaseInfo
+	if incumbent != nil {
+		plan.Incumbent = incumbent.Name
+		prev, err = planReleaseInfo(incumbent, clusterList, chartFetcher)
+		if err != nil {
+			return nil, err
+		}
+
+		// The incumbent has finished its own rollout by the time the
+		// contender shows up.
+		for i := range prev.capacityTarget.Spec.Clusters {
+			prev.capacityTarget.Spec.Clusters[i].Percent = 100
+		}
+		for i := range prev.trafficTarget.Spec.Clusters {
+			prev.trafficTarget.Spec.Clusters[i].Weight = 100
+		}
+	}
+
+	for step := range strategy.Steps {
+		executor := NewStrategyExecutor(strategy, int32(step), nil, 0)
+
+		converged := false
+		for i := 0; i < maxPlanIterations; i++ {
+			_, patches, _ := executor.Execute(prev, curr, nil)
+
+			applied := false
+			for _, patch := range patches {
+				if applyPlanPatch(patch, curr) || applyPlanPatch(patch, prev) {
+					applied = true
+				}
+			}
+
+			if !applied {
+				converged = true
+				break
+			}
+		}
+
+		if !converged {
+			return nil, fmt.Errorf("step %d (%q) did not converge after %d iterations",
+				step, strategy.Steps[step].Name, maxPlanIterations)
+		}
+
+		plan.Steps = append(plan.Steps, PlannedStep{
+			Name:     strategy.Steps[step].Name,
+			Clusters: plannedClusters(curr, prev),
+		})
+	}
+
+	return plan, nil
+}
+
+// planReleaseInfo builds in-memory target objects for rel, the same as the
+// scheduler would create them, and marks them as ready.
+func planReleaseInfo(
+	rel *shipper.Release,
+	clusterList []*shipper.Cluster,
+	chartFetcher shipperrepo.ChartFetcher,
+) (*releaseInfo, error) {
+	rel = rel.DeepCopy()
+
+	if !releaseHasClusters(rel) {
+		if rel.Annotations == nil {
+			rel.Annotations = map[string]string{}
+		}
+
+		clusters, err := computeTargetClusters(rel, clusterList)
+		if err != nil {
+			return nil, err
+		}
+		setReleaseClusters(rel, clusters)
+	}
+	clusters := getReleaseClusters(rel)
+
+	chart, err := chartFetcher(&rel.Spec.Environment.Chart)
+	if err != nil {
+		return nil, err
+	}
+
+	replicaCount, workload, err := extractReplicasFromChartForRel(chart, rel)
+	if err != nil {
+		return nil, err
+	}
+
+	objectMeta := metav1.ObjectMeta{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+	}
+	ready := []shipper.TargetCondition{
+		{
+			Type:   shipper.TargetConditionTypeReady,
+			Status: corev1.ConditionTrue,
+		},
+	}
+
+	it := &shipper.InstallationTarget{ObjectMeta: objectMeta}
+	setInstallationTargetClusters(it, clusters)
+	it.Status.Conditions = ready
+
+	ct := &shipper.CapacityTarget{ObjectMeta: objectMeta}
+	setCapacityTargetClusters(ct, clusters, replicaCount)
+	ct.Spec.Workload = workload
+	ct.Status.Conditions = ready
+
+	tt := &shipper.TrafficTarget{ObjectMeta: objectMeta}
+	setTrafficTargetClusters(tt, clusters)
+	tt.Status.Conditions = ready
+
+	return &releaseInfo{
+		release:            rel,
+		installationTarget: it,
+		capacityTarget:     ct,
+		trafficTarget:      tt,
+	}, nil
+}
+
+// applyPlanPatch applies a capacity or traffic target patch to the in-memory
+// target objects of relinfo, if the patch is meant for them. Any other patch
+// is ignored, as it doesn't change capacity or traffic.
+func applyPlanPatch(patch StrategyPatch, relinfo *releaseInfo) bool {
+	if relinfo == nil {
+		return false
+	}
+
+	switch p := patch.(type) {
+	case *CapacityTargetSpecPatch:
+		if p.Name != relinfo.release.Name || p.IsEmpty() {
+			return false
+		}
+		relinfo.capacityTarget.Spec = *p.NewSpec
+		return true
+	case *TrafficTargetSpecPatch:
+		if p.Name != relinfo.release.Name || p.IsEmpty() {
+			return false
+		}
+		relinfo.trafficTarget.Spec = *p.NewSpec
+		return true
+	}
+
+	return false
+}
+
+// plannedClusters captures the current state of the target objects of the
+// contender and incumbent, ordered as the contender's clusters.
+func plannedClusters(curr, prev *releaseInfo) []PlannedCluster {
+	clusters := make([]PlannedCluster, 0, len(curr.capacityTarget.Spec.Clusters))
+	seen := map[string]int{}
+
+	add := func(relinfo *releaseInfo, isContender bool) {
+		for _, ct := range relinfo.capacityTarget.Spec.Clusters {
+			planned := &PlannedRelease{
+				Replicas:          capacityutil.DesiredReplicaCount(ct),
+				TotalReplicaCount: ct.TotalReplicaCount,
+			}
+			for _, tt := range relinfo.trafficTarget.Spec.Clusters {
+				if tt.Name == ct.Name {
+					planned.Weight = tt.Weight
+					planned.Pods = tt.Pods
+					break
+				}
+			}
+
+			i, ok := seen[ct.Name]
+			if !ok {
+				i = len(clusters)
+				seen[ct.Name] = i
+				clusters = append(clusters, PlannedCluster{Name: ct.Name})
+			}
+
+			if isContender {
+				clusters[i].Contender = planned
+			} else {
+				clusters[i].Incumbent = planned
+			}
+		}
+	}
+
+	add(curr, true)
+	if prev != nil {
+		add(prev, false)
+	}
+
+	return clusters
+}
@@ -0,0 +1,103 @@
+package release
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+func TestPlan(t *testing.T) {
+	clusters := []*shipper.Cluster{buildCluster("minikube-a"), buildCluster("minikube-b")}
+	two := int32(2)
+
+	incumbent := buildRelease()
+	incumbent.Name = "test-incumbent"
+	incumbent.Spec.Environment.ClusterRequirements.Regions[0].Replicas = &two
+
+	contender := buildRelease()
+	contender.Spec.Environment.ClusterRequirements.Regions[0].Replicas = &two
+	contender.Name = "test-contender"
+	strategy := vanguard.DeepCopy()
+	strategy.Steps[0].Capacity.Contender = intstr.FromString("3")
+	strategy.Steps[0].Traffic.Contender = intstr.FromString("1")
+	contender.Spec.Environment.Strategy = strategy
+
+	plan, err := Plan(contender, incumbent, clusters, localFetchChart)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(plan.Steps) != len(strategy.Steps) {
+		t.Fatalf("expected %d steps, got %d", len(strategy.Steps), len(plan.Steps))
+	}
+
+	type expectation struct {
+		contenderReplicas, incumbentReplicas int32
+		contenderTraffic, incumbentTraffic   string
+	}
+	expected := []expectation{
+		{3, 12, "1 pods", "weight 100"},
+		{6, 6, "weight 50", "weight 50"},
+		{12, 0, "weight 100", "weight 0"},
+	}
+
+	for i, step := range plan.Steps {
+		if step.Name != strategy.Steps[i].Name {
+			t.Errorf("expected step %d to be named %q, got %q", i, strategy.Steps[i].Name, step.Name)
+		}
+
+		if len(step.Clusters) != len(clusters) {
+			t.Fatalf("expected step %q to have %d clusters, got %d", step.Name, len(clusters), len(step.Clusters))
+		}
+
+		e := expected[i]
+		for _, c := range step.Clusters {
+			if c.Contender == nil || c.Incumbent == nil {
+				t.Fatalf("expected both releases in cluster %q at step %q", c.Name, step.Name)
+			}
+
+			got := expectation{
+				c.Contender.Replicas, c.Incumbent.Replicas,
+				c.Contender.Traffic(), c.Incumbent.Traffic(),
+			}
+			if got != e {
+				t.Errorf("step %q cluster %q: expected %+v, got %+v", step.Name, c.Name, e, got)
+			}
+		}
+	}
+}
+
+func TestPlanWithoutIncumbent(t *testing.T) {
+	clusters := []*shipper.Cluster{buildCluster("minikube")}
+
+	plan, err := Plan(buildRelease(), nil, clusters, localFetchChart)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	last := plan.Steps[len(plan.Steps)-1]
+	if len(last.Clusters) != 1 {
+		t.Fatalf("expected 1 cluster, got %d", len(last.Clusters))
+	}
+
+	c := last.Clusters[0]
+	if c.Incumbent != nil {
+		t.Errorf("expected no incumbent, got %+v", c.Incumbent)
+	}
+	if c.Contender.Replicas != c.Contender.TotalReplicaCount {
+		t.Errorf("expected contender to have all %d replicas, got %d", c.Contender.TotalReplicaCount, c.Contender.Replicas)
+	}
+}
+
+func TestPlanNotEnoughClusters(t *testing.T) {
+	rel := buildRelease()
+	two := int32(2)
+	rel.Spec.Environment.ClusterRequirements.Regions[0].Replicas = &two
+
+	_, err := Plan(rel, nil, []*shipper.Cluster{buildCluster("minikube")}, localFetchChart)
+	if err == nil {
+		t.Fatalf("expected an error scheduling on too few clusters")
+	}
+}
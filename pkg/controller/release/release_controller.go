@@ -13,11 +13,14 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformers "k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 
+	"github.com/bookingcom/shipper/pkg/analysis"
 	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
 	shipperrepo "github.com/bookingcom/shipper/pkg/chart/repo"
 	shipperclient "github.com/bookingcom/shipper/pkg/client/clientset/versioned"
@@ -39,9 +42,21 @@ const (
 )
 
 const (
-	ClustersNotReady = "ClustersNotReady"
+	ClustersNotReady   = "ClustersNotReady"
+	AnalysisInProgress = "AnalysisInProgress"
+	AnalysisFailed     = "AnalysisFailed"
+	AnalysisError      = "AnalysisError"
+
+	StepDeadlineExceeded = "StepDeadlineExceeded"
+	StepNotApproved      = "StepNotApproved"
+
+	Draining = "Draining"
 )
 
+// analysisInterval is how often releases going through an analysis get
+// their metrics checked again.
+const analysisInterval = 30 * time.Second
+
 // Controller is a Kubernetes controller whose role is to pick up a newly created
 // release and progress it forward by scheduling the release on a set of
 // selected clusters, creating a set of associated objects and executing the
@@ -71,10 +86,18 @@ type Controller struct {
 	rolloutBlockLister shipperlisters.RolloutBlockLister
 	rolloutBlockSynced cache.InformerSynced
 
+	releaseApprovalLister  shipperlisters.ReleaseApprovalLister
+	releaseApprovalsSynced cache.InformerSynced
+
+	namespaceLister  corelisters.NamespaceLister
+	namespacesSynced cache.InformerSynced
+
 	releaseWorkqueue workqueue.RateLimitingInterface
 
 	chartFetcher shipperrepo.ChartFetcher
 
+	analysisProvider analysis.Provider
+
 	recorder record.EventRecorder
 }
 
@@ -95,7 +118,9 @@ func NewController(
 	clientset shipperclient.Interface,
 	store clusterclientstore.Interface,
 	informerFactory shipperinformers.SharedInformerFactory,
+	kubeInformerFactory kubeinformers.SharedInformerFactory,
 	chartFetcher shipperrepo.ChartFetcher,
+	analysisProvider analysis.Provider,
 	recorder record.EventRecorder,
 ) *Controller {
 
@@ -106,6 +131,8 @@ func NewController(
 	trafficTargetInformer := informerFactory.Shipper().V1alpha1().TrafficTargets()
 	capacityTargetInformer := informerFactory.Shipper().V1alpha1().CapacityTargets()
 	rolloutBlockInformer := informerFactory.Shipper().V1alpha1().RolloutBlocks()
+	releaseApprovalInformer := informerFactory.Shipper().V1alpha1().ReleaseApprovals()
+	namespaceInformer := kubeInformerFactory.Core().V1().Namespaces()
 
 	klog.Info("Building a release controller")
 
@@ -134,6 +161,12 @@ func NewController(
 		rolloutBlockLister: rolloutBlockInformer.Lister(),
 		rolloutBlockSynced: rolloutBlockInformer.Informer().HasSynced,
 
+		releaseApprovalLister:  releaseApprovalInformer.Lister(),
+		releaseApprovalsSynced: releaseApprovalInformer.Informer().HasSynced,
+
+		namespaceLister:  namespaceInformer.Lister(),
+		namespacesSynced: namespaceInformer.Informer().HasSynced,
+
 		releaseWorkqueue: workqueue.NewNamedRateLimitingQueue(
 			shipperworkqueue.NewDefaultControllerRateLimiter(),
 			"release_controller_releases",
@@ -141,6 +174,8 @@ func NewController(
 
 		chartFetcher: chartFetcher,
 
+		analysisProvider: analysisProvider,
+
 		recorder: recorder,
 	}
 
@@ -160,6 +195,15 @@ func NewController(
 			DeleteFunc: controller.enqueueReleaseFromRolloutBlock,
 		})
 
+	releaseApprovalInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: controller.enqueueReleaseFromApproval,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				controller.enqueueReleaseFromApproval(newObj)
+			},
+			DeleteFunc: controller.enqueueReleaseFromApproval,
+		})
+
 	eventHandler := cache.ResourceEventHandlerFuncs{
 		AddFunc: controller.enqueueReleaseFromAssociatedObject,
 		UpdateFunc: func(oldObj, newObj interface{}) {
@@ -192,6 +236,8 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) {
 		c.trafficTargetsSynced,
 		c.capacityTargetsSynced,
 		c.rolloutBlockSynced,
+		c.releaseApprovalsSynced,
+		c.namespacesSynced,
 	); !ok {
 		runtime.HandleError(fmt.Errorf("failed to wait for caches to sync"))
 		return
@@ -416,6 +462,16 @@ func (c *Controller) executeReleaseStrategy(relinfo *releaseInfo, diff *diffutil
 		return nil, nil, err
 	}
 
+	// Releases are meant to always carry a strategy, but the ones that
+	// were created by hand or bypassed the webhook might not, and there
+	// is nothing to execute then.
+	for _, r := range []*shipper.Release{rel, succ} {
+		if r != nil && r.Spec.Environment.Strategy == nil {
+			err := fmt.Errorf("no strategy in Release %q", controller.MetaKey(r))
+			return nil, nil, shippererrors.NewUnrecoverableError(err)
+		}
+	}
+
 	var relinfoPrev, relinfoSucc *releaseInfo
 	if prev != nil {
 		relinfoPrev, err = c.buildReleaseInfo(prev)
@@ -445,7 +501,7 @@ func (c *Controller) executeReleaseStrategy(relinfo *releaseInfo, diff *diffutil
 	}
 
 	isHead := succ == nil
-	var strategy *shipper.RolloutStrategy
+	var strategy *shipper.RolloutStrategySpec
 	var targetStep int32
 	// A head release uses it's local spec-defined strategy, any other release
 	// follows it's successor state, therefore looking into the forecoming spec.
@@ -464,7 +520,28 @@ func (c *Controller) executeReleaseStrategy(relinfo *releaseInfo, diff *diffutil
 		return nil, nil, shippererrors.NewUnrecoverableError(err)
 	}
 
-	executor := NewStrategyExecutor(strategy, targetStep)
+	// In namespaces that require approvals, the steps are only taken as
+	// far as the head release has been approved for.
+	head := rel
+	if !isHead {
+		head = succ
+	}
+	approvedStep, approvalRequired, err := c.approvedTargetStep(head)
+	if err != nil {
+		return nil, nil, err
+	}
+	if isHead {
+		reportReleaseApproval(rel, strategy, approvedStep, approvalRequired, diff)
+	}
+	isApproved := approvedStep == targetStep
+	targetStep = approvedStep
+
+	drainDelay, err := c.drainDelay(rel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	executor := NewStrategyExecutor(strategy, targetStep, c.analysisProvider, drainDelay)
 
 	complete, patches, trans := executor.Execute(relinfoPrev, relinfo, relinfoSucc)
 
@@ -482,6 +559,25 @@ func (c *Controller) executeReleaseStrategy(relinfo *releaseInfo, diff *diffutil
 	)
 	diff.Append(releaseutil.SetReleaseCondition(&rel.Status, *condition))
 
+	if isHead && strategy.Steps[targetStep].Analysis != nil {
+		c.reportReleaseAnalysis(rel, patches, targetStep, diff)
+	}
+
+	if isHead && relinfoPrev != nil {
+		c.checkIncumbentDraining(rel, patches, targetStep, drainDelay)
+	}
+
+	if isHead {
+		releaseutil.UpdateTimeline(
+			&rel.Status,
+			targetStep,
+			strategy.Steps[targetStep].Name,
+			strategyConditionsForRelease(rel, patches),
+			complete,
+		)
+		c.checkStepDeadline(rel, strategy.Steps[targetStep], targetStep, diff)
+	}
+
 	isLastStep := int(targetStep) == len(strategy.Steps)-1
 	prevStep := rel.Status.AchievedStep
 
@@ -497,8 +593,9 @@ func (c *Controller) executeReleaseStrategy(relinfo *releaseInfo, diff *diffutil
 		}
 		if prevStep == nil || achievedStep != prevStep.Step {
 			rel.Status.AchievedStep = &shipper.AchievedStep{
-				Step: achievedStep,
-				Name: achievedStepName,
+				Step:       achievedStep,
+				Name:       achievedStepName,
+				AchievedAt: metav1.Now(),
 			}
 			c.recorder.Eventf(
 				rel,
@@ -517,6 +614,8 @@ func (c *Controller) executeReleaseStrategy(relinfo *releaseInfo, diff *diffutil
 				"",
 			)
 			diff.Append(releaseutil.SetReleaseCondition(&rel.Status, *condition))
+		} else if isHead && isApproved {
+			c.advanceReleaseStrategy(rel, strategy.Steps[targetStep])
 		}
 	}
 
@@ -535,6 +634,275 @@ func (c *Controller) executeReleaseStrategy(relinfo *releaseInfo, diff *diffutil
 	return rel, patches, nil
 }
 
+// strategyConditionsForRelease returns the strategy conditions the release
+// will have once patches are applied, falling back to the ones it currently
+// has if the strategy executor didn't produce any for it.
+func strategyConditionsForRelease(rel *shipper.Release, patches []StrategyPatch) conditions.StrategyConditionsMap {
+	for _, patch := range patches {
+		if p, ok := patch.(*ReleaseStrategyStatusPatch); ok && p.Name == rel.Name && !p.IsEmpty() {
+			return conditions.NewStrategyConditions(p.NewStrategyStatus.Conditions...)
+		}
+	}
+
+	if rel.Status.Strategy != nil {
+		return conditions.NewStrategyConditions(rel.Status.Strategy.Conditions...)
+	}
+
+	return conditions.NewStrategyConditions()
+}
+
+// reportReleaseAnalysis reflects the outcome of the analysis of the given
+// step in the release conditions. While the analysis is ongoing, the release
+// is put back in the workqueue so its metrics keep being checked.
+func (c *Controller) reportReleaseAnalysis(rel *shipper.Release, patches []StrategyPatch, step int32, diff *diffutil.MultiDiff) {
+	// The patches carry the most recent view of the strategy
+	// conditions; if there are none, nothing has changed since the
+	// last time we looked.
+	var strategyConditions []shipper.ReleaseStrategyCondition
+	if rel.Status.Strategy != nil {
+		strategyConditions = rel.Status.Strategy.Conditions
+	}
+	for _, patch := range patches {
+		if p, ok := patch.(*ReleaseStrategyStatusPatch); ok && p.Name == rel.Name {
+			strategyConditions = p.NewStrategyStatus.Conditions
+		}
+	}
+
+	cond, ok := conditions.NewStrategyConditions(strategyConditions...).
+		GetCondition(shipper.StrategyConditionContenderAchievedAnalysis)
+	if !ok || cond.Step != step {
+		return
+	}
+
+	switch {
+	case cond.Status == corev1.ConditionTrue:
+		condition := releaseutil.NewReleaseCondition(
+			shipper.ReleaseConditionTypeAnalysisFailed,
+			corev1.ConditionFalse,
+			"",
+			"",
+		)
+		diff.Append(releaseutil.SetReleaseCondition(&rel.Status, *condition))
+	case cond.Reason == AnalysisFailed:
+		condition := releaseutil.NewReleaseCondition(
+			shipper.ReleaseConditionTypeAnalysisFailed,
+			corev1.ConditionTrue,
+			AnalysisFailed,
+			cond.Message,
+		)
+		if d := releaseutil.SetReleaseCondition(&rel.Status, *condition); !d.IsEmpty() {
+			diff.Append(d)
+			c.recorder.Event(
+				rel,
+				corev1.EventTypeWarning,
+				"ReleaseAnalysisFailed",
+				cond.Message,
+			)
+		}
+	default:
+		c.enqueueReleaseAfter(rel, analysisInterval)
+	}
+}
+
+// drainDelay returns how long the incumbent of the application a release
+// belongs to needs to drain before its capacity is reduced.
+func (c *Controller) drainDelay(rel *shipper.Release) (time.Duration, error) {
+	appName, err := releaseutil.ApplicationNameForRelease(rel)
+	if err != nil {
+		return 0, err
+	}
+
+	app, err := c.applicationLister.Applications(rel.Namespace).Get(appName)
+	if errors.IsNotFound(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, shippererrors.NewKubeclientGetError(rel.Namespace, appName, err).
+			WithShipperKind("Application")
+	}
+
+	if app.Spec.DrainDelay == nil {
+		return 0, nil
+	}
+
+	return app.Spec.DrainDelay.Duration, nil
+}
+
+// checkIncumbentDraining puts the release back in the workqueue once the
+// incumbent is done draining, as nothing else might trigger a sync by then.
+func (c *Controller) checkIncumbentDraining(rel *shipper.Release, patches []StrategyPatch, step int32, drainDelay time.Duration) {
+	cond := strategyConditionsForRelease(rel, patches)
+	capacity, ok := cond.GetCondition(shipper.StrategyConditionIncumbentAchievedCapacity)
+	if !ok || capacity.Step != step || capacity.Reason != Draining {
+		return
+	}
+
+	if remaining := drainRemaining(cond, drainDelay); remaining > 0 {
+		c.enqueueReleaseAfter(rel, remaining)
+	}
+}
+
+// checkStepDeadline reflects in the StepTimedOut release condition whether
+// the contender and incumbent reached the capacity and traffic of the target
+// step within its deadline. The deadline is counted from the moment the step
+// was first targeted, as recorded in the release timeline. While it hasn't
+// expired, the release is put back in the workqueue to be checked again once
+// it does.
+func (c *Controller) checkStepDeadline(rel *shipper.Release, step shipper.RolloutStrategyStep, stepIdx int32, diff *diffutil.MultiDiff) {
+	timedOut := releaseutil.NewReleaseCondition(
+		shipper.ReleaseConditionTypeStepTimedOut,
+		corev1.ConditionFalse,
+		"",
+		"",
+	)
+
+	if step.Deadline == nil {
+		// Only clear a condition left over by a previous step, so
+		// releases that don't use deadlines don't get it at all.
+		if releaseutil.GetReleaseCondition(rel.Status, shipper.ReleaseConditionTypeStepTimedOut) != nil {
+			diff.Append(releaseutil.SetReleaseCondition(&rel.Status, *timedOut))
+		}
+		return
+	}
+
+	n := len(rel.Status.Timeline)
+	if n == 0 || rel.Status.Timeline[n-1].Step != stepIdx {
+		return
+	}
+	entry := rel.Status.Timeline[n-1]
+
+	achieved := entry.CapacityAchievedAt != nil && entry.TrafficAchievedAt != nil
+
+	// A zero timestamp means we don't know when the step was
+	// targeted, so we can't tell whether it's late.
+	if !achieved && !entry.TargetedAt.IsZero() {
+		deadline := step.Deadline.Duration
+		remaining := deadline - time.Since(entry.TargetedAt.Time)
+		if remaining > 0 {
+			c.enqueueReleaseAfter(rel, remaining)
+		} else {
+			timedOut = releaseutil.NewReleaseCondition(
+				shipper.ReleaseConditionTypeStepTimedOut,
+				corev1.ConditionTrue,
+				StepDeadlineExceeded,
+				fmt.Sprintf(
+					"step %d (%q) did not achieve capacity and traffic within %s",
+					stepIdx, step.Name, deadline),
+			)
+		}
+	}
+
+	d := releaseutil.SetReleaseCondition(&rel.Status, *timedOut)
+	if d.IsEmpty() {
+		return
+	}
+
+	diff.Append(d)
+	if timedOut.Status == corev1.ConditionTrue {
+		c.recorder.Event(rel, corev1.EventTypeWarning, "ReleaseStepTimedOut", timedOut.Message)
+	}
+}
+
+// approvedTargetStep returns the strategy step the given head release may
+// work towards, and whether its namespace requires approvals at all. Unless
+// it does, that is simply the target step of the release.
+func (c *Controller) approvedTargetStep(rel *shipper.Release) (int32, bool, error) {
+	ns, err := c.namespaceLister.Get(rel.Namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return rel.Spec.TargetStep, false, nil
+		}
+		return 0, false, shippererrors.NewKubeclientGetError("", rel.Namespace, err).
+			WithCoreV1Kind("Namespace")
+	}
+
+	if ns.Labels[shipper.ApprovalRequiredLabel] != "true" {
+		return rel.Spec.TargetStep, false, nil
+	}
+
+	approvals, err := c.releaseApprovalLister.ReleaseApprovals(rel.Namespace).List(labels.Everything())
+	if err != nil {
+		return 0, false, shippererrors.NewKubeclientListError(
+			shipper.SchemeGroupVersion.WithKind("ReleaseApproval"),
+			rel.Namespace, labels.Everything(), err)
+	}
+
+	return releaseutil.ApprovedTargetStep(rel, approvals), true, nil
+}
+
+// reportReleaseApproval reflects in the AwaitingApproval release condition
+// whether the target step of a release is held back for lack of approval.
+func reportReleaseApproval(rel *shipper.Release, strategy *shipper.RolloutStrategySpec, approvedStep int32, required bool, diff *diffutil.MultiDiff) {
+	awaiting := releaseutil.NewReleaseCondition(
+		shipper.ReleaseConditionTypeAwaitingApproval,
+		corev1.ConditionFalse,
+		"",
+		"",
+	)
+
+	if !required {
+		// Only clear a condition left over from when the namespace
+		// required approvals, so other releases don't get it at all.
+		if releaseutil.GetReleaseCondition(rel.Status, shipper.ReleaseConditionTypeAwaitingApproval) != nil {
+			diff.Append(releaseutil.SetReleaseCondition(&rel.Status, *awaiting))
+		}
+		return
+	}
+
+	if targetStep := rel.Spec.TargetStep; approvedStep != targetStep {
+		awaiting = releaseutil.NewReleaseCondition(
+			shipper.ReleaseConditionTypeAwaitingApproval,
+			corev1.ConditionTrue,
+			StepNotApproved,
+			fmt.Sprintf(
+				"step %d (%q) has not been approved, holding at step %d (%q)",
+				targetStep, strategy.Steps[targetStep].Name,
+				approvedStep, strategy.Steps[approvedStep].Name),
+		)
+	}
+
+	diff.Append(releaseutil.SetReleaseCondition(&rel.Status, *awaiting))
+}
+
+// advanceReleaseStrategy moves a head release on to its next strategy step
+// if the step it has just achieved is marked for auto-advance and its pause
+// has elapsed. While the pause is still running, the release is put back in
+// the workqueue to be re-evaluated once it's due.
+func (c *Controller) advanceReleaseStrategy(rel *shipper.Release, step shipper.RolloutStrategyStep) {
+	if !step.AutoAdvance {
+		return
+	}
+
+	var pause time.Duration
+	if step.Pause != nil {
+		pause = step.Pause.Duration
+	}
+
+	// A zero timestamp means we don't know when the step was achieved
+	// (e.g. it was achieved before Shipper started recording it), so
+	// we consider its pause long elapsed.
+	achievedAt := rel.Status.AchievedStep.AchievedAt
+	if !achievedAt.IsZero() {
+		remaining := pause - time.Since(achievedAt.Time)
+		if remaining > 0 {
+			klog.V(4).Infof("Release %q step %d is paused, will advance in %s",
+				controller.MetaKey(rel), rel.Spec.TargetStep, remaining)
+			c.enqueueReleaseAfter(rel, remaining)
+			return
+		}
+	}
+
+	rel.Spec.TargetStep++
+
+	c.recorder.Eventf(
+		rel,
+		corev1.EventTypeNormal,
+		"StrategyAutoAdvanced",
+		"step [%d] auto-advanced to step [%d]",
+		rel.Spec.TargetStep-1,
+		rel.Spec.TargetStep,
+	)
+}
+
 func (c *Controller) applyPatch(namespace string, patch StrategyPatch) error {
 	name, gvk, b := patch.PatchSpec()
 
@@ -678,6 +1046,22 @@ func (c *Controller) enqueueReleaseRateLimited(obj interface{}) {
 	c.releaseWorkqueue.AddRateLimited(key)
 }
 
+func (c *Controller) enqueueReleaseAfter(obj interface{}, duration time.Duration) {
+	rel, ok := obj.(*shipper.Release)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("not a shipper.Release: %#v", obj))
+		return
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(rel)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+
+	c.releaseWorkqueue.AddAfter(key, duration)
+}
+
 func (c *Controller) enqueueReleaseFromRolloutBlock(obj interface{}) {
 	_, ok := obj.(*shipper.RolloutBlock)
 	if !ok {
@@ -697,6 +1081,28 @@ func (c *Controller) enqueueReleaseFromRolloutBlock(obj interface{}) {
 	}
 }
 
+func (c *Controller) enqueueReleaseFromApproval(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	approval, ok := obj.(*shipper.ReleaseApproval)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("not a shipper.ReleaseApproval: %#v", obj))
+		return
+	}
+
+	rel, err := c.releaseLister.Releases(approval.Namespace).Get(approval.Spec.Release)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			runtime.HandleError(err)
+		}
+		return
+	}
+
+	c.enqueueReleaseAndNeighbours(rel)
+}
+
 func (c *Controller) enqueueReleaseFromAssociatedObject(obj interface{}) {
 	kubeobj, ok := obj.(metav1.Object)
 	if !ok {
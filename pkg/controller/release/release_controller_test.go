@@ -13,11 +13,15 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
 	kubetesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/record"
 
+	"github.com/bookingcom/shipper/pkg/analysis"
 	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
 	shipperfake "github.com/bookingcom/shipper/pkg/client/clientset/versioned/fake"
 	shipperinformers "github.com/bookingcom/shipper/pkg/client/informers/externalversions"
@@ -32,34 +36,35 @@ func init() {
 	apputil.ConditionsShouldDiscardTimestamps = true
 	releaseutil.ConditionsShouldDiscardTimestamps = true
 	conditions.StrategyConditionsShouldDiscardTimestamps = true
+	releaseutil.TimelineShouldDiscardTimestamps = true
 }
 
-var vanguard = shipper.RolloutStrategy{
+var vanguard = shipper.RolloutStrategySpec{
 	Steps: []shipper.RolloutStrategyStep{
 		{
 			Name:     "staging",
-			Capacity: shipper.RolloutStrategyStepValue{Incumbent: 100, Contender: 1},
-			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 100, Contender: 0},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(1)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(0)},
 		},
 		{
 			Name:     "50/50",
-			Capacity: shipper.RolloutStrategyStepValue{Incumbent: 50, Contender: 50},
-			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 50, Contender: 50},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(50), Contender: intstr.FromInt(50)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(50), Contender: intstr.FromInt(50)},
 		},
 		{
 			Name:     "full on",
-			Capacity: shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
-			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
 		},
 	},
 }
 
-var fullon = shipper.RolloutStrategy{
+var fullon = shipper.RolloutStrategySpec{
 	Steps: []shipper.RolloutStrategyStep{
 		{
 			Name:     "full on",
-			Capacity: shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
-			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
 		},
 	},
 }
@@ -144,11 +149,16 @@ type fixture struct {
 	t               *testing.T
 	cycles          int
 	objects         []runtime.Object
+	kubeObjects     []runtime.Object
 	clientset       *shipperfake.Clientset
 	store           *shippertesting.FakeClusterClientStore
 	informerFactory shipperinformers.SharedInformerFactory
 	recorder        *record.FakeRecorder
 
+	kubeInformerFactory kubeinformers.SharedInformerFactory
+
+	analysisProvider analysis.Provider
+
 	actions        []kubetesting.Action
 	filter         actionfilter
 	receivedEvents []string
@@ -180,6 +190,8 @@ func (f *fixture) run() {
 	informerFactory := shipperinformers.NewSharedInformerFactory(f.clientset, syncPeriod)
 
 	f.informerFactory = informerFactory
+	f.kubeInformerFactory = kubeinformers.NewSharedInformerFactory(
+		kubefake.NewSimpleClientset(f.kubeObjects...), syncPeriod)
 	f.recorder = record.NewFakeRecorder(42)
 
 	controller := f.newController()
@@ -189,6 +201,8 @@ func (f *fixture) run() {
 
 	f.informerFactory.Start(stopCh)
 	f.informerFactory.WaitForCacheSync(stopCh)
+	f.kubeInformerFactory.Start(stopCh)
+	f.kubeInformerFactory.WaitForCacheSync(stopCh)
 
 	wait.PollUntil(
 		10*time.Millisecond,
@@ -226,7 +240,9 @@ func (f *fixture) newController() *Controller {
 		f.clientset,
 		f.store,
 		f.informerFactory,
+		f.kubeInformerFactory,
 		localFetchChart,
+		f.analysisProvider,
 		f.recorder,
 	)
 }
@@ -654,6 +670,20 @@ func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
+// buildCapacityAchievedTimeline returns the timeline of a release targeting
+// the given step that has already achieved installation and capacity, as
+// recorded with timestamps discarded.
+func buildCapacityAchievedTimeline(step int32, name string) []shipper.ReleaseStepTimeline {
+	return []shipper.ReleaseStepTimeline{
+		{
+			Step:                   step,
+			Name:                   name,
+			InstallationAchievedAt: &metav1.Time{},
+			CapacityAchievedAt:     &metav1.Time{},
+		},
+	}
+}
+
 func addCluster(ri *releaseInfo, cluster *shipper.Cluster) {
 	clusters := getReleaseClusters(ri.release)
 	exists := false
@@ -897,6 +927,10 @@ func (f *fixture) expectReleaseScheduled(release *shipper.Release, clusters []*s
 		{Type: shipper.ReleaseConditionTypeScheduled, Status: corev1.ConditionTrue},
 		{Type: shipper.ReleaseConditionTypeStrategyExecuted, Status: corev1.ConditionTrue},
 	}
+	step := release.Spec.TargetStep
+	expected.Status.Timeline = []shipper.ReleaseStepTimeline{
+		{Step: step, Name: release.Spec.Environment.Strategy.Steps[step].Name},
+	}
 
 	f.filter = f.filter.Extend(actionfilter{[]string{"update"}, []string{"releases"}})
 	f.actions = append(f.actions, buildExpectedActions(expected, clusters)...)
@@ -1641,6 +1675,8 @@ func TestContenderDoNothingClusterTrafficNotReady(t *testing.T) {
 	incumbent.capacityTarget.Spec.Clusters[0].Percent = 50
 	incumbent.capacityTarget.Spec.Clusters[0].TotalReplicaCount = totalReplicaCount
 
+	contender.release.Status.Timeline = buildCapacityAchievedTimeline(1, "50/50")
+
 	f.addObjects(
 		contender.release.DeepCopy(),
 		contender.installationTarget.DeepCopy(),
@@ -2173,6 +2209,138 @@ func TestIncumbentCapacityShouldNotDecreaseWithRolloutBlock(t *testing.T) {
 	f.run()
 }
 
+func TestIncumbentCapacityWaitsForDrain(t *testing.T) {
+	namespace := "test-namespace"
+	incumbentName, contenderName := "test-incumbent", "test-contender"
+	drainDelay := time.Hour
+
+	tests := []struct {
+		name            string
+		trafficAchieved time.Time
+		expectReduction bool
+	}{
+		{"traffic just changed", time.Time{}, false},
+		{"traffic changed long ago", time.Now().Add(-2 * drainDelay), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := buildApplication(namespace, "test-app")
+			app.Spec.DrainDelay = &metav1.Duration{Duration: drainDelay}
+			cluster := buildCluster("minikube")
+
+			f := newFixture(t, app.DeepCopy(), cluster.DeepCopy())
+			f.cycles = 1
+
+			totalReplicaCount := int32(10)
+			contender := f.buildContender(namespace, contenderName, totalReplicaCount)
+			incumbent := f.buildIncumbent(namespace, incumbentName, totalReplicaCount)
+
+			step := int32(1)
+			contender.release.Spec.TargetStep = step
+			contender.capacityTarget.Spec.Clusters[0].Percent = 50
+			contender.capacityTarget.Spec.Clusters[0].TotalReplicaCount = totalReplicaCount
+			contender.trafficTarget.Spec.Clusters[0].Weight = 50
+			contender.release.Status.AchievedStep = &shipper.AchievedStep{Step: step}
+
+			incumbent.trafficTarget.Spec.Clusters[0].Weight = 50
+
+			// Without a record of when the incumbent achieved its
+			// traffic, it does so during this sync.
+			if !tt.trafficAchieved.IsZero() {
+				contender.release.Status.Strategy = &shipper.ReleaseStrategyStatus{
+					Conditions: []shipper.ReleaseStrategyCondition{
+						{
+							Type:               shipper.StrategyConditionIncumbentAchievedTraffic,
+							Status:             corev1.ConditionTrue,
+							Step:               step,
+							LastTransitionTime: metav1.NewTime(tt.trafficAchieved),
+						},
+					},
+				}
+			}
+
+			f.addObjects(
+				contender.release.DeepCopy(),
+				contender.installationTarget.DeepCopy(),
+				contender.capacityTarget.DeepCopy(),
+				contender.trafficTarget.DeepCopy(),
+
+				incumbent.release.DeepCopy(),
+				incumbent.installationTarget.DeepCopy(),
+				incumbent.capacityTarget.DeepCopy(),
+				incumbent.trafficTarget.DeepCopy(),
+			)
+
+			ct := incumbent.capacityTarget.DeepCopy()
+			r := contender.release.DeepCopy()
+			if tt.expectReduction {
+				f.expectCapacityStatusPatch(step, ct, r, 50, uint(totalReplicaCount), Incumbent)
+				f.run()
+				return
+			}
+
+			f.filter = f.filter.Extend(actionfilter{
+				[]string{"patch"},
+				[]string{"releases", "capacitytargets"},
+			})
+
+			strategyConditions := conditions.NewStrategyConditions(
+				shipper.ReleaseStrategyCondition{
+					Type:   shipper.StrategyConditionContenderAchievedInstallation,
+					Status: corev1.ConditionTrue,
+					Step:   step,
+				},
+				shipper.ReleaseStrategyCondition{
+					Type:   shipper.StrategyConditionContenderAchievedCapacity,
+					Status: corev1.ConditionTrue,
+					Step:   step,
+				},
+				shipper.ReleaseStrategyCondition{
+					Type:   shipper.StrategyConditionContenderAchievedTraffic,
+					Status: corev1.ConditionTrue,
+					Step:   step,
+				},
+				shipper.ReleaseStrategyCondition{
+					Type:   shipper.StrategyConditionIncumbentAchievedTraffic,
+					Status: corev1.ConditionTrue,
+					Step:   step,
+				},
+				shipper.ReleaseStrategyCondition{
+					Type:   shipper.StrategyConditionIncumbentAchievedCapacity,
+					Status: corev1.ConditionFalse,
+					Step:   step,
+					Reason: Draining,
+					Message: fmt.Sprintf(
+						"release %q is waiting %s for its connections to drain before reducing its capacity",
+						incumbentName, drainDelay),
+				},
+			)
+
+			newStatus := map[string]interface{}{
+				"status": shipper.ReleaseStatus{
+					Strategy: &shipper.ReleaseStrategyStatus{
+						Conditions: strategyConditions.AsReleaseStrategyConditions(),
+						State:      strategyConditions.AsReleaseStrategyState(step, true, false),
+					},
+				},
+			}
+			patch, _ := json.Marshal(newStatus)
+			f.actions = append(f.actions, kubetesting.NewPatchAction(
+				shipper.SchemeGroupVersion.WithResource("releases"),
+				r.GetNamespace(),
+				r.GetName(),
+				types.MergePatchType,
+				patch))
+
+			f.expectedEvents = []string{
+				"Normal ReleaseConditionChanged [] -> [Scheduled True], [] -> [StrategyExecuted True]",
+			}
+			f.run()
+		})
+	}
+}
+
 func TestContenderReleasePhaseIsWaitingForCommandForFinalStepState(t *testing.T) {
 	namespace := "test-namespace"
 	incumbentName, contenderName := "test-incumbent", "test-contender"
@@ -2265,17 +2433,17 @@ func TestApplicationExposesStrategyFailureIndexOutOfBounds(t *testing.T) {
 	missingStepMsg := fmt.Sprintf("failed to execute strategy: \"no step 2 in strategy for Release \\\"%s/%s\\\"\"", contender.release.Namespace, contender.release.Name)
 
 	// We define 2 steps and will intentionally set target step index out of this bound
-	strategy := shipper.RolloutStrategy{
+	strategy := shipper.RolloutStrategySpec{
 		Steps: []shipper.RolloutStrategyStep{
 			{
 				Name:     "staging",
-				Capacity: shipper.RolloutStrategyStepValue{Incumbent: 100, Contender: 1},
-				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 100, Contender: 0},
+				Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(1)},
+				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(0)},
 			},
 			{
 				Name:     "full on",
-				Capacity: shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
-				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
+				Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
+				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
 			},
 		},
 	}
@@ -2328,6 +2496,69 @@ func TestApplicationExposesStrategyFailureIndexOutOfBounds(t *testing.T) {
 	f.run()
 }
 
+func TestApplicationExposesStrategyFailureNoStrategy(t *testing.T) {
+	namespace := "test-namespace"
+	incumbentName, contenderName := "test-incumbent", "test-contender"
+	app := buildApplication(namespace, "test-app")
+
+	cluster := buildCluster("minikube")
+
+	f := newFixture(t, app.DeepCopy(), cluster.DeepCopy())
+	f.cycles = 1
+
+	totalReplicaCount := int32(1)
+	contender := f.buildContender(namespace, contenderName, totalReplicaCount)
+	incumbent := f.buildIncumbent(namespace, incumbentName, totalReplicaCount)
+
+	noStrategyMsg := fmt.Sprintf("failed to execute strategy: \"no strategy in Release \\\"%s/%s\\\"\"", contender.release.Namespace, contender.release.Name)
+
+	contender.release.Spec.Environment.Strategy = nil
+
+	expectedRel := contender.release.DeepCopy()
+	expectedRel.Status.Conditions = []shipper.ReleaseCondition{
+		{
+			Type:   shipper.ReleaseConditionTypeBlocked,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   shipper.ReleaseConditionTypeScheduled,
+			Status: corev1.ConditionTrue,
+		},
+		{
+			Type:    shipper.ReleaseConditionTypeStrategyExecuted,
+			Status:  corev1.ConditionFalse,
+			Reason:  conditions.StrategyExecutionFailed,
+			Message: noStrategyMsg,
+		},
+	}
+
+	f.addObjects(
+		contender.release.DeepCopy(),
+		contender.installationTarget.DeepCopy(),
+		contender.capacityTarget.DeepCopy(),
+		contender.trafficTarget.DeepCopy(),
+
+		incumbent.release.DeepCopy(),
+		incumbent.installationTarget.DeepCopy(),
+		incumbent.capacityTarget.DeepCopy(),
+		incumbent.trafficTarget.DeepCopy(),
+	)
+
+	f.actions = append(f.actions, kubetesting.NewUpdateAction(
+		shipper.SchemeGroupVersion.WithResource("releases"),
+		namespace,
+		expectedRel))
+
+	f.filter = f.filter.Extend(actionfilter{
+		[]string{"update"},
+		[]string{"releases"},
+	})
+	f.expectedEvents = append(f.expectedEvents,
+		fmt.Sprintf("Normal ReleaseConditionChanged [] -> [Scheduled True], [] -> [StrategyExecuted False StrategyExecutionFailed %s]", noStrategyMsg))
+
+	f.run()
+}
+
 func TestApplicationExposesStrategyFailureSuccessorIndexOutOfBounds(t *testing.T) {
 	namespace := "test-namespace"
 	incumbentName, contenderName := "test-incumbent", "test-contender"
@@ -2345,17 +2576,17 @@ func TestApplicationExposesStrategyFailureSuccessorIndexOutOfBounds(t *testing.T
 	incumbent := f.buildIncumbent(namespace, incumbentName, totalReplicaCount)
 
 	// We define 2 steps and will intentionally set target step index out of this bound
-	strategyStaging := shipper.RolloutStrategy{
+	strategyStaging := shipper.RolloutStrategySpec{
 		Steps: []shipper.RolloutStrategyStep{
 			{
 				Name:     "staging",
-				Capacity: shipper.RolloutStrategyStepValue{Incumbent: 100, Contender: 1},
-				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 100, Contender: 0},
+				Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(1)},
+				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(0)},
 			},
 			{
 				Name:     "full on",
-				Capacity: shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
-				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
+				Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
+				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
 			},
 		},
 	}
@@ -2530,6 +2761,8 @@ func TestWaitingOnContenderTrafficProducesNoPatches(t *testing.T) {
 			corev1.ConditionFalse,
 			ClustersNotReady, "[minikube]"))
 
+	contender.release.Status.Timeline = buildCapacityAchievedTimeline(1, "50/50")
+
 	f.addObjects(
 		contender.release.DeepCopy(),
 		contender.installationTarget.DeepCopy(),
@@ -2589,6 +2822,8 @@ func TestWaitingOnIncumbentTrafficProducesNoPatches(t *testing.T) {
 			corev1.ConditionFalse,
 			ClustersNotReady, "[minikube]"))
 
+	contender.release.Status.Timeline = buildCapacityAchievedTimeline(1, "50/50")
+
 	f.addObjects(
 		contender.release.DeepCopy(),
 		contender.installationTarget.DeepCopy(),
@@ -2688,6 +2923,9 @@ func TestControllerChooseClusters(t *testing.T) {
 	releaseutil.SetReleaseCondition(&expected.Status, *condScheduled)
 	condStrategyExecuted := releaseutil.NewReleaseCondition(shipper.ReleaseConditionTypeStrategyExecuted, corev1.ConditionTrue, "", "")
 	releaseutil.SetReleaseCondition(&expected.Status, *condStrategyExecuted)
+	expected.Status.Timeline = []shipper.ReleaseStepTimeline{
+		{Step: 0, Name: "staging"},
+	}
 
 	f.actions = []kubetesting.Action{
 		kubetesting.NewUpdateAction(
@@ -2745,6 +2983,9 @@ func TestControllerChooseClustersSkipsUnschedulable(t *testing.T) {
 	releaseutil.SetReleaseCondition(&expected.Status, *condScheduled)
 	condStrategyExecuted := releaseutil.NewReleaseCondition(shipper.ReleaseConditionTypeStrategyExecuted, corev1.ConditionTrue, "", "")
 	releaseutil.SetReleaseCondition(&expected.Status, *condStrategyExecuted)
+	expected.Status.Timeline = []shipper.ReleaseStepTimeline{
+		{Step: 0, Name: "staging"},
+	}
 
 	f.actions = []kubetesting.Action{
 		kubetesting.NewUpdateAction(
@@ -2925,6 +3166,8 @@ func TestUnhealthyTrafficAndCapacityIncumbentConvergesConsistently(t *testing.T)
 		},
 	}
 
+	contender.release.Status.Timeline = buildCapacityAchievedTimeline(2, "full on")
+
 	expected := contender.release.DeepCopy()
 	condScheduled := releaseutil.NewReleaseCondition(shipper.ReleaseConditionTypeScheduled, corev1.ConditionTrue, "", "")
 	releaseutil.SetReleaseCondition(&expected.Status, *condScheduled)
@@ -3054,3 +3297,570 @@ func TestControllerDetectInconsistentTargetStep(t *testing.T) {
 
 	f.run()
 }
+
+func TestContenderReleaseAutoAdvancesToNextStep(t *testing.T) {
+	tests := []struct {
+		name               string
+		pause              *metav1.Duration
+		expectedTargetStep int32
+	}{
+		{"without a pause", nil, 1},
+		{"with a pause that has not elapsed", &metav1.Duration{Duration: time.Hour}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := "test-namespace"
+			app := buildApplication(namespace, "test-app")
+			cluster := buildCluster("minikube")
+
+			incumbentName, contenderName := "test-incumbent", "test-contender"
+			app.Status.History = []string{incumbentName, contenderName}
+			f := newFixture(t, app.DeepCopy(), cluster.DeepCopy())
+			f.cycles = 1
+
+			totalReplicaCount := int32(10)
+			incumbent := f.buildIncumbent(namespace, incumbentName, totalReplicaCount)
+			contender := f.buildContender(namespace, contenderName, totalReplicaCount)
+
+			strategy := vanguard.DeepCopy()
+			strategy.Steps[0].AutoAdvance = true
+			strategy.Steps[0].Pause = tt.pause
+			contender.release.Spec.Environment.Strategy = strategy
+
+			contender.capacityTarget.Spec.Clusters[0].Percent = 1
+			incumbent.capacityTarget.Spec.Clusters[0].Percent = 100
+
+			f.addObjects(
+				contender.release.DeepCopy(),
+				contender.installationTarget.DeepCopy(),
+				contender.capacityTarget.DeepCopy(),
+				contender.trafficTarget.DeepCopy(),
+
+				incumbent.release.DeepCopy(),
+				incumbent.installationTarget.DeepCopy(),
+				incumbent.capacityTarget.DeepCopy(),
+				incumbent.trafficTarget.DeepCopy(),
+			)
+
+			var step int32 = 0
+			f.expectReleaseWaitingForCommand(contender.release, step)
+			if tt.expectedTargetStep != step {
+				f.expectedEvents = append(
+					f.expectedEvents[:1],
+					append(
+						[]string{fmt.Sprintf("Normal StrategyAutoAdvanced step [%d] auto-advanced to step [%d]", step, tt.expectedTargetStep)},
+						f.expectedEvents[1:]...,
+					)...,
+				)
+			}
+			f.run()
+
+			rel, err := f.clientset.ShipperV1alpha1().Releases(namespace).Get(contenderName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get release %q: %s", contenderName, err)
+			}
+
+			if rel.Spec.TargetStep != tt.expectedTargetStep {
+				t.Fatalf("expected target step %d, got %d", tt.expectedTargetStep, rel.Spec.TargetStep)
+			}
+		})
+	}
+}
+
+func TestContenderReleaseStepDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		targeted time.Duration
+		timedOut bool
+	}{
+		{"within the deadline", time.Minute, false},
+		{"past the deadline", time.Hour, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := "test-namespace"
+			app := buildApplication(namespace, "test-app")
+			cluster := buildCluster("minikube")
+
+			incumbentName, contenderName := "test-incumbent", "test-contender"
+			app.Status.History = []string{incumbentName, contenderName}
+			f := newFixture(t, app.DeepCopy(), cluster.DeepCopy())
+			f.cycles = 1
+
+			totalReplicaCount := int32(10)
+			incumbent := f.buildIncumbent(namespace, incumbentName, totalReplicaCount)
+			contender := f.buildContender(namespace, contenderName, totalReplicaCount)
+
+			var step int32 = 0
+			strategy := vanguard.DeepCopy()
+			strategy.Steps[step].Deadline = &metav1.Duration{Duration: 10 * time.Minute}
+			contender.release.Spec.Environment.Strategy = strategy
+			contender.release.Status.Timeline = []shipper.ReleaseStepTimeline{
+				{
+					Step:       step,
+					Name:       strategy.Steps[step].Name,
+					TargetedAt: metav1.NewTime(time.Now().Add(-tt.targeted)),
+				},
+			}
+
+			// The contender is stuck getting its capacity.
+			contender.capacityTarget.Spec.Clusters[0].Percent = 1
+			contender.capacityTarget.Status.Conditions, _ = targetutil.SetTargetCondition(
+				contender.capacityTarget.Status.Conditions,
+				targetutil.NewTargetCondition(
+					shipper.TargetConditionTypeReady,
+					corev1.ConditionFalse,
+					ClustersNotReady, "[minikube]"))
+			incumbent.capacityTarget.Spec.Clusters[0].Percent = 100
+
+			f.addObjects(
+				contender.release.DeepCopy(),
+				contender.installationTarget.DeepCopy(),
+				contender.capacityTarget.DeepCopy(),
+				contender.trafficTarget.DeepCopy(),
+
+				incumbent.release.DeepCopy(),
+				incumbent.installationTarget.DeepCopy(),
+				incumbent.capacityTarget.DeepCopy(),
+				incumbent.trafficTarget.DeepCopy(),
+			)
+
+			expected := contender.release.DeepCopy()
+			expected.Status.Conditions = []shipper.ReleaseCondition{
+				{Type: shipper.ReleaseConditionTypeBlocked, Status: corev1.ConditionFalse},
+				{Type: shipper.ReleaseConditionTypeScheduled, Status: corev1.ConditionTrue},
+				{Type: shipper.ReleaseConditionTypeStepTimedOut, Status: corev1.ConditionFalse},
+				{Type: shipper.ReleaseConditionTypeStrategyExecuted, Status: corev1.ConditionTrue},
+			}
+			expected.Status.Timeline[0].InstallationAchievedAt = &metav1.Time{}
+
+			conditionChange := "[] -> [StepTimedOut False]"
+			if tt.timedOut {
+				message := fmt.Sprintf(`step %d (%q) did not achieve capacity and traffic within 10m0s`, step, strategy.Steps[step].Name)
+				expected.Status.Conditions[2] = shipper.ReleaseCondition{
+					Type:    shipper.ReleaseConditionTypeStepTimedOut,
+					Status:  corev1.ConditionTrue,
+					Reason:  StepDeadlineExceeded,
+					Message: message,
+				}
+				conditionChange = fmt.Sprintf("[] -> [StepTimedOut True %s %s]", StepDeadlineExceeded, message)
+				f.expectedEvents = append(f.expectedEvents,
+					fmt.Sprintf("Warning ReleaseStepTimedOut %s", message))
+			}
+
+			f.filter = f.filter.Extend(actionfilter{
+				[]string{"update"},
+				[]string{"releases"},
+			})
+			f.actions = append(f.actions, kubetesting.NewUpdateAction(
+				shipper.SchemeGroupVersion.WithResource("releases"),
+				namespace,
+				expected))
+
+			f.expectedEvents = append(f.expectedEvents,
+				fmt.Sprintf("Normal ReleaseConditionChanged [] -> [Scheduled True], [] -> [StrategyExecuted True], %s", conditionChange))
+
+			f.run()
+		})
+	}
+}
+
+type fakeAnalysisProvider struct {
+	value float64
+}
+
+func (p fakeAnalysisProvider) Query(query string, ts time.Time) (float64, error) {
+	return p.value, nil
+}
+
+func TestContenderReleaseAnalysis(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  float64
+		failed bool
+	}{
+		{"within threshold", 0.01, false},
+		{"above threshold", 0.5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := "test-namespace"
+			app := buildApplication(namespace, "test-app")
+			cluster := buildCluster("minikube")
+
+			incumbentName, contenderName := "test-incumbent", "test-contender"
+			app.Status.History = []string{incumbentName, contenderName}
+			f := newFixture(t, app.DeepCopy(), cluster.DeepCopy())
+			f.cycles = 1
+			f.analysisProvider = fakeAnalysisProvider{value: tt.value}
+
+			totalReplicaCount := int32(10)
+			incumbent := f.buildIncumbent(namespace, incumbentName, totalReplicaCount)
+			contender := f.buildContender(namespace, contenderName, totalReplicaCount)
+
+			strategy := vanguard.DeepCopy()
+			strategy.Steps[0].Analysis = &shipper.RolloutStrategyStepAnalysis{
+				Queries: []shipper.AnalysisQuery{
+					{Name: "error-rate", Query: "errors", Threshold: "0.1"},
+				},
+			}
+			contender.release.Spec.Environment.Strategy = strategy
+
+			contender.capacityTarget.Spec.Clusters[0].Percent = 1
+			incumbent.capacityTarget.Spec.Clusters[0].Percent = 100
+
+			f.addObjects(
+				contender.release.DeepCopy(),
+				contender.installationTarget.DeepCopy(),
+				contender.capacityTarget.DeepCopy(),
+				contender.trafficTarget.DeepCopy(),
+
+				incumbent.release.DeepCopy(),
+				incumbent.installationTarget.DeepCopy(),
+				incumbent.capacityTarget.DeepCopy(),
+				incumbent.trafficTarget.DeepCopy(),
+			)
+
+			var step int32 = 0
+			message := fmt.Sprintf("release %q has failed analysis: error-rate=0.5 (threshold 0.1)", contenderName)
+			relKey := fmt.Sprintf("%s/%s", namespace, contenderName)
+
+			analysisCondition := shipper.ReleaseStrategyCondition{
+				Type:   shipper.StrategyConditionContenderAchievedAnalysis,
+				Status: corev1.ConditionTrue,
+				Step:   step,
+			}
+			waitingForCommand := shipper.StrategyStateTrue
+			if tt.failed {
+				analysisCondition.Status = corev1.ConditionFalse
+				analysisCondition.Reason = AnalysisFailed
+				analysisCondition.Message = message
+				waitingForCommand = shipper.StrategyStateFalse
+			}
+
+			f.filter = f.filter.Extend(actionfilter{
+				[]string{"patch"},
+				[]string{"applications", "releases"},
+			})
+
+			relPatch, _ := json.Marshal(map[string]interface{}{
+				"status": map[string]interface{}{
+					"strategy": shipper.ReleaseStrategyStatus{
+						State: shipper.ReleaseStrategyState{
+							WaitingForInstallation: shipper.StrategyStateFalse,
+							WaitingForCommand:      waitingForCommand,
+							WaitingForTraffic:      shipper.StrategyStateFalse,
+							WaitingForCapacity:     shipper.StrategyStateFalse,
+						},
+						Conditions: []shipper.ReleaseStrategyCondition{
+							analysisCondition,
+							{
+								Type:   shipper.StrategyConditionContenderAchievedCapacity,
+								Status: corev1.ConditionTrue,
+								Step:   step,
+							},
+							{
+								Type:   shipper.StrategyConditionContenderAchievedInstallation,
+								Status: corev1.ConditionTrue,
+								Step:   step,
+							},
+							{
+								Type:   shipper.StrategyConditionContenderAchievedTraffic,
+								Status: corev1.ConditionTrue,
+								Step:   step,
+							},
+							{
+								Type:   shipper.StrategyConditionIncumbentAchievedCapacity,
+								Status: corev1.ConditionTrue,
+								Step:   step,
+							},
+							{
+								Type:   shipper.StrategyConditionIncumbentAchievedTraffic,
+								Status: corev1.ConditionTrue,
+								Step:   step,
+							},
+						},
+					},
+				},
+			})
+
+			// The application is left alone either way: a failed
+			// release gets aborted by the application controller.
+			f.actions = append(f.actions, kubetesting.NewPatchAction(
+				shipper.SchemeGroupVersion.WithResource("releases"),
+				namespace,
+				contenderName,
+				types.MergePatchType,
+				relPatch,
+			))
+
+			if !tt.failed {
+				f.expectedEvents = []string{
+					fmt.Sprintf("Normal StrategyApplied step [%d] finished", step),
+					fmt.Sprintf(`Normal ReleaseStateTransitioned Release "%s" had its state "WaitingForCapacity" transitioned to "False"`, relKey),
+					fmt.Sprintf(`Normal ReleaseStateTransitioned Release "%s" had its state "WaitingForCommand" transitioned to "True"`, relKey),
+					fmt.Sprintf(`Normal ReleaseStateTransitioned Release "%s" had its state "WaitingForInstallation" transitioned to "False"`, relKey),
+					fmt.Sprintf(`Normal ReleaseStateTransitioned Release "%s" had its state "WaitingForTraffic" transitioned to "False"`, relKey),
+					"Normal ReleaseConditionChanged [] -> [Scheduled True], [] -> [StrategyExecuted True], [] -> [AnalysisFailed False]",
+				}
+
+				f.run()
+				return
+			}
+
+			f.expectedEvents = []string{
+				fmt.Sprintf("Warning ReleaseAnalysisFailed %s", message),
+				fmt.Sprintf("Normal ReleaseConditionChanged [] -> [Scheduled True], [] -> [StrategyExecuted True], [] -> [AnalysisFailed True %s %s]", AnalysisFailed, message),
+			}
+
+			f.run()
+		})
+	}
+}
+
+func TestContenderCapacityFollowsClusterOverrides(t *testing.T) {
+	namespace := "test-namespace"
+	incumbentName, contenderName := "test-incumbent", "test-contender"
+	app := buildApplication(namespace, "test-app")
+	canaryCluster := buildCluster("kube-eu-1")
+	otherCluster := buildCluster("kube-us-1")
+
+	f := newFixture(t, app.DeepCopy(), canaryCluster.DeepCopy(), otherCluster.DeepCopy())
+	f.cycles = 1
+
+	totalReplicaCount := int32(10)
+	contender := f.buildContender(namespace, contenderName, totalReplicaCount)
+	incumbent := f.buildIncumbent(namespace, incumbentName, totalReplicaCount)
+
+	contender.release.Spec.Environment.Strategy = &shipper.RolloutStrategySpec{
+		Steps: []shipper.RolloutStrategyStep{
+			{
+				Name:     "eu canary",
+				Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(0)},
+				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(0)},
+				ClusterOverrides: []shipper.RolloutStrategyClusterOverride{
+					{
+						Clusters: []string{canaryCluster.Name},
+						Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(50), Contender: intstr.FromInt(50)},
+						Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(50), Contender: intstr.FromInt(50)},
+					},
+				},
+			},
+			{
+				Name:     "full on",
+				Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
+				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
+			},
+		},
+	}
+
+	f.addObjects(
+		contender.release.DeepCopy(),
+		contender.installationTarget.DeepCopy(),
+		contender.capacityTarget.DeepCopy(),
+		contender.trafficTarget.DeepCopy(),
+
+		incumbent.release.DeepCopy(),
+		incumbent.installationTarget.DeepCopy(),
+		incumbent.capacityTarget.DeepCopy(),
+		incumbent.trafficTarget.DeepCopy(),
+	)
+
+	f.filter = f.filter.Extend(actionfilter{
+		[]string{"patch"},
+		[]string{"capacitytargets"},
+	})
+
+	patch, _ := json.Marshal(map[string]interface{}{
+		"spec": shipper.CapacityTargetSpec{
+			Clusters: []shipper.ClusterCapacityTarget{
+				{Name: canaryCluster.Name, Percent: 50, TotalReplicaCount: totalReplicaCount},
+				{Name: otherCluster.Name, Percent: 0, TotalReplicaCount: totalReplicaCount},
+			},
+		},
+	})
+	f.actions = append(f.actions, kubetesting.NewPatchAction(
+		shipper.SchemeGroupVersion.WithResource("capacitytargets"),
+		namespace,
+		contenderName,
+		types.MergePatchType,
+		patch,
+	))
+
+	f.expectedEvents = []string{
+		"Normal ReleaseConditionChanged [] -> [Scheduled True], [] -> [StrategyExecuted True]",
+	}
+
+	f.run()
+}
+
+func TestContenderCapacityAcceptsReplicaCount(t *testing.T) {
+	namespace := "test-namespace"
+	incumbentName, contenderName := "test-incumbent", "test-contender"
+	app := buildApplication(namespace, "test-app")
+	cluster := buildCluster("minikube")
+
+	f := newFixture(t, app.DeepCopy(), cluster.DeepCopy())
+	f.cycles = 1
+
+	totalReplicaCount := int32(1000)
+	contender := f.buildContender(namespace, contenderName, totalReplicaCount)
+	incumbent := f.buildIncumbent(namespace, incumbentName, totalReplicaCount)
+
+	contender.release.Spec.Environment.Strategy = &shipper.RolloutStrategySpec{
+		Steps: []shipper.RolloutStrategyStep{
+			{
+				Name:     "single pod canary",
+				Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromString("100%"), Contender: intstr.FromString("1")},
+				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromString("100%"), Contender: intstr.FromString("1")},
+			},
+			{
+				Name:     "full on",
+				Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
+				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
+			},
+		},
+	}
+
+	f.addObjects(
+		contender.release.DeepCopy(),
+		contender.installationTarget.DeepCopy(),
+		contender.capacityTarget.DeepCopy(),
+		contender.trafficTarget.DeepCopy(),
+
+		incumbent.release.DeepCopy(),
+		incumbent.installationTarget.DeepCopy(),
+		incumbent.capacityTarget.DeepCopy(),
+		incumbent.trafficTarget.DeepCopy(),
+	)
+
+	f.filter = f.filter.Extend(actionfilter{
+		[]string{"patch"},
+		[]string{"capacitytargets"},
+	})
+
+	replicas := int32(1)
+	patch, _ := json.Marshal(map[string]interface{}{
+		"spec": shipper.CapacityTargetSpec{
+			Clusters: []shipper.ClusterCapacityTarget{
+				{Name: cluster.Name, Replicas: &replicas, TotalReplicaCount: totalReplicaCount},
+			},
+		},
+	})
+	f.actions = append(f.actions, kubetesting.NewPatchAction(
+		shipper.SchemeGroupVersion.WithResource("capacitytargets"),
+		namespace,
+		contenderName,
+		types.MergePatchType,
+		patch,
+	))
+
+	f.expectedEvents = []string{
+		"Normal ReleaseConditionChanged [] -> [Scheduled True], [] -> [StrategyExecuted True]",
+	}
+
+	f.run()
+}
+
+func TestContenderReleaseWaitsForApproval(t *testing.T) {
+	one := int32(1)
+	tests := []struct {
+		name            string
+		approvedStep    *int32
+		expectedPercent int32
+		expectedEvents  []string
+	}{
+		{
+			"without an approval",
+			nil,
+			1,
+			[]string{
+				`Normal ReleaseConditionChanged [] -> [Scheduled True], [] -> [AwaitingApproval True StepNotApproved step 1 ("50/50") has not been approved, holding at step 0 ("staging")], [] -> [StrategyExecuted True]`,
+			},
+		},
+		{
+			"with an approval",
+			&one,
+			50,
+			[]string{
+				"Normal ReleaseConditionChanged [] -> [Scheduled True], [] -> [AwaitingApproval False], [] -> [StrategyExecuted True]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := "test-namespace"
+			incumbentName, contenderName := "test-incumbent", "test-contender"
+			app := buildApplication(namespace, "test-app")
+			cluster := buildCluster("minikube")
+
+			f := newFixture(t, app.DeepCopy(), cluster.DeepCopy())
+			f.cycles = 1
+			f.kubeObjects = append(f.kubeObjects, &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   namespace,
+					Labels: map[string]string{shipper.ApprovalRequiredLabel: "true"},
+				},
+			})
+
+			totalReplicaCount := int32(10)
+			contender := f.buildContender(namespace, contenderName, totalReplicaCount)
+			incumbent := f.buildIncumbent(namespace, incumbentName, totalReplicaCount)
+
+			contender.release.Spec.TargetStep = 1
+
+			f.addObjects(
+				contender.release.DeepCopy(),
+				contender.installationTarget.DeepCopy(),
+				contender.capacityTarget.DeepCopy(),
+				contender.trafficTarget.DeepCopy(),
+
+				incumbent.release.DeepCopy(),
+				incumbent.installationTarget.DeepCopy(),
+				incumbent.capacityTarget.DeepCopy(),
+				incumbent.trafficTarget.DeepCopy(),
+			)
+
+			if tt.approvedStep != nil {
+				f.addObjects(&shipper.ReleaseApproval{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("%s-%d", contenderName, *tt.approvedStep),
+						Namespace: namespace,
+					},
+					Spec: shipper.ReleaseApprovalSpec{
+						Release:  contenderName,
+						Step:     *tt.approvedStep,
+						Approver: "alice",
+					},
+				})
+			}
+
+			f.filter = f.filter.Extend(actionfilter{
+				[]string{"patch"},
+				[]string{"capacitytargets"},
+			})
+
+			patch, _ := json.Marshal(map[string]interface{}{
+				"spec": shipper.CapacityTargetSpec{
+					Clusters: []shipper.ClusterCapacityTarget{
+						{Name: cluster.Name, Percent: tt.expectedPercent, TotalReplicaCount: totalReplicaCount},
+					},
+				},
+			})
+			f.actions = append(f.actions, kubetesting.NewPatchAction(
+				shipper.SchemeGroupVersion.WithResource("capacitytargets"),
+				namespace,
+				contenderName,
+				types.MergePatchType,
+				patch,
+			))
+			f.expectedEvents = tt.expectedEvents
+
+			f.run()
+		})
+	}
+}
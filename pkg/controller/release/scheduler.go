@@ -107,7 +107,7 @@ func (s *Scheduler) ScheduleRelease(rel *shipper.Release) (*releaseInfo, error)
 		)
 	}
 
-	replicaCount, err := s.fetchChartAndExtractReplicaCount(rel)
+	replicaCount, workload, err := s.fetchChartAndExtractReplicaCount(rel)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +124,7 @@ func (s *Scheduler) ScheduleRelease(rel *shipper.Release) (*releaseInfo, error)
 		releaseErrors.Append(err)
 	}
 
-	ct, err := s.CreateOrUpdateCapacityTarget(rel, replicaCount)
+	ct, err := s.CreateOrUpdateCapacityTarget(rel, replicaCount, workload)
 	if err != nil {
 		releaseErrors.Append(err)
 	}
@@ -313,7 +313,11 @@ func (s *Scheduler) CreateOrUpdateInstallationTarget(rel *shipper.Release) (*shi
 	return it, nil
 }
 
-func (s *Scheduler) CreateOrUpdateCapacityTarget(rel *shipper.Release, totalReplicaCount int32) (*shipper.CapacityTarget, error) {
+func (s *Scheduler) CreateOrUpdateCapacityTarget(
+	rel *shipper.Release,
+	totalReplicaCount int32,
+	workload *shipper.WorkloadReference,
+) (*shipper.CapacityTarget, error) {
 	clusters := getReleaseClusters(rel)
 
 	ct, err := s.capacityTargetLister.CapacityTargets(rel.GetNamespace()).Get(rel.GetName())
@@ -333,6 +337,9 @@ func (s *Scheduler) CreateOrUpdateCapacityTarget(rel *shipper.Release, totalRepl
 					createOwnerRefFromRelease(rel),
 				},
 			},
+			Spec: shipper.CapacityTargetSpec{
+				Workload: workload,
+			},
 		}
 		setCapacityTargetClusters(ct, clusters, totalReplicaCount)
 
@@ -571,53 +578,94 @@ func setReleaseClusters(rel *shipper.Release, clusters []*shipper.Cluster) {
 	rel.Annotations[shipper.ReleaseClustersAnnotation] = strings.Join(clusterNames, ",")
 }
 
-func (s *Scheduler) fetchChartAndExtractReplicaCount(rel *shipper.Release) (int32, error) {
+func (s *Scheduler) fetchChartAndExtractReplicaCount(rel *shipper.Release) (int32, *shipper.WorkloadReference, error) {
 	chart, err := s.chartFetcher(&rel.Spec.Environment.Chart)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
-	replicas, err := extractReplicasFromChartForRel(chart, rel)
+	replicas, workload, err := extractReplicasFromChartForRel(chart, rel)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	klog.V(4).Infof("Extracted %d replicas from release %q", replicas, controller.MetaKey(rel))
 
-	return int32(replicas), nil
+	return int32(replicas), workload, nil
 }
 
-func extractReplicasFromChartForRel(chart *helmchart.Chart, rel *shipper.Release) (int32, error) {
+// extractReplicasFromChartForRel returns the number of replicas a release has
+// at full capacity, and the kind of its workload when it's neither a
+// Deployment nor a StatefulSet.
+func extractReplicasFromChartForRel(chart *helmchart.Chart, rel *shipper.Release) (int32, *shipper.WorkloadReference, error) {
 	owners := rel.OwnerReferences
 	if l := len(owners); l != 1 {
-		return 0, shippererrors.NewMultipleOwnerReferencesError(rel.Name, l)
+		return 0, nil, shippererrors.NewMultipleOwnerReferencesError(rel.Name, l)
 	}
 
 	applicationName := owners[0].Name
 	rendered, err := shipperchart.Render(chart, applicationName, rel.Namespace, rel.Spec.Environment.Values)
 	if err != nil {
-		return 0, shippererrors.NewBrokenChartSpecError(
+		return 0, nil, shippererrors.NewBrokenChartSpecError(
 			&rel.Spec.Environment.Chart,
 			err,
 		)
 	}
 
 	deployments := shipperchart.GetDeployments(rendered)
-	if len(deployments) != 1 {
-		return 0, shippererrors.NewWrongChartDeploymentsError(
+	statefulSets := shipperchart.GetStatefulSets(rendered)
+	workloads := shipperchart.GetWorkloads(rendered)
+	if n := len(deployments) + len(statefulSets) + len(workloads); n != 1 {
+		return 0, nil, shippererrors.NewWrongChartDeploymentsError(
 			&rel.Spec.Environment.Chart,
-			len(deployments),
+			n,
 		)
 	}
 
-	replicas := deployments[0].Spec.Replicas
-	// Deployments default to 1 replica when replicas is nil or unspecified. See
-	// k8s.io/api/apps/v1/types.go's DeploymentSpec.
+	var (
+		kind, name string
+		replicas   *int32
+		workload   *shipper.WorkloadReference
+	)
+	switch {
+	case len(deployments) == 1:
+		kind, name, replicas = "Deployment", deployments[0].Name, deployments[0].Spec.Replicas
+	case len(statefulSets) == 1:
+		kind, name, replicas = "StatefulSet", statefulSets[0].Name, statefulSets[0].Spec.Replicas
+	default:
+		obj := workloads[0]
+		kind, name = obj.GetKind(), obj.GetName()
+		workload = &shipper.WorkloadReference{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       kind,
+		}
+
+		var err error
+		replicas, err = shipperchart.WorkloadReplicas(obj)
+		if err != nil {
+			return 0, nil, shippererrors.NewInvalidChartError(
+				fmt.Sprintf("cannot read replicas of %s %q: %s", kind, name, err))
+		}
+	}
+
+	// Charts that autoscale their workload reach full capacity at the
+	// HorizontalPodAutoscaler's maxReplicas rather than at the replicas
+	// in the workload itself.
+	for _, hpa := range shipperchart.GetHorizontalPodAutoscalers(rendered) {
+		if hpa.Targets(kind, name) {
+			return hpa.MaxReplicas, workload, nil
+		}
+	}
+
+	// Deployments and StatefulSets default to 1 replica when replicas is
+	// nil or unspecified. See k8s.io/api/apps/v1/types.go's
+	// DeploymentSpec and StatefulSetSpec. We assume other workloads do
+	// the same.
 	if replicas == nil {
-		return 1, nil
+		return 1, workload, nil
 	}
 
-	return int32(*replicas), nil
+	return int32(*replicas), workload, nil
 }
 
 // The strings here are insane, but if you create a fresh release object for
@@ -909,7 +909,7 @@ func TestCreateAssociatedObjectsDuplicateCapacityTargetNoOwner(t *testing.T) {
 
 	c, _ := newScheduler(fixtures)
 
-	_, err := c.CreateOrUpdateCapacityTarget(release.DeepCopy(), 1)
+	_, err := c.CreateOrUpdateCapacityTarget(release.DeepCopy(), 1, nil)
 	if err == nil {
 		t.Fatalf("Expected an error here, none received")
 	}
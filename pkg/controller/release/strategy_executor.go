@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/klog"
 
+	"github.com/bookingcom/shipper/pkg/analysis"
 	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
 	"github.com/bookingcom/shipper/pkg/controller"
 	"github.com/bookingcom/shipper/pkg/util/conditions"
@@ -19,7 +22,7 @@ const (
 	PipelineContinue                      = true
 )
 
-type PipelineStep func(*shipper.RolloutStrategy, int32, Extra, conditions.StrategyConditionsMap) (PipelineContinuation, []StrategyPatch, []ReleaseStrategyStateTransition)
+type PipelineStep func(*shipper.RolloutStrategySpec, int32, Extra, conditions.StrategyConditionsMap) (PipelineContinuation, []StrategyPatch, []ReleaseStrategyStateTransition)
 
 type Pipeline []PipelineStep
 
@@ -35,9 +38,10 @@ type Extra struct {
 	IsLastStep bool
 	HasTail    bool
 	Initiator  *shipper.Release
+	DrainDelay time.Duration
 }
 
-func (p *Pipeline) Process(strategy *shipper.RolloutStrategy, step int32, extra Extra, cond conditions.StrategyConditionsMap) (bool, []StrategyPatch, []ReleaseStrategyStateTransition) {
+func (p *Pipeline) Process(strategy *shipper.RolloutStrategySpec, step int32, extra Extra, cond conditions.StrategyConditionsMap) (bool, []StrategyPatch, []ReleaseStrategyStateTransition) {
 	var patches []StrategyPatch
 	var trans []ReleaseStrategyStateTransition
 	complete := true
@@ -55,14 +59,18 @@ func (p *Pipeline) Process(strategy *shipper.RolloutStrategy, step int32, extra
 }
 
 type StrategyExecutor struct {
-	strategy *shipper.RolloutStrategy
-	step     int32
+	strategy         *shipper.RolloutStrategySpec
+	step             int32
+	analysisProvider analysis.Provider
+	drainDelay       time.Duration
 }
 
-func NewStrategyExecutor(strategy *shipper.RolloutStrategy, step int32) *StrategyExecutor {
+func NewStrategyExecutor(strategy *shipper.RolloutStrategySpec, step int32, analysisProvider analysis.Provider, drainDelay time.Duration) *StrategyExecutor {
 	return &StrategyExecutor{
-		strategy: strategy,
-		step:     step,
+		strategy:         strategy,
+		step:             step,
+		analysisProvider: analysisProvider,
+		drainDelay:       drainDelay,
 	}
 }
 
@@ -85,7 +93,10 @@ func NewStrategyExecutor(strategy *shipper.RolloutStrategy, step int32) *Strateg
 	6. For a tail release, ensure capacity.
 	  6.1. Look at the leader and check it's target capacity.
 	  6.2 Look at the strategy and figure out the target capacity.
-	7. Make necessary adjustments to the release object.
+	7. For the head release, ensure the analysis gate passes.
+	  7.1. Query the metrics for the contender over the analysis window.
+	  7.2. On failure, mark the release as failed for it to be aborted.
+	8. Make necessary adjustments to the release object.
 */
 
 func (e *StrategyExecutor) Execute(prev, curr, succ *releaseInfo) (bool, []StrategyPatch, []ReleaseStrategyStateTransition) {
@@ -124,6 +135,7 @@ func (e *StrategyExecutor) Execute(prev, curr, succ *releaseInfo) (bool, []Strat
 		Initiator:  curr.release,
 		IsLastStep: isLastStep,
 		HasTail:    hasTail,
+		DrainDelay: e.drainDelay,
 	}
 
 	pipeline := NewPipeline()
@@ -138,6 +150,7 @@ func (e *StrategyExecutor) Execute(prev, curr, succ *releaseInfo) (bool, []Strat
 			pipeline.Enqueue(genTrafficEnforcer(prev, curr))
 			pipeline.Enqueue(genCapacityEnforcer(prev, curr))
 		}
+		pipeline.Enqueue(genAnalysisGate(curr, e.analysisProvider))
 		pipeline.Enqueue(genReleaseStrategyStateEnforcer(curr, nil))
 	}
 
@@ -145,7 +158,7 @@ func (e *StrategyExecutor) Execute(prev, curr, succ *releaseInfo) (bool, []Strat
 }
 
 func genInstallationEnforcer(curr, succ *releaseInfo) PipelineStep {
-	return func(strategy *shipper.RolloutStrategy, targetStep int32, extra Extra, cond conditions.StrategyConditionsMap) (PipelineContinuation, []StrategyPatch, []ReleaseStrategyStateTransition) {
+	return func(strategy *shipper.RolloutStrategySpec, targetStep int32, extra Extra, cond conditions.StrategyConditionsMap) (PipelineContinuation, []StrategyPatch, []ReleaseStrategyStateTransition) {
 		if ready, clusters := checkInstallation(curr.installationTarget); !ready {
 			cond.SetFalse(
 				shipper.StrategyConditionContenderAchievedInstallation,
@@ -185,9 +198,8 @@ func genInstallationEnforcer(curr, succ *releaseInfo) PipelineStep {
 }
 
 func genCapacityEnforcer(curr, succ *releaseInfo) PipelineStep {
-	return func(strategy *shipper.RolloutStrategy, targetStep int32, extra Extra, cond conditions.StrategyConditionsMap) (PipelineContinuation, []StrategyPatch, []ReleaseStrategyStateTransition) {
+	return func(strategy *shipper.RolloutStrategySpec, targetStep int32, extra Extra, cond conditions.StrategyConditionsMap) (PipelineContinuation, []StrategyPatch, []ReleaseStrategyStateTransition) {
 		var condType shipper.StrategyConditionType
-		var capacityWeight int32
 		isHead := succ == nil
 		isInitiator := releasesIdentical(extra.Initiator, curr.release)
 
@@ -196,10 +208,12 @@ func genCapacityEnforcer(curr, succ *releaseInfo) PipelineStep {
 		} else {
 			condType = shipper.StrategyConditionIncumbentAchievedCapacity
 		}
-		if isHead {
-			capacityWeight = strategy.Steps[targetStep].Capacity.Contender
-		} else {
-			capacityWeight = strategy.Steps[targetStep].Capacity.Incumbent
+		capacityWeight := func(cluster string) intstr.IntOrString {
+			capacity, _ := releaseutil.StepValuesForCluster(strategy.Steps[targetStep], cluster)
+			if isHead {
+				return capacity.Contender
+			}
+			return capacity.Incumbent
 		}
 
 		if achieved, newSpec, clustersNotReady := checkCapacity(curr.capacityTarget, capacityWeight); !achieved {
@@ -207,6 +221,38 @@ func genCapacityEnforcer(curr, succ *releaseInfo) PipelineStep {
 
 			patches := make([]StrategyPatch, 0, 2)
 
+			// A tail release only gives up capacity once the
+			// traffic it lost has had time to drain.
+			if !isHead && capacityDecreases(curr.capacityTarget, newSpec) {
+				remaining := drainRemaining(cond, extra.DrainDelay)
+				if remaining > 0 {
+					klog.Infof("Release %q is draining, will reduce capacity in %s", controller.MetaKey(curr.release), remaining)
+
+					cond.SetFalse(
+						condType,
+						conditions.StrategyConditionsUpdate{
+							Reason:             Draining,
+							Message:            fmt.Sprintf("release %q is waiting %s for its connections to drain before reducing its capacity", curr.release.Name, extra.DrainDelay),
+							Step:               targetStep,
+							LastTransitionTime: time.Now(),
+						},
+					)
+
+					relPatch := buildContenderStrategyConditionsPatch(
+						extra.Initiator.Name,
+						cond,
+						targetStep,
+						extra.IsLastStep,
+						extra.HasTail,
+					)
+					if relPatch.Alters(extra.Initiator) {
+						patches = append(patches, relPatch)
+					}
+
+					return PipelineBreak, patches, nil
+				}
+			}
+
 			cond.SetFalse(
 				condType,
 				conditions.StrategyConditionsUpdate{
@@ -255,10 +301,27 @@ func genCapacityEnforcer(curr, succ *releaseInfo) PipelineStep {
 	}
 }
 
+// drainRemaining returns how much longer the incumbent has to wait before its
+// capacity can be reduced, counting drainDelay from the moment its traffic was
+// last achieved.
+func drainRemaining(cond conditions.StrategyConditionsMap, drainDelay time.Duration) time.Duration {
+	if drainDelay <= 0 {
+		return 0
+	}
+
+	// A zero timestamp means we don't know when traffic was achieved,
+	// so we consider it drained long ago.
+	c, ok := cond.GetCondition(shipper.StrategyConditionIncumbentAchievedTraffic)
+	if !ok || c.Status != corev1.ConditionTrue || c.LastTransitionTime.IsZero() {
+		return 0
+	}
+
+	return drainDelay - time.Since(c.LastTransitionTime.Time)
+}
+
 func genTrafficEnforcer(curr, succ *releaseInfo) PipelineStep {
-	return func(strategy *shipper.RolloutStrategy, targetStep int32, extra Extra, cond conditions.StrategyConditionsMap) (PipelineContinuation, []StrategyPatch, []ReleaseStrategyStateTransition) {
+	return func(strategy *shipper.RolloutStrategySpec, targetStep int32, extra Extra, cond conditions.StrategyConditionsMap) (PipelineContinuation, []StrategyPatch, []ReleaseStrategyStateTransition) {
 		var condType shipper.StrategyConditionType
-		var trafficWeight int32
 		isHead := succ == nil
 		isInitiator := releasesIdentical(extra.Initiator, curr.release)
 
@@ -267,13 +330,15 @@ func genTrafficEnforcer(curr, succ *releaseInfo) PipelineStep {
 		} else {
 			condType = shipper.StrategyConditionIncumbentAchievedTraffic
 		}
-		if isHead {
-			trafficWeight = strategy.Steps[targetStep].Traffic.Contender
-		} else {
-			trafficWeight = strategy.Steps[targetStep].Traffic.Incumbent
+		trafficWeight := func(cluster string) intstr.IntOrString {
+			_, traffic := releaseutil.StepValuesForCluster(strategy.Steps[targetStep], cluster)
+			if isHead {
+				return traffic.Contender
+			}
+			return traffic.Incumbent
 		}
 
-		if achieved, newSpec, reason := checkTraffic(curr.trafficTarget, uint32(trafficWeight)); !achieved {
+		if achieved, newSpec, reason := checkTraffic(curr.trafficTarget, trafficWeight); !achieved {
 			klog.Infof("Release %q %s", controller.MetaKey(curr.release), "hasn't achieved traffic yet")
 
 			patches := make([]StrategyPatch, 0, 2)
@@ -324,8 +389,149 @@ func genTrafficEnforcer(curr, succ *releaseInfo) PipelineStep {
 	}
 }
 
+func genAnalysisGate(curr *releaseInfo, provider analysis.Provider) PipelineStep {
+	return func(strategy *shipper.RolloutStrategySpec, targetStep int32, extra Extra, cond conditions.StrategyConditionsMap) (PipelineContinuation, []StrategyPatch, []ReleaseStrategyStateTransition) {
+		spec := strategy.Steps[targetStep].Analysis
+		if spec == nil {
+			return PipelineContinue, nil, nil
+		}
+
+		condType := shipper.StrategyConditionContenderAchievedAnalysis
+		now := time.Now()
+		start := now
+
+		// A condition left over from a previous step says nothing about
+		// this one: the analysis window starts over from scratch.
+		if c, ok := cond.GetCondition(condType); ok && c.Step == targetStep {
+			if c.Status == corev1.ConditionTrue {
+				return PipelineContinue, nil, nil
+			}
+
+			// A failed analysis is final for this step: the
+			// release is about to be aborted, so there is no point
+			// in querying the metrics once again.
+			if c.Reason == AnalysisFailed {
+				return PipelineBreak, nil, nil
+			}
+
+			if !c.LastTransitionTime.IsZero() {
+				start = c.LastTransitionTime.Time
+			}
+		} else {
+			delete(cond, condType)
+		}
+
+		buildRelPatch := func() []StrategyPatch {
+			relPatch := buildContenderStrategyConditionsPatch(
+				extra.Initiator.Name,
+				cond,
+				targetStep,
+				extra.IsLastStep,
+				extra.HasTail,
+			)
+			if relPatch.Alters(extra.Initiator) {
+				return []StrategyPatch{relPatch}
+			}
+			return nil
+		}
+
+		if provider == nil {
+			cond.SetFalse(
+				condType,
+				conditions.StrategyConditionsUpdate{
+					Reason:             AnalysisError,
+					Message:            "step requires an analysis, but shipper has no metrics provider configured",
+					Step:               targetStep,
+					LastTransitionTime: start,
+				},
+			)
+
+			return PipelineBreak, buildRelPatch(), nil
+		}
+
+		// Until the window has elapsed we only look at the current
+		// values to catch regressions early, and then at the whole
+		// window to make sure nothing slipped in between syncs.
+		window := spec.Window.Duration
+		windowElapsed := now.Sub(start) >= window
+		var queryWindow time.Duration
+		if windowElapsed {
+			queryWindow = window
+		}
+
+		appName, _ := releaseutil.ApplicationNameForRelease(curr.release)
+		vars := analysis.QueryVars{
+			Namespace:   curr.release.Namespace,
+			Application: appName,
+			Release:     curr.release.Name,
+		}
+
+		breach, err := analysis.Evaluate(provider, spec, vars, queryWindow, now)
+		if err != nil {
+			klog.Infof("Release %q analysis could not be evaluated: %s", controller.MetaKey(curr.release), err)
+
+			cond.SetFalse(
+				condType,
+				conditions.StrategyConditionsUpdate{
+					Reason:             AnalysisError,
+					Message:            err.Error(),
+					Step:               targetStep,
+					LastTransitionTime: start,
+				},
+			)
+
+			return PipelineBreak, buildRelPatch(), nil
+		}
+
+		if breach != nil {
+			klog.Infof("Release %q %s: %s", controller.MetaKey(curr.release), "has failed analysis", breach)
+
+			cond.SetFalse(
+				condType,
+				conditions.StrategyConditionsUpdate{
+					Reason:             AnalysisFailed,
+					Message:            fmt.Sprintf("release %q has failed analysis: %s", curr.release.Name, breach),
+					Step:               targetStep,
+					LastTransitionTime: now,
+				},
+			)
+
+			// The release is marked as failed from this condition,
+			// and the application controller takes it from there
+			// by rolling the application back to its incumbent.
+			return PipelineBreak, buildRelPatch(), nil
+		}
+
+		if !windowElapsed {
+			cond.SetFalse(
+				condType,
+				conditions.StrategyConditionsUpdate{
+					Reason:             AnalysisInProgress,
+					Message:            fmt.Sprintf("release %q is being analyzed until %s", curr.release.Name, start.Add(window).UTC().Format(time.RFC3339)),
+					Step:               targetStep,
+					LastTransitionTime: start,
+				},
+			)
+
+			return PipelineBreak, buildRelPatch(), nil
+		}
+
+		klog.Infof("Release %q %s", controller.MetaKey(curr.release), "has passed analysis")
+
+		cond.SetTrue(
+			condType,
+			conditions.StrategyConditionsUpdate{
+				Step:               targetStep,
+				LastTransitionTime: now,
+			},
+		)
+
+		return PipelineContinue, nil, nil
+	}
+}
+
 func genReleaseStrategyStateEnforcer(curr, succ *releaseInfo) PipelineStep {
-	return func(strategy *shipper.RolloutStrategy, targetStep int32, extra Extra, cond conditions.StrategyConditionsMap) (PipelineContinuation, []StrategyPatch, []ReleaseStrategyStateTransition) {
+	return func(strategy *shipper.RolloutStrategySpec, targetStep int32, extra Extra, cond conditions.StrategyConditionsMap) (PipelineContinuation, []StrategyPatch, []ReleaseStrategyStateTransition) {
 		var releaseStrategyStateTransitions []ReleaseStrategyStateTransition
 		patches := make([]StrategyPatch, 0, 1)
 
@@ -0,0 +1,108 @@
+package traffic
+
+import (
+	"fmt"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/clusterclientstore"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+// trafficBackend shifts traffic between the releases of an application in
+// a single application cluster.
+type trafficBackend interface {
+	// shift moves the traffic of the application in the cluster towards
+	// the desired weights, and returns the traffic weight achieved by the
+	// release along with a Ready condition describing its progress. An
+	// error without a condition means that the state of the cluster
+	// could not be observed at all.
+	shift(req *shiftRequest) (uint32, *shipper.ClusterTrafficCondition, error)
+}
+
+// shiftRequest holds everything a backend needs to know to shift traffic for
+// a release in a cluster.
+type shiftRequest struct {
+	cluster     *shipper.Cluster
+	clusterName string
+	clientset   clusterclientstore.ClientsetInterface
+
+	namespace   string
+	appName     string
+	releaseName string
+
+	weights clusterReleaseWeights
+	pods    clusterReleasePods
+
+	limits  podShiftLimits
+	limiter *podShiftLimiter
+
+	// endpoints is where the readiness of pods is read from.
+	endpoints *clusterEndpoints
+
+	// requeueAfter is set by backends that need the traffic target to
+	// be looked at again after some time, even if nothing changes.
+	requeueAfter time.Duration
+
+	// achievedPods is set by backends to how many pods get traffic when
+	// the release asked for a number of pods instead of a weight.
+	achievedPods *uint32
+
+	// weightDeviation is set by backends that can only achieve weights
+	// as precisely as the number of pods allows.
+	weightDeviation *weightDeviation
+}
+
+// trafficBackendFor returns the traffic backend an application uses in a
+// cluster. The application's choice wins over the cluster's, and pod
+// labels are used if neither has one.
+func (c *Controller) trafficBackendFor(app *shipper.Application, clusterName string) (trafficBackend, *shipper.Cluster, error) {
+	cluster, err := c.clustersLister.Get(clusterName)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, nil, shippererrors.NewKubeclientGetError("", clusterName, err).
+			WithShipperKind("Cluster")
+	} else if err != nil {
+		cluster = nil
+	}
+
+	backendType := trafficutil.BackendType(app, cluster)
+	switch backendType {
+	case shipper.TrafficBackendPodLabels:
+		return podLabelsBackend{}, cluster, nil
+	case shipper.TrafficBackendNginxCanary:
+		return nginxCanaryBackend{}, cluster, nil
+	}
+
+	// Mesh backends need the Cluster to build dynamic clients.
+	if cluster == nil {
+		return nil, nil, shippererrors.NewKubeclientGetError("", clusterName, err).
+			WithShipperKind("Cluster")
+	}
+
+	switch backendType {
+	case shipper.TrafficBackendIstio:
+		return istioBackend{dynamicClientBuilder: c.dynamicClientBuilder}, cluster, nil
+	case shipper.TrafficBackendSMI:
+		return smiBackend{dynamicClientBuilder: c.dynamicClientBuilder}, cluster, nil
+	}
+
+	return nil, nil, shippererrors.NewUnrecoverableError(
+		fmt.Errorf("unknown traffic backend %q", backendType))
+}
+
+// getApplication returns the application a traffic target belongs to, or
+// nil if it doesn't exist anymore.
+func (c *Controller) getApplication(namespace, appName string) (*shipper.Application, error) {
+	app, err := c.applicationsLister.Applications(namespace).Get(appName)
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, shippererrors.NewKubeclientGetError(namespace, appName, err).
+			WithShipperKind("Application")
+	}
+
+	return app, nil
+}
@@ -0,0 +1,380 @@
+package traffic
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	kuberuntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/clusterclientstore"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	"github.com/bookingcom/shipper/pkg/util/filters"
+)
+
+var (
+	endpointSliceGVK = schema.GroupVersionKind{Group: "discovery.k8s.io", Version: "v1beta1", Kind: "EndpointSlice"}
+	endpointSliceGVR = endpointSliceGVK.GroupVersion().WithResource("endpointslices")
+)
+
+const (
+	// endpointSliceServiceLabel is set by Kubernetes on every
+	// EndpointSlice with the name of the Service it belongs to.
+	endpointSliceServiceLabel = "kubernetes.io/service-name"
+
+	// endpointSliceServiceIndex indexes EndpointSlices by the
+	// namespace/name key of the Service they belong to.
+	endpointSliceServiceIndex = "service"
+
+	// endpointSliceDiscoveryInterval is how long we trust what we found
+	// out about a cluster serving EndpointSlices, so clusters that get
+	// upgraded start using them eventually.
+	endpointSliceDiscoveryInterval = 10 * time.Minute
+)
+
+// podReadiness tells, for every pod that is an endpoint of a Service, whether
+// it's ready to receive traffic.
+type podReadiness map[string]bool
+
+// podReadinessFromEndpoints returns the readiness of the pods in an Endpoints
+// object.
+func podReadinessFromEndpoints(endpoints *corev1.Endpoints) podReadiness {
+	readiness := make(podReadiness)
+	for _, subset := range endpoints.Subsets {
+		markAddressReadiness(readiness, subset.Addresses, true)
+		markAddressReadiness(readiness, subset.NotReadyAddresses, false)
+	}
+
+	return readiness
+}
+
+// markAddressReadiness updates readiness by marking the pods from a list of
+// EndpointAddress as either ready or not ready according to the markAs
+// parameter.
+func markAddressReadiness(
+	readiness podReadiness,
+	addresses []corev1.EndpointAddress,
+	markAs bool,
+) {
+	for _, address := range addresses {
+		target := address.TargetRef
+		// Don't know what to do if the target is not a Pod, so
+		// just skip it.
+		if target == nil || target.Kind != "Pod" {
+			continue
+		}
+
+		readiness[target.Name] = markAs
+	}
+}
+
+// podReadinessFromEndpointSlices returns the readiness of the pods in all the
+// EndpointSlices of a Service. A pod may show up in more than one slice, be it
+// because it has addresses of different types, or because it's being moved
+// between slices, so it's ready as long as any of them says so.
+func podReadinessFromEndpointSlices(slices []*unstructured.Unstructured) podReadiness {
+	readiness := make(podReadiness)
+	for _, slice := range slices {
+		endpoints, _, _ := unstructured.NestedSlice(slice.Object, "endpoints")
+		for _, e := range endpoints {
+			endpoint, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			kind, _, _ := unstructured.NestedString(endpoint, "targetRef", "kind")
+			name, _, _ := unstructured.NestedString(endpoint, "targetRef", "name")
+			if kind != "Pod" || name == "" {
+				continue
+			}
+
+			// A missing ready condition means readiness is
+			// unknown, which consumers should take as ready.
+			ready, found, err := unstructured.NestedBool(endpoint, "conditions", "ready")
+			if !found || err != nil {
+				ready = true
+			}
+
+			readiness[name] = readiness[name] || ready
+		}
+	}
+
+	return readiness
+}
+
+// endpointSliceSupport is what we found out about a cluster serving
+// EndpointSlices, and when.
+type endpointSliceSupport struct {
+	served    bool
+	checkedAt time.Time
+}
+
+// endpointSliceDiscovery remembers which clusters serve EndpointSlices, so we
+// don't need to ask every time traffic is shifted.
+type endpointSliceDiscovery struct {
+	mu       sync.Mutex
+	clusters map[string]endpointSliceSupport
+	now      func() time.Time
+}
+
+func newEndpointSliceDiscovery() *endpointSliceDiscovery {
+	return &endpointSliceDiscovery{
+		clusters: make(map[string]endpointSliceSupport),
+		now:      time.Now,
+	}
+}
+
+// served returns whether a cluster serves EndpointSlices. Clusters that can't
+// tell are taken not to, and are asked again in the next interval.
+func (d *endpointSliceDiscovery) served(clusterName string, client kubernetes.Interface) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	support, ok := d.clusters[clusterName]
+	if ok && now.Sub(support.checkedAt) < endpointSliceDiscoveryInterval {
+		return support.served
+	}
+
+	served := false
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(
+		endpointSliceGVK.GroupVersion().String())
+	if err == nil {
+		for _, resource := range resources.APIResources {
+			if resource.Name == endpointSliceGVR.Resource {
+				served = true
+				break
+			}
+		}
+	}
+
+	d.clusters[clusterName] = endpointSliceSupport{
+		served:    served,
+		checkedAt: now,
+	}
+
+	return served
+}
+
+// endpointSliceServiceIndexFunc indexes an EndpointSlice by the key of its
+// Service.
+func endpointSliceServiceIndexFunc(obj interface{}) ([]string, error) {
+	slice, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("not an EndpointSlice: %#v", obj)
+	}
+
+	svcName, ok := slice.GetLabels()[endpointSliceServiceLabel]
+	if !ok {
+		return nil, nil
+	}
+
+	return []string{fmt.Sprintf("%s/%s", slice.GetNamespace(), svcName)}, nil
+}
+
+// clusterEndpoints watches the readiness of the pods behind Services in an
+// application cluster. It watches their EndpointSlices if the cluster serves
+// them, and their Endpoints otherwise, but never both, as Endpoints are the
+// most expensive objects to watch in large clusters.
+type clusterEndpoints struct {
+	// informerFactory is the one of the cluster client store we started
+	// watching with. The store replaces it when it rebuilds the clients
+	// of a cluster, and so must we.
+	informerFactory kubeinformers.SharedInformerFactory
+
+	endpointSlices cache.SharedIndexInformer
+	endpoints      cache.SharedIndexInformer
+
+	stopCh chan struct{}
+}
+
+func (e *clusterEndpoints) hasSynced() bool {
+	if e.endpointSlices != nil {
+		return e.endpointSlices.HasSynced()
+	}
+
+	return e.endpoints.HasSynced()
+}
+
+// podReadiness returns the readiness of the pods behind svc.
+func (e *clusterEndpoints) podReadiness(svc *corev1.Service) (podReadiness, error) {
+	if e.endpointSlices != nil {
+		key := fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)
+		objs, err := e.endpointSlices.GetIndexer().ByIndex(endpointSliceServiceIndex, key)
+		if err != nil {
+			selector := labels.Set{endpointSliceServiceLabel: svc.Name}.AsSelector()
+			return nil, shippererrors.NewKubeclientListError(
+				endpointSliceGVK, svc.Namespace, selector, err)
+		}
+
+		slices := make([]*unstructured.Unstructured, 0, len(objs))
+		for _, obj := range objs {
+			slices = append(slices, obj.(*unstructured.Unstructured))
+		}
+
+		return podReadinessFromEndpointSlices(slices), nil
+	}
+
+	endpoints, err := e.informerFactory.Core().V1().Endpoints().Lister().
+		Endpoints(svc.Namespace).Get(svc.Name)
+	if err != nil {
+		return nil, shippererrors.NewKubeclientGetError(svc.Namespace, svc.Name, err).
+			WithCoreV1Kind("Endpoints")
+	}
+
+	return podReadinessFromEndpoints(endpoints), nil
+}
+
+// watchEndpoints returns what watches the readiness of pods in a cluster,
+// and starts watching if nothing does yet. A cluster that starts serving
+// EndpointSlices gets switched over to them, but never back, so a cluster
+// failing discovery doesn't make us drop a perfectly good cache. Building a
+// dynamic client needs the Cluster, so without it we can only watch
+// Endpoints.
+func (c *Controller) watchEndpoints(
+	clusterName string,
+	clientset clusterclientstore.ClientsetInterface,
+	cluster *shipper.Cluster,
+) *clusterEndpoints {
+	c.clusterEndpointsMut.Lock()
+	defer c.clusterEndpointsMut.Unlock()
+
+	informerFactory := clientset.GetKubeInformerFactory()
+	slicesServed := cluster != nil &&
+		c.endpointSliceDiscovery.served(clusterName, clientset.GetKubeClient())
+
+	if e, ok := c.clusterEndpoints[clusterName]; ok {
+		if e.informerFactory == informerFactory && (e.endpointSlices != nil || !slicesServed) {
+			return e
+		}
+
+		close(e.stopCh)
+	}
+
+	e := &clusterEndpoints{
+		informerFactory: informerFactory,
+		stopCh:          make(chan struct{}),
+	}
+
+	if slicesServed {
+		e.endpointSlices = c.newEndpointSliceInformer(clientset, cluster)
+		e.endpointSlices.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				c.enqueueTrafficTargetsFromEndpointSlice(informerFactory, obj)
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				c.enqueueTrafficTargetsFromEndpointSlice(informerFactory, newObj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				c.enqueueTrafficTargetsFromEndpointSlice(informerFactory, obj)
+			},
+		})
+
+		go e.endpointSlices.Run(e.stopCh)
+	} else {
+		// An event on an Endpoints object enqueues all traffic
+		// targets for an app, as a change in one of them might
+		// affect the weight in the others.
+		e.endpoints = informerFactory.Core().V1().Endpoints().Informer()
+		e.endpoints.AddEventHandler(cache.FilteringResourceEventHandler{
+			FilterFunc: filters.BelongsToApp,
+			Handler: cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.enqueueAllTrafficTargets,
+				DeleteFunc: c.enqueueAllTrafficTargets,
+				UpdateFunc: func(oldObj, newObj interface{}) {
+					c.enqueueAllTrafficTargets(newObj)
+				},
+			},
+		})
+
+		// The informer factory has been started already, and only
+		// starts informers it hasn't started yet.
+		informerFactory.Start(e.stopCh)
+	}
+
+	if c.clusterEndpoints == nil {
+		c.clusterEndpoints = make(map[string]*clusterEndpoints)
+	}
+	c.clusterEndpoints[clusterName] = e
+
+	return e
+}
+
+// stopWatchingEndpoints stops watching the readiness of pods in a cluster
+// that's gone.
+func (c *Controller) stopWatchingEndpoints(clusterName string) {
+	c.clusterEndpointsMut.Lock()
+	defer c.clusterEndpointsMut.Unlock()
+
+	if e, ok := c.clusterEndpoints[clusterName]; ok {
+		close(e.stopCh)
+		delete(c.clusterEndpoints, clusterName)
+	}
+}
+
+// newEndpointSliceInformer returns an informer for all the EndpointSlices in
+// a cluster, indexed by the Service they belong to.
+func (c *Controller) newEndpointSliceInformer(
+	clientset clusterclientstore.ClientsetInterface,
+	cluster *shipper.Cluster,
+) cache.SharedIndexInformer {
+	client := clusterclientstore.NewDynamicInformerClient(
+		clientset, cluster, endpointSliceGVK, c.dynamicClientBuilder).Resource(endpointSliceGVR)
+
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (kuberuntime.Object, error) {
+				return client.List(opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				return client.Watch(opts)
+			},
+		},
+		&unstructured.Unstructured{},
+		0,
+		cache.Indexers{endpointSliceServiceIndex: endpointSliceServiceIndexFunc},
+	)
+}
+
+// enqueueTrafficTargetsFromEndpointSlice enqueues all the traffic targets of
+// the application an EndpointSlice's Service belongs to, just like we do for
+// events on Endpoints objects. Unlike those, EndpointSlices don't carry the
+// labels of their Service, so we need to look it up.
+func (c *Controller) enqueueTrafficTargetsFromEndpointSlice(
+	informerFactory kubeinformers.SharedInformerFactory,
+	obj interface{},
+) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	slice, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("not an EndpointSlice: %#v", obj))
+		return
+	}
+
+	svcName, ok := slice.GetLabels()[endpointSliceServiceLabel]
+	if !ok {
+		return
+	}
+
+	svc, err := informerFactory.Core().V1().Services().Lister().
+		Services(slice.GetNamespace()).Get(svcName)
+	if err != nil || !filters.BelongsToApp(svc) {
+		return
+	}
+
+	c.enqueueAllTrafficTargets(svc)
+}
@@ -0,0 +1,252 @@
+package traffic
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippertesting "github.com/bookingcom/shipper/pkg/testing"
+)
+
+type sliceEndpoint struct {
+	pod   string
+	ready *bool
+}
+
+func buildEndpointSlice(name, service string, endpoints ...sliceEndpoint) *unstructured.Unstructured {
+	items := make([]interface{}, 0, len(endpoints))
+	for _, e := range endpoints {
+		endpoint := map[string]interface{}{
+			"addresses": []interface{}{"10.0.0.1"},
+			"targetRef": map[string]interface{}{
+				"kind":      "Pod",
+				"namespace": shippertesting.TestNamespace,
+				"name":      e.pod,
+			},
+		}
+		if e.ready != nil {
+			endpoint["conditions"] = map[string]interface{}{
+				"ready": *e.ready,
+			}
+		}
+		items = append(items, endpoint)
+	}
+
+	slice := &unstructured.Unstructured{}
+	slice.SetGroupVersionKind(endpointSliceGVK)
+	slice.SetName(name)
+	slice.SetNamespace(shippertesting.TestNamespace)
+	slice.SetLabels(map[string]string{
+		endpointSliceServiceLabel: service,
+	})
+	slice.Object["addressType"] = "IPv4"
+	slice.Object["endpoints"] = items
+
+	return slice
+}
+
+func TestPodReadinessFromEndpointSlices(t *testing.T) {
+	ready, notReady := true, false
+
+	slices := []*unstructured.Unstructured{
+		buildEndpointSlice("svc-ipv4", "svc",
+			sliceEndpoint{"pod-a", &ready},
+			sliceEndpoint{"pod-b", nil},
+			sliceEndpoint{"pod-c", &notReady},
+		),
+		buildEndpointSlice("svc-ipv6", "svc",
+			sliceEndpoint{"pod-a", &notReady},
+			sliceEndpoint{"pod-c", &notReady},
+			sliceEndpoint{"pod-d", &ready},
+		),
+	}
+
+	expected := podReadiness{
+		"pod-a": true,
+		"pod-b": true,
+		"pod-c": false,
+		"pod-d": true,
+	}
+
+	eq, diff := shippertesting.DeepEqualDiff(expected, podReadinessFromEndpointSlices(slices))
+	if !eq {
+		t.Errorf("pod readiness differs from expected:\n%s", diff)
+	}
+}
+
+// TestTrafficShiftingWithEndpointSlices verifies that the traffic controller
+// reads pod readiness from EndpointSlices when the cluster serves them,
+// instead of from Endpoints.
+func TestTrafficShiftingWithEndpointSlices(t *testing.T) {
+	f := shippertesting.NewControllerTestFixture()
+	cluster := f.AddNamedCluster(clusterA)
+
+	tt := buildTrafficTarget(shippertesting.TestApp, ttName,
+		map[string]uint32{clusterA: 10})
+
+	// Endpoints get all the pods as ready, but the EndpointSlices say
+	// otherwise, which is what we should listen to.
+	pods := buildPods(shippertesting.TestApp, ttName, 3, withTraffic)
+	objects := []runtime.Object{
+		buildService(shippertesting.TestApp),
+		buildEndpoints(shippertesting.TestApp),
+	}
+	objects = addPodsToList(objects, pods)
+	cluster.AddMany(objects)
+
+	ready, notReady := true, false
+	svcName := fmt.Sprintf("%s-prod", shippertesting.TestApp)
+	cluster.InitializeDynamicClient([]runtime.Object{
+		buildEndpointSlice("slice-a", svcName,
+			sliceEndpoint{pods[0].Name, &ready},
+			sliceEndpoint{pods[1].Name, &notReady},
+			sliceEndpoint{pods[2].Name, &notReady},
+		),
+		// pods[1] is being moved between slices, and is ready in the
+		// one it's being moved to.
+		buildEndpointSlice("slice-b", svcName,
+			sliceEndpoint{pods[1].Name, &ready},
+		),
+		buildEndpointSlice("another-slice", "another-service",
+			sliceEndpoint{pods[2].Name, &ready},
+		),
+	})
+	cluster.InitializeDiscovery([]*metav1.APIResourceList{
+		{
+			GroupVersion: endpointSliceGVK.GroupVersion().String(),
+			APIResources: []metav1.APIResource{
+				{Name: endpointSliceGVR.Resource, Namespaced: true, Kind: endpointSliceGVK.Kind},
+			},
+		},
+	})
+
+	f.ShipperClient.Tracker().Add(&shipper.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterA},
+	})
+	f.ShipperClient.Tracker().Add(tt)
+
+	runController(f)
+
+	object, err := f.ShipperClient.Tracker().Get(
+		shipper.SchemeGroupVersion.WithResource("traffictargets"), tt.Namespace, tt.Name)
+	if err != nil {
+		t.Fatalf("could not Get TrafficTarget %q: %s", tt.Name, err)
+	}
+	tt = object.(*shipper.TrafficTarget)
+
+	expected := &shipper.ClusterTrafficStatus{
+		Name:             clusterA,
+		RequestedTraffic: 10,
+		AchievedTraffic:  7,
+		Conditions: []shipper.ClusterTrafficCondition{
+			ClusterTrafficOperational,
+			{
+				Type:    shipper.ClusterConditionTypeReady,
+				Status:  corev1.ConditionFalse,
+				Reason:  PodsNotReady,
+				Message: "1/3 pods designated to receive traffic are not ready",
+			},
+		},
+	}
+
+	if len(tt.Status.Clusters) != 1 {
+		t.Fatalf("expected status for a single cluster, got %d", len(tt.Status.Clusters))
+	}
+
+	eq, diff := shippertesting.DeepEqualDiff(expected, tt.Status.Clusters[0])
+	if !eq {
+		t.Errorf("TrafficTarget %q has cluster status different from expected:\n%s", tt.Name, diff)
+	}
+}
+
+// TestEndpointSliceEventsEnqueueTrafficTargets verifies that clusters that
+// serve EndpointSlices get them watched instead of Endpoints, and that a
+// change to a slice enqueues the traffic targets of its Service's app.
+func TestEndpointSliceEventsEnqueueTrafficTargets(t *testing.T) {
+	f := shippertesting.NewControllerTestFixture()
+	cluster := f.AddNamedCluster(clusterA)
+
+	tt := buildTrafficTarget(shippertesting.TestApp, ttName,
+		map[string]uint32{clusterA: 10})
+
+	pods := buildPods(shippertesting.TestApp, ttName, 1, withTraffic)
+	cluster.AddOne(buildService(shippertesting.TestApp))
+
+	ready, notReady := true, false
+	svcName := fmt.Sprintf("%s-prod", shippertesting.TestApp)
+	slice := buildEndpointSlice("slice-a", svcName, sliceEndpoint{pods[0].Name, &notReady})
+	cluster.InitializeDynamicClient([]runtime.Object{slice})
+	cluster.InitializeDiscovery([]*metav1.APIResourceList{
+		{
+			GroupVersion: endpointSliceGVK.GroupVersion().String(),
+			APIResources: []metav1.APIResource{
+				{Name: endpointSliceGVR.Resource, Namespaced: true, Kind: endpointSliceGVK.Kind},
+			},
+		},
+	})
+
+	f.ShipperClient.Tracker().Add(&shipper.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterA},
+	})
+	f.ShipperClient.Tracker().Add(tt)
+
+	controller := NewController(
+		f.ShipperClient,
+		f.ShipperInformerFactory,
+		f.ClusterClientStore,
+		f.DynamicClientBuilder,
+		f.Recorder,
+	)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	f.Run(stopCh)
+
+	endpoints := controller.clusterEndpoints[clusterA]
+	if !cache.WaitForCacheSync(stopCh, endpoints.hasSynced) {
+		t.Fatalf("EndpointSlices in cluster %q never synced", clusterA)
+	}
+
+	if endpoints.endpointSlices == nil || endpoints.endpoints != nil {
+		t.Fatalf("expected EndpointSlices to be watched instead of Endpoints in cluster %q", clusterA)
+	}
+
+	// Whatever got enqueued while the caches were filling up is not
+	// what we're after.
+	for controller.workqueue.Len() > 0 {
+		key, _ := controller.workqueue.Get()
+		controller.workqueue.Forget(key)
+		controller.workqueue.Done(key)
+	}
+
+	slice = buildEndpointSlice("slice-a", svcName, sliceEndpoint{pods[0].Name, &ready})
+	_, err := cluster.DynamicClient.Resource(endpointSliceGVR).
+		Namespace(slice.GetNamespace()).Update(slice, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("could not update EndpointSlice %q: %s", slice.GetName(), err)
+	}
+
+	err = wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		return controller.workqueue.Len() > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("expected TrafficTarget %q to be enqueued, but it wasn't", tt.Name)
+	}
+
+	key, _ := controller.workqueue.Get()
+	defer controller.workqueue.Done(key)
+
+	expected := fmt.Sprintf("%s/%s", tt.Namespace, tt.Name)
+	if key != expected {
+		t.Errorf("expected %q to be enqueued, got %q", expected, key)
+	}
+}
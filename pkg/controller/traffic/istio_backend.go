@@ -0,0 +1,205 @@
+package traffic
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/clusterclientstore"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+var (
+	istioVirtualServiceGVK  = schema.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "VirtualService"}
+	istioDestinationRuleGVK = schema.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "DestinationRule"}
+
+	istioVirtualServiceGVR  = istioVirtualServiceGVK.GroupVersion().WithResource("virtualservices")
+	istioDestinationRuleGVR = istioDestinationRuleGVK.GroupVersion().WithResource("destinationrules")
+)
+
+// istioBackend shifts traffic by writing weighted routes into an Istio
+// VirtualService, with a DestinationRule subset per release. Every production
+// Service of the application gets both, named after it. This gives request
+// level weights, so the weight configured in the mesh is the achieved
+// traffic.
+//
+// Every pod of a release that gets any traffic is labeled to be selected by
+// the Services, so Istio can find it as an endpoint.
+type istioBackend struct {
+	dynamicClientBuilder clusterclientstore.DynamicClientBuilderFunc
+}
+
+var _ trafficBackend = istioBackend{}
+
+func (b istioBackend) shift(req *shiftRequest) (uint32, *shipper.ClusterTrafficCondition, error) {
+	services, err := getProductionServices(req.clientset, req.namespace, req.appName)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	appPods, err := getAppPods(req.clientset, req.namespace, req.appName)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	routeWeights := meshRouteWeights(
+		req.weights[req.clusterName], req.pods[req.clusterName], appPods)
+
+	notReady := func(reason string, err error) (uint32, *shipper.ClusterTrafficCondition, error) {
+		return 0, trafficutil.NewClusterTrafficCondition(
+			shipper.ClusterConditionTypeReady,
+			corev1.ConditionFalse,
+			reason,
+			err.Error(),
+		), err
+	}
+
+	client := clusterclientstore.NewDynamicClient(
+		req.clientset, req.cluster, istioVirtualServiceGVK, b.dynamicClientBuilder)
+
+	appliedVSs := make([]*unstructured.Unstructured, 0, len(services))
+	for _, svc := range services {
+		dr := buildIstioDestinationRule(svc, req.appName, routeWeights)
+		if _, err := applyUnstructured(client.Resource(istioDestinationRuleGVR).Namespace(req.namespace), dr); err != nil {
+			return notReady(InternalError, err)
+		}
+
+		vs := buildIstioVirtualService(svc, req.appName, routeWeights)
+		appliedVS, err := applyUnstructured(client.Resource(istioVirtualServiceGVR).Namespace(req.namespace), vs)
+		if err != nil {
+			return notReady(InternalError, err)
+		}
+		appliedVSs = append(appliedVSs, appliedVS)
+	}
+
+	if err := recordTrafficDecision(req, meshTrafficDecision(routeWeights, req.releaseName)); err != nil {
+		return notReady(InternalError, err)
+	}
+
+	podsToShift := buildMeshPodsToShift(appPods, routeWeights)
+	var progress podShiftProgress
+	if len(podsToShift) > 0 {
+		progress, err = shiftPods(req, podsToShift)
+		if err != nil {
+			return notReady(InternalError, err)
+		}
+	}
+
+	for _, appliedVS := range appliedVSs {
+		// A release without traffic has no route when nobody gets any.
+		weight, _ := istioAppliedWeight(appliedVS, req.releaseName)
+		if weight != routeWeights[req.releaseName] {
+			return 0, trafficutil.NewClusterTrafficCondition(
+				shipper.ClusterConditionTypeReady,
+				corev1.ConditionFalse,
+				InProgress,
+				fmt.Sprintf("VirtualService %q does not route to release %q yet", appliedVS.GetName(), req.releaseName),
+			), nil
+		}
+	}
+
+	achievedTraffic := meshAchievedTraffic(req, routeWeights, appPods)
+
+	if len(podsToShift) > 0 {
+		return achievedTraffic, buildPodShiftCondition(progress), nil
+	}
+
+	return achievedTraffic, trafficutil.NewClusterTrafficCondition(
+		shipper.ClusterConditionTypeReady,
+		corev1.ConditionTrue,
+		"",
+		"",
+	), nil
+}
+
+func buildIstioDestinationRule(svc *corev1.Service, appName string, routeWeights map[string]int64) *unstructured.Unstructured {
+	subsets := make([]interface{}, 0, len(routeWeights))
+	for _, release := range sortedReleases(routeWeights) {
+		subsets = append(subsets, map[string]interface{}{
+			"name": release,
+			"labels": map[string]interface{}{
+				shipper.ReleaseLabel: release,
+			},
+		})
+	}
+
+	return buildIstioObject(istioDestinationRuleGVK, svc, appName, map[string]interface{}{
+		"host":    svc.Name,
+		"subsets": subsets,
+	})
+}
+
+func buildIstioVirtualService(svc *corev1.Service, appName string, routeWeights map[string]int64) *unstructured.Unstructured {
+	routes := make([]interface{}, 0, len(routeWeights))
+	for _, release := range sortedReleases(routeWeights) {
+		routes = append(routes, map[string]interface{}{
+			"destination": map[string]interface{}{
+				"host":   svc.Name,
+				"subset": release,
+			},
+			"weight": routeWeights[release],
+		})
+	}
+
+	// Istio rejects routes whose weights don't add up to 100, so when no
+	// release is meant to get any traffic we don't route at all.
+	http := []interface{}{}
+	var total int64
+	for _, weight := range routeWeights {
+		total += weight
+	}
+	if total > 0 {
+		http = append(http, map[string]interface{}{"route": routes})
+	}
+
+	return buildIstioObject(istioVirtualServiceGVK, svc, appName, map[string]interface{}{
+		"hosts": []interface{}{svc.Name},
+		"http":  http,
+	})
+}
+
+func buildIstioObject(gvk schema.GroupVersionKind, svc *corev1.Service, appName string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(svc.Name)
+	obj.SetNamespace(svc.Namespace)
+	obj.SetLabels(map[string]string{
+		shipper.AppLabel: appName,
+	})
+	obj.Object["spec"] = spec
+
+	return obj
+}
+
+// istioAppliedWeight returns the weight a VirtualService routes to a
+// release, if it routes to it at all.
+func istioAppliedWeight(vs *unstructured.Unstructured, release string) (int64, bool) {
+	http, _, _ := unstructured.NestedSlice(vs.Object, "spec", "http")
+	for _, h := range http {
+		httpRoute, ok := h.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		routes, _, _ := unstructured.NestedSlice(httpRoute, "route")
+		for _, r := range routes {
+			route, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			subset, _, _ := unstructured.NestedString(route, "destination", "subset")
+			if subset != release {
+				continue
+			}
+
+			weight, _, _ := unstructured.NestedInt64(route, "weight")
+			return weight, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,81 @@
+package traffic
+
+import (
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippertesting "github.com/bookingcom/shipper/pkg/testing"
+)
+
+// TestIstioBackend verifies that the istio backend writes the weights of all
+// releases into a VirtualService, and reports them as achieved traffic.
+func TestIstioBackend(t *testing.T) {
+	f := shippertesting.NewControllerTestFixture()
+
+	cluster := f.AddNamedCluster(clusterA)
+	cluster.InitializeDynamicClient(nil)
+
+	incumbent := buildTrafficTarget(shippertesting.TestApp, "foobar-a",
+		map[string]uint32{clusterA: 90})
+	contender := buildTrafficTarget(shippertesting.TestApp, "foobar-b",
+		map[string]uint32{clusterA: 10})
+
+	objects := []runtime.Object{
+		buildService(shippertesting.TestApp),
+		buildEndpoints(shippertesting.TestApp),
+	}
+	objects = addPodsToList(objects, buildPods(shippertesting.TestApp, incumbent.Name, 2, withTraffic))
+	objects = addPodsToList(objects, buildPods(shippertesting.TestApp, contender.Name, 2, noTraffic))
+	cluster.AddMany(objects)
+
+	f.ShipperClient.Tracker().Add(&shipper.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterA},
+		Spec: shipper.ClusterSpec{
+			TrafficBackend: shipper.TrafficBackendIstio,
+		},
+	})
+	f.ShipperClient.Tracker().Add(incumbent)
+	f.ShipperClient.Tracker().Add(contender)
+
+	runController(f)
+
+	ttGVR := shipper.SchemeGroupVersion.WithResource("traffictargets")
+	for _, expected := range []*shipper.TrafficTarget{incumbent, contender} {
+		object, err := f.ShipperClient.Tracker().Get(ttGVR, expected.Namespace, expected.Name)
+		if err != nil {
+			t.Fatalf("could not Get TrafficTarget %q: %s", expected.Name, err)
+		}
+
+		tt := object.(*shipper.TrafficTarget)
+		eq, diff := shippertesting.DeepEqualDiff(buildSuccessStatus(expected.Spec.Clusters), tt.Status)
+		if !eq {
+			t.Errorf("TrafficTarget %q has Status different from expected:\n%s", tt.Name, diff)
+		}
+
+		assertPodTraffic(t, tt, cluster, podStatus{withTraffic: 2})
+	}
+
+	svcName := fmt.Sprintf("%s-prod", shippertesting.TestApp)
+	vs, err := cluster.DynamicClient.Resource(istioVirtualServiceGVR).
+		Namespace(shippertesting.TestNamespace).Get(svcName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("could not Get VirtualService %q: %s", svcName, err)
+	}
+
+	for release, weight := range map[string]int64{incumbent.Name: 90, contender.Name: 10} {
+		got, ok := istioAppliedWeight(vs, release)
+		if !ok || got != weight {
+			t.Errorf("expected VirtualService to route %d to %q, got %d", weight, release, got)
+		}
+	}
+
+	_, err = cluster.DynamicClient.Resource(istioDestinationRuleGVR).
+		Namespace(shippertesting.TestNamespace).Get(svcName, metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("could not Get DestinationRule %q: %s", svcName, err)
+	}
+}
@@ -0,0 +1,203 @@
+package traffic
+
+import (
+	"math"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+)
+
+// meshRouteWeights turns release weights into route weights, as service
+// meshes expect them. Releases asking for an absolute number of pods get the
+// share of traffic those pods represent in the application, and the rest is
+// shared by weight. When no release has any weight, the pods of the releases
+// asking for them are the only ones getting traffic, so they share all of it.
+// Route weights add up to 100 unless no release gets any traffic at all, as
+// the rounding remainder goes to the releases with the largest fractions.
+func meshRouteWeights(
+	releaseWeights map[string]uint32,
+	releasePods map[string]uint32,
+	appPods []*corev1.Pod,
+) map[string]int64 {
+	var totalWeight uint32
+	for _, weight := range releaseWeights {
+		totalWeight += weight
+	}
+
+	podsByRelease := make(map[string]int)
+	for _, pod := range appPods {
+		podsByRelease[pod.Labels[shipper.ReleaseLabel]]++
+	}
+
+	podsWithTraffic := make(map[string]float64, len(releasePods))
+	var totalPodsWithTraffic float64
+	for release, pods := range releasePods {
+		n := math.Min(float64(podsByRelease[release]), float64(pods))
+		podsWithTraffic[release] = n
+		totalPodsWithTraffic += n
+	}
+
+	// Pods get their share of the whole application, unless they're all
+	// there is to get traffic.
+	podsShareOf := float64(len(appPods))
+	if totalWeight == 0 {
+		podsShareOf = totalPodsWithTraffic
+	}
+
+	shares := make(map[string]float64, len(releaseWeights)+len(releasePods))
+	remaining := 100.0
+	for release, n := range podsWithTraffic {
+		if n > 0 {
+			shares[release] = 100 * n / podsShareOf
+		} else {
+			shares[release] = 0
+		}
+		remaining -= shares[release]
+	}
+
+	for release, weight := range releaseWeights {
+		if totalWeight == 0 {
+			shares[release] = 0
+		} else {
+			shares[release] = remaining * float64(weight) / float64(totalWeight)
+		}
+	}
+
+	releases := make([]string, 0, len(shares))
+	routeWeights := make(map[string]int64, len(shares))
+	var total int64
+	for release, share := range shares {
+		releases = append(releases, release)
+		routeWeights[release] = int64(math.Floor(share))
+		total += routeWeights[release]
+	}
+
+	// Shares only add up to 100 when some release gets traffic, and
+	// otherwise there's no remainder to hand out.
+	if totalWeight == 0 && totalPodsWithTraffic == 0 {
+		return routeWeights
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		fi := shares[releases[i]] - math.Floor(shares[releases[i]])
+		fj := shares[releases[j]] - math.Floor(shares[releases[j]])
+		if fi != fj {
+			return fi > fj
+		}
+		return releases[i] < releases[j]
+	})
+	for i := 0; total < 100 && i < len(releases); i++ {
+		// Releases without any share of the traffic don't get any
+		// of the remainder either.
+		if shares[releases[i]] == 0 {
+			continue
+		}
+		routeWeights[releases[i]]++
+		total++
+	}
+
+	return routeWeights
+}
+
+// meshAchievedTraffic returns the weight the release in req achieves once
+// routeWeights are configured in the mesh. Releases asking for an absolute
+// number of pods achieve the route weight those pods got, and how many of
+// them there are is set in req.
+func meshAchievedTraffic(req *shiftRequest, routeWeights map[string]int64, appPods []*corev1.Pod) uint32 {
+	pods, ok := req.pods[req.clusterName][req.releaseName]
+	if !ok {
+		return req.weights[req.clusterName][req.releaseName]
+	}
+
+	var podsInRelease uint32
+	for _, pod := range appPods {
+		if pod.Labels[shipper.ReleaseLabel] == req.releaseName {
+			podsInRelease++
+		}
+	}
+
+	achievedPods := pods
+	if podsInRelease < achievedPods {
+		achievedPods = podsInRelease
+	}
+	req.achievedPods = &achievedPods
+
+	return uint32(routeWeights[req.releaseName])
+}
+
+// buildMeshPodsToShift returns which pods need their traffic status label
+// changed so that releases with any traffic are fully selected by the
+// Service, and releases without traffic are not.
+func buildMeshPodsToShift(appPods []*corev1.Pod, routeWeights map[string]int64) map[string][]*corev1.Pod {
+	podsToShift := make(map[string][]*corev1.Pod)
+	for _, pod := range appPods {
+		status := shipper.Disabled
+		if routeWeights[pod.Labels[shipper.ReleaseLabel]] > 0 {
+			status = shipper.Enabled
+		}
+
+		if pod.Labels[shipper.PodTrafficStatusLabel] != status {
+			podsToShift[status] = append(podsToShift[status], pod)
+		}
+	}
+
+	return podsToShift
+}
+
+// meshTrafficDecision returns how new pods of a release should be labeled
+// when traffic is shifted by a mesh: all of them if the release gets any
+// traffic, and none otherwise.
+func meshTrafficDecision(routeWeights map[string]int64, release string) string {
+	if routeWeights[release] > 0 {
+		return shipper.Enabled
+	}
+	return shipper.Disabled
+}
+
+func sortedReleases(routeWeights map[string]int64) []string {
+	releases := make([]string, 0, len(routeWeights))
+	for release := range routeWeights {
+		releases = append(releases, release)
+	}
+	sort.Strings(releases)
+	return releases
+}
+
+// applyUnstructured creates obj, or updates its spec if it already exists
+// and differs.
+func applyUnstructured(client dynamic.ResourceInterface, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	existing, err := client.Get(obj.GetName(), metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		created, err := client.Create(obj, metav1.CreateOptions{})
+		if err != nil {
+			return nil, shippererrors.NewKubeclientCreateError(obj, err).
+				WithKind(obj.GroupVersionKind())
+		}
+		return created, nil
+	} else if err != nil {
+		return nil, shippererrors.NewKubeclientGetError(obj.GetNamespace(), obj.GetName(), err).
+			WithKind(obj.GroupVersionKind())
+	}
+
+	if equality.Semantic.DeepEqual(existing.Object["spec"], obj.Object["spec"]) {
+		return existing, nil
+	}
+
+	existing = existing.DeepCopy()
+	existing.Object["spec"] = obj.Object["spec"]
+	updated, err := client.Update(existing, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, shippererrors.NewKubeclientUpdateError(existing, err).
+			WithKind(existing.GroupVersionKind())
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,95 @@
+package traffic
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	shippertesting "github.com/bookingcom/shipper/pkg/testing"
+)
+
+func TestMeshRouteWeights(t *testing.T) {
+	pods := func(release string, n int) []*corev1.Pod {
+		return buildPods(shippertesting.TestApp, release, n, noTraffic)
+	}
+
+	tests := []struct {
+		name     string
+		weights  map[string]uint32
+		pods     map[string]uint32
+		appPods  []*corev1.Pod
+		expected map[string]int64
+	}{
+		{
+			name:     "single release",
+			weights:  map[string]uint32{"a": 10},
+			expected: map[string]int64{"a": 100},
+		},
+		{
+			name:     "weights add up to 100",
+			weights:  map[string]uint32{"a": 90, "b": 10},
+			expected: map[string]int64{"a": 90, "b": 10},
+		},
+		{
+			name:     "remainders are distributed",
+			weights:  map[string]uint32{"a": 1, "b": 1, "c": 1},
+			expected: map[string]int64{"a": 34, "b": 33, "c": 33},
+		},
+		{
+			name:     "no traffic at all",
+			weights:  map[string]uint32{"a": 0, "b": 0},
+			expected: map[string]int64{"a": 0, "b": 0},
+		},
+		{
+			name:     "absolute pods",
+			weights:  map[string]uint32{"a": 100},
+			pods:     map[string]uint32{"b": 1},
+			appPods:  append(pods("a", 3), pods("b", 1)...),
+			expected: map[string]int64{"a": 75, "b": 25},
+		},
+		{
+			name:     "absolute pods with remainders",
+			weights:  map[string]uint32{"a": 100},
+			pods:     map[string]uint32{"b": 1},
+			appPods:  append(pods("a", 2), pods("b", 1)...),
+			expected: map[string]int64{"a": 67, "b": 33},
+		},
+		{
+			name:     "absolute pods with zero weights",
+			weights:  map[string]uint32{"a": 0},
+			pods:     map[string]uint32{"b": 1},
+			appPods:  append(pods("a", 3), pods("b", 1)...),
+			expected: map[string]int64{"a": 0, "b": 100},
+		},
+		{
+			name:     "absolute pods only",
+			pods:     map[string]uint32{"a": 1, "b": 2},
+			appPods:  append(pods("a", 3), pods("b", 3)...),
+			expected: map[string]int64{"a": 33, "b": 67},
+		},
+		{
+			name:     "absolute pods without any pods",
+			weights:  map[string]uint32{"a": 0},
+			pods:     map[string]uint32{"b": 1},
+			appPods:  pods("a", 3),
+			expected: map[string]int64{"a": 0, "b": 0},
+		},
+		{
+			name:     "zero weight next to absolute pods",
+			weights:  map[string]uint32{"a": 100, "c": 0},
+			pods:     map[string]uint32{"b": 1},
+			appPods:  append(append(pods("a", 2), pods("b", 1)...), pods("c", 3)...),
+			expected: map[string]int64{"a": 83, "b": 17, "c": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := meshRouteWeights(tt.weights, tt.pods, tt.appPods)
+			eq, diff := shippertesting.DeepEqualDiff(tt.expected, got)
+			if !eq {
+				t.Errorf("route weights differ from expected:\n%s", diff)
+			}
+		})
+	}
+}
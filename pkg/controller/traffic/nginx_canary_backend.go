@@ -0,0 +1,226 @@
+package traffic
+
+import (
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+// nginxCanaryBackend shifts traffic with the canary Ingresses the
+// installation controller renders next to the Ingresses of an application's
+// chart. ingress-nginx only honours a single canary per Ingress, so the
+// canary always points to the release that was installed last, and the
+// weight of that release is set as the canary weight. Every other release
+// shares the rest of the traffic through the application's Services, with pod
+// labels just like the podLabels backend.
+//
+// Once the canary release gets all of the traffic, its pods are also added
+// to the application's Service, so that traffic keeps flowing to it when the
+// canary is taken over by the next release.
+type nginxCanaryBackend struct{}
+
+var _ trafficBackend = nginxCanaryBackend{}
+
+func (b nginxCanaryBackend) shift(req *shiftRequest) (uint32, *shipper.ClusterTrafficCondition, error) {
+	appPods, services, err := getClusterObjects(req)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	kubeclient := req.clientset.GetKubeClient()
+	canaries, err := getNginxCanaryIngresses(kubeclient, req.namespace, req.appName)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Without a canary Ingress, there's nothing to do but to shift
+	// traffic with pod labels.
+	if len(canaries) == 0 {
+		return shiftByPodLabels(req, req.weights, req.pods, appPods, services)
+	}
+
+	canaryRelease := canaries[0].Labels[shipper.ReleaseLabel]
+
+	// The canary Ingress outlives the release it points to if that
+	// release goes away, for instance when it's rolled back, as the
+	// anchors of the other releases own it too. Nothing would take its
+	// weight away then, so whichever release comes along does, and
+	// everything else goes through the application's Service.
+	_, hasWeight := req.weights[req.clusterName][canaryRelease]
+	_, hasPods := req.pods[req.clusterName][canaryRelease]
+	if !hasWeight && !hasPods {
+		err := setNginxCanaryWeights(kubeclient, canaries, canaryRelease, 0)
+		if err != nil {
+			return 0, trafficutil.NewClusterTrafficCondition(
+				shipper.ClusterConditionTypeReady,
+				corev1.ConditionFalse,
+				InternalError,
+				err.Error(),
+			), err
+		}
+
+		return shiftByPodLabels(req, req.weights, req.pods, appPods, services)
+	}
+
+	routeWeights := meshRouteWeights(
+		req.weights[req.clusterName], req.pods[req.clusterName], appPods)
+	canaryWeight := routeWeights[canaryRelease]
+
+	weights, pods, primaryPods := buildNginxPrimaryWeights(
+		req.clusterName, req.weights, req.pods, appPods, canaryRelease, canaryWeight)
+
+	if req.releaseName != canaryRelease {
+		return shiftByPodLabels(req, weights, pods, primaryPods, services)
+	}
+
+	err = setNginxCanaryWeights(kubeclient, canaries, canaryRelease, canaryWeight)
+	if err != nil {
+		return 0, trafficutil.NewClusterTrafficCondition(
+			shipper.ClusterConditionTypeReady,
+			corev1.ConditionFalse,
+			InternalError,
+			err.Error(),
+		), err
+	}
+
+	// The canary gets its traffic as soon as its weight is set, so we
+	// only need to wait for its pods to be labeled as the application's
+	// Service requires.
+	_, cond, err := shiftByPodLabels(req, weights, pods, primaryPods, services)
+	if err != nil {
+		return 0, cond, err
+	}
+
+	// The canary's weight is achieved exactly, regardless of how many
+	// pods it has.
+	req.weightDeviation = nil
+
+	achievedTraffic := meshAchievedTraffic(req, routeWeights, appPods)
+
+	return achievedTraffic, cond, nil
+}
+
+// buildNginxPrimaryWeights returns the weights and pods the releases should
+// get through the application's Service, along with the pods that back it.
+// The canary release is left out of the Service, unless it's meant to get
+// all of the traffic, in which case it's the only release in there.
+func buildNginxPrimaryWeights(
+	clusterName string,
+	weights clusterReleaseWeights,
+	pods clusterReleasePods,
+	appPods []*corev1.Pod,
+	canaryRelease string,
+	canaryWeight int64,
+) (clusterReleaseWeights, clusterReleasePods, []*corev1.Pod) {
+	primaryWeights := make(map[string]uint32)
+	primaryPods := make(map[string]uint32)
+
+	if canaryWeight >= 100 {
+		for release := range weights[clusterName] {
+			primaryWeights[release] = 0
+		}
+		for release := range pods[clusterName] {
+			primaryWeights[release] = 0
+		}
+		primaryWeights[canaryRelease] = 1
+
+		return clusterReleaseWeights{clusterName: primaryWeights},
+			clusterReleasePods{clusterName: primaryPods},
+			appPods
+	}
+
+	for release, weight := range weights[clusterName] {
+		primaryWeights[release] = weight
+	}
+	for release, n := range pods[clusterName] {
+		primaryPods[release] = n
+	}
+	delete(primaryPods, canaryRelease)
+	primaryWeights[canaryRelease] = 0
+
+	servicePods := make([]*corev1.Pod, 0, len(appPods))
+	for _, pod := range appPods {
+		if pod.Labels[shipper.ReleaseLabel] != canaryRelease {
+			servicePods = append(servicePods, pod)
+		}
+	}
+
+	return clusterReleaseWeights{clusterName: primaryWeights},
+		clusterReleasePods{clusterName: primaryPods},
+		servicePods
+}
+
+// getNginxCanaryIngresses returns the canary Ingresses of an application,
+// sorted by name.
+func getNginxCanaryIngresses(client kubernetes.Interface, ns, appName string) ([]*networkingv1beta1.Ingress, error) {
+	selector := labels.Set{shipper.AppLabel: appName}.AsSelector()
+	list, err := client.NetworkingV1beta1().Ingresses(ns).List(metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, shippererrors.NewKubeclientListError(
+			networkingv1beta1.SchemeGroupVersion.WithKind("Ingress"),
+			ns, selector, err)
+	}
+
+	canaries := make([]*networkingv1beta1.Ingress, 0, len(list.Items))
+	for i := range list.Items {
+		ing := &list.Items[i]
+		if ing.Annotations[trafficutil.NginxCanaryAnnotation] == "true" {
+			canaries = append(canaries, ing)
+		}
+	}
+
+	sort.Slice(canaries, func(i, j int) bool {
+		return canaries[i].Name < canaries[j].Name
+	})
+
+	return canaries, nil
+}
+
+// setNginxCanaryWeights sets weight on all the canaries that point to
+// release.
+func setNginxCanaryWeights(
+	client kubernetes.Interface,
+	canaries []*networkingv1beta1.Ingress,
+	release string,
+	weight int64,
+) error {
+	for _, ing := range canaries {
+		if ing.Labels[shipper.ReleaseLabel] != release {
+			continue
+		}
+
+		if err := setNginxCanaryWeight(client, ing, weight); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setNginxCanaryWeight(client kubernetes.Interface, ing *networkingv1beta1.Ingress, weight int64) error {
+	value := strconv.FormatInt(weight, 10)
+	if ing.Annotations[trafficutil.NginxCanaryWeightAnnotation] == value {
+		return nil
+	}
+
+	ing = ing.DeepCopy()
+	ing.Annotations[trafficutil.NginxCanaryWeightAnnotation] = value
+	_, err := client.NetworkingV1beta1().Ingresses(ing.Namespace).Update(ing)
+	if err != nil {
+		return shippererrors.NewKubeclientUpdateError(ing, err).
+			WithKind(networkingv1beta1.SchemeGroupVersion.WithKind("Ingress"))
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package traffic
+
+import (
+	"testing"
+
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippertesting "github.com/bookingcom/shipper/pkg/testing"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+func buildNginxCanaryIngress(app, release string) *networkingv1beta1.Ingress {
+	return &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      trafficutil.NginxCanaryIngressName(app),
+			Namespace: shippertesting.TestNamespace,
+			Labels: map[string]string{
+				shipper.AppLabel:     app,
+				shipper.ReleaseLabel: release,
+			},
+			Annotations: map[string]string{
+				trafficutil.NginxCanaryAnnotation:       "true",
+				trafficutil.NginxCanaryWeightAnnotation: "0",
+			},
+		},
+	}
+}
+
+// TestNginxCanaryBackend verifies that the contender gets its traffic
+// through the canary Ingress, and the incumbent through the application's
+// Service.
+func TestNginxCanaryBackend(t *testing.T) {
+	tests := []struct {
+		name            string
+		incumbentWeight uint32
+		contenderWeight uint32
+		canaryWeight    string
+		incumbentPods   podStatus
+		contenderPods   podStatus
+	}{
+		{
+			name:            "halfway through",
+			incumbentWeight: 50,
+			contenderWeight: 50,
+			canaryWeight:    "50",
+			incumbentPods:   podStatus{withTraffic: 2},
+			contenderPods:   podStatus{withoutTraffic: 2},
+		},
+		{
+			name:            "contender gets all traffic",
+			incumbentWeight: 0,
+			contenderWeight: 100,
+			canaryWeight:    "100",
+			incumbentPods:   podStatus{withoutTraffic: 2},
+			contenderPods:   podStatus{withTraffic: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := shippertesting.NewControllerTestFixture()
+			cluster := f.AddNamedCluster(clusterA)
+
+			incumbent := buildTrafficTarget(shippertesting.TestApp, "foobar-a",
+				map[string]uint32{clusterA: tt.incumbentWeight})
+			contender := buildTrafficTarget(shippertesting.TestApp, "foobar-b",
+				map[string]uint32{clusterA: tt.contenderWeight})
+
+			objects := []runtime.Object{
+				buildService(shippertesting.TestApp),
+				buildEndpoints(shippertesting.TestApp),
+				buildNginxCanaryIngress(shippertesting.TestApp, contender.Name),
+			}
+			objects = addPodsToList(objects, buildPods(shippertesting.TestApp, incumbent.Name, 2, withTraffic))
+			objects = addPodsToList(objects, buildPods(shippertesting.TestApp, contender.Name, 2, noTraffic))
+			cluster.AddMany(objects)
+
+			f.ShipperClient.Tracker().Add(&shipper.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: clusterA},
+				Spec: shipper.ClusterSpec{
+					TrafficBackend: shipper.TrafficBackendNginxCanary,
+				},
+			})
+			f.ShipperClient.Tracker().Add(incumbent)
+			f.ShipperClient.Tracker().Add(contender)
+
+			runController(f)
+
+			ttGVR := shipper.SchemeGroupVersion.WithResource("traffictargets")
+			expectedPods := map[string]podStatus{
+				incumbent.Name: tt.incumbentPods,
+				contender.Name: tt.contenderPods,
+			}
+			for _, expected := range []*shipper.TrafficTarget{incumbent, contender} {
+				object, err := f.ShipperClient.Tracker().Get(ttGVR, expected.Namespace, expected.Name)
+				if err != nil {
+					t.Fatalf("could not Get TrafficTarget %q: %s", expected.Name, err)
+				}
+
+				got := object.(*shipper.TrafficTarget)
+				eq, diff := shippertesting.DeepEqualDiff(buildSuccessStatus(expected.Spec.Clusters), got.Status)
+				if !eq {
+					t.Errorf("TrafficTarget %q has Status different from expected:\n%s", got.Name, diff)
+				}
+
+				assertPodTraffic(t, got, cluster, expectedPods[got.Name])
+			}
+
+			ing, err := cluster.Client.NetworkingV1beta1().Ingresses(shippertesting.TestNamespace).
+				Get(trafficutil.NginxCanaryIngressName(shippertesting.TestApp), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("could not Get canary Ingress: %s", err)
+			}
+
+			weight := ing.Annotations[trafficutil.NginxCanaryWeightAnnotation]
+			if weight != tt.canaryWeight {
+				t.Errorf("expected canary weight %q, got %q", tt.canaryWeight, weight)
+			}
+		})
+	}
+}
+
+// TestNginxCanaryBackendRollback verifies that the canary Ingress stops
+// getting any traffic once the release it points to is gone, and the
+// incumbent gets all of it through the application's Service.
+func TestNginxCanaryBackendRollback(t *testing.T) {
+	f := shippertesting.NewControllerTestFixture()
+	cluster := f.AddNamedCluster(clusterA)
+
+	incumbent := buildTrafficTarget(shippertesting.TestApp, "foobar-a",
+		map[string]uint32{clusterA: 100})
+
+	// The contender, foobar-b, was rolled back halfway through, so its
+	// pods and traffic target are gone, but not the canary Ingress.
+	canary := buildNginxCanaryIngress(shippertesting.TestApp, "foobar-b")
+	canary.Annotations[trafficutil.NginxCanaryWeightAnnotation] = "50"
+
+	objects := []runtime.Object{
+		buildService(shippertesting.TestApp),
+		buildEndpoints(shippertesting.TestApp),
+		canary,
+	}
+	objects = addPodsToList(objects, buildPods(shippertesting.TestApp, incumbent.Name, 2, withTraffic))
+	cluster.AddMany(objects)
+
+	f.ShipperClient.Tracker().Add(&shipper.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterA},
+		Spec: shipper.ClusterSpec{
+			TrafficBackend: shipper.TrafficBackendNginxCanary,
+		},
+	})
+	f.ShipperClient.Tracker().Add(incumbent)
+
+	runController(f)
+
+	ttGVR := shipper.SchemeGroupVersion.WithResource("traffictargets")
+	object, err := f.ShipperClient.Tracker().Get(ttGVR, incumbent.Namespace, incumbent.Name)
+	if err != nil {
+		t.Fatalf("could not Get TrafficTarget %q: %s", incumbent.Name, err)
+	}
+
+	got := object.(*shipper.TrafficTarget)
+	eq, diff := shippertesting.DeepEqualDiff(buildSuccessStatus(incumbent.Spec.Clusters), got.Status)
+	if !eq {
+		t.Errorf("TrafficTarget %q has Status different from expected:\n%s", got.Name, diff)
+	}
+
+	assertPodTraffic(t, got, cluster, podStatus{withTraffic: 2})
+
+	ing, err := cluster.Client.NetworkingV1beta1().Ingresses(shippertesting.TestNamespace).
+		Get(trafficutil.NginxCanaryIngressName(shippertesting.TestApp), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("could not Get canary Ingress: %s", err)
+	}
+
+	if weight := ing.Annotations[trafficutil.NginxCanaryWeightAnnotation]; weight != "0" {
+		t.Errorf("expected canary weight %q, got %q", "0", weight)
+	}
+}
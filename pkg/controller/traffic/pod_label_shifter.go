@@ -1,12 +1,14 @@
 package traffic
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/workqueue"
 
 	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
 	shippererrors "github.com/bookingcom/shipper/pkg/errors"
@@ -22,11 +24,20 @@ type patchOperation struct {
 }
 
 // shiftPodLabels ensures that the pods in podsToShift have the
-// shipper.PodTrafficStatusLabel label set to the specified values.
+// shipper.PodTrafficStatusLabel label set to the specified values, patching up
+// to parallelism pods at the same time. It returns how many pods it patched,
+// even if it failed to patch others.
 func shiftPodLabels(
 	clientset kubernetes.Interface,
 	podsToShift map[string][]*corev1.Pod,
-) error {
+	parallelism int,
+) (int, error) {
+	type podShift struct {
+		pod   *corev1.Pod
+		value string
+	}
+
+	shifts := make([]podShift, 0)
 	for value, pods := range podsToShift {
 		for _, pod := range pods {
 			v, ok := pod.Labels[shipper.PodTrafficStatusLabel]
@@ -34,18 +45,38 @@ func shiftPodLabels(
 				continue
 			}
 
-			patch := patchPodTrafficStatusLabel(pod, value)
-			_, err := clientset.CoreV1().Pods(pod.Namespace).
-				Patch(pod.Name, types.JSONPatchType, patch)
-			if err != nil {
-				return shippererrors.
-					NewKubeclientPatchError(pod.Namespace, pod.Name, err).
-					WithCoreV1Kind("Pod")
-			}
+			shifts = append(shifts, podShift{pod: pod, value: value})
+		}
+	}
+
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	errs := make([]error, len(shifts))
+	workqueue.ParallelizeUntil(context.TODO(), parallelism, len(shifts), func(i int) {
+		pod := shifts[i].pod
+		patch := patchPodTrafficStatusLabel(pod, shifts[i].value)
+		_, err := clientset.CoreV1().Pods(pod.Namespace).
+			Patch(pod.Name, types.JSONPatchType, patch)
+		if err != nil {
+			errs[i] = shippererrors.
+				NewKubeclientPatchError(pod.Namespace, pod.Name, err).
+				WithCoreV1Kind("Pod")
+		}
+	})
+
+	shifted := 0
+	multiErr := shippererrors.NewMultiError()
+	for _, err := range errs {
+		if err != nil {
+			multiErr.Append(err)
+		} else {
+			shifted++
 		}
 	}
 
-	return nil
+	return shifted, multiErr.Flatten()
 }
 
 // patchPodTrafficStatusLabel returns a JSON Patch that modifies the
@@ -39,7 +39,7 @@ func TestShiftPodLabels(t *testing.T) {
 		}
 	}
 
-	err := shiftPodLabels(clientset, podsToShift)
+	_, err := shiftPodLabels(clientset, podsToShift, defaultPodShiftParallelism)
 	if err != nil {
 		t.Fatalf("unable to shift pod labels: %s", err)
 	}
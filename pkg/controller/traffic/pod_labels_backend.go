@@ -0,0 +1,182 @@
+package traffic
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+// podLabelsBackend shifts traffic by labeling a share of each release's pods
+// so that they get selected by the application's Services. Weights can only
+// be achieved with the granularity of a single pod.
+type podLabelsBackend struct{}
+
+var _ trafficBackend = podLabelsBackend{}
+
+func (b podLabelsBackend) shift(req *shiftRequest) (uint32, *shipper.ClusterTrafficCondition, error) {
+	appPods, services, err := getClusterObjects(req)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return shiftByPodLabels(req, req.weights, req.pods, appPods, services)
+}
+
+// shiftByPodLabels labels the pods of the release in req so that it gets its
+// share of the traffic going through each of the application's Services,
+// according to weights and pods, and reports on its progress.
+//
+// Every Service is looked at on its own, as it may select only some of the
+// pods of the application. The release is ready once it's ready in all of
+// them, and it has achieved as much traffic as it has in the Service that
+// lags behind the most.
+func shiftByPodLabels(
+	req *shiftRequest,
+	weights clusterReleaseWeights,
+	pods clusterReleasePods,
+	appPods []*corev1.Pod,
+	services []serviceEndpoints,
+) (uint32, *shipper.ClusterTrafficCondition, error) {
+	var (
+		achievedTraffic uint32
+		achievedPods    uint32
+		hasAchieved     bool
+		decision        string
+		notReady        *trafficShiftingStatus
+		notReadyService string
+	)
+
+	podsToShift := make(map[string][]*corev1.Pod)
+	podsSeen := make(map[string]struct{})
+	for i, se := range services {
+		svcPods := podsSelectedByService(se.service, appPods)
+		trafficStatus := buildTrafficShiftingStatus(
+			req.clusterName, req.appName, req.releaseName,
+			weights, pods,
+			se.readiness, svcPods)
+
+		if i == 0 {
+			decision = trafficStatus.decision
+		}
+
+		// A Service that doesn't select any of the pods of the
+		// application has no say in how much traffic got achieved.
+		if len(svcPods) > 0 || len(services) == 1 {
+			if !hasAchieved || trafficStatus.achievedTrafficWeight < achievedTraffic {
+				achievedTraffic = trafficStatus.achievedTrafficWeight
+			}
+			if !hasAchieved || uint32(trafficStatus.podsReady) < achievedPods {
+				achievedPods = uint32(trafficStatus.podsReady)
+			}
+			hasAchieved = true
+
+			deviation := trafficStatus.weightDeviation
+			if deviation != nil && (req.weightDeviation == nil ||
+				deviation.percentagePoints() > req.weightDeviation.percentagePoints()) {
+				req.weightDeviation = deviation
+			}
+		}
+
+		if trafficStatus.ready {
+			continue
+		}
+
+		if notReady == nil {
+			status := trafficStatus
+			notReady = &status
+			notReadyService = se.service.Name
+		}
+
+		// Services may select the same pods, and the first one to
+		// want a pod shifted gets its way.
+		for value, podList := range trafficStatus.podsToShift {
+			for _, pod := range podList {
+				if _, ok := podsSeen[pod.Name]; ok {
+					continue
+				}
+				podsSeen[pod.Name] = struct{}{}
+				podsToShift[value] = append(podsToShift[value], pod)
+			}
+		}
+	}
+
+	if _, ok := pods[req.clusterName][req.releaseName]; ok {
+		req.achievedPods = &achievedPods
+	}
+
+	err := recordTrafficDecision(req, decision)
+	if err != nil {
+		return achievedTraffic, trafficutil.NewClusterTrafficCondition(
+			shipper.ClusterConditionTypeReady,
+			corev1.ConditionFalse,
+			InternalError,
+			err.Error(),
+		), err
+	}
+
+	if notReady == nil {
+		return achievedTraffic, trafficutil.NewClusterTrafficCondition(
+			shipper.ClusterConditionTypeReady,
+			corev1.ConditionTrue,
+			"",
+			"",
+		), nil
+	}
+
+	if len(podsToShift) > 0 {
+		// If we have pods to shift, our job can only be done after the
+		// change is made and observed, so we definitely still in
+		// progress.
+		progress, err := shiftPods(req, podsToShift)
+		if err != nil {
+			return achievedTraffic, trafficutil.NewClusterTrafficCondition(
+				shipper.ClusterConditionTypeReady,
+				corev1.ConditionFalse,
+				InternalError,
+				err.Error(),
+			), err
+		}
+
+		return achievedTraffic, buildPodShiftCondition(progress), nil
+	}
+
+	// With more than one Service, we need to say which one is holding
+	// the release back.
+	withService := func(msg string) string {
+		if len(services) == 1 {
+			return msg
+		}
+		return fmt.Sprintf("Service %q: %s", notReadyService, msg)
+	}
+
+	if notReady.podsNotReady > 0 {
+		// All the pods have been shifted, made it to endpoints, but
+		// some aren't ready.
+		msg := fmt.Sprintf(
+			"%d/%d pods designated to receive traffic are not ready",
+			notReady.podsNotReady, notReady.podsLabeled)
+		return achievedTraffic, trafficutil.NewClusterTrafficCondition(
+			shipper.ClusterConditionTypeReady,
+			corev1.ConditionFalse,
+			PodsNotReady,
+			withService(msg),
+		), nil
+	}
+
+	// All the pods have been shifted, but not enough of them are
+	// ready, and there are none not ready in endpoints, which
+	// means that they haven't made it there yet, or that the
+	// service selector does not match any pods.
+	msg := fmt.Sprintf(
+		"%d/%d pods designated to receive traffic are not yet in endpoints",
+		notReady.podsLabeled-notReady.podsReady, notReady.podsLabeled)
+	return achievedTraffic, trafficutil.NewClusterTrafficCondition(
+		shipper.ClusterConditionTypeReady,
+		corev1.ConditionFalse,
+		PodsNotInEndpoints,
+		withService(msg),
+	), nil
+}
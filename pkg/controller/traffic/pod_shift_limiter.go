@@ -0,0 +1,251 @@
+package traffic
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+const (
+	// defaultPodShiftParallelism is how many pods get their traffic
+	// status changed at the same time when an application doesn't say.
+	defaultPodShiftParallelism = 10
+)
+
+// podShiftLimits are the limits an application sets on how fast its pods get
+// their traffic status changed in a cluster. Zero values mean no limit.
+type podShiftLimits struct {
+	maxPodsPerSync     int
+	maxPodsPerInterval int
+	interval           time.Duration
+	parallelism        int
+}
+
+func buildPodShiftLimits(app *shipper.Application) podShiftLimits {
+	limits := podShiftLimits{
+		parallelism: defaultPodShiftParallelism,
+	}
+
+	if app == nil || app.Spec.TrafficShifting == nil {
+		return limits
+	}
+
+	shifting := app.Spec.TrafficShifting
+	limits.maxPodsPerSync = int(shifting.MaxPodsPerSync)
+	limits.maxPodsPerInterval = int(shifting.MaxPodsPerInterval)
+	limits.interval = shifting.Interval.Duration
+	if shifting.Parallelism > 0 {
+		limits.parallelism = int(shifting.Parallelism)
+	}
+
+	return limits
+}
+
+// podShiftWindow counts the pods that had their traffic status changed
+// between start and end.
+type podShiftWindow struct {
+	start time.Time
+	end   time.Time
+	count int
+}
+
+// podShiftLimiter keeps track of how many pods of each application had their
+// traffic status changed in each cluster during the current interval. It is
+// shared by all the workers of the controller, as the releases of an
+// application share its limits.
+type podShiftLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*podShiftWindow
+	now     func() time.Time
+}
+
+func newPodShiftLimiter() *podShiftLimiter {
+	return &podShiftLimiter{
+		windows: make(map[string]*podShiftWindow),
+		now:     time.Now,
+	}
+}
+
+// reserve returns how many out of n pods can have their traffic status
+// changed right now, and counts them against the current interval. When the
+// interval doesn't allow as many as a single sync would, it also returns how
+// long until the next interval starts.
+func (l *podShiftLimiter) reserve(key string, limits podShiftLimits, n int) (int, time.Duration) {
+	allowed := n
+	if limits.maxPodsPerSync > 0 && allowed > limits.maxPodsPerSync {
+		allowed = limits.maxPodsPerSync
+	}
+
+	if l == nil || limits.maxPodsPerInterval <= 0 || limits.interval <= 0 {
+		return allowed, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w, ok := l.windows[key]
+	if !ok || !now.Before(w.end) {
+		// Applications and clusters come and go, so this is as good
+		// a time as any to forget about the windows that are over.
+		l.pruneWindows(now)
+
+		w = &podShiftWindow{start: now, end: now.Add(limits.interval)}
+		l.windows[key] = w
+	}
+
+	var wait time.Duration
+	if left := limits.maxPodsPerInterval - w.count; allowed > left {
+		allowed = left
+		wait = w.end.Sub(now)
+	}
+	w.count += allowed
+
+	return allowed, wait
+}
+
+// release gives n pods reserved for key back to the current interval, as
+// they didn't have their traffic status changed after all. Pods reserved in
+// an interval that is already over don't count against anything anymore, so
+// there's nothing to give back to.
+func (l *podShiftLimiter) release(key string, n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[key]
+	if !ok || !l.now().Before(w.end) {
+		return
+	}
+
+	w.count -= n
+	if w.count < 0 {
+		w.count = 0
+	}
+}
+
+// pruneWindows forgets about all the windows that ended before now. It must
+// be called with l.mu held.
+func (l *podShiftLimiter) pruneWindows(now time.Time) {
+	for key, w := range l.windows {
+		if !now.Before(w.end) {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// podShiftProgress describes how far along a release is in changing the
+// traffic status of its pods.
+type podShiftProgress struct {
+	shifted int
+	pending int
+	wait    time.Duration
+}
+
+// shiftPods changes the traffic status of as many pods in podsToShift as the
+// limits of the application in req allow. If it has to wait for the next
+// interval to shift the rest, req is marked to be looked at again by then.
+func shiftPods(req *shiftRequest, podsToShift map[string][]*corev1.Pod) (podShiftProgress, error) {
+	// Enabling pods goes first, so that a release that's limited never
+	// loses capacity to get traffic while it waits.
+	values := make([]string, 0, len(podsToShift))
+	for value := range podsToShift {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if (values[i] == shipper.Enabled) != (values[j] == shipper.Enabled) {
+			return values[i] == shipper.Enabled
+		}
+		return values[i] < values[j]
+	})
+
+	pending := 0
+	for _, value := range values {
+		for _, pod := range podsToShift[value] {
+			if v, ok := pod.Labels[shipper.PodTrafficStatusLabel]; !ok || v != value {
+				pending++
+			}
+		}
+	}
+
+	key := fmt.Sprintf("%s/%s/%s", req.namespace, req.appName, req.clusterName)
+	allowed, wait := req.limiter.reserve(key, req.limits, pending)
+
+	batch := make(map[string][]*corev1.Pod)
+	left := allowed
+	for _, value := range values {
+		for _, pod := range podsToShift[value] {
+			if left == 0 {
+				break
+			}
+
+			if v, ok := pod.Labels[shipper.PodTrafficStatusLabel]; ok && v == value {
+				continue
+			}
+
+			batch[value] = append(batch[value], pod)
+			left--
+		}
+	}
+
+	progress := podShiftProgress{
+		shifted: allowed,
+		pending: pending - allowed,
+		wait:    wait,
+	}
+
+	if wait > 0 && (req.requeueAfter == 0 || wait < req.requeueAfter) {
+		req.requeueAfter = wait
+	}
+
+	if len(batch) == 0 {
+		return progress, nil
+	}
+
+	shifted, err := shiftPodLabels(req.clientset.GetKubeClient(), batch, req.limits.parallelism)
+	if failed := allowed - shifted; failed > 0 {
+		req.limiter.release(key, failed)
+		progress.shifted = shifted
+		progress.pending += failed
+	}
+
+	return progress, err
+}
+
+// buildPodShiftCondition returns a Ready condition reporting on the progress
+// of a release that still has pods to shift.
+func buildPodShiftCondition(progress podShiftProgress) *shipper.ClusterTrafficCondition {
+	if progress.shifted == 0 && progress.pending > 0 {
+		return trafficutil.NewClusterTrafficCondition(
+			shipper.ClusterConditionTypeReady,
+			corev1.ConditionFalse,
+			PodShiftThrottled,
+			fmt.Sprintf(
+				"%d pods are waiting to have their traffic status changed, next batch in %s",
+				progress.pending, progress.wait.Round(time.Second)),
+		)
+	}
+
+	var msg string
+	if progress.pending > 0 {
+		msg = fmt.Sprintf(
+			"changed traffic status of %d pods, %d pods left",
+			progress.shifted, progress.pending)
+	}
+
+	return trafficutil.NewClusterTrafficCondition(
+		shipper.ClusterConditionTypeReady,
+		corev1.ConditionFalse,
+		InProgress,
+		msg,
+	)
+}
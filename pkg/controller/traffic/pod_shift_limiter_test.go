@@ -0,0 +1,205 @@
+package traffic
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubetesting "k8s.io/client-go/testing"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippertesting "github.com/bookingcom/shipper/pkg/testing"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+func TestPodShiftLimiterReserve(t *testing.T) {
+	now := time.Now()
+	limiter := newPodShiftLimiter()
+	limiter.now = func() time.Time { return now }
+
+	limits := podShiftLimits{
+		maxPodsPerSync:     3,
+		maxPodsPerInterval: 5,
+		interval:           time.Minute,
+	}
+
+	steps := []struct {
+		name            string
+		elapsed         time.Duration
+		pods            int
+		expectedAllowed int
+		expectedWait    time.Duration
+	}{
+		{"capped by sync", 0, 10, 3, 0},
+		{"capped by interval", 10 * time.Second, 10, 2, 50 * time.Second},
+		{"interval exhausted", 20 * time.Second, 10, 0, 40 * time.Second},
+		{"next interval", 60 * time.Second, 2, 2, 0},
+	}
+
+	start := now
+	for _, step := range steps {
+		now = start.Add(step.elapsed)
+		allowed, wait := limiter.reserve("app", limits, step.pods)
+		if allowed != step.expectedAllowed || wait != step.expectedWait {
+			t.Errorf("%s: expected to be allowed %d pods and wait %s, got %d pods and %s",
+				step.name, step.expectedAllowed, step.expectedWait, allowed, wait)
+		}
+	}
+
+	allowed, wait := limiter.reserve("another-app", limits, 2)
+	if allowed != 2 || wait != 0 {
+		t.Errorf("expected applications not to share limits, got %d pods and %s", allowed, wait)
+	}
+}
+
+func TestPodShiftLimiterRelease(t *testing.T) {
+	now := time.Now()
+	limiter := newPodShiftLimiter()
+	limiter.now = func() time.Time { return now }
+
+	limits := podShiftLimits{
+		maxPodsPerInterval: 5,
+		interval:           time.Minute,
+	}
+
+	if allowed, _ := limiter.reserve("app", limits, 5); allowed != 5 {
+		t.Fatalf("expected to be allowed 5 pods, got %d", allowed)
+	}
+
+	limiter.release("app", 2)
+
+	if allowed, _ := limiter.reserve("app", limits, 5); allowed != 2 {
+		t.Errorf("expected released pods to be reserved again, got %d pods", allowed)
+	}
+}
+
+func TestPodShiftLimiterPrunesWindows(t *testing.T) {
+	now := time.Now()
+	limiter := newPodShiftLimiter()
+	limiter.now = func() time.Time { return now }
+
+	limits := podShiftLimits{
+		maxPodsPerInterval: 5,
+		interval:           time.Minute,
+	}
+
+	limiter.reserve("gone-app", limits, 1)
+	limiter.reserve("app", limits, 1)
+
+	now = now.Add(time.Minute)
+	limiter.reserve("app", limits, 1)
+
+	if _, ok := limiter.windows["gone-app"]; ok {
+		t.Errorf("expected window of %q to be pruned once over, but it's still there", "gone-app")
+	}
+	if len(limiter.windows) != 1 {
+		t.Errorf("expected only the window of %q to be left, got %v", "app", limiter.windows)
+	}
+}
+
+// TestPodShiftIsThrottled verifies that no more pods than an application
+// allows per interval get their traffic status changed, and that the traffic
+// target reports it.
+func TestPodShiftIsThrottled(t *testing.T) {
+	f := shippertesting.NewControllerTestFixture()
+	cluster := f.AddNamedCluster(clusterA)
+
+	tt := buildTrafficTarget(
+		shippertesting.TestApp, "foobar-a",
+		map[string]uint32{clusterA: 100})
+
+	objects := []runtime.Object{
+		buildService(shippertesting.TestApp),
+		buildEndpoints(shippertesting.TestApp),
+	}
+	objects = addPodsToList(objects, buildPods(shippertesting.TestApp, "foobar-a", 4, noTraffic))
+	cluster.AddMany(objects)
+
+	f.ShipperClient.Tracker().Add(&shipper.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      shippertesting.TestApp,
+			Namespace: shippertesting.TestNamespace,
+		},
+		Spec: shipper.ApplicationSpec{
+			TrafficShifting: &shipper.ApplicationTrafficShifting{
+				MaxPodsPerInterval: 1,
+				Interval:           metav1.Duration{Duration: time.Hour},
+			},
+		},
+	})
+	f.ShipperClient.Tracker().Add(tt)
+
+	runController(f)
+
+	object, err := f.ShipperClient.Tracker().Get(
+		shipper.SchemeGroupVersion.WithResource("traffictargets"), tt.Namespace, tt.Name)
+	if err != nil {
+		t.Fatalf("could not Get TrafficTarget %q: %s", tt.Name, err)
+	}
+	tt = object.(*shipper.TrafficTarget)
+
+	assertPodTraffic(t, tt, cluster, podStatus{withTraffic: 1, withoutTraffic: 3})
+
+	if len(tt.Status.Clusters) != 1 {
+		t.Fatalf("expected status for a single cluster, got %d", len(tt.Status.Clusters))
+	}
+
+	cond := trafficutil.GetClusterTrafficCondition(*tt.Status.Clusters[0], shipper.ClusterConditionTypeReady)
+	if cond == nil || cond.Status != corev1.ConditionFalse || cond.Reason != PodShiftThrottled {
+		t.Errorf("expected cluster to be not ready with reason %q, got %+v", PodShiftThrottled, cond)
+	}
+}
+
+// TestShiftPodsReleasesFailedPatches verifies that pods that fail to have
+// their traffic status changed don't count against the limits of their
+// application.
+func TestShiftPodsReleasesFailedPatches(t *testing.T) {
+	f := shippertesting.NewControllerTestFixture()
+	cluster := f.AddNamedCluster(clusterA)
+
+	pods := buildPods(shippertesting.TestApp, "foobar-a", 3, noTraffic)
+	cluster.AddMany(addPodsToList(nil, pods))
+	cluster.Client.PrependReactor("patch", "pods", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		if action.(kubetesting.PatchAction).GetName() == pods[0].Name {
+			return true, nil, fmt.Errorf("patch failed")
+		}
+		return false, nil, nil
+	})
+
+	clientset, err := f.ClusterClientStore.GetApplicationClusterClientset(clusterA, AgentName)
+	if err != nil {
+		t.Fatalf("could not get clientset for cluster %q: %s", clusterA, err)
+	}
+
+	req := &shiftRequest{
+		clusterName: clusterA,
+		clientset:   clientset,
+		namespace:   shippertesting.TestNamespace,
+		appName:     shippertesting.TestApp,
+		limits: podShiftLimits{
+			maxPodsPerInterval: 5,
+			interval:           time.Hour,
+			parallelism:        1,
+		},
+		limiter: newPodShiftLimiter(),
+	}
+
+	progress, err := shiftPods(req, map[string][]*corev1.Pod{shipper.Enabled: pods})
+	if err == nil {
+		t.Fatalf("expected shifting pods to fail, but it didn't")
+	}
+
+	if progress.shifted != 2 || progress.pending != 1 {
+		t.Errorf("expected 2 pods shifted and 1 pending, got %+v", progress)
+	}
+
+	allowed, _ := req.limiter.reserve(
+		fmt.Sprintf("%s/%s/%s", req.namespace, req.appName, req.clusterName),
+		req.limits, 5)
+	if allowed != 3 {
+		t.Errorf("expected the failed pod not to count against the interval, got %d pods allowed", allowed)
+	}
+}
@@ -0,0 +1,263 @@
+package traffic
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/clusterclientstore"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	"github.com/bookingcom/shipper/pkg/util/anchor"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+var (
+	smiTrafficSplitGVK = schema.GroupVersionKind{Group: "split.smi-spec.io", Version: "v1alpha2", Kind: "TrafficSplit"}
+	smiTrafficSplitGVR = smiTrafficSplitGVK.GroupVersion().WithResource("trafficsplits")
+)
+
+// smiBackend shifts traffic by writing backend weights into an SMI
+// TrafficSplit for each of the application's production Services, named
+// after it. Every release gets a backing Service of its own per production
+// Service, selecting only its pods, that the TrafficSplit sends its share of
+// the traffic to. Like with Istio, the weight configured in the mesh is the
+// achieved traffic.
+type smiBackend struct {
+	dynamicClientBuilder clusterclientstore.DynamicClientBuilderFunc
+}
+
+var _ trafficBackend = smiBackend{}
+
+func (b smiBackend) shift(req *shiftRequest) (uint32, *shipper.ClusterTrafficCondition, error) {
+	services, err := getProductionServices(req.clientset, req.namespace, req.appName)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	appPods, err := getAppPods(req.clientset, req.namespace, req.appName)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	routeWeights := meshRouteWeights(
+		req.weights[req.clusterName], req.pods[req.clusterName], appPods)
+
+	notReady := func(reason string, err error) (uint32, *shipper.ClusterTrafficCondition, error) {
+		return 0, trafficutil.NewClusterTrafficCondition(
+			shipper.ClusterConditionTypeReady,
+			corev1.ConditionFalse,
+			reason,
+			err.Error(),
+		), err
+	}
+
+	client := clusterclientstore.NewDynamicClient(
+		req.clientset, req.cluster, smiTrafficSplitGVK, b.dynamicClientBuilder)
+
+	kubeclient := req.clientset.GetKubeClient()
+	appliedTSs := make([]*unstructured.Unstructured, 0, len(services))
+	for _, svc := range services {
+		backing := smiBackingServicePrefix(svc, len(services))
+		if err := applySMIBackingService(kubeclient, svc, backing, req.appName, req.releaseName); err != nil {
+			return notReady(InternalError, err)
+		}
+
+		ts := buildSMITrafficSplit(svc, backing, req.appName, routeWeights)
+		appliedTS, err := applyUnstructured(client.Resource(smiTrafficSplitGVR).Namespace(req.namespace), ts)
+		if err != nil {
+			return notReady(InternalError, err)
+		}
+		appliedTSs = append(appliedTSs, appliedTS)
+	}
+
+	if err := recordTrafficDecision(req, meshTrafficDecision(routeWeights, req.releaseName)); err != nil {
+		return notReady(InternalError, err)
+	}
+
+	podsToShift := buildMeshPodsToShift(appPods, routeWeights)
+	var progress podShiftProgress
+	if len(podsToShift) > 0 {
+		progress, err = shiftPods(req, podsToShift)
+		if err != nil {
+			return notReady(InternalError, err)
+		}
+	}
+
+	for i, appliedTS := range appliedTSs {
+		backing := smiBackingServicePrefix(services[i], len(services))
+		weight, ok := smiAppliedWeight(appliedTS, smiBackingServiceName(backing, req.releaseName))
+		if !ok || weight != routeWeights[req.releaseName] {
+			return 0, trafficutil.NewClusterTrafficCondition(
+				shipper.ClusterConditionTypeReady,
+				corev1.ConditionFalse,
+				InProgress,
+				fmt.Sprintf("TrafficSplit %q does not route to release %q yet", appliedTS.GetName(), req.releaseName),
+			), nil
+		}
+	}
+
+	achievedTraffic := meshAchievedTraffic(req, routeWeights, appPods)
+
+	if len(podsToShift) > 0 {
+		return achievedTraffic, buildPodShiftCondition(progress), nil
+	}
+
+	return achievedTraffic, trafficutil.NewClusterTrafficCondition(
+		shipper.ClusterConditionTypeReady,
+		corev1.ConditionTrue,
+		"",
+		"",
+	), nil
+}
+
+// smiBackingServicePrefix returns what the names of the backing Services for
+// svc start with. Applications with a single production Service keep their
+// backing Services named after the release alone.
+func smiBackingServicePrefix(svc *corev1.Service, services int) string {
+	if services == 1 {
+		return ""
+	}
+	return svc.Name
+}
+
+func smiBackingServiceName(prefix, release string) string {
+	if prefix == "" {
+		return fmt.Sprintf("%s-smi", release)
+	}
+	return fmt.Sprintf("%s-%s-smi", release, prefix)
+}
+
+// buildSMIBackingService returns a Service exposing the same ports as the
+// production Service, but selecting only the pods of a single release,
+// regardless of whether they're labeled to get traffic.
+func buildSMIBackingService(svc *corev1.Service, prefix, appName, release string) *corev1.Service {
+	selector := make(map[string]string, len(svc.Spec.Selector)+1)
+	for k, v := range svc.Spec.Selector {
+		selector[k] = v
+	}
+	delete(selector, shipper.PodTrafficStatusLabel)
+	selector[shipper.ReleaseLabel] = release
+
+	ports := make([]corev1.ServicePort, 0, len(svc.Spec.Ports))
+	for _, port := range svc.Spec.Ports {
+		port.NodePort = 0
+		ports = append(ports, port)
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      smiBackingServiceName(prefix, release),
+			Namespace: svc.Namespace,
+			Labels: map[string]string{
+				shipper.AppLabel:     appName,
+				shipper.ReleaseLabel: release,
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: selector,
+			Ports:    ports,
+		},
+	}
+}
+
+// applySMIBackingService makes sure the backing Service of a release exists
+// and is up to date. It is owned by the release's anchor, if there is one,
+// so it is garbage collected along with everything else the release
+// installed.
+func applySMIBackingService(client kubernetes.Interface, svc *corev1.Service, prefix, appName, release string) error {
+	desired := buildSMIBackingService(svc, prefix, appName, release)
+
+	existing, err := client.CoreV1().Services(desired.Namespace).Get(desired.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		anchorName := fmt.Sprintf("%s%s", release, anchor.AnchorSuffix)
+		cm, err := client.CoreV1().ConfigMaps(desired.Namespace).Get(anchorName, metav1.GetOptions{})
+		if err == nil {
+			desired.OwnerReferences = []metav1.OwnerReference{
+				anchor.ConfigMapAnchorToOwnerReference(cm),
+			}
+		} else if !kerrors.IsNotFound(err) {
+			return shippererrors.NewKubeclientGetError(desired.Namespace, anchorName, err).
+				WithCoreV1Kind("ConfigMap")
+		}
+
+		_, err = client.CoreV1().Services(desired.Namespace).Create(desired)
+		if err != nil && !kerrors.IsAlreadyExists(err) {
+			return shippererrors.NewKubeclientCreateError(desired, err).
+				WithCoreV1Kind("Service")
+		}
+		return nil
+	} else if err != nil {
+		return shippererrors.NewKubeclientGetError(desired.Namespace, desired.Name, err).
+			WithCoreV1Kind("Service")
+	}
+
+	if equality.Semantic.DeepEqual(existing.Spec.Selector, desired.Spec.Selector) &&
+		equality.Semantic.DeepEqual(existing.Spec.Ports, desired.Spec.Ports) {
+		return nil
+	}
+
+	existing = existing.DeepCopy()
+	existing.Spec.Selector = desired.Spec.Selector
+	existing.Spec.Ports = desired.Spec.Ports
+	_, err = client.CoreV1().Services(existing.Namespace).Update(existing)
+	if err != nil {
+		return shippererrors.NewKubeclientUpdateError(existing, err).
+			WithCoreV1Kind("Service")
+	}
+
+	return nil
+}
+
+func buildSMITrafficSplit(svc *corev1.Service, prefix, appName string, routeWeights map[string]int64) *unstructured.Unstructured {
+	backends := make([]interface{}, 0, len(routeWeights))
+	for _, release := range sortedReleases(routeWeights) {
+		backends = append(backends, map[string]interface{}{
+			"service": smiBackingServiceName(prefix, release),
+			"weight":  routeWeights[release],
+		})
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(smiTrafficSplitGVK)
+	obj.SetName(svc.Name)
+	obj.SetNamespace(svc.Namespace)
+	obj.SetLabels(map[string]string{
+		shipper.AppLabel: appName,
+	})
+	obj.Object["spec"] = map[string]interface{}{
+		"service":  svc.Name,
+		"backends": backends,
+	}
+
+	return obj
+}
+
+// smiAppliedWeight returns the weight a TrafficSplit gives to a backing
+// Service, if it has it as a backend at all.
+func smiAppliedWeight(ts *unstructured.Unstructured, service string) (int64, bool) {
+	backends, _, _ := unstructured.NestedSlice(ts.Object, "spec", "backends")
+	for _, b := range backends {
+		backend, ok := b.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _, _ := unstructured.NestedString(backend, "service")
+		if name != service {
+			continue
+		}
+
+		weight, _, _ := unstructured.NestedInt64(backend, "weight")
+		return weight, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,93 @@
+package traffic
+
+import (
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippertesting "github.com/bookingcom/shipper/pkg/testing"
+)
+
+// TestSMIBackend verifies that the smi backend creates a backing Service for
+// every release, points a TrafficSplit at them with the weights of all
+// releases, and reports those as achieved traffic.
+func TestSMIBackend(t *testing.T) {
+	f := shippertesting.NewControllerTestFixture()
+
+	cluster := f.AddNamedCluster(clusterA)
+	cluster.InitializeDynamicClient(nil)
+
+	incumbent := buildTrafficTarget(shippertesting.TestApp, "foobar-a",
+		map[string]uint32{clusterA: 90})
+	contender := buildTrafficTarget(shippertesting.TestApp, "foobar-b",
+		map[string]uint32{clusterA: 10})
+
+	objects := []runtime.Object{
+		buildService(shippertesting.TestApp),
+		buildEndpoints(shippertesting.TestApp),
+	}
+	objects = addPodsToList(objects, buildPods(shippertesting.TestApp, incumbent.Name, 2, withTraffic))
+	objects = addPodsToList(objects, buildPods(shippertesting.TestApp, contender.Name, 2, noTraffic))
+	cluster.AddMany(objects)
+
+	f.ShipperClient.Tracker().Add(&shipper.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterA},
+		Spec: shipper.ClusterSpec{
+			TrafficBackend: shipper.TrafficBackendSMI,
+		},
+	})
+	f.ShipperClient.Tracker().Add(incumbent)
+	f.ShipperClient.Tracker().Add(contender)
+
+	runController(f)
+
+	ttGVR := shipper.SchemeGroupVersion.WithResource("traffictargets")
+	for _, expected := range []*shipper.TrafficTarget{incumbent, contender} {
+		object, err := f.ShipperClient.Tracker().Get(ttGVR, expected.Namespace, expected.Name)
+		if err != nil {
+			t.Fatalf("could not Get TrafficTarget %q: %s", expected.Name, err)
+		}
+
+		tt := object.(*shipper.TrafficTarget)
+		eq, diff := shippertesting.DeepEqualDiff(buildSuccessStatus(expected.Spec.Clusters), tt.Status)
+		if !eq {
+			t.Errorf("TrafficTarget %q has Status different from expected:\n%s", tt.Name, diff)
+		}
+
+		assertPodTraffic(t, tt, cluster, podStatus{withTraffic: 2})
+	}
+
+	svcName := fmt.Sprintf("%s-prod", shippertesting.TestApp)
+	ts, err := cluster.DynamicClient.Resource(smiTrafficSplitGVR).
+		Namespace(shippertesting.TestNamespace).Get(svcName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("could not Get TrafficSplit %q: %s", svcName, err)
+	}
+
+	for release, weight := range map[string]int64{incumbent.Name: 90, contender.Name: 10} {
+		backend := smiBackingServiceName("", release)
+		got, ok := smiAppliedWeight(ts, backend)
+		if !ok || got != weight {
+			t.Errorf("expected TrafficSplit to route %d to %q, got %d", weight, backend, got)
+		}
+
+		svc, err := cluster.Client.CoreV1().Services(shippertesting.TestNamespace).
+			Get(backend, metav1.GetOptions{})
+		if err != nil {
+			t.Errorf("could not Get Service %q: %s", backend, err)
+			continue
+		}
+
+		expected := map[string]string{
+			shipper.AppLabel:     shippertesting.TestApp,
+			shipper.ReleaseLabel: release,
+		}
+		eq, diff := shippertesting.DeepEqualDiff(expected, svc.Spec.Selector)
+		if !eq {
+			t.Errorf("Service %q has selector different from expected:\n%s", backend, diff)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -40,21 +41,37 @@ const (
 	ClustersNotReady   = "ClustersNotReady"
 	InProgress         = "InProgress"
 	InternalError      = "InternalError"
+	NotEnoughPods      = "NotEnoughPods"
 	PodsNotInEndpoints = "PodsNotInEndpoints"
 	PodsNotReady       = "PodsNotReady"
+	PodShiftThrottled  = "PodShiftThrottled"
 
 	TrafficTargetConditionChanged  = "TrafficTargetConditionChanged"
 	ClusterTrafficConditionChanged = "ClusterTrafficConditionChanged"
+	WeightDeviation                = "WeightDeviation"
 )
 
 // Controller is the controller implementation for TrafficTarget resources.
 type Controller struct {
 	shipperclientset     shipperclient.Interface
 	clusterClientStore   clusterclientstore.Interface
+	dynamicClientBuilder clusterclientstore.DynamicClientBuilderFunc
+
 	trafficTargetsLister listers.TrafficTargetLister
 	trafficTargetsSynced cache.InformerSynced
-	workqueue            workqueue.RateLimitingInterface
-	recorder             record.EventRecorder
+	applicationsLister   listers.ApplicationLister
+	applicationsSynced   cache.InformerSynced
+	clustersLister       listers.ClusterLister
+	clustersSynced       cache.InformerSynced
+
+	podShiftLimiter        *podShiftLimiter
+	endpointSliceDiscovery *endpointSliceDiscovery
+
+	clusterEndpointsMut sync.Mutex
+	clusterEndpoints    map[string]*clusterEndpoints
+
+	workqueue workqueue.RateLimitingInterface
+	recorder  record.EventRecorder
 }
 
 // NewController returns a new TrafficTarget controller.
@@ -62,20 +79,33 @@ func NewController(
 	shipperclientset shipperclient.Interface,
 	shipperInformerFactory informers.SharedInformerFactory,
 	store clusterclientstore.Interface,
+	dynamicClientBuilder clusterclientstore.DynamicClientBuilderFunc,
 	recorder record.EventRecorder,
 ) *Controller {
 
 	// Obtain references to shared index informers for the TrafficTarget type.
 	trafficTargetInformer := shipperInformerFactory.Shipper().V1alpha1().TrafficTargets()
+	applicationInformer := shipperInformerFactory.Shipper().V1alpha1().Applications()
+	clusterInformer := shipperInformerFactory.Shipper().V1alpha1().Clusters()
 
 	controller := &Controller{
-		shipperclientset:   shipperclientset,
-		clusterClientStore: store,
+		shipperclientset:     shipperclientset,
+		clusterClientStore:   store,
+		dynamicClientBuilder: dynamicClientBuilder,
 
 		trafficTargetsLister: trafficTargetInformer.Lister(),
 		trafficTargetsSynced: trafficTargetInformer.Informer().HasSynced,
-		workqueue:            workqueue.NewNamedRateLimitingQueue(shipperworkqueue.NewDefaultControllerRateLimiter(), "traffic_controller_traffictargets"),
-		recorder:             recorder,
+		applicationsLister:   applicationInformer.Lister(),
+		applicationsSynced:   applicationInformer.Informer().HasSynced,
+		clustersLister:       clusterInformer.Lister(),
+		clustersSynced:       clusterInformer.Informer().HasSynced,
+
+		podShiftLimiter:        newPodShiftLimiter(),
+		endpointSliceDiscovery: newEndpointSliceDiscovery(),
+		clusterEndpoints:       make(map[string]*clusterEndpoints),
+
+		workqueue: workqueue.NewNamedRateLimitingQueue(shipperworkqueue.NewDefaultControllerRateLimiter(), "traffic_controller_traffictargets"),
+		recorder:  recorder,
 	}
 
 	klog.Info("Setting up event handlers")
@@ -87,31 +117,57 @@ func NewController(
 		DeleteFunc: controller.enqueueAllTrafficTargets,
 	})
 
+	// Switching an application to a different traffic backend, or
+	// changing how fast it shifts traffic, needs to be picked up by all
+	// of its traffic targets.
+	applicationInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, new interface{}) {
+			oldApp, oldOk := old.(*shipper.Application)
+			newApp, newOk := new.(*shipper.Application)
+			if oldOk && newOk && (oldApp.Spec.TrafficBackend != newApp.Spec.TrafficBackend ||
+				!reflect.DeepEqual(oldApp.Spec.TrafficShifting, newApp.Spec.TrafficShifting)) {
+				controller.enqueueTrafficTargetsFromApplication(newApp)
+			}
+		},
+	})
+
+	clusterInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if cluster, ok := obj.(*shipper.Cluster); ok {
+				controller.stopWatchingEndpoints(cluster.Name)
+			}
+		},
+	})
+
 	store.AddSubscriptionCallback(controller.subscribeToAppClusterEvents)
 	store.AddEventHandlerCallback(controller.registerAppClusterEventHandlers)
 
 	return controller
 }
 
-// registerAppClusterEventHandlers listens to events on both Endpoints and
-// Pods. An event on an Endpoints object enqueues all traffic targets for an
-// app, as a change in one of them might affect the weight in the others. For
-// Pods, we only enqueue the owning traffic target, and only for adds and
-// deletes, as any changes relevant for traffic will be reflected in the
-// Endpoints object anyway. In case a new or deleted pod does change traffic
-// shifting in any way, the update to the traffic target itself will trigger a
-// new evaluation of all traffic targets for an app.
+// registerAppClusterEventHandlers listens to events on both Endpoints (or
+// EndpointSlices, see watchEndpoints) and Pods. For Pods, we only enqueue the
+// owning traffic target, and only for adds and deletes, as any changes
+// relevant for traffic will be reflected in the Endpoints object anyway. In
+// case a new or deleted pod does change traffic shifting in any way, the
+// update to the traffic target itself will trigger a new evaluation of all
+// traffic targets for an app.
 func (c *Controller) registerAppClusterEventHandlers(informerFactory kubeinformers.SharedInformerFactory, clusterName string) {
-	informerFactory.Core().V1().Endpoints().Informer().AddEventHandler(cache.FilteringResourceEventHandler{
-		FilterFunc: filters.BelongsToApp,
-		Handler: cache.ResourceEventHandlerFuncs{
-			AddFunc:    c.enqueueAllTrafficTargets,
-			DeleteFunc: c.enqueueAllTrafficTargets,
-			UpdateFunc: func(oldObj, newObj interface{}) {
-				c.enqueueAllTrafficTargets(newObj)
-			},
-		},
-	})
+	clientset, err := c.clusterClientStore.GetApplicationClusterClientset(clusterName, AgentName)
+	if err != nil {
+		// We'll try again the first time we shift traffic in the
+		// cluster.
+		runtime.HandleError(fmt.Errorf("cannot watch endpoints in cluster %q: %s", clusterName, err))
+	} else {
+		cluster, err := c.clustersLister.Get(clusterName)
+		if err != nil {
+			cluster = nil
+		}
+		c.watchEndpoints(clusterName, clientset, cluster)
+	}
 
 	informerFactory.Core().V1().Pods().Informer().AddEventHandler(cache.FilteringResourceEventHandler{
 		FilterFunc: filters.BelongsToRelease,
@@ -125,7 +181,6 @@ func (c *Controller) registerAppClusterEventHandlers(informerFactory kubeinforme
 func (c *Controller) subscribeToAppClusterEvents(informerFactory kubeinformers.SharedInformerFactory) {
 	informerFactory.Core().V1().Pods().Informer()
 	informerFactory.Core().V1().Services().Informer()
-	informerFactory.Core().V1().Endpoints().Informer()
 }
 
 // Run will set up the event handlers for types we are interested in, as well as
@@ -139,7 +194,7 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) {
 	klog.V(2).Info("Starting Traffic controller")
 	defer klog.V(2).Info("Shutting down Traffic controller")
 
-	if ok := cache.WaitForCacheSync(stopCh, c.trafficTargetsSynced); !ok {
+	if ok := cache.WaitForCacheSync(stopCh, c.trafficTargetsSynced, c.applicationsSynced, c.clustersSynced); !ok {
 		runtime.HandleError(fmt.Errorf("failed to wait for caches to sync"))
 		return
 	}
@@ -251,7 +306,7 @@ func (c *Controller) processTrafficTarget(tt *shipper.TrafficTarget) (*shipper.T
 		return tt, err
 	}
 
-	clusterReleaseWeights, err := buildClusterReleaseWeights(allTTs)
+	clusterReleaseWeights, clusterReleasePods, err := buildClusterReleaseWeights(allTTs)
 	if err != nil {
 		tt.Status.Conditions = targetutil.TransitionToNotOperational(
 			diff, tt.Status.Conditions,
@@ -278,7 +333,7 @@ func (c *Controller) processTrafficTarget(tt *shipper.TrafficTarget) (*shipper.T
 			}
 		}
 
-		err := c.processTrafficTargetOnCluster(tt, &clusterSpec, clusterStatus, clusterReleaseWeights)
+		err := c.processTrafficTargetOnCluster(tt, &clusterSpec, clusterStatus, clusterReleaseWeights, clusterReleasePods)
 		if err != nil {
 			clusterErrors.Append(err)
 		}
@@ -316,6 +371,7 @@ func (c *Controller) processTrafficTargetOnCluster(
 	spec *shipper.ClusterTrafficTarget,
 	status *shipper.ClusterTrafficStatus,
 	clusterReleaseWeights clusterReleaseWeights,
+	clusterReleasePods clusterReleasePods,
 ) error {
 	diff := diffutil.NewMultiDiff()
 	operationalCond := trafficutil.NewClusterTrafficCondition(
@@ -329,12 +385,40 @@ func (c *Controller) processTrafficTargetOnCluster(
 		"",
 		"")
 
-	var achievedTraffic uint32
+	status.RequestedTraffic = spec.Weight
+	status.RequestedPods = nil
+	if spec.Pods != nil {
+		requestedPods := *spec.Pods
+		status.RequestedPods = &requestedPods
+	}
+
+	var (
+		achievedTraffic uint32
+		achievedPods    *uint32
+		shifted         bool
+		deviationCond   *shipper.ClusterTrafficCondition
+	)
 	defer func() {
 		status.AchievedTraffic = achievedTraffic
+		status.AchievedPods = achievedPods
 
 		diff.Append(trafficutil.SetClusterTrafficCondition(status, *operationalCond))
 		diff.Append(trafficutil.SetClusterTrafficCondition(status, *readyCond))
+
+		// We only know how far off the weight is once traffic got
+		// shifted, so whatever we knew before stays until then.
+		if shifted && deviationCond != nil {
+			deviationDiff := trafficutil.SetClusterTrafficCondition(status, *deviationCond)
+			if !deviationDiff.IsEmpty() {
+				c.recorder.Eventf(tt, corev1.EventTypeWarning, WeightDeviation,
+					"cluster %q: %s", spec.Name, deviationCond.Message)
+			}
+			diff.Append(deviationDiff)
+		} else if shifted {
+			diff.Append(trafficutil.RemoveClusterTrafficCondition(
+				status, shipper.ClusterConditionTypeWeightDeviation))
+		}
+
 		c.reportConditionChange(tt, ClusterTrafficConditionChanged, diff)
 	}()
 
@@ -353,7 +437,19 @@ func (c *Controller) processTrafficTargetOnCluster(
 	appName := tt.Labels[shipper.AppLabel]
 	releaseName := tt.Labels[shipper.ReleaseLabel]
 
-	appPods, endpoints, err := c.getClusterObjects(spec.Name, tt.Namespace, appName)
+	app, err := c.getApplication(tt.Namespace, appName)
+	if err != nil {
+		operationalCond = trafficutil.NewClusterTrafficCondition(
+			shipper.ClusterConditionTypeOperational,
+			corev1.ConditionFalse,
+			InternalError,
+			err.Error(),
+		)
+
+		return err
+	}
+
+	backend, cluster, err := c.trafficBackendFor(app, spec.Name)
 	if err != nil {
 		operationalCond = trafficutil.NewClusterTrafficCondition(
 			shipper.ClusterConditionTypeOperational,
@@ -365,130 +461,165 @@ func (c *Controller) processTrafficTargetOnCluster(
 		return err
 	}
 
+	req := &shiftRequest{
+		cluster:     cluster,
+		clusterName: spec.Name,
+		clientset:   clientset,
+		namespace:   tt.Namespace,
+		appName:     appName,
+		releaseName: releaseName,
+		weights:     clusterReleaseWeights,
+		pods:        clusterReleasePods,
+		limits:      buildPodShiftLimits(app),
+		limiter:     c.podShiftLimiter,
+	}
+
+	req.endpoints = c.watchEndpoints(spec.Name, clientset, cluster)
+
+	// achievedTraffic and achievedPods are used by the defer at the top
+	// of this func
+	achievedTraffic, cond, err := backend.shift(req)
+	achievedPods = req.achievedPods
+	if req.requeueAfter > 0 {
+		c.enqueueTrafficTargetAfter(tt, req.requeueAfter)
+	}
+
+	if err != nil && cond == nil {
+		operationalCond = trafficutil.NewClusterTrafficCondition(
+			shipper.ClusterConditionTypeOperational,
+			corev1.ConditionFalse,
+			InternalError,
+			err.Error(),
+		)
+
+		return err
+	}
+
 	operationalCond = trafficutil.NewClusterTrafficCondition(
 		shipper.ClusterConditionTypeOperational,
 		corev1.ConditionTrue,
 		"",
 		"",
 	)
+	readyCond = cond
 
-	trafficStatus := buildTrafficShiftingStatus(
-		spec.Name, appName, releaseName,
-		clusterReleaseWeights,
-		endpoints, appPods)
+	shifted = err == nil
+	deviationCond = buildWeightDeviationCondition(req.weightDeviation, maxWeightDeviation(app))
 
-	// achievedTraffic is used by the defer at the top of this func
-	achievedTraffic = trafficStatus.achievedTrafficWeight
+	return err
+}
 
-	if trafficStatus.ready {
-		readyCond = trafficutil.NewClusterTrafficCondition(
-			shipper.ClusterConditionTypeReady,
-			corev1.ConditionTrue,
-			"",
-			"",
-		)
+// getProductionServices returns the Services that load balance production
+// traffic for an application, sorted by name. There has to be at least one.
+func getProductionServices(clientset clusterclientstore.ClientsetInterface, ns, appName string) ([]*corev1.Service, error) {
+	informerFactory := clientset.GetKubeInformerFactory()
 
-		return nil
+	serviceSelector := labels.Set(map[string]string{
+		shipper.AppLabel: appName,
+		shipper.LBLabel:  shipper.LBForProduction,
+	}).AsSelector()
+	serviceGVK := corev1.SchemeGroupVersion.WithKind("Service")
+	services, err := informerFactory.Core().V1().Services().Lister().
+		Services(ns).List(serviceSelector)
+	if err != nil {
+		return nil, shippererrors.NewKubeclientListError(
+			serviceGVK, ns, serviceSelector, err)
 	}
 
-	if trafficStatus.podsToShift != nil {
-		// If we have pods to shift, our job can only be done after the
-		// change is made and observed, so we definitely still in
-		// progress.
-		client := clientset.GetKubeClient()
-		err := shiftPodLabels(client, trafficStatus.podsToShift)
-		if err != nil {
-			readyCond = trafficutil.NewClusterTrafficCondition(
-				shipper.ClusterConditionTypeReady,
-				corev1.ConditionFalse,
-				InternalError,
-				err.Error(),
-			)
-
-			return err
-		}
-
-		readyCond = trafficutil.NewClusterTrafficCondition(
-			shipper.ClusterConditionTypeReady,
-			corev1.ConditionFalse,
-			InProgress,
-			"",
-		)
-	} else if trafficStatus.podsNotReady > 0 {
-		// All the pods have been shifted, made it to endpoints, but
-		// some aren't ready.
-		msg := fmt.Sprintf(
-			"%d/%d pods designated to receive traffic are not ready",
-			trafficStatus.podsNotReady, trafficStatus.podsLabeled)
-		readyCond = trafficutil.NewClusterTrafficCondition(
-			shipper.ClusterConditionTypeReady,
-			corev1.ConditionFalse,
-			PodsNotReady,
-			msg,
-		)
-	} else {
-		// All the pods have been shifted, but not enough of them are
-		// ready, and there are none not ready in endpoints, which
-		// means that they haven't made it there yet, or that the
-		// service selector does not match any pods.
-		msg := fmt.Sprintf(
-			"%d/%d pods designated to receive traffic are not yet in endpoints",
-			trafficStatus.podsLabeled-trafficStatus.podsReady, trafficStatus.podsLabeled)
-		readyCond = trafficutil.NewClusterTrafficCondition(
-			shipper.ClusterConditionTypeReady,
-			corev1.ConditionFalse,
-			PodsNotInEndpoints,
-			msg,
-		)
+	if len(services) == 0 {
+		err := shippererrors.NewUnexpectedObjectCountFromSelectorError(
+			serviceSelector, serviceGVK, 1, len(services))
+		return nil, err
 	}
 
-	return nil
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+
+	return services, nil
 }
 
-func (c *Controller) getClusterObjects(clusterName, ns, appName string) ([]*corev1.Pod, *corev1.Endpoints, error) {
-	clientset, err := c.clusterClientStore.GetApplicationClusterClientset(clusterName, AgentName)
-	if err != nil {
-		return nil, nil, err
-	}
+// getAppPods returns all the pods of an application.
+func getAppPods(clientset clusterclientstore.ClientsetInterface, ns, appName string) ([]*corev1.Pod, error) {
 	informerFactory := clientset.GetKubeInformerFactory()
 
 	appSelector := labels.Set{shipper.AppLabel: appName}.AsSelector()
 	appPods, err := informerFactory.Core().V1().Pods().Lister().
 		Pods(ns).List(appSelector)
 	if err != nil {
-		return nil, nil, shippererrors.NewKubeclientListError(
+		return nil, shippererrors.NewKubeclientListError(
 			corev1.SchemeGroupVersion.WithKind("Pod"),
 			ns, appSelector, err)
 	}
 
-	serviceSelector := labels.Set(map[string]string{
-		shipper.AppLabel: appName,
-		shipper.LBLabel:  shipper.LBForProduction,
-	}).AsSelector()
-	serviceGVK := corev1.SchemeGroupVersion.WithKind("Service")
-	services, err := informerFactory.Core().V1().Services().Lister().
-		Services(ns).List(serviceSelector)
+	return appPods, nil
+}
+
+// serviceEndpoints is a production Service of an application along with the
+// readiness of the pods behind it.
+type serviceEndpoints struct {
+	service   *corev1.Service
+	readiness podReadiness
+}
+
+func getClusterObjects(req *shiftRequest) ([]*corev1.Pod, []serviceEndpoints, error) {
+	appPods, err := getAppPods(req.clientset, req.namespace, req.appName)
 	if err != nil {
-		return nil, nil, shippererrors.NewKubeclientListError(
-			serviceGVK, ns, serviceSelector, err)
+		return nil, nil, err
 	}
 
-	if len(services) != 1 {
-		err := shippererrors.NewUnexpectedObjectCountFromSelectorError(
-			serviceSelector, serviceGVK, 1, len(services))
+	services, err := getProductionServices(req.clientset, req.namespace, req.appName)
+	if err != nil {
 		return nil, nil, err
 	}
 
-	svc := services[0]
+	serviceEndpointsList := make([]serviceEndpoints, 0, len(services))
+	for _, svc := range services {
+		readiness, err := getServicePodReadiness(req, svc)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	endpoints, err := informerFactory.Core().V1().Endpoints().Lister().
-		Endpoints(svc.Namespace).Get(svc.Name)
-	if err != nil {
-		return nil, nil, shippererrors.NewKubeclientGetError(svc.Namespace, svc.Name, err).
-			WithCoreV1Kind("Endpoints")
+		serviceEndpointsList = append(serviceEndpointsList, serviceEndpoints{
+			service:   svc,
+			readiness: readiness,
+		})
 	}
 
-	return appPods, endpoints, nil
+	return appPods, serviceEndpointsList, nil
+}
+
+// getServicePodReadiness returns the readiness of the pods behind svc. It
+// comes from its EndpointSlices if the cluster serves them, as Endpoints
+// objects get truncated for large Services.
+func getServicePodReadiness(req *shiftRequest, svc *corev1.Service) (podReadiness, error) {
+	// We only start watching endpoints once the cluster's caches have
+	// synced, so there's a window in which we can't tell yet.
+	if !req.endpoints.hasSynced() {
+		return nil, shippererrors.NewClusterNotReadyError(req.clusterName)
+	}
+
+	return req.endpoints.podReadiness(svc)
+}
+
+// podsSelectedByService returns the pods out of appPods that svc would select
+// regardless of their traffic status.
+func podsSelectedByService(svc *corev1.Service, appPods []*corev1.Pod) []*corev1.Pod {
+	selectorSet := make(labels.Set, len(svc.Spec.Selector))
+	for k, v := range svc.Spec.Selector {
+		selectorSet[k] = v
+	}
+	delete(selectorSet, shipper.PodTrafficStatusLabel)
+	selector := selectorSet.AsSelector()
+
+	pods := make([]*corev1.Pod, 0, len(appPods))
+	for _, pod := range appPods {
+		if selector.Matches(labels.Set(pod.Labels)) {
+			pods = append(pods, pod)
+		}
+	}
+
+	return pods
 }
 
 // enqueueTrafficTarget takes a TrafficTarget resource and converts it into a
@@ -504,6 +635,18 @@ func (c *Controller) enqueueTrafficTarget(obj interface{}) {
 	c.workqueue.Add(key)
 }
 
+// enqueueTrafficTargetAfter puts a TrafficTarget back in the work queue
+// after the given duration.
+func (c *Controller) enqueueTrafficTargetAfter(obj interface{}, duration time.Duration) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+
+	c.workqueue.AddAfter(key, duration)
+}
+
 func (c *Controller) enqueueAllTrafficTargets(obj interface{}) {
 	kubeobj, ok := obj.(metav1.Object)
 	if !ok {
@@ -533,6 +676,21 @@ func (c *Controller) enqueueAllTrafficTargets(obj interface{}) {
 	}
 }
 
+func (c *Controller) enqueueTrafficTargetsFromApplication(app *shipper.Application) {
+	selector := labels.Set{shipper.AppLabel: app.Name}.AsSelector()
+	trafficTargets, err := c.trafficTargetsLister.TrafficTargets(app.Namespace).List(selector)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf(
+			"cannot list traffic targets for app '%s/%s': %s",
+			app.Namespace, app.Name, err))
+		return
+	}
+
+	for _, tt := range trafficTargets {
+		c.enqueueTrafficTarget(tt)
+	}
+}
+
 func (c *Controller) enqueueTrafficTargetFromPod(obj interface{}) {
 	pod, ok := obj.(*corev1.Pod)
 	if !ok {
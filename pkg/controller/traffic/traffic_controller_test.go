@@ -153,6 +153,65 @@ func TestMultipleTrafficTargets(t *testing.T) {
 	)
 }
 
+// TestAbsolutePodTrafficTargets verifies that a traffic target asking for a
+// number of pods instead of a weight gets them, and reports how many pods it
+// asked for and achieved apart from the weight it achieved.
+func TestAbsolutePodTrafficTargets(t *testing.T) {
+	foobarA := buildTrafficTarget(
+		shippertesting.TestApp, "foobar-a",
+		map[string]uint32{clusterA: 100},
+	)
+	foobarB := buildTrafficTarget(
+		shippertesting.TestApp, "foobar-b",
+		map[string]uint32{clusterA: 0},
+	)
+	onePod := uint32(1)
+	foobarB.Spec.Clusters[0].Pods = &onePod
+
+	clusterObjects := []runtime.Object{
+		buildService(shippertesting.TestApp),
+		buildEndpoints(shippertesting.TestApp),
+	}
+
+	podCount := 3
+	clusterObjects = addPodsToList(clusterObjects,
+		buildPods(shippertesting.TestApp,
+			foobarA.Name, podCount, noTraffic))
+
+	clusterObjects = addPodsToList(clusterObjects,
+		buildPods(shippertesting.TestApp,
+			foobarB.Name, podCount, noTraffic))
+
+	// foobar-b takes a single pod out of the 6 in the application, and
+	// foobar-a gets all of its own 3 out of the remaining 5.
+	foobarAStatus := buildSuccessStatus(foobarA.Spec.Clusters)
+	foobarAStatus.Clusters[0].AchievedTraffic = 60
+	foobarBStatus := buildSuccessStatus(foobarB.Spec.Clusters)
+	foobarBStatus.Clusters[0].AchievedTraffic = 17
+	foobarBStatus.Clusters[0].RequestedPods = &onePod
+	foobarBStatus.Clusters[0].AchievedPods = &onePod
+
+	runTrafficControllerTest(t,
+		map[string][]runtime.Object{clusterA: clusterObjects},
+		[]trafficTargetTestExpectation{
+			{
+				trafficTarget: foobarA,
+				status:        foobarAStatus,
+				podsByCluster: map[string]podStatus{
+					clusterA: {withTraffic: 3},
+				},
+			},
+			{
+				trafficTarget: foobarB,
+				status:        foobarBStatus,
+				podsByCluster: map[string]podStatus{
+					clusterA: {withTraffic: 1, withoutTraffic: 2},
+				},
+			},
+		},
+	)
+}
+
 // TestTrafficShiftingWithPodsNotReady verifies that the traffic controller can
 // handle cases where label shifting happened correctly, but pods report not
 // ready through endpoints.
@@ -203,8 +262,9 @@ func TestTrafficShiftingWithPodsNotReady(t *testing.T) {
 	status := shipper.TrafficTargetStatus{
 		Clusters: []*shipper.ClusterTrafficStatus{
 			{
-				Name:            clusterA,
-				AchievedTraffic: 7,
+				Name:             clusterA,
+				RequestedTraffic: 10,
+				AchievedTraffic:  7,
 				Conditions: []shipper.ClusterTrafficCondition{
 					{
 						Type:   shipper.ClusterConditionTypeOperational,
@@ -249,6 +309,92 @@ func TestTrafficShiftingWithPodsNotReady(t *testing.T) {
 	)
 }
 
+// TestMultipleServices verifies that the traffic controller shifts traffic
+// through every production Service of an application, and that it reports
+// readiness for each of them on their own.
+func TestMultipleServices(t *testing.T) {
+	const component = "component"
+
+	tt := buildTrafficTarget(shippertesting.TestApp, ttName,
+		map[string]uint32{clusterA: 10})
+
+	httpSvc := buildService(shippertesting.TestApp)
+	httpSvc.Spec.Selector[component] = "http"
+	httpEndpoints := buildEndpoints(shippertesting.TestApp)
+
+	grpcName := fmt.Sprintf("%s-grpc", shippertesting.TestApp)
+	grpcSvc := buildService(shippertesting.TestApp)
+	grpcSvc.Name = grpcName
+	grpcSvc.Spec.Selector[component] = "grpc"
+	grpcEndpoints := buildEndpoints(shippertesting.TestApp)
+	grpcEndpoints.Name = grpcName
+
+	httpPods := buildPods(shippertesting.TestApp, ttName, 2, noTraffic)
+	for _, pod := range httpPods {
+		pod.Labels[component] = "http"
+	}
+
+	grpcPods := buildPods(shippertesting.TestApp, ttName, 2, noTraffic)
+	for _, pod := range grpcPods {
+		pod.Labels[component] = "grpc"
+	}
+	grpcPods[1].Labels[podReadinessLabel] = podNotReady
+
+	objects := []runtime.Object{httpSvc, httpEndpoints, grpcSvc, grpcEndpoints}
+	objects = addPodsToList(objects, httpPods)
+	objects = addPodsToList(objects, grpcPods)
+
+	msg := fmt.Sprintf("Service %q: 1/2 pods designated to receive traffic are not ready", grpcName)
+	status := shipper.TrafficTargetStatus{
+		Clusters: []*shipper.ClusterTrafficStatus{
+			{
+				Name:             clusterA,
+				RequestedTraffic: 10,
+				AchievedTraffic:  5,
+				Conditions: []shipper.ClusterTrafficCondition{
+					{
+						Type:   shipper.ClusterConditionTypeOperational,
+						Status: corev1.ConditionTrue,
+					},
+					{
+						Type:    shipper.ClusterConditionTypeReady,
+						Status:  corev1.ConditionFalse,
+						Reason:  PodsNotReady,
+						Message: msg,
+					},
+				},
+			},
+		},
+		Conditions: []shipper.TargetCondition{
+			{
+				Type:   shipper.TargetConditionTypeOperational,
+				Status: corev1.ConditionTrue,
+			},
+			{
+				Type:    shipper.TargetConditionTypeReady,
+				Status:  corev1.ConditionFalse,
+				Reason:  ClustersNotReady,
+				Message: fmt.Sprintf("%s: PodsNotReady %s", clusterA, msg),
+			},
+		},
+	}
+
+	runTrafficControllerTest(t,
+		map[string][]runtime.Object{
+			clusterA: objects,
+		},
+		[]trafficTargetTestExpectation{
+			{
+				trafficTarget: tt,
+				status:        status,
+				podsByCluster: map[string]podStatus{
+					clusterA: {withTraffic: len(httpPods) + len(grpcPods)},
+				},
+			},
+		},
+	)
+}
+
 func runTrafficControllerTest(
 	t *testing.T,
 	objectsByCluster map[string][]runtime.Object,
@@ -372,6 +518,7 @@ func runController(f *shippertesting.ControllerTestFixture) {
 		f.ShipperClient,
 		f.ShipperInformerFactory,
 		f.ClusterClientStore,
+		f.DynamicClientBuilder,
 		f.Recorder,
 	)
 
@@ -380,7 +527,15 @@ func runController(f *shippertesting.ControllerTestFixture) {
 
 	f.Run(stopCh)
 
-	for _, cluster := range f.Clusters {
+	for name, cluster := range f.Clusters {
+		cache.WaitForCacheSync(stopCh, controller.clusterEndpoints[name].hasSynced)
+
+		// Clusters that serve EndpointSlices don't get their
+		// Endpoints watched at all.
+		if controller.clusterEndpoints[name].endpoints == nil {
+			continue
+		}
+
 		kubeclient := cluster.Client
 		corev1Informers := cluster.InformerFactory.Core().V1()
 
@@ -388,20 +543,45 @@ func runController(f *shippertesting.ControllerTestFixture) {
 		if err != nil {
 			panic(fmt.Sprintf("can't list endpoints: %s", err))
 		}
-		if len(endpointsList) != 1 {
-			panic(fmt.Sprintf("expected a single endpoint, got %d", len(endpointsList)))
+		if len(endpointsList) == 0 {
+			panic("expected at least one endpoint, got none")
+		}
+
+		// Every Endpoints object only gets the pods its Service
+		// selects, regardless of their traffic status.
+		selectors := make(map[string]labels.Selector, len(endpointsList))
+		for _, endpoints := range endpointsList {
+			svc, err := corev1Informers.Services().Lister().
+				Services(endpoints.Namespace).Get(endpoints.Name)
+			if err != nil {
+				panic(fmt.Sprintf("can't get service for endpoints %q: %s", endpoints.Name, err))
+			}
+
+			selector := labels.Set{}
+			for k, v := range svc.Spec.Selector {
+				if k != shipper.PodTrafficStatusLabel {
+					selector[k] = v
+				}
+			}
+			selectors[endpoints.Name] = selector.AsSelector()
 		}
 
 		var mutex sync.Mutex
-		endpoints := endpointsList[0]
 		handlerFn := func(pod *corev1.Pod) {
 			mutex.Lock()
 			defer mutex.Unlock()
 
-			endpoints = shiftPodInEndpoints(pod, endpoints)
-			_, err = kubeclient.CoreV1().Endpoints(endpoints.Namespace).Update(endpoints)
-			if err != nil {
-				panic(fmt.Sprintf("can't update endpoints: %s", err))
+			for i, endpoints := range endpointsList {
+				if !selectors[endpoints.Name].Matches(labels.Set(pod.Labels)) {
+					continue
+				}
+
+				endpoints = shiftPodInEndpoints(pod, endpoints)
+				_, err = kubeclient.CoreV1().Endpoints(endpoints.Namespace).Update(endpoints)
+				if err != nil {
+					panic(fmt.Sprintf("can't update endpoints: %s", err))
+				}
+				endpointsList[i] = endpoints
 			}
 		}
 
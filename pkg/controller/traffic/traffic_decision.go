@@ -0,0 +1,91 @@
+package traffic
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+// buildTrafficDecision describes how new pods of a release should be
+// labeled: all of them if the release is meant to have all of its pods
+// receive traffic, none of them if it gets no traffic, or only as many as
+// needed to reach podsToLabel.
+func buildTrafficDecision(getsTraffic bool, podsToLabel, podsInRelease int) string {
+	if !getsTraffic {
+		return shipper.Disabled
+	}
+
+	if podsToLabel >= podsInRelease {
+		return shipper.Enabled
+	}
+
+	return strconv.Itoa(podsToLabel)
+}
+
+// recordTrafficDecision mirrors the traffic decision for the release in req
+// into a ConfigMap in the application cluster, so that the pod traffic
+// webhook can label new pods even when Shipper is not around. Decisions for
+// releases that no longer get traffic in the cluster are removed.
+func recordTrafficDecision(req *shiftRequest, decision string) error {
+	client := req.clientset.GetKubeClient()
+	name := trafficutil.TrafficDecisionConfigMapName(req.appName)
+
+	cm, err := client.CoreV1().ConfigMaps(req.namespace).Get(name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: req.namespace,
+				Labels: map[string]string{
+					shipper.AppLabel: req.appName,
+				},
+			},
+			Data: map[string]string{
+				req.releaseName: decision,
+			},
+		}
+
+		_, err = client.CoreV1().ConfigMaps(req.namespace).Create(cm)
+		if err != nil && !kerrors.IsAlreadyExists(err) {
+			return shippererrors.NewKubeclientCreateError(cm, err).
+				WithCoreV1Kind("ConfigMap")
+		}
+
+		return nil
+	} else if err != nil {
+		return shippererrors.NewKubeclientGetError(req.namespace, name, err).
+			WithCoreV1Kind("ConfigMap")
+	}
+
+	data := map[string]string{
+		req.releaseName: decision,
+	}
+	for release, d := range cm.Data {
+		_, hasWeight := req.weights[req.clusterName][release]
+		_, hasPods := req.pods[req.clusterName][release]
+		if release != req.releaseName && (hasWeight || hasPods) {
+			data[release] = d
+		}
+	}
+
+	if equality.Semantic.DeepEqual(cm.Data, data) {
+		return nil
+	}
+
+	cm = cm.DeepCopy()
+	cm.Data = data
+	_, err = client.CoreV1().ConfigMaps(req.namespace).Update(cm)
+	if err != nil {
+		return shippererrors.NewKubeclientUpdateError(cm, err).
+			WithCoreV1Kind("ConfigMap")
+	}
+
+	return nil
+}
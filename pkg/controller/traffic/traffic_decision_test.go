@@ -0,0 +1,77 @@
+package traffic
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippertesting "github.com/bookingcom/shipper/pkg/testing"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+// TestTrafficDecisionIsRecorded verifies that the traffic controller mirrors
+// how new pods of each release should be labeled into the application
+// cluster, for the pod traffic webhook to use.
+func TestTrafficDecisionIsRecorded(t *testing.T) {
+	tests := []struct {
+		name     string
+		weights  [2]uint32
+		pods     [2]int
+		expected map[string]string
+	}{
+		{
+			name:    "steady state",
+			weights: [2]uint32{100, 0},
+			pods:    [2]int{2, 2},
+			expected: map[string]string{
+				"foobar-a": shipper.Enabled,
+				"foobar-b": shipper.Disabled,
+			},
+		},
+		{
+			name:    "some pods of a release",
+			weights: [2]uint32{50, 50},
+			pods:    [2]int{3, 1},
+			expected: map[string]string{
+				"foobar-a": "2",
+				"foobar-b": shipper.Enabled,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := shippertesting.NewControllerTestFixture()
+			cluster := f.AddNamedCluster(clusterA)
+
+			objects := []runtime.Object{
+				buildService(shippertesting.TestApp),
+				buildEndpoints(shippertesting.TestApp),
+			}
+			for i, release := range []string{"foobar-a", "foobar-b"} {
+				f.ShipperClient.Tracker().Add(buildTrafficTarget(
+					shippertesting.TestApp, release,
+					map[string]uint32{clusterA: tt.weights[i]}))
+				objects = addPodsToList(objects, buildPods(
+					shippertesting.TestApp, release, tt.pods[i], noTraffic))
+			}
+			cluster.AddMany(objects)
+
+			runController(f)
+
+			name := trafficutil.TrafficDecisionConfigMapName(shippertesting.TestApp)
+			cm, err := cluster.Client.CoreV1().ConfigMaps(shippertesting.TestNamespace).
+				Get(name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("could not Get ConfigMap %q: %s", name, err)
+			}
+
+			eq, diff := shippertesting.DeepEqualDiff(tt.expected, cm.Data)
+			if !eq {
+				t.Errorf("traffic decisions differ from expected:\n%s", diff)
+			}
+		})
+	}
+}
@@ -14,6 +14,10 @@ import (
 
 type clusterReleaseWeights map[string]map[string]uint32
 
+// clusterReleasePods holds the releases that asked for an absolute number
+// of pods to receive traffic instead of a weight.
+type clusterReleasePods map[string]map[string]uint32
+
 type trafficShiftingStatus struct {
 	ready                 bool
 	achievedTrafficWeight uint32
@@ -21,23 +25,34 @@ type trafficShiftingStatus struct {
 	podsNotReady          int
 	podsLabeled           int
 	podsToShift           map[string][]*corev1.Pod
+
+	// decision is how new pods of the release should be labeled, as
+	// understood by the pod traffic webhook.
+	decision string
+
+	// weightDeviation is how far the release can get from the weight it
+	// asked for, given how many pods there are to share it. It is nil
+	// when the release asked for a number of pods instead.
+	weightDeviation *weightDeviation
 }
 
 // buildTrafficShiftingStatus looks at the current state of a cluster regarding
 // the progression of traffic shifting. It's concerned with how many of the
 // available pods have been labeled to receive traffic, how many are actually
-// ready according to the state of the Endpoints object, and the currently
-// achieved weight for a release. If the current state is different from the
-// desired one, it also returns which pods need to receive which labels to move
-// forward.
+// ready according to the Service's Endpoints or EndpointSlices, and the
+// currently achieved weight for a release. If the current state is different
+// from the desired one, it also returns which pods need to receive which
+// labels to move forward.
 func buildTrafficShiftingStatus(
 	cluster, appName, releaseName string,
 	clusterReleaseWeights clusterReleaseWeights,
-	endpoints *corev1.Endpoints,
+	clusterReleasePods clusterReleasePods,
+	readiness podReadiness,
 	appPods []*corev1.Pod,
 ) trafficShiftingStatus {
-	releaseTargetWeights, ok := clusterReleaseWeights[cluster]
-	if !ok {
+	releaseTargetWeights, hasWeights := clusterReleaseWeights[cluster]
+	releaseTargetPods, hasPods := clusterReleasePods[cluster]
+	if !hasWeights && !hasPods {
 		return trafficShiftingStatus{}
 	}
 
@@ -47,7 +62,7 @@ func buildTrafficShiftingStatus(
 	}).AsSelector()
 
 	podsByTrafficStatus, podsInRelease, podsReady, podsNotReady := summarizePods(
-		appPods, endpoints, releaseSelector)
+		appPods, readiness, releaseSelector)
 
 	releaseTargetWeight := releaseTargetWeights[releaseName]
 	totalTargetWeight := uint32(0)
@@ -55,10 +70,31 @@ func buildTrafficShiftingStatus(
 		totalTargetWeight += weight
 	}
 
+	// Releases asking for an absolute number of pods get them first, as
+	// far as they have them, and the remaining pods are shared by weight
+	// between the other releases.
 	podsInApp := len(appPods)
+	podsForWeights := podsInApp
+	if len(releaseTargetPods) > 0 {
+		podsByRelease := make(map[string]int)
+		for _, pod := range appPods {
+			podsByRelease[pod.Labels[shipper.ReleaseLabel]]++
+		}
+		for release, pods := range releaseTargetPods {
+			podsForWeights -= int(math.Min(float64(podsByRelease[release]), float64(pods)))
+		}
+	}
+
 	podsLabeledForTraffic := len(podsByTrafficStatus[shipper.Enabled])
-	podsToLabel := calculateReleasePodTarget(
-		podsInRelease, releaseTargetWeight, podsInApp, totalTargetWeight)
+
+	var podsToLabel int
+	releaseTargetPodCount, isAbsolute := releaseTargetPods[releaseName]
+	if isAbsolute {
+		podsToLabel = int(math.Min(float64(podsInRelease), float64(releaseTargetPodCount)))
+	} else {
+		podsToLabel = calculateReleasePodTarget(
+			podsInRelease, releaseTargetWeight, podsForWeights, totalTargetWeight)
+	}
 
 	// A TrafficTarget is ready when it has achieved a certain number of
 	// pods, not a certain weight. That's because its number of pods is
@@ -71,21 +107,36 @@ func buildTrafficShiftingStatus(
 		podsToShift = buildPodsToShift(podsByTrafficStatus, podsToLabel)
 	}
 
-	var achievedPercentage float64
-	if podsInApp == 0 {
-		achievedPercentage = 0
-	} else {
-		achievedPercentage = float64(podsReady) / float64(podsInApp)
+	// Releases with an absolute number of pods have no weight of their
+	// own, so they report the share of the pods of the application their
+	// ready pods represent, out of 100, just like service meshes get.
+	var achievedWeight uint32
+	if isAbsolute {
+		if podsInApp > 0 {
+			achievedPercentage := float64(podsReady) / float64(podsInApp)
+			achievedWeight = uint32(math.Round(achievedPercentage * 100))
+		}
+	} else if podsForWeights > 0 {
+		achievedPercentage := float64(podsReady) / float64(podsForWeights)
+		achievedWeight = uint32(math.Round(achievedPercentage * float64(totalTargetWeight)))
+	}
+
+	getsTraffic := releaseTargetWeight > 0 || releaseTargetPodCount > 0
+
+	var deviation *weightDeviation
+	if !isAbsolute && totalTargetWeight > 0 && podsForWeights > 0 {
+		deviation = buildWeightDeviation(releaseTargetWeight, totalTargetWeight, podsForWeights)
 	}
-	achievedWeight := uint32(math.Round(achievedPercentage * float64(totalTargetWeight)))
 
 	return trafficShiftingStatus{
+		decision:              buildTrafficDecision(getsTraffic, podsToLabel, podsInRelease),
 		achievedTrafficWeight: achievedWeight,
 		podsReady:             podsReady,
 		podsNotReady:          podsNotReady,
 		podsLabeled:           podsLabeledForTraffic,
 		ready:                 ready,
 		podsToShift:           podsToShift,
+		weightDeviation:       deviation,
 	}
 }
 
@@ -129,11 +180,11 @@ func buildPodsToShift(
 
 // summarizePods returns an aggregated summary of the current state of pods:
 // which pods are labeled to receive (or not receive) traffic, how many belong
-// to the specified release, and how many are ready according to the Endpoints
-// object.
+// to the specified release, and how many are ready according to the
+// endpoints of the Service.
 func summarizePods(
 	pods []*corev1.Pod,
-	endpoints *corev1.Endpoints,
+	readiness podReadiness,
 	releaseSelector labels.Selector,
 ) (map[string][]*corev1.Pod, int, int, int) {
 	podsInRelease := make(map[string]struct{})
@@ -158,15 +209,9 @@ func summarizePods(
 		podsByTrafficStatus[v] = append(podsByTrafficStatus[v], pod)
 	}
 
-	podReadiness := make(map[string]bool)
-	for _, subset := range endpoints.Subsets {
-		markAddressReadiness(podReadiness, subset.Addresses, true)
-		markAddressReadiness(podReadiness, subset.NotReadyAddresses, false)
-	}
-
 	podsReady := 0
 	podsNotReady := 0
-	for podName, podReady := range podReadiness {
+	for podName, podReady := range readiness {
 		_, belongsToRelease := podsInRelease[podName]
 
 		if !belongsToRelease {
@@ -183,26 +228,6 @@ func summarizePods(
 	return podsByTrafficStatus, len(podsInRelease), podsReady, podsNotReady
 }
 
-// markAddressReadiness updates podReadiness  by marking
-// the pods from a list of EndpointAddress as either ready or not ready
-// according to the markAs parameter.
-func markAddressReadiness(
-	podReadiness map[string]bool,
-	addresses []corev1.EndpointAddress,
-	markAs bool,
-) {
-	for _, address := range addresses {
-		target := address.TargetRef
-		// Don't know what to do if the target is not a Pod, so
-		// just skip it.
-		if target.Kind != "Pod" {
-			continue
-		}
-
-		podReadiness[target.Name] = markAs
-	}
-}
-
 /*
 	Transform this (a list of each release's traffic target object in this namespace):
 	[
@@ -220,25 +245,36 @@ func markAddressReadiness(
 		}
 	}
 */
-func buildClusterReleaseWeights(trafficTargets []*shipper.TrafficTarget) (clusterReleaseWeights, error) {
+func buildClusterReleaseWeights(trafficTargets []*shipper.TrafficTarget) (clusterReleaseWeights, clusterReleasePods, error) {
 	clusterReleases := map[string]map[string]uint32{}
+	clusterPods := map[string]map[string]uint32{}
 	releaseTT := map[string]*shipper.TrafficTarget{}
 
 	for _, tt := range trafficTargets {
 		release, ok := tt.Labels[shipper.ReleaseLabel]
 		if !ok {
 			err := shippererrors.NewMissingShipperLabelError(tt, shipper.ReleaseLabel)
-			return nil, err
+			return nil, nil, err
 		}
 
 		existingTT, ok := releaseTT[release]
 		if ok {
-			return nil, shippererrors.NewMultipleTrafficTargetsForReleaseError(
+			return nil, nil, shippererrors.NewMultipleTrafficTargetsForReleaseError(
 				tt.Namespace, release, []string{tt.Name, existingTT.Name})
 		}
 		releaseTT[release] = tt
 
 		for _, cluster := range tt.Spec.Clusters {
+			if cluster.Pods != nil {
+				pods, ok := clusterPods[cluster.Name]
+				if !ok {
+					pods = map[string]uint32{}
+					clusterPods[cluster.Name] = pods
+				}
+				pods[release] += *cluster.Pods
+				continue
+			}
+
 			weights, ok := clusterReleases[cluster.Name]
 			if !ok {
 				weights = map[string]uint32{}
@@ -248,7 +284,7 @@ func buildClusterReleaseWeights(trafficTargets []*shipper.TrafficTarget) (cluste
 		}
 	}
 
-	return clusterReleaseWeights(clusterReleases), nil
+	return clusterReleaseWeights(clusterReleases), clusterReleasePods(clusterPods), nil
 }
 
 func calculateReleasePodTarget(releasePods int, releaseWeight uint32, totalPods int, totalWeight uint32) int {
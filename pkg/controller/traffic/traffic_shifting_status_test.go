@@ -13,6 +13,7 @@ import (
 
 type release struct {
 	weight   uint32
+	pods     *uint32
 	podCount podStatus
 }
 
@@ -170,6 +171,44 @@ func TestTrafficShiftingMassiveWeightDisparity(t *testing.T) {
 	})
 }
 
+func TestTrafficShiftingAbsolutePods(t *testing.T) {
+	one := uint32(1)
+	runBuildTestTrafficShiftingStatus(t, []trafficShiftingStatusTestExpectation{
+		{
+			Release:               release{weight: 100, podCount: podStatus{withTraffic: 10}},
+			Ready:                 true,
+			AchievedTrafficWeight: 83,
+			PodsReady:             10,
+			PodsLabeled:           10,
+		},
+		{
+			Release:     release{pods: &one, podCount: podStatus{withoutTraffic: 3}},
+			Ready:       false,
+			PodsToShift: podsToShift{1, 0},
+		},
+	})
+}
+
+func TestTrafficShiftingAchievedAbsolutePods(t *testing.T) {
+	one := uint32(1)
+	runBuildTestTrafficShiftingStatus(t, []trafficShiftingStatusTestExpectation{
+		{
+			Release:               release{weight: 100, podCount: podStatus{withTraffic: 10}},
+			Ready:                 true,
+			AchievedTrafficWeight: 83,
+			PodsReady:             10,
+			PodsLabeled:           10,
+		},
+		{
+			Release:               release{pods: &one, podCount: podStatus{withTraffic: 1, withoutTraffic: 2}},
+			Ready:                 true,
+			AchievedTrafficWeight: 8,
+			PodsReady:             1,
+			PodsLabeled:           1,
+		},
+	})
+}
+
 func TestTrafficShiftingUnevedPodsEqualWeights(t *testing.T) {
 	runBuildTestTrafficShiftingStatus(t, []trafficShiftingStatusTestExpectation{
 		{
@@ -232,7 +271,8 @@ func TestTrafficShiftingPodsLabeledButNotReady(t *testing.T) {
 				releaseName: releaseWeight,
 			},
 		},
-		endpoints, appPods,
+		nil,
+		podReadinessFromEndpoints(endpoints), appPods,
 	)
 
 	assertTrafficShiftingStatusExpectation(t, releaseName,
@@ -270,7 +310,8 @@ func TestTrafficShiftingUnmanagedPods(t *testing.T) {
 				releaseName: releaseWeight,
 			},
 		},
-		endpoints, appPods,
+		nil,
+		podReadinessFromEndpoints(endpoints), appPods,
 	)
 
 	assertTrafficShiftingStatusExpectation(t, releaseName,
@@ -292,11 +333,15 @@ func runBuildTestTrafficShiftingStatus(
 		release := expectation.Release
 		releaseName := fmt.Sprintf("release-%d", i)
 
-		trafficTargets = append(trafficTargets, buildTrafficTarget(
+		tt := buildTrafficTarget(
 			shippertesting.TestApp, releaseName, map[string]uint32{
 				shippertesting.TestCluster: release.weight,
 			},
-		))
+		)
+		if release.pods != nil {
+			tt.Spec.Clusters[0].Pods = release.pods
+		}
+		trafficTargets = append(trafficTargets, tt)
 
 		podsWithTraffic := buildPods(
 			shippertesting.TestApp,
@@ -318,7 +363,7 @@ func runBuildTestTrafficShiftingStatus(
 		appPods = append(appPods, podsWithoutTraffic...)
 	}
 
-	clusterReleaseWeights, err := buildClusterReleaseWeights(trafficTargets)
+	clusterReleaseWeights, clusterReleasePods, err := buildClusterReleaseWeights(trafficTargets)
 	if err != nil {
 		t.Fatalf("cannot build cluster release weights: %s", err)
 	}
@@ -328,8 +373,8 @@ func runBuildTestTrafficShiftingStatus(
 		relName := tt.Labels[shipper.ReleaseLabel]
 		trafficStatus := buildTrafficShiftingStatus(
 			shippertesting.TestCluster, shippertesting.TestApp, relName,
-			clusterReleaseWeights,
-			endpoints, appPods,
+			clusterReleaseWeights, clusterReleasePods,
+			podReadinessFromEndpoints(endpoints), appPods,
 		)
 
 		assertTrafficShiftingStatusExpectation(t, relName, expectation, trafficStatus)
@@ -87,7 +87,7 @@ func shiftPodInEndpoints(pod *corev1.Pod, endpoints *corev1.Endpoints) *corev1.E
 			},
 		})
 	} else if !podGetsTraffic && addressIndex >= 0 {
-		addresses = append(addresses[:addressIndex], addresses[:addressIndex+1]...)
+		addresses = append(addresses[:addressIndex], addresses[addressIndex+1:]...)
 	}
 
 	if ready {
@@ -129,8 +129,9 @@ func buildSuccessStatus(clusters []shipper.ClusterTrafficTarget) shipper.Traffic
 
 	for _, cluster := range clusters {
 		clusterStatuses = append(clusterStatuses, &shipper.ClusterTrafficStatus{
-			Name:            cluster.Name,
-			AchievedTraffic: cluster.Weight,
+			Name:             cluster.Name,
+			RequestedTraffic: cluster.Weight,
+			AchievedTraffic:  cluster.Weight,
 			Conditions: []shipper.ClusterTrafficCondition{
 				ClusterTrafficOperational,
 				ClusterTrafficReady,
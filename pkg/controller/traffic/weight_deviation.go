@@ -0,0 +1,92 @@
+package traffic
+
+import (
+	"fmt"
+	"math"
+
+	corev1 "k8s.io/api/core/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/util/replicas"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+const (
+	// defaultMaxWeightDeviation is how many percentage points the
+	// traffic a release can get is allowed to be away from the one it
+	// asked for when an application doesn't say.
+	defaultMaxWeightDeviation = 10
+)
+
+// weightDeviation compares the share of the traffic a release asked for with
+// the one it can get by labeling whole pods, both in percent.
+type weightDeviation struct {
+	requested  float64
+	achievable float64
+	pods       int
+}
+
+func buildWeightDeviation(weight, totalWeight uint32, pods int) *weightDeviation {
+	requested := float64(weight) / float64(totalWeight) * 100
+
+	return &weightDeviation{
+		requested:  requested,
+		achievable: achievablePercent(requested, pods),
+		pods:       pods,
+	}
+}
+
+// achievablePercent returns the share of the traffic a release gets when it
+// asks for requested percent of it, and there are pods to share it. It rounds
+// up just like the number of pods to label does.
+func achievablePercent(requested float64, pods int) float64 {
+	achievablePods := replicas.CalculateDesiredReplicaCount(uint(pods), requested)
+	return float64(achievablePods) / float64(pods) * 100
+}
+
+// percentagePoints returns how far apart the requested and achievable
+// shares of the traffic are.
+func (d *weightDeviation) percentagePoints() float64 {
+	return math.Abs(d.achievable - d.requested)
+}
+
+// minPods returns the smallest number of pods that allows getting within
+// maxDeviation percentage points of the requested share of the traffic.
+func (d *weightDeviation) minPods(maxDeviation float64) int {
+	// Pods come in steps of 100/pods percent, so there's always a number
+	// of pods that gets close enough.
+	limit := int(math.Ceil(100 / maxDeviation))
+	for pods := 1; pods < limit; pods++ {
+		if math.Abs(achievablePercent(d.requested, pods)-d.requested) <= maxDeviation {
+			return pods
+		}
+	}
+
+	return limit
+}
+
+func maxWeightDeviation(app *shipper.Application) float64 {
+	if app == nil || app.Spec.TrafficShifting == nil || app.Spec.TrafficShifting.MaxWeightDeviation <= 0 {
+		return defaultMaxWeightDeviation
+	}
+
+	return float64(app.Spec.TrafficShifting.MaxWeightDeviation)
+}
+
+// buildWeightDeviationCondition returns a WeightDeviation condition when a
+// release can't get close enough to the traffic it asked for, or nil if it
+// can.
+func buildWeightDeviationCondition(d *weightDeviation, maxDeviation float64) *shipper.ClusterTrafficCondition {
+	if d == nil || d.percentagePoints() <= maxDeviation {
+		return nil
+	}
+
+	return trafficutil.NewClusterTrafficCondition(
+		shipper.ClusterConditionTypeWeightDeviation,
+		corev1.ConditionTrue,
+		NotEnoughPods,
+		fmt.Sprintf(
+			"requested %.1f%% of the traffic, but %d pods only allow for %.1f%%; at least %d pods are needed to get within %.0f percentage points of it",
+			d.requested, d.pods, d.achievable, d.minPods(maxDeviation), maxDeviation),
+	)
+}
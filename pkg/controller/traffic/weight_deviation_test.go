@@ -0,0 +1,130 @@
+package traffic
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippertesting "github.com/bookingcom/shipper/pkg/testing"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+func TestWeightDeviationMinPods(t *testing.T) {
+	tests := []struct {
+		name            string
+		weight          uint32
+		totalWeight     uint32
+		pods            int
+		maxDeviation    float64
+		expectedPods    int
+		expectDeviation bool
+	}{
+		{"5% on 4 pods", 5, 100, 4, 10, 7, true},
+		{"5% on 20 pods", 5, 100, 20, 10, 7, false},
+		{"50% on 3 pods", 50, 100, 3, 10, 2, true},
+		{"5% on 4 pods, lenient", 5, 100, 4, 25, 4, false},
+		{"1 out of 3 on 2 pods", 1, 3, 2, 1, 3, true},
+	}
+
+	for _, tt := range tests {
+		d := buildWeightDeviation(tt.weight, tt.totalWeight, tt.pods)
+
+		if deviates := d.percentagePoints() > tt.maxDeviation; deviates != tt.expectDeviation {
+			t.Errorf("%s: expected deviation to be %t, got %t (%.1f percentage points)",
+				tt.name, tt.expectDeviation, deviates, d.percentagePoints())
+		}
+
+		if pods := d.minPods(tt.maxDeviation); pods != tt.expectedPods {
+			t.Errorf("%s: expected to need at least %d pods, got %d", tt.name, tt.expectedPods, pods)
+		}
+	}
+}
+
+// TestWeightDeviationIsReported verifies that a release that can't get close
+// enough to the weight it asked for with the pods there are gets a warning
+// condition and event, and that one that can doesn't.
+func TestWeightDeviationIsReported(t *testing.T) {
+	f := shippertesting.NewControllerTestFixture()
+	cluster := f.AddNamedCluster(clusterA)
+
+	incumbent := buildTrafficTarget(
+		shippertesting.TestApp, "foobar-a",
+		map[string]uint32{clusterA: 95})
+	contender := buildTrafficTarget(
+		shippertesting.TestApp, "foobar-b",
+		map[string]uint32{clusterA: 5})
+
+	objects := []runtime.Object{
+		buildService(shippertesting.TestApp),
+		buildEndpoints(shippertesting.TestApp),
+	}
+	objects = addPodsToList(objects, buildPods(shippertesting.TestApp, incumbent.Name, 2, withTraffic))
+	objects = addPodsToList(objects, buildPods(shippertesting.TestApp, contender.Name, 2, noTraffic))
+	cluster.AddMany(objects)
+
+	f.ShipperClient.Tracker().Add(incumbent)
+	f.ShipperClient.Tracker().Add(contender)
+
+	runController(f)
+
+	ttGVR := shipper.SchemeGroupVersion.WithResource("traffictargets")
+	expectedMsg := "requested 5.0% of the traffic, but 4 pods only allow for 25.0%; " +
+		"at least 7 pods are needed to get within 10 percentage points of it"
+
+	for _, expected := range []struct {
+		tt      *shipper.TrafficTarget
+		deviant bool
+	}{
+		{incumbent, false},
+		{contender, true},
+	} {
+		object, err := f.ShipperClient.Tracker().Get(ttGVR, expected.tt.Namespace, expected.tt.Name)
+		if err != nil {
+			t.Fatalf("could not Get TrafficTarget %q: %s", expected.tt.Name, err)
+		}
+		tt := object.(*shipper.TrafficTarget)
+
+		if len(tt.Status.Clusters) != 1 {
+			t.Fatalf("expected status for a single cluster, got %d", len(tt.Status.Clusters))
+		}
+		status := tt.Status.Clusters[0]
+
+		if status.RequestedTraffic != expected.tt.Spec.Clusters[0].Weight {
+			t.Errorf("expected TrafficTarget %q to request %d, got %d",
+				tt.Name, expected.tt.Spec.Clusters[0].Weight, status.RequestedTraffic)
+		}
+
+		cond := trafficutil.GetClusterTrafficCondition(*status, shipper.ClusterConditionTypeWeightDeviation)
+		if !expected.deviant {
+			if cond != nil {
+				t.Errorf("expected TrafficTarget %q not to deviate, got %+v", tt.Name, cond)
+			}
+			continue
+		}
+
+		if cond == nil || cond.Status != corev1.ConditionTrue || cond.Reason != NotEnoughPods {
+			t.Fatalf("expected TrafficTarget %q to deviate with reason %q, got %+v", tt.Name, NotEnoughPods, cond)
+		}
+
+		if cond.Message != expectedMsg {
+			t.Errorf("expected message %q, got %q", expectedMsg, cond.Message)
+		}
+	}
+
+	expectedEvent := fmt.Sprintf("%s %s cluster %q: %s",
+		corev1.EventTypeWarning, WeightDeviation, clusterA, expectedMsg)
+	found := false
+	for len(f.Recorder.Events) > 0 {
+		if event := <-f.Recorder.Events; strings.HasPrefix(event, expectedEvent) {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("expected a %q event for TrafficTarget %q", WeightDeviation, contender.Name)
+	}
+}
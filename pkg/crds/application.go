@@ -34,7 +34,46 @@ var Application = &apiextensionv1beta1.CustomResourceDefinition{
 							"template",
 						},
 						Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
-							"template": environmentValidation,
+							"template": applicationTemplateValidation,
+							"autoRollback": apiextensionv1beta1.JSONSchemaProps{
+								Type: "object",
+								Required: []string{
+									"deadline",
+								},
+								Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
+									"deadline": apiextensionv1beta1.JSONSchemaProps{
+										Type: "string",
+									},
+								},
+							},
+							"trafficBackend": trafficBackendValidation,
+							"drainDelay": apiextensionv1beta1.JSONSchemaProps{
+								Type: "string",
+							},
+							"trafficShifting": apiextensionv1beta1.JSONSchemaProps{
+								Type: "object",
+								Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
+									"maxPodsPerSync": apiextensionv1beta1.JSONSchemaProps{
+										Type:    "integer",
+										Minimum: &zero,
+									},
+									"maxPodsPerInterval": apiextensionv1beta1.JSONSchemaProps{
+										Type:    "integer",
+										Minimum: &zero,
+									},
+									"interval": apiextensionv1beta1.JSONSchemaProps{
+										Type: "string",
+									},
+									"parallelism": apiextensionv1beta1.JSONSchemaProps{
+										Type:    "integer",
+										Minimum: &zero,
+									},
+									"maxWeightDeviation": apiextensionv1beta1.JSONSchemaProps{
+										Type:    "integer",
+										Minimum: &zero,
+									},
+								},
+							},
 						},
 					},
 				},
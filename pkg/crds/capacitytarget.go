@@ -55,10 +55,29 @@ var CapacityTarget = &apiextensionv1beta1.CustomResourceDefinition{
 												Minimum: &zero,
 												Maximum: &hundred,
 											},
+											"replicas": apiextensionv1beta1.JSONSchemaProps{
+												Type:    "integer",
+												Minimum: &zero,
+											},
 										},
 									},
 								},
 							},
+							"workload": apiextensionv1beta1.JSONSchemaProps{
+								Type: "object",
+								Required: []string{
+									"apiVersion",
+									"kind",
+								},
+								Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
+									"apiVersion": apiextensionv1beta1.JSONSchemaProps{
+										Type: "string",
+									},
+									"kind": apiextensionv1beta1.JSONSchemaProps{
+										Type: "string",
+									},
+								},
+							},
 						},
 					},
 				},
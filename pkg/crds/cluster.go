@@ -64,6 +64,7 @@ var Cluster = &apiextensionv1beta1.CustomResourceDefinition{
 									},
 								},
 							},
+							"trafficBackend": trafficBackendValidation,
 						},
 					},
 				},
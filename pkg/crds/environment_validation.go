@@ -4,11 +4,64 @@ import (
 	apiextensionv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 )
 
+// capacityStepValueValidation and trafficStepValueValidation accept either
+// an integer percentage, or a string with a percentage ("5%") or a number of
+// pods ("1").
+var capacityStepValueValidation = apiextensionv1beta1.JSONSchemaProps{
+	XIntOrString: true,
+	AnyOf: []apiextensionv1beta1.JSONSchemaProps{
+		{
+			Type:    "integer",
+			Minimum: &zero,
+			Maximum: &hundred,
+		},
+		{
+			Type:    "string",
+			Pattern: stepValuePattern,
+		},
+	},
+}
+
+var trafficStepValueValidation = apiextensionv1beta1.JSONSchemaProps{
+	XIntOrString: true,
+	AnyOf: []apiextensionv1beta1.JSONSchemaProps{
+		{
+			Type:    "integer",
+			Minimum: &zero,
+		},
+		{
+			Type:    "string",
+			Pattern: stepValuePattern,
+		},
+	},
+}
+
+const stepValuePattern = `^[0-9]+%?$`
+
+// applicationTemplateValidation is the environment of an Application, which
+// has either an inline strategy or a reference to a RolloutStrategy, but
+// never both.
+var applicationTemplateValidation = func() apiextensionv1beta1.JSONSchemaProps {
+	v := *environmentValidation.DeepCopy()
+	v.OneOf = []apiextensionv1beta1.JSONSchemaProps{
+		{Required: []string{"strategy"}},
+		{Required: []string{"strategyRef"}},
+	}
+	return v
+}()
+
+// releaseEnvironmentValidation is the environment of a Release, which always
+// has a strategy, as references are resolved when the Release is created.
+var releaseEnvironmentValidation = func() apiextensionv1beta1.JSONSchemaProps {
+	v := *environmentValidation.DeepCopy()
+	v.Required = append(v.Required, "strategy")
+	return v
+}()
+
 var environmentValidation = apiextensionv1beta1.JSONSchemaProps{
 	Type: "object",
 	Required: []string{
 		"clusterRequirements",
-		"strategy",
 		"chart",
 		"values",
 	},
@@ -56,59 +109,152 @@ var environmentValidation = apiextensionv1beta1.JSONSchemaProps{
 				},
 			},
 		},
-		"strategy": apiextensionv1beta1.JSONSchemaProps{
+		"strategy": strategyValidation,
+		"strategyRef": apiextensionv1beta1.JSONSchemaProps{
 			Type: "object",
 			Required: []string{
-				"steps",
+				"name",
 			},
 			Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
-				"steps": apiextensionv1beta1.JSONSchemaProps{
-					Type: "array",
-					Items: &apiextensionv1beta1.JSONSchemaPropsOrArray{
-						Schema: &apiextensionv1beta1.JSONSchemaProps{
+				"name": apiextensionv1beta1.JSONSchemaProps{
+					Type: "string",
+				},
+			},
+		},
+		"values": apiextensionv1beta1.JSONSchemaProps{
+			Type: "object",
+		},
+	},
+}
+
+var strategyValidation = apiextensionv1beta1.JSONSchemaProps{
+	Type: "object",
+	Required: []string{
+		"steps",
+	},
+	Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
+		"steps": apiextensionv1beta1.JSONSchemaProps{
+			Type: "array",
+			Items: &apiextensionv1beta1.JSONSchemaPropsOrArray{
+				Schema: &apiextensionv1beta1.JSONSchemaProps{
+					Type: "object",
+					Required: []string{
+						"name",
+						"traffic",
+						"capacity",
+					},
+					Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
+						"name": apiextensionv1beta1.JSONSchemaProps{
+							Type: "string",
+						},
+						"capacity": apiextensionv1beta1.JSONSchemaProps{
 							Type: "object",
 							Required: []string{
-								"name",
-								"traffic",
-								"capacity",
+								"incumbent",
+								"contender",
 							},
 							Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
-								"name": apiextensionv1beta1.JSONSchemaProps{
-									Type: "string",
-								},
-								"capacity": apiextensionv1beta1.JSONSchemaProps{
+								"incumbent": capacityStepValueValidation,
+								"contender": capacityStepValueValidation,
+							},
+						},
+						"traffic": apiextensionv1beta1.JSONSchemaProps{
+							Type: "object",
+							Required: []string{
+								"incumbent",
+								"contender",
+							},
+							Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
+								"incumbent": trafficStepValueValidation,
+								"contender": trafficStepValueValidation,
+							},
+						},
+						"clusterOverrides": apiextensionv1beta1.JSONSchemaProps{
+							Type: "array",
+							Items: &apiextensionv1beta1.JSONSchemaPropsOrArray{
+								Schema: &apiextensionv1beta1.JSONSchemaProps{
 									Type: "object",
 									Required: []string{
-										"incumbent",
-										"contender",
+										"clusters",
+										"capacity",
+										"traffic",
 									},
 									Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
-										"incumbent": apiextensionv1beta1.JSONSchemaProps{
-											Type:    "integer",
-											Minimum: &zero,
-											Maximum: &hundred,
+										"clusters": apiextensionv1beta1.JSONSchemaProps{
+											Type: "array",
+											Items: &apiextensionv1beta1.JSONSchemaPropsOrArray{
+												Schema: &apiextensionv1beta1.JSONSchemaProps{
+													Type: "string",
+												},
+											},
 										},
-										"contender": apiextensionv1beta1.JSONSchemaProps{
-											Type:    "integer",
-											Minimum: &zero,
-											Maximum: &hundred,
+										"capacity": apiextensionv1beta1.JSONSchemaProps{
+											Type: "object",
+											Required: []string{
+												"incumbent",
+												"contender",
+											},
+											Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
+												"incumbent": capacityStepValueValidation,
+												"contender": capacityStepValueValidation,
+											},
+										},
+										"traffic": apiextensionv1beta1.JSONSchemaProps{
+											Type: "object",
+											Required: []string{
+												"incumbent",
+												"contender",
+											},
+											Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
+												"incumbent": trafficStepValueValidation,
+												"contender": trafficStepValueValidation,
+											},
 										},
 									},
 								},
-								"traffic": apiextensionv1beta1.JSONSchemaProps{
-									Type: "object",
-									Required: []string{
-										"incumbent",
-										"contender",
-									},
-									Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
-										"incumbent": apiextensionv1beta1.JSONSchemaProps{
-											Type:    "integer",
-											Minimum: &zero,
-										},
-										"contender": apiextensionv1beta1.JSONSchemaProps{
-											Type:    "integer",
-											Minimum: &zero,
+							},
+						},
+						"autoAdvance": apiextensionv1beta1.JSONSchemaProps{
+							Type: "boolean",
+						},
+						"pause": apiextensionv1beta1.JSONSchemaProps{
+							Type: "string",
+						},
+						"deadline": apiextensionv1beta1.JSONSchemaProps{
+							Type: "string",
+						},
+						"analysis": apiextensionv1beta1.JSONSchemaProps{
+							Type: "object",
+							Required: []string{
+								"window",
+								"queries",
+							},
+							Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
+								"window": apiextensionv1beta1.JSONSchemaProps{
+									Type: "string",
+								},
+								"queries": apiextensionv1beta1.JSONSchemaProps{
+									Type: "array",
+									Items: &apiextensionv1beta1.JSONSchemaPropsOrArray{
+										Schema: &apiextensionv1beta1.JSONSchemaProps{
+											Type: "object",
+											Required: []string{
+												"name",
+												"query",
+												"threshold",
+											},
+											Properties: map[string]apiextensionv1beta1.JSONSchemaProps{
+												"name": apiextensionv1beta1.JSONSchemaProps{
+													Type: "string",
+												},
+												"query": apiextensionv1beta1.JSONSchemaProps{
+													Type: "string",
+												},
+												"threshold": apiextensionv1beta1.JSONSchemaProps{
+													Type:    "string",
+													Pattern: `^[0-9]+(\.[0-9]+)?$`,
+												},
+											},
 										},
 									},
 								},
@@ -118,8 +264,5 @@ var environmentValidation = apiextensionv1beta1.JSONSchemaProps{
 				},
 			},
 		},
-		"values": apiextensionv1beta1.JSONSchemaProps{
-			Type: "object",
-		},
 	},
 }
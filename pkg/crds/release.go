@@ -39,7 +39,7 @@ var Release = &apiextensionv1beta1.CustomResourceDefinition{
 								Type:    "integer",
 								Minimum: &zero,
 							},
-							"environment": environmentValidation,
+							"environment": releaseEnvironmentValidation,
 						},
 					},
 				},
@@ -0,0 +1,17 @@
+package crds
+
+import (
+	apiextensionv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+)
+
+// trafficBackendValidation lists the traffic backends Shipper knows how to
+// drive, as accepted by Applications and Clusters.
+var trafficBackendValidation = apiextensionv1beta1.JSONSchemaProps{
+	Type: "string",
+	Enum: []apiextensionv1beta1.JSON{
+		{Raw: []byte(`"podLabels"`)},
+		{Raw: []byte(`"istio"`)},
+		{Raw: []byte(`"smi"`)},
+		{Raw: []byte(`"nginxCanary"`)},
+	},
+}
@@ -80,6 +80,10 @@ var TrafficTarget = &apiextensionv1beta1.CustomResourceDefinition{
 												Type:    "integer",
 												Minimum: &zero,
 											},
+											"pods": apiextensionv1beta1.JSONSchemaProps{
+												Type:    "integer",
+												Minimum: &zero,
+											},
 										},
 									},
 								},
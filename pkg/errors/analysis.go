@@ -0,0 +1,45 @@
+package errors
+
+import (
+	"fmt"
+)
+
+type AnalysisQueryError struct {
+	query string
+	err   error
+}
+
+func (e AnalysisQueryError) Error() string {
+	return fmt.Sprintf("failed to run analysis query %q: %s", e.query, e.err)
+}
+
+func (e AnalysisQueryError) ShouldRetry() bool {
+	return true
+}
+
+func NewAnalysisQueryError(query string, err error) AnalysisQueryError {
+	return AnalysisQueryError{
+		query: query,
+		err:   err,
+	}
+}
+
+type InvalidAnalysisQueryError struct {
+	name string
+	err  error
+}
+
+func (e InvalidAnalysisQueryError) Error() string {
+	return fmt.Sprintf("invalid analysis query %q: %s", e.name, e.err)
+}
+
+func (e InvalidAnalysisQueryError) ShouldRetry() bool {
+	return false
+}
+
+func NewInvalidAnalysisQueryError(name string, err error) InvalidAnalysisQueryError {
+	return InvalidAnalysisQueryError{
+		name: name,
+		err:  err,
+	}
+}
@@ -0,0 +1,47 @@
+package errors
+
+import (
+	"fmt"
+)
+
+type InvalidStrategyError string
+
+func (e InvalidStrategyError) Error() string {
+	return string(e)
+}
+
+func (e InvalidStrategyError) ShouldRetry() bool {
+	return false
+}
+
+func NewInvalidStrategyError(format string, args ...interface{}) InvalidStrategyError {
+	return InvalidStrategyError(fmt.Sprintf("invalid rollout strategy: "+format, args...))
+}
+
+type InvalidClusterRequirementsError string
+
+func (e InvalidClusterRequirementsError) Error() string {
+	return string(e)
+}
+
+func (e InvalidClusterRequirementsError) ShouldRetry() bool {
+	return false
+}
+
+func NewInvalidClusterRequirementsError(format string, args ...interface{}) InvalidClusterRequirementsError {
+	return InvalidClusterRequirementsError(fmt.Sprintf("invalid cluster requirements: "+format, args...))
+}
+
+type InvalidReleaseApprovalError string
+
+func (e InvalidReleaseApprovalError) Error() string {
+	return string(e)
+}
+
+func (e InvalidReleaseApprovalError) ShouldRetry() bool {
+	return false
+}
+
+func NewInvalidReleaseApprovalError(format string, args ...interface{}) InvalidReleaseApprovalError {
+	return InvalidReleaseApprovalError(fmt.Sprintf("invalid release approval: "+format, args...))
+}
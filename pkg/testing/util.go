@@ -136,8 +136,10 @@ func FilterActions(actions []kubetesting.Action) []kubetesting.Action {
 				"endpoints",
 				"installationtargets",
 				"pods",
+				"releaseapprovals",
 				"releases",
 				"rolloutblocks",
+				"rolloutstrategies",
 				"secrets",
 				"services",
 				"traffictargets",
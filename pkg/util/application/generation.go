@@ -25,6 +25,15 @@ func SetHighestObservedGeneration(app *shipper.Application, generation int) {
 	app.Annotations[shipper.AppHighestObservedGenerationAnnotation] = strconv.Itoa(generation)
 }
 
+// CopyEnvironment replaces the template of app with the environment of rel.
+// Releases keep the strategy resolved from a strategyRef next to the
+// reference itself, which an Application template can't have, so the
+// strategy is left out in that case.
 func CopyEnvironment(app *shipper.Application, rel *shipper.Release) {
-	app.Spec.Template = *(rel.Spec.Environment.DeepCopy())
+	env := rel.Spec.Environment.DeepCopy()
+	if env.StrategyRef != nil {
+		env.Strategy = nil
+	}
+
+	app.Spec.Template = *env
 }
@@ -12,6 +12,15 @@ import (
 	"github.com/bookingcom/shipper/pkg/util/diff"
 )
 
+const (
+	// PodsNotReady, DeploymentStuck and QuotaInsufficient are the reasons
+	// for a False Ready cluster capacity condition that say what is
+	// holding the workload back, as opposed to it just being in progress.
+	PodsNotReady      = "PodsNotReady"
+	DeploymentStuck   = "DeploymentStuck"
+	QuotaInsufficient = "QuotaInsufficient"
+)
+
 var CapacityConditionsShouldDiscardTimestamps = false
 
 type ClusterCapacityConditionDiff struct {
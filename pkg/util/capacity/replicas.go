@@ -0,0 +1,20 @@
+package capacity
+
+import (
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/util/replicas"
+)
+
+// DesiredReplicaCount returns how many replicas a cluster capacity target
+// asks for. An absolute number of replicas wins over the percentage, but is
+// capped at the total replica count of the release.
+func DesiredReplicaCount(spec shipper.ClusterCapacityTarget) int32 {
+	if spec.Replicas != nil {
+		if *spec.Replicas > spec.TotalReplicaCount {
+			return spec.TotalReplicaCount
+		}
+		return *spec.Replicas
+	}
+
+	return int32(replicas.CalculateDesiredReplicaCount(uint(spec.TotalReplicaCount), float64(spec.Percent)))
+}
@@ -0,0 +1,39 @@
+package capacity
+
+import (
+	"testing"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+func TestDesiredReplicaCount(t *testing.T) {
+	one, twenty := int32(1), int32(20)
+
+	tests := []struct {
+		name     string
+		spec     shipper.ClusterCapacityTarget
+		expected int32
+	}{
+		{
+			"percentage is rounded up",
+			shipper.ClusterCapacityTarget{Percent: 1, TotalReplicaCount: 1000},
+			10,
+		},
+		{
+			"absolute replicas win over percentage",
+			shipper.ClusterCapacityTarget{Percent: 50, Replicas: &one, TotalReplicaCount: 1000},
+			1,
+		},
+		{
+			"absolute replicas are capped",
+			shipper.ClusterCapacityTarget{Replicas: &twenty, TotalReplicaCount: 10},
+			10,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := DesiredReplicaCount(tt.spec); got != tt.expected {
+			t.Errorf("%s: expected %d replicas, got %d", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package capacity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+type sadContainerSummary struct {
+	pods    int
+	reasons map[string]struct{}
+}
+
+// SummarizeSadPods returns a human readable summary of the containers that
+// are not ready in sadPods, grouped by container name.
+func SummarizeSadPods(sadPods []shipper.PodStatus) string {
+	summary := make(map[string]*sadContainerSummary)
+
+	for _, sadPod := range sadPods {
+		summarizeContainers(sadPod.InitContainers, summary)
+		summarizeContainers(sadPod.Containers, summary)
+	}
+
+	containers := make([]string, 0, len(summary))
+	for c, _ := range summary {
+		containers = append(containers, c)
+	}
+
+	sort.Strings(containers)
+
+	summaryStrs := make([]string, 0, len(summary))
+	for _, container := range containers {
+		summary := summary[container]
+		reasons := make([]string, 0, len(summary.reasons))
+		for r, _ := range summary.reasons {
+			reasons = append(reasons, r)
+		}
+
+		sort.Strings(reasons)
+
+		summaryStrs = append(summaryStrs, fmt.Sprintf("%dx%q containers with %v", summary.pods, container, reasons))
+	}
+
+	return strings.Join(summaryStrs, "; ")
+}
+
+// SadPodsRestarted returns whether any of the containers in sadPods has been
+// restarted, which means that the pods are not just slow to become ready.
+func SadPodsRestarted(sadPods []shipper.PodStatus) bool {
+	for _, sadPod := range sadPods {
+		for _, container := range sadPod.InitContainers {
+			if container.RestartCount > 0 {
+				return true
+			}
+		}
+		for _, container := range sadPod.Containers {
+			if container.RestartCount > 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func summarizeContainers(containers []corev1.ContainerStatus, summary map[string]*sadContainerSummary) {
+	for _, container := range containers {
+		if container.Ready {
+			continue
+		}
+
+		sadContainer, ok := summary[container.Name]
+		if !ok {
+			sadContainer = &sadContainerSummary{
+				reasons: make(map[string]struct{}),
+			}
+
+			summary[container.Name] = sadContainer
+		}
+
+		sadContainer.pods++
+
+		if state := container.State.Waiting; state != nil {
+			sadContainer.reasons[state.Reason] = struct{}{}
+		} else if state := container.State.Terminated; state != nil {
+			sadContainer.reasons[state.Reason] = struct{}{}
+		}
+	}
+}
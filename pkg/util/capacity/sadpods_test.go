@@ -78,10 +78,58 @@ func TestSummarizeSadPods(t *testing.T) {
 		`2x"waiting-container" containers with [ErrImagePull ImagePullBackOff]`,
 		`1x"waiting-init-container" containers with [ImagePullBackOff]`,
 	}, "; ")
-	actual := summarizeSadPods(sadPods)
+	actual := SummarizeSadPods(sadPods)
 	if expected != actual {
 		t.Fatalf(
 			"summary does not match.\nexpected: %s\nactual:   %s",
 			expected, actual)
 	}
 }
+
+func TestSadPodsRestarted(t *testing.T) {
+	tests := []struct {
+		name     string
+		sadPods  []shipper.PodStatus
+		expected bool
+	}{
+		{
+			name: "pods without restarts",
+			sadPods: []shipper.PodStatus{
+				{
+					Containers: []corev1.ContainerStatus{{Name: "app"}},
+				},
+			},
+			expected: false,
+		},
+		{
+			name: "container with restarts",
+			sadPods: []shipper.PodStatus{
+				{
+					Containers: []corev1.ContainerStatus{{Name: "app"}},
+				},
+				{
+					Containers: []corev1.ContainerStatus{{Name: "app", RestartCount: 2}},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "init container with restarts",
+			sadPods: []shipper.PodStatus{
+				{
+					InitContainers: []corev1.ContainerStatus{{Name: "init", RestartCount: 1}},
+					Containers:     []corev1.ContainerStatus{{Name: "app"}},
+				},
+			},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := SadPodsRestarted(tt.sadPods); actual != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, actual)
+			}
+		})
+	}
+}
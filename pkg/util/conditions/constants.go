@@ -9,4 +9,6 @@ const (
 	BrokenReleaseGeneration             = "BrokenReleaseGeneration"
 	BrokenApplicationObservedGeneration = "BrokenApplicationObservedGeneration"
 	StrategyExecutionFailed             = "StrategyExecutionFailed"
+	ContenderStuck                      = "ContenderStuck"
+	ContenderAnalysisFailed             = "ContenderAnalysisFailed"
 )
@@ -208,9 +208,15 @@ func (sc StrategyConditionsMap) AsReleaseStrategyState(
 		state.WaitingForTraffic = shipper.StrategyStateFalse
 	}
 
+	// An analysis only exists for steps that ask for one, so we only
+	// hold off while there is one going on for this step.
+	analysis, ok := sc.GetCondition(shipper.StrategyConditionContenderAchievedAnalysis)
+	waitingForAnalysis := ok && analysis.Step == step && analysis.Status != corev1.ConditionTrue
+
 	waitingForCommandFlag := !isLastStep &&
 		!waitingForCapacity &&
 		!waitingForTraffic &&
+		!waitingForAnalysis &&
 		achievedInstallation
 
 	if waitingForCommandFlag {
@@ -428,6 +428,56 @@ func TestStateWaitingForCommand(t *testing.T) {
 	}
 }
 
+func TestStateNotWaitingForCommandDuringAnalysis(t *testing.T) {
+	step1 := int32(1)
+	sc := NewStrategyConditions(
+		shipper.ReleaseStrategyCondition{
+			Type:   shipper.StrategyConditionContenderAchievedInstallation,
+			Status: corev1.ConditionTrue,
+			Step:   step1,
+		},
+		shipper.ReleaseStrategyCondition{
+			Type:   shipper.StrategyConditionContenderAchievedCapacity,
+			Status: corev1.ConditionTrue,
+			Step:   step1,
+		},
+		shipper.ReleaseStrategyCondition{
+			Type:   shipper.StrategyConditionContenderAchievedTraffic,
+			Status: corev1.ConditionTrue,
+			Step:   step1,
+		},
+		shipper.ReleaseStrategyCondition{
+			Type:   shipper.StrategyConditionIncumbentAchievedCapacity,
+			Status: corev1.ConditionTrue,
+			Step:   step1,
+		},
+		shipper.ReleaseStrategyCondition{
+			Type:   shipper.StrategyConditionIncumbentAchievedTraffic,
+			Status: corev1.ConditionTrue,
+			Step:   step1,
+		},
+		shipper.ReleaseStrategyCondition{
+			Type:   shipper.StrategyConditionContenderAchievedAnalysis,
+			Status: corev1.ConditionFalse,
+			Step:   step1,
+		},
+	)
+
+	expected := shipper.ReleaseStrategyState{
+		WaitingForCapacity:     shipper.StrategyStateFalse,
+		WaitingForInstallation: shipper.StrategyStateFalse,
+		WaitingForTraffic:      shipper.StrategyStateFalse,
+		WaitingForCommand:      shipper.StrategyStateFalse,
+	}
+
+	releaseStrategyState := sc.AsReleaseStrategyState(step1, true, false)
+	if !reflect.DeepEqual(releaseStrategyState, expected) {
+		t.Fatalf(
+			"Strategy states are different\nDiff:\n %s",
+			cmp.Diff(releaseStrategyState, expected))
+	}
+}
+
 func TestContenderAchievedInstallationCondition(t *testing.T) {
 	sc := NewStrategyConditions()
 
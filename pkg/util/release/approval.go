@@ -0,0 +1,32 @@
+package release
+
+import (
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+// ApprovedTargetStep returns the strategy step a release may work towards
+// given the approvals in its namespace. The target step of the release is
+// honoured if it, or any step after it, has been approved. Otherwise the
+// release holds at the furthest approved step before it. The first step
+// never needs an approval.
+func ApprovedTargetStep(rel *shipper.Release, approvals []*shipper.ReleaseApproval) int32 {
+	targetStep := rel.Spec.TargetStep
+
+	var approvedStep int32
+	for _, approval := range approvals {
+		if approval.Spec.Release != rel.Name {
+			continue
+		}
+
+		step := approval.Spec.Step
+		if step >= targetStep {
+			return targetStep
+		}
+
+		if step > approvedStep {
+			approvedStep = step
+		}
+	}
+
+	return approvedStep
+}
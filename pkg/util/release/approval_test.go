@@ -0,0 +1,46 @@
+package release
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+func TestApprovedTargetStep(t *testing.T) {
+	approval := func(release string, step int32) *shipper.ReleaseApproval {
+		return &shipper.ReleaseApproval{
+			Spec: shipper.ReleaseApprovalSpec{
+				Release:  release,
+				Step:     step,
+				Approver: "alice",
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		targetStep int32
+		approvals  []*shipper.ReleaseApproval
+		expected   int32
+	}{
+		{"first step needs no approval", 0, nil, 0},
+		{"unapproved step", 2, nil, 0},
+		{"approved step", 2, []*shipper.ReleaseApproval{approval("test-release", 1), approval("test-release", 2)}, 2},
+		{"holds at furthest approved step", 2, []*shipper.ReleaseApproval{approval("test-release", 1)}, 1},
+		{"going back to an earlier step", 1, []*shipper.ReleaseApproval{approval("test-release", 2)}, 1},
+		{"approvals for other releases", 2, []*shipper.ReleaseApproval{approval("other-release", 2)}, 0},
+	}
+
+	for _, tt := range tests {
+		rel := &shipper.Release{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-release"},
+			Spec:       shipper.ReleaseSpec{TargetStep: tt.targetStep},
+		}
+
+		if got := ApprovedTargetStep(rel, tt.approvals); got != tt.expected {
+			t.Errorf("%s: expected step %d, got %d", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -109,6 +109,11 @@ func ReleaseComplete(release *shipper.Release) bool {
 	return releasedCond != nil && releasedCond.Status == corev1.ConditionTrue
 }
 
+func ReleaseAnalysisFailed(release *shipper.Release) bool {
+	analysisFailedCond := GetReleaseCondition(release.Status, shipper.ReleaseConditionTypeAnalysisFailed)
+	return analysisFailedCond != nil && analysisFailedCond.Status == corev1.ConditionTrue
+}
+
 func ReleaseProgressing(release *shipper.Release) bool {
 	return !(ReleaseComplete(release))
 }
@@ -0,0 +1,50 @@
+package release
+
+import (
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+)
+
+// StepValuesForCluster returns the capacity and traffic values a strategy
+// step defines for the given cluster. The first cluster override listing
+// the cluster wins over the values of the step itself.
+func StepValuesForCluster(step shipper.RolloutStrategyStep, cluster string) (shipper.RolloutStrategyStepValue, shipper.RolloutStrategyStepValue) {
+	for _, override := range step.ClusterOverrides {
+		for _, name := range override.Clusters {
+			if name == cluster {
+				return override.Capacity, override.Traffic
+			}
+		}
+	}
+
+	return step.Capacity, step.Traffic
+}
+
+// ParseStepValue interprets a capacity or traffic value of a strategy step.
+// Integers and strings ending in "%" are percentages, any other string is an
+// absolute number of pods. It returns the number and whether it is a
+// percentage.
+func ParseStepValue(v intstr.IntOrString) (int32, bool, error) {
+	if v.Type == intstr.Int {
+		if v.IntVal < 0 {
+			return 0, false, shippererrors.NewInvalidStrategyError(
+				"step value %d is negative", v.IntVal)
+		}
+		return v.IntVal, true, nil
+	}
+
+	s := v.StrVal
+	isPercent := strings.HasSuffix(s, "%")
+	n, err := strconv.ParseUint(strings.TrimSuffix(s, "%"), 10, 31)
+	if err != nil {
+		return 0, false, shippererrors.NewInvalidStrategyError(
+			"step value %q is neither a number of pods nor a percentage", s)
+	}
+
+	return int32(n), isPercent, nil
+}
@@ -0,0 +1,77 @@
+package release
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+func TestStepValuesForCluster(t *testing.T) {
+	step := shipper.RolloutStrategyStep{
+		Name:     "eu canary",
+		Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(0)},
+		Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(0)},
+		ClusterOverrides: []shipper.RolloutStrategyClusterOverride{
+			{
+				Clusters: []string{"kube-eu-1"},
+				Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(90), Contender: intstr.FromInt(10)},
+				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(95), Contender: intstr.FromInt(5)},
+			},
+			{
+				Clusters: []string{"kube-eu-1", "kube-eu-2"},
+				Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(50), Contender: intstr.FromInt(50)},
+				Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(50), Contender: intstr.FromInt(50)},
+			},
+		},
+	}
+
+	tests := []struct {
+		cluster  string
+		capacity shipper.RolloutStrategyStepValue
+		traffic  shipper.RolloutStrategyStepValue
+	}{
+		{"kube-eu-1", step.ClusterOverrides[0].Capacity, step.ClusterOverrides[0].Traffic},
+		{"kube-eu-2", step.ClusterOverrides[1].Capacity, step.ClusterOverrides[1].Traffic},
+		{"kube-us-1", step.Capacity, step.Traffic},
+	}
+
+	for _, tt := range tests {
+		capacity, traffic := StepValuesForCluster(step, tt.cluster)
+		if capacity != tt.capacity || traffic != tt.traffic {
+			t.Errorf("unexpected values for cluster %q: got capacity %v and traffic %v, want capacity %v and traffic %v",
+				tt.cluster, capacity, traffic, tt.capacity, tt.traffic)
+		}
+	}
+}
+
+func TestParseStepValue(t *testing.T) {
+	tests := []struct {
+		value     intstr.IntOrString
+		expected  int32
+		isPercent bool
+		isValid   bool
+	}{
+		{intstr.FromInt(50), 50, true, true},
+		{intstr.FromString("5%"), 5, true, true},
+		{intstr.FromString("1"), 1, false, true},
+		{intstr.FromString("0"), 0, false, true},
+		{intstr.FromInt(-1), 0, false, false},
+		{intstr.FromString("-1"), 0, false, false},
+		{intstr.FromString("one"), 0, false, false},
+		{intstr.FromString("%"), 0, false, false},
+	}
+
+	for _, tt := range tests {
+		value, isPercent, err := ParseStepValue(tt.value)
+		if tt.isValid != (err == nil) {
+			t.Errorf("unexpected error for %q: %v", tt.value.String(), err)
+			continue
+		}
+		if value != tt.expected || isPercent != tt.isPercent {
+			t.Errorf("unexpected result for %q: got %d (percent: %t), want %d (percent: %t)",
+				tt.value.String(), value, isPercent, tt.expected, tt.isPercent)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package release
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/util/conditions"
+)
+
+// TimelineMaxEntries caps the number of entries kept in a release timeline,
+// so a release that keeps going back and forth between steps doesn't grow
+// without bound. The oldest entries are dropped first.
+const TimelineMaxEntries = 32
+
+var TimelineShouldDiscardTimestamps = false
+
+func timelineNow() metav1.Time {
+	if TimelineShouldDiscardTimestamps {
+		return metav1.Time{}
+	}
+	return metav1.Now()
+}
+
+// UpdateTimeline records the progress of a release on the given strategy
+// step in its status timeline. A new entry is appended whenever the release
+// targets a different step than the one in its latest entry. Each phase
+// timestamp is only set the first time the phase is observed as reached, so
+// calling this repeatedly is safe.
+func UpdateTimeline(
+	status *shipper.ReleaseStatus,
+	step int32,
+	name string,
+	sc conditions.StrategyConditionsMap,
+	complete bool,
+) {
+	n := len(status.Timeline)
+	if n == 0 || status.Timeline[n-1].Step != step {
+		status.Timeline = append(status.Timeline, shipper.ReleaseStepTimeline{
+			Step:       step,
+			Name:       name,
+			TargetedAt: timelineNow(),
+		})
+		if len(status.Timeline) > TimelineMaxEntries {
+			status.Timeline = status.Timeline[len(status.Timeline)-TimelineMaxEntries:]
+		}
+		n = len(status.Timeline)
+	}
+
+	entry := &status.Timeline[n-1]
+
+	installed := sc.IsTrue(step, shipper.StrategyConditionContenderAchievedInstallation)
+	markTimeline(&entry.InstallationAchievedAt, installed)
+
+	capacity := phaseAchieved(sc, step,
+		shipper.StrategyConditionContenderAchievedCapacity,
+		shipper.StrategyConditionIncumbentAchievedCapacity)
+	markTimeline(&entry.CapacityAchievedAt, capacity)
+
+	traffic := phaseAchieved(sc, step,
+		shipper.StrategyConditionContenderAchievedTraffic,
+		shipper.StrategyConditionIncumbentAchievedTraffic)
+	markTimeline(&entry.TrafficAchievedAt, traffic)
+
+	markTimeline(&entry.CompletedAt, complete)
+}
+
+// phaseAchieved returns true when the contender condition is true for the
+// step, and so is the incumbent condition if there is an incumbent at all.
+func phaseAchieved(
+	sc conditions.StrategyConditionsMap,
+	step int32,
+	contenderType, incumbentType shipper.StrategyConditionType,
+) bool {
+	if !sc.IsTrue(step, contenderType) {
+		return false
+	}
+
+	if _, ok := sc.GetCondition(incumbentType); ok {
+		return sc.IsTrue(step, incumbentType)
+	}
+
+	return true
+}
+
+func markTimeline(t **metav1.Time, achieved bool) {
+	if !achieved || *t != nil {
+		return
+	}
+
+	now := timelineNow()
+	*t = &now
+}
@@ -0,0 +1,118 @@
+package release
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	"github.com/bookingcom/shipper/pkg/util/conditions"
+)
+
+func strategyConditions(step int32, trueTypes ...shipper.StrategyConditionType) conditions.StrategyConditionsMap {
+	sc := conditions.NewStrategyConditions()
+	for _, t := range trueTypes {
+		sc.Set(shipper.ReleaseStrategyCondition{
+			Type:   t,
+			Status: corev1.ConditionTrue,
+			Step:   step,
+		})
+	}
+	return sc
+}
+
+func TestUpdateTimelineRecordsPhases(t *testing.T) {
+	status := &shipper.ReleaseStatus{}
+
+	UpdateTimeline(status, 0, "staging", strategyConditions(0), false)
+	if len(status.Timeline) != 1 {
+		t.Fatalf("expected 1 timeline entry, got %d", len(status.Timeline))
+	}
+
+	entry := status.Timeline[0]
+	if entry.Step != 0 || entry.Name != "staging" || entry.TargetedAt.IsZero() {
+		t.Fatalf("unexpected timeline entry %+v", entry)
+	}
+	if entry.InstallationAchievedAt != nil || entry.CompletedAt != nil {
+		t.Fatalf("expected no phases to be reached, got %+v", entry)
+	}
+
+	sc := strategyConditions(0,
+		shipper.StrategyConditionContenderAchievedInstallation,
+		shipper.StrategyConditionContenderAchievedCapacity)
+	UpdateTimeline(status, 0, "staging", sc, false)
+
+	entry = status.Timeline[0]
+	if entry.InstallationAchievedAt == nil || entry.CapacityAchievedAt == nil {
+		t.Fatalf("expected installation and capacity to be reached, got %+v", entry)
+	}
+	if entry.TrafficAchievedAt != nil {
+		t.Fatalf("expected traffic not to be reached, got %+v", entry)
+	}
+	installedAt := *entry.InstallationAchievedAt
+
+	sc = strategyConditions(0,
+		shipper.StrategyConditionContenderAchievedInstallation,
+		shipper.StrategyConditionContenderAchievedCapacity,
+		shipper.StrategyConditionContenderAchievedTraffic)
+	UpdateTimeline(status, 0, "staging", sc, true)
+
+	entry = status.Timeline[0]
+	if entry.TrafficAchievedAt == nil || entry.CompletedAt == nil {
+		t.Fatalf("expected traffic and completion to be reached, got %+v", entry)
+	}
+	if !entry.InstallationAchievedAt.Equal(&installedAt) {
+		t.Fatalf("expected installation timestamp to be kept, got %s instead of %s",
+			entry.InstallationAchievedAt, installedAt)
+	}
+}
+
+func TestUpdateTimelineWaitsForIncumbent(t *testing.T) {
+	status := &shipper.ReleaseStatus{}
+
+	sc := strategyConditions(1, shipper.StrategyConditionContenderAchievedCapacity)
+	sc.Set(shipper.ReleaseStrategyCondition{
+		Type:   shipper.StrategyConditionIncumbentAchievedCapacity,
+		Status: corev1.ConditionFalse,
+		Step:   1,
+	})
+	UpdateTimeline(status, 1, "50/50", sc, false)
+
+	if status.Timeline[0].CapacityAchievedAt != nil {
+		t.Fatalf("expected capacity not to be reached while incumbent is not ready")
+	}
+}
+
+func TestUpdateTimelineAppendsOnStepChange(t *testing.T) {
+	status := &shipper.ReleaseStatus{}
+
+	UpdateTimeline(status, 0, "staging", strategyConditions(0), true)
+	UpdateTimeline(status, 1, "50/50", strategyConditions(1), false)
+	UpdateTimeline(status, 0, "staging", strategyConditions(0), false)
+
+	steps := make([]int32, 0, len(status.Timeline))
+	for _, entry := range status.Timeline {
+		steps = append(steps, entry.Step)
+	}
+
+	if len(steps) != 3 || steps[0] != 0 || steps[1] != 1 || steps[2] != 0 {
+		t.Fatalf("expected timeline for steps [0 1 0], got %v", steps)
+	}
+
+	if status.Timeline[2].CompletedAt != nil {
+		t.Fatalf("expected re-targeted step to start a fresh entry")
+	}
+}
+
+func TestUpdateTimelineIsCapped(t *testing.T) {
+	status := &shipper.ReleaseStatus{}
+
+	for i := 0; i < TimelineMaxEntries+5; i++ {
+		step := int32(i % 2)
+		UpdateTimeline(status, step, "step", strategyConditions(step), false)
+	}
+
+	if len(status.Timeline) != TimelineMaxEntries {
+		t.Fatalf("expected %d timeline entries, got %d", TimelineMaxEntries, len(status.Timeline))
+	}
+}
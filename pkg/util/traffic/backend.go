@@ -0,0 +1,40 @@
+package traffic
+
+import (
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+const (
+	NginxCanaryAnnotation       = "nginx.ingress.kubernetes.io/canary"
+	NginxCanaryWeightAnnotation = "nginx.ingress.kubernetes.io/canary-weight"
+
+	nginxCanarySuffix = "-canary"
+)
+
+// BackendType returns the traffic backend an application uses in a cluster.
+// The application's choice wins over the cluster's, and pod labels are used
+// if neither has one. Both app and cluster may be nil.
+func BackendType(app *shipper.Application, cluster *shipper.Cluster) shipper.TrafficBackendType {
+	if app != nil && app.Spec.TrafficBackend != "" {
+		return app.Spec.TrafficBackend
+	}
+
+	if cluster != nil && cluster.Spec.TrafficBackend != "" {
+		return cluster.Spec.TrafficBackend
+	}
+
+	return shipper.TrafficBackendPodLabels
+}
+
+// NginxCanaryIngressName returns the name of the canary Ingress that shadows
+// an Ingress of an application's chart. It is shared by all the releases of
+// the application, and points to the backing Service of the contender.
+func NginxCanaryIngressName(ingressName string) string {
+	return ingressName + nginxCanarySuffix
+}
+
+// NginxCanaryServiceName returns the name of the Service selecting only the
+// pods of a release, that a canary Ingress sends its traffic to.
+func NginxCanaryServiceName(releaseName string) string {
+	return releaseName + nginxCanarySuffix
+}
@@ -81,6 +81,19 @@ func SetClusterTrafficCondition(status *shipper.ClusterTrafficStatus, condition
 	return diff
 }
 
+// RemoveClusterTrafficCondition removes the condition of type condType from
+// status, if it has one.
+func RemoveClusterTrafficCondition(status *shipper.ClusterTrafficStatus, condType shipper.ClusterConditionType) diff.Diff {
+	currentCond := GetClusterTrafficCondition(*status, condType)
+
+	diff := NewClusterTrafficConditionDiff(currentCond, nil)
+	if !diff.IsEmpty() {
+		status.Conditions = filterOutCondition(status.Conditions, condType)
+	}
+
+	return diff
+}
+
 func GetClusterTrafficCondition(status shipper.ClusterTrafficStatus, condType shipper.ClusterConditionType) *shipper.ClusterTrafficCondition {
 	for _, c := range status.Conditions {
 		if c.Type == condType {
@@ -0,0 +1,41 @@
+package traffic
+
+import (
+	"strconv"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+const trafficDecisionSuffix = "-shipper-traffic"
+
+// TrafficDecisionConfigMapName returns the name of the ConfigMap in an
+// application cluster where the traffic controller mirrors how the pods of
+// each release of an application should be labeled. Its data maps release
+// names to a decision: "enabled" if every pod of the release gets traffic,
+// "disabled" if none does, or the number of pods that should get traffic
+// otherwise.
+func TrafficDecisionConfigMapName(appName string) string {
+	return appName + trafficDecisionSuffix
+}
+
+// PodTrafficStatusForDecision returns the traffic status label a new pod of a
+// release should get, given the traffic decision for the release and the
+// number of its pods that already have traffic enabled. It returns false if
+// the decision can't be understood.
+func PodTrafficStatusForDecision(decision string, podsEnabled int) (string, bool) {
+	switch decision {
+	case shipper.Enabled, shipper.Disabled:
+		return decision, true
+	}
+
+	podsToLabel, err := strconv.Atoi(decision)
+	if err != nil || podsToLabel < 0 {
+		return "", false
+	}
+
+	if podsEnabled < podsToLabel {
+		return shipper.Enabled, true
+	}
+
+	return shipper.Disabled, true
+}
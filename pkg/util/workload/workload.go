@@ -0,0 +1,43 @@
+package workload
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+var (
+	defaultReplicasPath      = []string{"spec", "replicas"}
+	defaultReadyReplicasPath = []string{"status", "readyReplicas"}
+)
+
+// IsMarked returns whether obj is marked as the workload of a release.
+func IsMarked(obj metav1.Object) bool {
+	return obj.GetLabels()[shipper.WorkloadLabel] == shipper.True
+}
+
+// ReplicasPath returns the fields leading to the number of replicas a
+// workload asks for.
+func ReplicasPath(obj metav1.Object) []string {
+	return pathFromAnnotation(obj, shipper.WorkloadReplicasPathAnnotation, defaultReplicasPath)
+}
+
+// ReadyReplicasPath returns the fields leading to the number of replicas of
+// a workload that are ready.
+func ReadyReplicasPath(obj metav1.Object) []string {
+	return pathFromAnnotation(obj, shipper.WorkloadReadyReplicasPathAnnotation, defaultReadyReplicasPath)
+}
+
+// pathFromAnnotation parses a path like ".status.availableReplicas" from an
+// annotation into the fields that make it up, or returns defaultPath if obj
+// doesn't have the annotation.
+func pathFromAnnotation(obj metav1.Object, annotation string, defaultPath []string) []string {
+	path, ok := obj.GetAnnotations()[annotation]
+	if !ok || path == "" {
+		return defaultPath
+	}
+
+	return strings.Split(strings.TrimPrefix(path, "."), ".")
+}
@@ -0,0 +1,174 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admission "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+const (
+	PodTrafficAgentName = "pod-traffic-webhook"
+)
+
+// PodTrafficWebhook is a mutating webhook that runs in application clusters,
+// and labels new pods of a release to receive traffic according to the last
+// decision the traffic controller mirrored into the cluster. This keeps pods
+// that get recreated while Shipper can't reach the cluster serving traffic.
+type PodTrafficWebhook struct {
+	kubeClient       kubernetes.Interface
+	configMapsLister corelisters.ConfigMapLister
+	configMapsSynced cache.InformerSynced
+
+	bindAddr string
+	bindPort string
+
+	tlsCertFile       string
+	tlsPrivateKeyFile string
+}
+
+func NewPodTrafficWebhook(
+	bindAddr, bindPort, tlsPrivateKeyFile, tlsCertFile string,
+	kubeClient kubernetes.Interface,
+	kubeInformerFactory kubeinformers.SharedInformerFactory,
+) *PodTrafficWebhook {
+	configMapInformer := kubeInformerFactory.Core().V1().ConfigMaps()
+
+	return &PodTrafficWebhook{
+		kubeClient:       kubeClient,
+		configMapsLister: configMapInformer.Lister(),
+		configMapsSynced: configMapInformer.Informer().HasSynced,
+
+		bindAddr: bindAddr,
+		bindPort: bindPort,
+
+		tlsPrivateKeyFile: tlsPrivateKeyFile,
+		tlsCertFile:       tlsCertFile,
+	}
+}
+
+func (c *PodTrafficWebhook) Run(stopCh <-chan struct{}) {
+	addr := c.bindAddr + ":" + c.bindPort
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mutate", adaptHandler(c.mutateHandlerFunc))
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	if !cache.WaitForCacheSync(stopCh, c.configMapsSynced) {
+		klog.Fatalf("failed to wait for caches to sync")
+		return
+	}
+
+	serve(server, c.tlsCertFile, c.tlsPrivateKeyFile, stopCh)
+}
+
+// mutateHandlerFunc never rejects a pod: if anything goes wrong, the pod is
+// admitted as is, and it's up to the traffic controller to label it.
+func (c *PodTrafficWebhook) mutateHandlerFunc(review *admission.AdmissionReview) *admission.AdmissionResponse {
+	request := review.Request
+	allowed := &admission.AdmissionResponse{
+		Allowed: true,
+	}
+
+	if request.Kind.Kind != "Pod" || request.Operation != admission.Create {
+		return allowed
+	}
+
+	var pod corev1.Pod
+	if err := json.Unmarshal(request.Object.Raw, &pod); err != nil {
+		klog.Errorf("could not decode Pod: %s", err)
+		return allowed
+	}
+
+	patch, err := c.buildPodTrafficPatch(request.Namespace, &pod)
+	if err != nil {
+		klog.Errorf("could not decide traffic for Pod %s/%s: %s", request.Namespace, pod.GenerateName, err)
+		return allowed
+	} else if patch == nil {
+		return allowed
+	}
+
+	patchType := admission.PatchTypeJSONPatch
+	return &admission.AdmissionResponse{
+		Allowed:   true,
+		Patch:     patch,
+		PatchType: &patchType,
+	}
+}
+
+// buildPodTrafficPatch returns a JSON patch adding the traffic status label
+// to a pod, or nil if the pod should be left alone: when it doesn't belong to
+// a release, already has a traffic status, or there's no decision for its
+// release.
+func (c *PodTrafficWebhook) buildPodTrafficPatch(namespace string, pod *corev1.Pod) ([]byte, error) {
+	appName := pod.Labels[shipper.AppLabel]
+	releaseName := pod.Labels[shipper.ReleaseLabel]
+	if appName == "" || releaseName == "" {
+		return nil, nil
+	}
+
+	if _, ok := pod.Labels[shipper.PodTrafficStatusLabel]; ok {
+		return nil, nil
+	}
+
+	cmName := trafficutil.TrafficDecisionConfigMapName(appName)
+	cm, err := c.configMapsLister.ConfigMaps(namespace).Get(cmName)
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, shippererrors.NewKubeclientGetError(namespace, cmName, err).
+			WithCoreV1Kind("ConfigMap")
+	}
+
+	decision, ok := cm.Data[releaseName]
+	if !ok {
+		return nil, nil
+	}
+
+	podsEnabled := 0
+	if decision != shipper.Enabled && decision != shipper.Disabled {
+		selector := labels.Set{
+			shipper.AppLabel:              appName,
+			shipper.ReleaseLabel:          releaseName,
+			shipper.PodTrafficStatusLabel: shipper.Enabled,
+		}.AsSelector()
+		pods, err := c.kubeClient.CoreV1().Pods(namespace).List(metav1.ListOptions{
+			LabelSelector: selector.String(),
+		})
+		if err != nil {
+			return nil, shippererrors.NewKubeclientListError(
+				corev1.SchemeGroupVersion.WithKind("Pod"),
+				namespace, selector, err)
+		}
+		podsEnabled = len(pods.Items)
+	}
+
+	status, ok := trafficutil.PodTrafficStatusForDecision(decision, podsEnabled)
+	if !ok {
+		return nil, fmt.Errorf("invalid traffic decision %q for release %q", decision, releaseName)
+	}
+
+	return json.Marshal([]map[string]interface{}{
+		{
+			"op":    "add",
+			"path":  "/metadata/labels/" + shipper.PodTrafficStatusLabel,
+			"value": status,
+		},
+	})
+}
@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	admission "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	trafficutil "github.com/bookingcom/shipper/pkg/util/traffic"
+)
+
+const (
+	podTrafficTestNamespace = "test-namespace"
+	podTrafficTestApp       = "test-app"
+	podTrafficTestRelease   = "test-app-deadbeef-0"
+)
+
+func buildPodTrafficTestPod(name string, trafficStatus string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: podTrafficTestNamespace,
+			Labels: map[string]string{
+				shipper.AppLabel:     podTrafficTestApp,
+				shipper.ReleaseLabel: podTrafficTestRelease,
+			},
+		},
+	}
+
+	if trafficStatus != "" {
+		pod.Labels[shipper.PodTrafficStatusLabel] = trafficStatus
+	}
+
+	return pod
+}
+
+func TestPodTrafficWebhook(t *testing.T) {
+	tests := []struct {
+		name        string
+		decision    string
+		podsEnabled int
+		pod         *corev1.Pod
+		expected    string
+	}{
+		{
+			name:     "release gets traffic",
+			decision: shipper.Enabled,
+			pod:      buildPodTrafficTestPod("", ""),
+			expected: shipper.Enabled,
+		},
+		{
+			name:     "release gets no traffic",
+			decision: shipper.Disabled,
+			pod:      buildPodTrafficTestPod("", ""),
+			expected: shipper.Disabled,
+		},
+		{
+			name:        "release needs more pods with traffic",
+			decision:    "2",
+			podsEnabled: 1,
+			pod:         buildPodTrafficTestPod("", ""),
+			expected:    shipper.Enabled,
+		},
+		{
+			name:        "release has enough pods with traffic",
+			decision:    "2",
+			podsEnabled: 2,
+			pod:         buildPodTrafficTestPod("", ""),
+			expected:    shipper.Disabled,
+		},
+		{
+			name:     "pod already has a traffic status",
+			decision: shipper.Enabled,
+			pod:      buildPodTrafficTestPod("", shipper.Disabled),
+			expected: "",
+		},
+		{
+			name:     "no decision for the release",
+			decision: "",
+			pod:      buildPodTrafficTestPod("", ""),
+			expected: "",
+		},
+		{
+			name:     "invalid decision",
+			decision: "some",
+			pod:      buildPodTrafficTestPod("", ""),
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.decision != "" {
+				indexer.Add(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      trafficutil.TrafficDecisionConfigMapName(podTrafficTestApp),
+						Namespace: podTrafficTestNamespace,
+					},
+					Data: map[string]string{
+						podTrafficTestRelease: tt.decision,
+					},
+				})
+			}
+
+			objects := []runtime.Object{}
+			for i := 0; i < tt.podsEnabled; i++ {
+				objects = append(objects, buildPodTrafficTestPod(fmt.Sprintf("pod-%d", i), shipper.Enabled))
+			}
+
+			c := &PodTrafficWebhook{
+				kubeClient:       kubefake.NewSimpleClientset(objects...),
+				configMapsLister: corelisters.NewConfigMapLister(indexer),
+			}
+
+			raw, err := json.Marshal(tt.pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := c.mutateHandlerFunc(&admission.AdmissionReview{
+				Request: &admission.AdmissionRequest{
+					Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+					Namespace: podTrafficTestNamespace,
+					Operation: admission.Create,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+
+			if !response.Allowed {
+				t.Fatalf("expected pod to be allowed")
+			}
+
+			if tt.expected == "" {
+				if response.Patch != nil {
+					t.Errorf("expected no patch, got %s", response.Patch)
+				}
+				return
+			}
+
+			var patch []map[string]interface{}
+			if err := json.Unmarshal(response.Patch, &patch); err != nil {
+				t.Fatalf("could not decode patch %q: %s", response.Patch, err)
+			}
+
+			if len(patch) != 1 || patch[0]["value"] != tt.expected {
+				t.Errorf("expected pod to be labeled %q, got patch %s", tt.expected, response.Patch)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package webhook
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	admission "k8s.io/api/admission/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	shippererrors "github.com/bookingcom/shipper/pkg/errors"
+	releaseutil "github.com/bookingcom/shipper/pkg/util/release"
+)
+
+// validateEnvironment performs the semantic checks on a release environment
+// that can't be expressed in the CRD's OpenAPI schema.
+func (c *Webhook) validateEnvironment(env shipper.ReleaseEnvironment) error {
+	errs := shippererrors.NewMultiError()
+
+	if env.Strategy != nil {
+		if err := validateStrategy(env.Strategy); err != nil {
+			errs.Append(err)
+		}
+	}
+
+	if err := c.validateClusterRequirements(env.ClusterRequirements); err != nil {
+		errs.Append(err)
+	}
+
+	return errs.Flatten()
+}
+
+// validateApplicationTemplate checks the environment of an Application,
+// which must have either an inline strategy or a reference to an existing
+// RolloutStrategy, but not both.
+func (c *Webhook) validateApplicationTemplate(env shipper.ReleaseEnvironment) error {
+	switch {
+	case env.Strategy == nil && env.StrategyRef == nil:
+		return shippererrors.NewInvalidStrategyError("template has neither a strategy nor a strategyRef")
+	case env.Strategy != nil && env.StrategyRef != nil:
+		return shippererrors.NewInvalidStrategyError("template has both a strategy and a strategyRef")
+	case env.StrategyRef != nil:
+		name := env.StrategyRef.Name
+		if _, err := c.rolloutStrategyLister.Get(name); err != nil {
+			if kerrors.IsNotFound(err) {
+				return shippererrors.NewInvalidStrategyError("RolloutStrategy %q does not exist", name)
+			}
+			return shippererrors.NewKubeclientGetError("", name, err).
+				WithShipperKind("RolloutStrategy")
+		}
+	}
+
+	return c.validateEnvironment(env)
+}
+
+// validateReleaseEnvironment checks the environment of a Release, which must
+// always have a strategy, as references get resolved when Releases are
+// created.
+func (c *Webhook) validateReleaseEnvironment(env shipper.ReleaseEnvironment) error {
+	if env.Strategy == nil {
+		return shippererrors.NewInvalidStrategyError("environment has no strategy")
+	}
+
+	return c.validateEnvironment(env)
+}
+
+// validateStrategy checks that a strategy can actually be executed to
+// completion: step names must be unique, capacity and traffic values must be
+// either numbers of pods or percentages, the incumbent must never get more
+// traffic than it had in the previous step, and the last step must leave the
+// contender with full capacity and traffic. Steps with cluster overrides are
+// checked for every cluster they mention as well.
+func validateStrategy(strategy *shipper.RolloutStrategySpec) error {
+	steps := strategy.Steps
+	if len(steps) == 0 {
+		return shippererrors.NewInvalidStrategyError("strategy has no steps")
+	}
+
+	errs := shippererrors.NewMultiError()
+
+	names := make(map[string]int)
+	for i, step := range steps {
+		if j, ok := names[step.Name]; ok {
+			errs.Append(shippererrors.NewInvalidStrategyError(
+				"step %d has the same name %q as step %d", i, step.Name, j))
+			continue
+		}
+		names[step.Name] = i
+	}
+
+	// The empty cluster name stands for every cluster that is not
+	// mentioned in any override.
+	clusters := []string{""}
+	seen := make(map[string]struct{})
+	for _, step := range steps {
+		for _, override := range step.ClusterOverrides {
+			for _, cluster := range override.Clusters {
+				if _, ok := seen[cluster]; ok {
+					continue
+				}
+				seen[cluster] = struct{}{}
+				clusters = append(clusters, cluster)
+			}
+		}
+	}
+
+	// Values are checked on their own first, as the comparisons below
+	// only make sense for valid ones.
+	valid := true
+	for _, cluster := range clusters {
+		for _, step := range steps {
+			capacity, traffic := releaseutil.StepValuesForCluster(step, cluster)
+			for _, v := range []intstr.IntOrString{capacity.Incumbent, capacity.Contender} {
+				if err := validateStepValue(step.Name, "capacity", v, true); err != nil {
+					errs.Append(err)
+					valid = false
+				}
+			}
+			for _, v := range []intstr.IntOrString{traffic.Incumbent, traffic.Contender} {
+				if err := validateStepValue(step.Name, "traffic", v, false); err != nil {
+					errs.Append(err)
+					valid = false
+				}
+			}
+		}
+	}
+	if !valid {
+		return errs.Flatten()
+	}
+
+	for _, cluster := range clusters {
+		var where string
+		if cluster != "" {
+			where = " in cluster " + cluster
+		}
+
+		for i := 1; i < len(steps); i++ {
+			_, prevTraffic := releaseutil.StepValuesForCluster(steps[i-1], cluster)
+			_, traffic := releaseutil.StepValuesForCluster(steps[i], cluster)
+
+			// A number of pods and a percentage can't be compared
+			// without knowing the size of the fleet.
+			prev, prevIsPercent, _ := releaseutil.ParseStepValue(prevTraffic.Incumbent)
+			curr, currIsPercent, _ := releaseutil.ParseStepValue(traffic.Incumbent)
+			if prevIsPercent == currIsPercent && curr > prev {
+				errs.Append(shippererrors.NewInvalidStrategyError(
+					"incumbent traffic%s increases from %s in step %q to %s in step %q",
+					where, prevTraffic.Incumbent.String(), steps[i-1].Name, traffic.Incumbent.String(), steps[i].Name))
+			}
+		}
+
+		last := steps[len(steps)-1]
+		capacity, traffic := releaseutil.StepValuesForCluster(last, cluster)
+		if !isFullPercentage(capacity.Contender) || !isFullPercentage(traffic.Contender) {
+			errs.Append(shippererrors.NewInvalidStrategyError(
+				"last step %q must have contender capacity and traffic of 100%%%s, got %s and %s",
+				last.Name, where, capacity.Contender.String(), traffic.Contender.String()))
+		}
+	}
+
+	return errs.Flatten()
+}
+
+// validateStepValue checks that a capacity or traffic value is either a
+// number of pods or a percentage. Capacity percentages can't exceed 100.
+func validateStepValue(stepName, kind string, v intstr.IntOrString, isCapacity bool) error {
+	value, isPercent, err := releaseutil.ParseStepValue(v)
+	if err != nil {
+		return shippererrors.NewInvalidStrategyError(
+			"%s of step %q is %s, neither a number of pods nor a percentage",
+			kind, stepName, v.String())
+	}
+
+	if isCapacity && isPercent && value > 100 {
+		return shippererrors.NewInvalidStrategyError(
+			"%s of step %q is %s, more than 100%%", kind, stepName, v.String())
+	}
+
+	return nil
+}
+
+// isFullPercentage tells whether a step value stands for 100%. A number of
+// pods never does, as the size of the fleet may change.
+func isFullPercentage(v intstr.IntOrString) bool {
+	value, isPercent, err := releaseutil.ParseStepValue(v)
+	return err == nil && isPercent && value == 100
+}
+
+// validateClusterRequirements checks that every required region has at least
+// one Cluster, and that every required capability is advertised by at least
+// one Cluster.
+func (c *Webhook) validateClusterRequirements(requirements shipper.ClusterRequirements) error {
+	clusters, err := c.clusterLister.List(labels.Everything())
+	if err != nil {
+		return shippererrors.NewKubeclientListError(
+			shipper.SchemeGroupVersion.WithKind("Cluster"),
+			"", labels.Everything(), err)
+	}
+
+	regions := make(map[string]struct{})
+	capabilities := make(map[string]struct{})
+	for _, cluster := range clusters {
+		regions[cluster.Spec.Region] = struct{}{}
+		for _, capability := range cluster.Spec.Capabilities {
+			capabilities[capability] = struct{}{}
+		}
+	}
+
+	errs := shippererrors.NewMultiError()
+
+	var missingRegions []string
+	for _, region := range requirements.Regions {
+		if _, ok := regions[region.Name]; !ok {
+			missingRegions = append(missingRegions, region.Name)
+		}
+	}
+	if len(missingRegions) > 0 {
+		sort.Strings(missingRegions)
+		errs.Append(shippererrors.NewInvalidClusterRequirementsError(
+			"no clusters in region(s) %s", strings.Join(missingRegions, ", ")))
+	}
+
+	var missingCapabilities []string
+	for _, capability := range requirements.Capabilities {
+		if _, ok := capabilities[capability]; !ok {
+			missingCapabilities = append(missingCapabilities, capability)
+		}
+	}
+	if len(missingCapabilities) > 0 {
+		sort.Strings(missingCapabilities)
+		errs.Append(shippererrors.NewInvalidClusterRequirementsError(
+			"no clusters with capability(ies) %s", strings.Join(missingCapabilities, ", ")))
+	}
+
+	return errs.Flatten()
+}
+
+// validateReleaseApproval makes sure approvals can be trusted: they can only
+// be made in the name of the user creating them, and can't be changed
+// afterwards.
+func validateReleaseApproval(request *admission.AdmissionRequest, approval shipper.ReleaseApproval) error {
+	switch request.Operation {
+	case admission.Create:
+		if approval.Spec.Approver != request.UserInfo.Username {
+			return shippererrors.NewInvalidReleaseApprovalError(
+				"approver %q does not match the requesting user %q",
+				approval.Spec.Approver, request.UserInfo.Username)
+		}
+	case admission.Update:
+		var oldApproval shipper.ReleaseApproval
+		if err := json.Unmarshal(request.OldObject.Raw, &oldApproval); err != nil {
+			return err
+		}
+
+		if !reflect.DeepEqual(approval.Spec, oldApproval.Spec) {
+			return shippererrors.NewInvalidReleaseApprovalError("spec is immutable")
+		}
+	}
+
+	return nil
+}
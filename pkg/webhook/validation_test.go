@@ -0,0 +1,398 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	admission "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/cache"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	listers "github.com/bookingcom/shipper/pkg/client/listers/shipper/v1alpha1"
+)
+
+func step(name string, capacity, traffic [2]int32) shipper.RolloutStrategyStep {
+	return shipper.RolloutStrategyStep{
+		Name:     name,
+		Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(int(capacity[0])), Contender: intstr.FromInt(int(capacity[1]))},
+		Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(int(traffic[0])), Contender: intstr.FromInt(int(traffic[1]))},
+	}
+}
+
+func stringStep(name string, capacity, traffic [2]string) shipper.RolloutStrategyStep {
+	return shipper.RolloutStrategyStep{
+		Name:     name,
+		Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromString(capacity[0]), Contender: intstr.FromString(capacity[1])},
+		Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromString(traffic[0]), Contender: intstr.FromString(traffic[1])},
+	}
+}
+
+func TestValidateStrategy(t *testing.T) {
+	overriddenStep := step("full on", [2]int32{0, 100}, [2]int32{0, 100})
+	overriddenStep.ClusterOverrides = []shipper.RolloutStrategyClusterOverride{
+		{
+			Clusters: []string{"cluster-b"},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(0)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(0)},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		steps    []shipper.RolloutStrategyStep
+		expected string
+	}{
+		{
+			"valid strategy",
+			[]shipper.RolloutStrategyStep{
+				step("staging", [2]int32{100, 1}, [2]int32{100, 0}),
+				step("50/50", [2]int32{50, 50}, [2]int32{50, 50}),
+				step("full on", [2]int32{0, 100}, [2]int32{0, 100}),
+			},
+			"",
+		},
+		{
+			"no steps",
+			nil,
+			"invalid rollout strategy: strategy has no steps",
+		},
+		{
+			"duplicate step names",
+			[]shipper.RolloutStrategyStep{
+				step("staging", [2]int32{100, 1}, [2]int32{100, 0}),
+				step("staging", [2]int32{0, 100}, [2]int32{0, 100}),
+			},
+			`invalid rollout strategy: step 1 has the same name "staging" as step 0`,
+		},
+		{
+			"last step not full on",
+			[]shipper.RolloutStrategyStep{
+				step("staging", [2]int32{100, 1}, [2]int32{100, 0}),
+				step("50/50", [2]int32{50, 50}, [2]int32{50, 50}),
+			},
+			`invalid rollout strategy: last step "50/50" must have contender capacity and traffic of 100%, got 50 and 50`,
+		},
+		{
+			"incumbent traffic increases",
+			[]shipper.RolloutStrategyStep{
+				step("staging", [2]int32{100, 1}, [2]int32{50, 50}),
+				step("oops", [2]int32{100, 1}, [2]int32{90, 10}),
+				step("full on", [2]int32{0, 100}, [2]int32{0, 100}),
+			},
+			`invalid rollout strategy: incumbent traffic increases from 50 in step "staging" to 90 in step "oops"`,
+		},
+		{
+			"cluster override on last step",
+			[]shipper.RolloutStrategyStep{
+				step("staging", [2]int32{100, 1}, [2]int32{100, 0}),
+				overriddenStep,
+			},
+			`invalid rollout strategy: last step "full on" must have contender capacity and traffic of 100% in cluster cluster-b, got 0 and 0`,
+		},
+		{
+			"pod counts and percentages",
+			[]shipper.RolloutStrategyStep{
+				stringStep("canary", [2]string{"100%", "1"}, [2]string{"100%", "1"}),
+				stringStep("half", [2]string{"50%", "50%"}, [2]string{"50%", "50%"}),
+				stringStep("full on", [2]string{"0", "100%"}, [2]string{"0", "100%"}),
+			},
+			"",
+		},
+		{
+			"invalid value",
+			[]shipper.RolloutStrategyStep{
+				stringStep("canary", [2]string{"100%", "one"}, [2]string{"100%", "0"}),
+				step("full on", [2]int32{0, 100}, [2]int32{0, 100}),
+			},
+			`invalid rollout strategy: capacity of step "canary" is one, neither a number of pods nor a percentage`,
+		},
+		{
+			"capacity percentage over 100",
+			[]shipper.RolloutStrategyStep{
+				stringStep("canary", [2]string{"150%", "1"}, [2]string{"100%", "0"}),
+				step("full on", [2]int32{0, 100}, [2]int32{0, 100}),
+			},
+			`invalid rollout strategy: capacity of step "canary" is 150%, more than 100%`,
+		},
+		{
+			"last step with a number of pods",
+			[]shipper.RolloutStrategyStep{
+				stringStep("canary", [2]string{"100%", "1"}, [2]string{"100%", "0"}),
+				stringStep("full on", [2]string{"0", "10"}, [2]string{"0", "100%"}),
+			},
+			`invalid rollout strategy: last step "full on" must have contender capacity and traffic of 100%, got 10 and 100%`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStrategy(&shipper.RolloutStrategySpec{Steps: tt.steps})
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error %q, got none", tt.expected)
+			}
+
+			if err.Error() != tt.expected {
+				t.Fatalf("expected error %q, got %q", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateClusterRequirements(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(&shipper.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-a"},
+		Spec: shipper.ClusterSpec{
+			Region:       "eu-west",
+			Capabilities: []string{"gpu"},
+		},
+	})
+
+	c := &Webhook{clusterLister: listers.NewClusterLister(indexer)}
+
+	tests := []struct {
+		name         string
+		requirements shipper.ClusterRequirements
+		expected     string
+	}{
+		{
+			"valid requirements",
+			shipper.ClusterRequirements{
+				Regions:      []shipper.RegionRequirement{{Name: "eu-west"}},
+				Capabilities: []string{"gpu"},
+			},
+			"",
+		},
+		{
+			"unknown region",
+			shipper.ClusterRequirements{
+				Regions: []shipper.RegionRequirement{{Name: "eu-west"}, {Name: "us-east"}},
+			},
+			"invalid cluster requirements: no clusters in region(s) us-east",
+		},
+		{
+			"unknown capability",
+			shipper.ClusterRequirements{
+				Regions:      []shipper.RegionRequirement{{Name: "eu-west"}},
+				Capabilities: []string{"ssd", "gpu"},
+			},
+			"invalid cluster requirements: no clusters with capability(ies) ssd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.validateClusterRequirements(tt.requirements)
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error %q, got none", tt.expected)
+			}
+
+			if err.Error() != tt.expected {
+				t.Fatalf("expected error %q, got %q", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateStrategyReferences(t *testing.T) {
+	strategy := shipper.RolloutStrategySpec{
+		Steps: []shipper.RolloutStrategyStep{
+			step("full on", [2]int32{0, 100}, [2]int32{0, 100}),
+		},
+	}
+
+	clusterIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	strategyIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	strategyIndexer.Add(&shipper.RolloutStrategy{
+		ObjectMeta: metav1.ObjectMeta{Name: "vanguard"},
+		Spec:       strategy,
+	})
+
+	c := &Webhook{
+		clusterLister:         listers.NewClusterLister(clusterIndexer),
+		rolloutStrategyLister: listers.NewRolloutStrategyLister(strategyIndexer),
+	}
+
+	tests := []struct {
+		name     string
+		release  bool
+		env      shipper.ReleaseEnvironment
+		expected string
+	}{
+		{
+			"template with a strategy",
+			false,
+			shipper.ReleaseEnvironment{Strategy: &strategy},
+			"",
+		},
+		{
+			"template with an existing strategyRef",
+			false,
+			shipper.ReleaseEnvironment{StrategyRef: &shipper.RolloutStrategyReference{Name: "vanguard"}},
+			"",
+		},
+		{
+			"template with a missing strategyRef",
+			false,
+			shipper.ReleaseEnvironment{StrategyRef: &shipper.RolloutStrategyReference{Name: "rearguard"}},
+			`invalid rollout strategy: RolloutStrategy "rearguard" does not exist`,
+		},
+		{
+			"template with neither",
+			false,
+			shipper.ReleaseEnvironment{},
+			"invalid rollout strategy: template has neither a strategy nor a strategyRef",
+		},
+		{
+			"template with both",
+			false,
+			shipper.ReleaseEnvironment{
+				Strategy:    &strategy,
+				StrategyRef: &shipper.RolloutStrategyReference{Name: "vanguard"},
+			},
+			"invalid rollout strategy: template has both a strategy and a strategyRef",
+		},
+		{
+			"release with a strategy and a strategyRef",
+			true,
+			shipper.ReleaseEnvironment{
+				Strategy:    &strategy,
+				StrategyRef: &shipper.RolloutStrategyReference{Name: "vanguard"},
+			},
+			"",
+		},
+		{
+			"release without a strategy",
+			true,
+			shipper.ReleaseEnvironment{StrategyRef: &shipper.RolloutStrategyReference{Name: "vanguard"}},
+			"invalid rollout strategy: environment has no strategy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.release {
+				err = c.validateReleaseEnvironment(tt.env)
+			} else {
+				err = c.validateApplicationTemplate(tt.env)
+			}
+
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error %q, got none", tt.expected)
+			}
+
+			if err.Error() != tt.expected {
+				t.Fatalf("expected error %q, got %q", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateReleaseApproval(t *testing.T) {
+	approval := func(approver, comment string) shipper.ReleaseApproval {
+		return shipper.ReleaseApproval{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-release-1", Namespace: "test-namespace"},
+			Spec: shipper.ReleaseApprovalSpec{
+				Release:  "test-release",
+				Step:     1,
+				Approver: approver,
+				Comment:  comment,
+			},
+		}
+	}
+
+	raw := func(a shipper.ReleaseApproval) runtime.RawExtension {
+		b, _ := json.Marshal(a)
+		return runtime.RawExtension{Raw: b}
+	}
+
+	tests := []struct {
+		name      string
+		operation admission.Operation
+		old       shipper.ReleaseApproval
+		approval  shipper.ReleaseApproval
+		expected  string
+	}{
+		{
+			"approval by the requesting user",
+			admission.Create,
+			shipper.ReleaseApproval{},
+			approval("alice", "LGTM"),
+			"",
+		},
+		{
+			"approval on behalf of someone else",
+			admission.Create,
+			shipper.ReleaseApproval{},
+			approval("bob", "LGTM"),
+			`invalid release approval: approver "bob" does not match the requesting user "alice"`,
+		},
+		{
+			"metadata update",
+			admission.Update,
+			approval("alice", "LGTM"),
+			approval("alice", "LGTM"),
+			"",
+		},
+		{
+			"spec update",
+			admission.Update,
+			approval("alice", "LGTM"),
+			approval("alice", "changed my mind"),
+			"invalid release approval: spec is immutable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &admission.AdmissionRequest{
+				Operation: tt.operation,
+				UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+				Object:    raw(tt.approval),
+				OldObject: raw(tt.old),
+			}
+
+			err := validateReleaseApproval(request, tt.approval)
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error %q, got none", tt.expected)
+			}
+
+			if err.Error() != tt.expected {
+				t.Fatalf("expected error %q, got %q", tt.expected, err)
+			}
+		})
+	}
+}
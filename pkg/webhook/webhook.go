@@ -32,6 +32,11 @@ type Webhook struct {
 	shipperClientset    clientset.Interface
 	rolloutBlocksLister listers.RolloutBlockLister
 	rolloutBlocksSynced cache.InformerSynced
+	clusterLister       listers.ClusterLister
+	clusterSynced       cache.InformerSynced
+
+	rolloutStrategyLister listers.RolloutStrategyLister
+	rolloutStrategySynced cache.InformerSynced
 
 	bindAddr string
 	bindPort string
@@ -52,11 +57,18 @@ func NewWebhook(
 	shipperInformerFactory informers.SharedInformerFactory,
 ) *Webhook {
 	rolloutBlocksInformer := shipperInformerFactory.Shipper().V1alpha1().RolloutBlocks()
+	clusterInformer := shipperInformerFactory.Shipper().V1alpha1().Clusters()
+	rolloutStrategyInformer := shipperInformerFactory.Shipper().V1alpha1().RolloutStrategies()
 
 	return &Webhook{
 		shipperClientset:    shipperClientset,
 		rolloutBlocksLister: rolloutBlocksInformer.Lister(),
 		rolloutBlocksSynced: rolloutBlocksInformer.Informer().HasSynced,
+		clusterLister:       clusterInformer.Lister(),
+		clusterSynced:       clusterInformer.Informer().HasSynced,
+
+		rolloutStrategyLister: rolloutStrategyInformer.Lister(),
+		rolloutStrategySynced: rolloutStrategyInformer.Informer().HasSynced,
 
 		bindAddr: bindAddr,
 		bindPort: bindPort,
@@ -74,17 +86,23 @@ func (c *Webhook) Run(stopCh <-chan struct{}) {
 		Handler: mux,
 	}
 
-	if !cache.WaitForCacheSync(stopCh, c.rolloutBlocksSynced) {
+	if !cache.WaitForCacheSync(stopCh, c.rolloutBlocksSynced, c.clusterSynced, c.rolloutStrategySynced) {
 		klog.Fatalf("failed to wait for caches to sync")
 		return
 	}
 
+	serve(server, c.tlsCertFile, c.tlsPrivateKeyFile, stopCh)
+}
+
+// serve runs server until stopCh is closed, over TLS if both a certificate
+// and a private key are given.
+func serve(server *http.Server, tlsCertFile, tlsPrivateKeyFile string, stopCh <-chan struct{}) {
 	go func() {
 		var serverError error
-		if c.tlsCertFile == "" || c.tlsPrivateKeyFile == "" {
+		if tlsCertFile == "" || tlsPrivateKeyFile == "" {
 			serverError = server.ListenAndServe()
 		} else {
-			serverError = server.ListenAndServeTLS(c.tlsCertFile, c.tlsPrivateKeyFile)
+			serverError = server.ListenAndServeTLS(tlsCertFile, tlsPrivateKeyFile)
 		}
 
 		if serverError != nil && serverError != http.ErrServerClosed {
@@ -200,6 +218,18 @@ func (c *Webhook) validateHandlerFunc(review *admission.AdmissionReview) *admiss
 	case "RolloutBlock":
 		var rolloutBlock shipper.RolloutBlock
 		err = json.Unmarshal(request.Object.Raw, &rolloutBlock)
+	case "RolloutStrategy":
+		var rolloutStrategy shipper.RolloutStrategy
+		err = json.Unmarshal(request.Object.Raw, &rolloutStrategy)
+		if err == nil {
+			err = validateStrategy(&rolloutStrategy.Spec)
+		}
+	case "ReleaseApproval":
+		var approval shipper.ReleaseApproval
+		err = json.Unmarshal(request.Object.Raw, &approval)
+		if err == nil {
+			err = validateReleaseApproval(request, approval)
+		}
 	}
 
 	if err != nil {
@@ -227,6 +257,9 @@ func (c *Webhook) validateRelease(request *admission.AdmissionRequest, release s
 	switch request.Operation {
 	case kubeclient.Create:
 		err = rolloutblock.ValidateBlocks(existingBlocks, overrides)
+		if err == nil {
+			err = c.validateReleaseEnvironment(release.Spec.Environment)
+		}
 	case kubeclient.Update:
 		var oldRelease shipper.Release
 		err = json.Unmarshal(request.OldObject.Raw, &oldRelease)
@@ -237,6 +270,13 @@ func (c *Webhook) validateRelease(request *admission.AdmissionRequest, release s
 		if !reflect.DeepEqual(release.Spec, oldRelease.Spec) {
 			err = rolloutblock.ValidateBlocks(existingBlocks, overrides)
 		}
+
+		// Only validate the environment when it changes, so objects
+		// that became invalid after the fact (e.g. because a Cluster
+		// was removed) can still have their status updated.
+		if err == nil && !reflect.DeepEqual(release.Spec.Environment, oldRelease.Spec.Environment) {
+			err = c.validateReleaseEnvironment(release.Spec.Environment)
+		}
 	}
 
 	return err
@@ -254,6 +294,9 @@ func (c *Webhook) validateApplication(request *admission.AdmissionRequest, appli
 	switch request.Operation {
 	case kubeclient.Create:
 		err = rolloutblock.ValidateBlocks(existingBlocks, overrides)
+		if err == nil {
+			err = c.validateApplicationTemplate(application.Spec.Template)
+		}
 	case kubeclient.Update:
 		var oldApp shipper.Application
 		err = json.Unmarshal(request.OldObject.Raw, &oldApp)
@@ -264,6 +307,10 @@ func (c *Webhook) validateApplication(request *admission.AdmissionRequest, appli
 		if !reflect.DeepEqual(application.Spec, oldApp.Spec) {
 			err = rolloutblock.ValidateBlocks(existingBlocks, overrides)
 		}
+
+		if err == nil && !reflect.DeepEqual(application.Spec.Template, oldApp.Spec.Template) {
+			err = c.validateApplicationTemplate(application.Spec.Template)
+		}
 	}
 
 	return err
@@ -9,6 +9,7 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 
@@ -41,32 +42,32 @@ var (
 	globalTimeout time.Duration
 )
 
-var allIn = shipper.RolloutStrategy{
+var allIn = shipper.RolloutStrategySpec{
 	Steps: []shipper.RolloutStrategyStep{
 		{
 			Name:     "full on",
-			Capacity: shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
-			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
 		},
 	},
 }
 
-var vanguard = shipper.RolloutStrategy{
+var vanguard = shipper.RolloutStrategySpec{
 	Steps: []shipper.RolloutStrategyStep{
 		{
 			Name:     "staging",
-			Capacity: shipper.RolloutStrategyStepValue{Incumbent: 100, Contender: 1},
-			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 100, Contender: 0},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(1)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(100), Contender: intstr.FromInt(0)},
 		},
 		{
 			Name:     "50/50",
-			Capacity: shipper.RolloutStrategyStepValue{Incumbent: 50, Contender: 50},
-			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 50, Contender: 50},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(50), Contender: intstr.FromInt(50)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(50), Contender: intstr.FromInt(50)},
 		},
 		{
 			Name:     "full on",
-			Capacity: shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
-			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: 0, Contender: 100},
+			Capacity: shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
+			Traffic:  shipper.RolloutStrategyStepValue{Incumbent: intstr.FromInt(0), Contender: intstr.FromInt(100)},
 		},
 	},
 }
@@ -549,7 +550,7 @@ func testNewApplicationVanguard(targetReplicas int, t *testing.T) {
 			f.waitForReleaseStrategyState("command", relName, i)
 		}
 
-		expectedCapacity := int(replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender), float64(targetReplicas)))
+		expectedCapacity := int(replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender.IntValue()), float64(targetReplicas)))
 		t.Logf("checking that release %q has %d pods (strategy step %d aka %q)", relName, expectedCapacity, i, step.Name)
 		f.checkReadyPods(relName, expectedCapacity)
 	}
@@ -605,7 +606,7 @@ func testNewApplicationVanguardWithRolloutBlockOverride(targetReplicas int, t *t
 			f.waitForReleaseStrategyState("command", relName, i)
 		}
 
-		expectedCapacity := int(replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender), float64(targetReplicas)))
+		expectedCapacity := int(replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender.IntValue()), float64(targetReplicas)))
 		t.Logf("checking that release %q has %d pods (strategy step %d aka %q)", relName, expectedCapacity, i, step.Name)
 		f.checkReadyPods(relName, expectedCapacity)
 	}
@@ -699,12 +700,12 @@ func testRolloutVanguard(targetReplicas int, t *testing.T) {
 			f.waitForReleaseStrategyState("command", contenderName, i)
 		}
 
-		expectedContenderCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender), float64(targetReplicas))
-		expectedIncumbentCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Incumbent), float64(targetReplicas))
+		expectedContenderCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender.IntValue()), float64(targetReplicas))
+		expectedIncumbentCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Incumbent.IntValue()), float64(targetReplicas))
 
 		t.Logf(
-			"checking that incumbent %q has %d pods and contender %q has %d pods (strategy step %d -- %d/%d)",
-			incumbentName, expectedIncumbentCapacity, contenderName, expectedContenderCapacity, i, step.Capacity.Incumbent, step.Capacity.Contender,
+			"checking that incumbent %q has %d pods and contender %q has %d pods (strategy step %d -- %s/%s)",
+			incumbentName, expectedIncumbentCapacity, contenderName, expectedContenderCapacity, i, step.Capacity.Incumbent.String(), step.Capacity.Contender.String(),
 		)
 
 		f.checkReadyPods(contenderName, int(expectedContenderCapacity))
@@ -761,7 +762,7 @@ func TestNewApplicationMovingStrategyBackwards(t *testing.T) {
 		t.Logf("waiting for release %q to achieve waitingForCommand for targetStep %d", relName, i)
 		f.waitForReleaseStrategyState("command", relName, i)
 
-		expectedCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender), float64(targetReplicas))
+		expectedCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender.IntValue()), float64(targetReplicas))
 		t.Logf("checking that release %q has %d pods (strategy step %d aka %q)", relName, expectedCapacity, i, step.Name)
 		f.checkReadyPods(relName, int(expectedCapacity))
 	}
@@ -812,7 +813,7 @@ func TestNewApplicationBlockStrategyBackwards(t *testing.T) {
 		t.Logf("waiting for release %q to achieve waitingForCommand for targetStep %d", relName, i)
 		f.waitForReleaseStrategyState("command", relName, i)
 
-		expectedCapacity = replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender), float64(targetReplicas))
+		expectedCapacity = replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender.IntValue()), float64(targetReplicas))
 		t.Logf("checking that release %q has %d pods (strategy step %d aka %q)", relName, expectedCapacity, i, step.Name)
 		f.checkReadyPods(relName, int(expectedCapacity))
 	}
@@ -900,12 +901,12 @@ func TestRolloutMovingStrategyBackwards(t *testing.T) {
 		t.Logf("waiting for release %q to achieve waitingForCommand for targetStep %d", contenderName, i)
 		f.waitForReleaseStrategyState("command", contenderName, i)
 
-		expectedContenderCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender), float64(targetReplicas))
-		expectedIncumbentCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Incumbent), float64(targetReplicas))
+		expectedContenderCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender.IntValue()), float64(targetReplicas))
+		expectedIncumbentCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Incumbent.IntValue()), float64(targetReplicas))
 
 		t.Logf(
-			"checking that incumbent %q has %d pods and contender %q has %d pods (strategy step %d -- %d/%d)",
-			incumbentName, expectedIncumbentCapacity, contenderName, expectedContenderCapacity, i, step.Capacity.Incumbent, step.Capacity.Contender,
+			"checking that incumbent %q has %d pods and contender %q has %d pods (strategy step %d -- %s/%s)",
+			incumbentName, expectedIncumbentCapacity, contenderName, expectedContenderCapacity, i, step.Capacity.Incumbent.String(), step.Capacity.Contender.String(),
 		)
 
 		f.checkReadyPods(contenderName, int(expectedContenderCapacity))
@@ -975,12 +976,12 @@ func TestRolloutBlockMovingStrategyBackwards(t *testing.T) {
 	t.Logf("waiting for release %q to achieve waitingForCommand for targetStep %d", contenderName, i)
 	f.waitForReleaseStrategyState("command", contenderName, i)
 
-	expectedContenderCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender), float64(targetReplicas))
-	expectedIncumbentCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Incumbent), float64(targetReplicas))
+	expectedContenderCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender.IntValue()), float64(targetReplicas))
+	expectedIncumbentCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Incumbent.IntValue()), float64(targetReplicas))
 
 	t.Logf(
-		"checking that incumbent %q has %d pods and contender %q has %d pods (strategy step %d -- %d/%d)",
-		incumbentName, expectedIncumbentCapacity, contenderName, expectedContenderCapacity, i, step.Capacity.Incumbent, step.Capacity.Contender,
+		"checking that incumbent %q has %d pods and contender %q has %d pods (strategy step %d -- %s/%s)",
+		incumbentName, expectedIncumbentCapacity, contenderName, expectedContenderCapacity, i, step.Capacity.Incumbent.String(), step.Capacity.Contender.String(),
 	)
 
 	f.checkReadyPods(contenderName, int(expectedContenderCapacity))
@@ -1006,8 +1007,8 @@ func TestRolloutBlockMovingStrategyBackwards(t *testing.T) {
 	f.waitForReleaseStrategyState("command", contenderName, 0)
 
 	t.Logf(
-		"checking that incumbent %q has %d pods and contender %q has %d pods (strategy step %d -- %d/%d)",
-		incumbentName, expectedIncumbentCapacity, contenderName, expectedContenderCapacity, i, step.Capacity.Incumbent, step.Capacity.Contender,
+		"checking that incumbent %q has %d pods and contender %q has %d pods (strategy step %d -- %s/%s)",
+		incumbentName, expectedIncumbentCapacity, contenderName, expectedContenderCapacity, i, step.Capacity.Incumbent.String(), step.Capacity.Contender.String(),
 	)
 
 	f.checkReadyPods(contenderName, int(expectedContenderCapacity))
@@ -1062,7 +1063,7 @@ func TestNewApplicationAbort(t *testing.T) {
 	t.Logf("waiting for release %q to achieve waitingForCommand for targetStep %d", relName, targetStep)
 	f.waitForReleaseStrategyState("command", relName, targetStep)
 
-	expectedCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender), float64(targetReplicas))
+	expectedCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender.IntValue()), float64(targetReplicas))
 	t.Logf("checking that release %q has %d pods (strategy step %d aka %q)", relName, expectedCapacity, targetStep, step.Name)
 	f.checkReadyPods(relName, int(expectedCapacity))
 
@@ -1077,7 +1078,7 @@ func TestNewApplicationAbort(t *testing.T) {
 	f.waitForReleaseStrategyState("command", relName, 0)
 
 	// It's back to step 0, let's check the number of pods
-	expectedCapacity = replicas.CalculateDesiredReplicaCount(uint(vanguard.Steps[0].Capacity.Contender), float64(targetReplicas))
+	expectedCapacity = replicas.CalculateDesiredReplicaCount(uint(vanguard.Steps[0].Capacity.Contender.IntValue()), float64(targetReplicas))
 	f.checkReadyPods(relName, int(expectedCapacity))
 }
 
@@ -1141,12 +1142,12 @@ func TestRolloutAbort(t *testing.T) {
 	t.Logf("waiting for contender release %q to achieve waitingForCommand for targetStep %d", contenderName, targetStep)
 	f.waitForReleaseStrategyState("command", contenderName, targetStep)
 
-	expectedContenderCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender), float64(targetReplicas))
-	expectedIncumbentCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Incumbent), float64(targetReplicas))
+	expectedContenderCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Contender.IntValue()), float64(targetReplicas))
+	expectedIncumbentCapacity := replicas.CalculateDesiredReplicaCount(uint(step.Capacity.Incumbent.IntValue()), float64(targetReplicas))
 
 	t.Logf(
-		"checking that incumbent %q has %d pods and contender %q has %d pods (strategy step %d -- %d/%d)",
-		incumbentName, expectedIncumbentCapacity, contenderName, expectedContenderCapacity, targetStep, step.Capacity.Incumbent, step.Capacity.Contender,
+		"checking that incumbent %q has %d pods and contender %q has %d pods (strategy step %d -- %s/%s)",
+		incumbentName, expectedIncumbentCapacity, contenderName, expectedContenderCapacity, targetStep, step.Capacity.Incumbent.String(), step.Capacity.Contender.String(),
 	)
 
 	f.checkReadyPods(contenderName, int(expectedContenderCapacity))
@@ -1170,7 +1171,7 @@ func TestRolloutAbort(t *testing.T) {
 
 	// By this moment shipper is expected to have recovered the missing capacity
 	// and get all pods up and running
-	expectedCapacity := replicas.CalculateDesiredReplicaCount(uint(allIn.Steps[0].Capacity.Contender), float64(targetReplicas))
+	expectedCapacity := replicas.CalculateDesiredReplicaCount(uint(allIn.Steps[0].Capacity.Contender.IntValue()), float64(targetReplicas))
 	f.checkReadyPods(incumbentName, int(expectedCapacity))
 }
 
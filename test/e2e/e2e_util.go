@@ -440,7 +440,7 @@ func buildApplicationClient(cluster *shipper.Cluster) kubernetes.Interface {
 	return client
 }
 
-func newApplication(namespace, name string, strategy *shipper.RolloutStrategy) *shipper.Application {
+func newApplication(namespace, name string, strategy *shipper.RolloutStrategySpec) *shipper.Application {
 	return &shipper.Application{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        name,